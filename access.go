@@ -0,0 +1,199 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// clfLayout is the layout of the times in the access logs.
+const clfLayout = "02/Jan/2006:15:04:05 -0700"
+
+// AccessEntry describes an HTTP request and its response, as written in an access log.
+type AccessEntry struct {
+	ClientIP  string
+	User      string // the authenticated user, if any
+	Method    string
+	URI       string // the request URI, i.e. the path and the query
+	Proto     string
+	Status    int
+	Size      int64 // the number of bytes of the response body
+	Referer   string
+	UserAgent string
+	Latency   time.Duration
+}
+
+// NewAccessEntry returns an AccessEntry describing a request. The status, the size and the latency
+// are to be set once the response is sent.
+func NewAccessEntry(r *http.Request) *AccessEntry {
+	a := &AccessEntry{
+		ClientIP:  r.RemoteAddr,
+		Method:    r.Method,
+		URI:       r.RequestURI,
+		Proto:     r.Proto,
+		Referer:   r.Referer(),
+		UserAgent: r.UserAgent(),
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		a.ClientIP = host
+	}
+	if a.URI == "" && r.URL != nil {
+		a.URI = r.URL.RequestURI()
+	}
+	if user, _, ok := r.BasicAuth(); ok {
+		a.User = user
+	} else if r.URL != nil && r.URL.User != nil {
+		a.User = r.URL.User.Username()
+	}
+	return a
+}
+
+// Fields returns the fields describing the request, which AccessLogFormatter writes: client_ip, user,
+// method, uri, proto, status, size, referer, user_agent and latency. The empty user and the zero latency
+// are left out.
+func (a *AccessEntry) Fields() Fields {
+	fields := Fields{
+		"client_ip":  a.ClientIP,
+		"method":     a.Method,
+		"uri":        a.URI,
+		"proto":      a.Proto,
+		"status":     a.Status,
+		"size":       a.Size,
+		"referer":    a.Referer,
+		"user_agent": a.UserAgent,
+	}
+	if a.User != "" {
+		fields["user"] = a.User
+	}
+	if a.Latency > 0 {
+		fields["latency"] = a.Latency.String()
+	}
+	return fields
+}
+
+// Access logs a request with its fields, as a warning if it was answered with a 4xx status,
+// as an error with a 5xx status, and else as an information message.
+func (l *Logger) Access(a *AccessEntry) {
+	level := LevelInfo
+	if a.Status >= http.StatusInternalServerError {
+		level = LevelError
+	} else if a.Status >= http.StatusBadRequest {
+		level = LevelWarn
+	}
+	l.WithFields(a.Fields()).Log(level, a.Method+" "+a.URI)
+}
+
+// AccessLogFormatter formats the messages logged by Logger.Access and by the HTTP middlewares in the
+// combined log format of Apache and NCSA, so that tools such as goaccess and awstats can parse the access logs:
+//
+//	10.0.0.1 - alice [22/Oct/2015:08:39:28 -0400] "GET /users/1 HTTP/1.1" 200 512 "-" "curl/7.43.0"
+//
+// The time is the time of the message, in the location set for the logger. The request is taken from
+// the fields of the message, the URI from the path field if there is no uri field, and the missing
+// values are written as "-".
+func AccessLogFormatter(l *Logger, e *Entry) string {
+	return formatWith(AppendAccessLog, l, e)
+}
+
+// AppendAccessLog appends a message formatted like AccessLogFormatter does.
+func AppendAccessLog(buf []byte, l *Logger, e *Entry) []byte {
+	buf = appendAccessField(buf, e, "client_ip")
+	buf = append(buf, " - "...)
+	buf = appendAccessField(buf, e, "user")
+	buf = append(buf, " ["...)
+	t := e.Time
+	if l != nil && l.coreLogger != nil {
+		if f, ok := l.timeFormat.Load().(*timeFormat); ok && f.location != nil {
+			t = t.In(f.location)
+		}
+	}
+	buf = t.AppendFormat(buf, clfLayout)
+	buf = append(buf, `] "`...)
+	if _, ok := e.Fields["method"]; ok {
+		buf = appendCLFString(buf, string(appendValue(nil, e.Fields["method"])))
+		buf = append(buf, ' ')
+		if uri, ok := e.Fields["uri"]; ok {
+			buf = appendCLFString(buf, string(appendValue(nil, uri)))
+		} else {
+			buf = appendCLFString(buf, string(appendValue(nil, e.Fields["path"])))
+		}
+		if proto, ok := e.Fields["proto"]; ok {
+			buf = append(buf, ' ')
+			buf = appendCLFString(buf, string(appendValue(nil, proto)))
+		}
+	} else {
+		buf = append(buf, '-')
+	}
+	buf = append(buf, `" `...)
+	buf = appendAccessField(buf, e, "status")
+	buf = append(buf, ' ')
+	if size, ok := e.Fields["size"]; ok && string(appendValue(nil, size)) != "0" {
+		buf = appendValue(buf, size)
+	} else {
+		buf = append(buf, '-')
+	}
+	buf = append(buf, ` "`...)
+	buf = appendQuotedAccessField(buf, e, "referer")
+	buf = append(buf, `" "`...)
+	buf = appendQuotedAccessField(buf, e, "user_agent")
+	return append(buf, '"')
+}
+
+// appendAccessField appends the value of a field, or "-" if it is missing or empty, with the spaces escaped.
+func appendAccessField(buf []byte, e *Entry, key string) []byte {
+	value := ""
+	if v, ok := e.Fields[key]; ok {
+		value = string(appendValue(nil, v))
+	}
+	if value == "" {
+		return append(buf, '-')
+	}
+	for i := 0; i < len(value); i++ {
+		if value[i] == ' ' {
+			buf = append(buf, `\x20`...)
+		} else {
+			buf = appendCLFByte(buf, value[i])
+		}
+	}
+	return buf
+}
+
+// appendQuotedAccessField appends the value of a field to be quoted, or "-" if it is missing or empty.
+func appendQuotedAccessField(buf []byte, e *Entry, key string) []byte {
+	value := ""
+	if v, ok := e.Fields[key]; ok {
+		value = string(appendValue(nil, v))
+	}
+	if value == "" {
+		return append(buf, '-')
+	}
+	return appendCLFString(buf, value)
+}
+
+// appendCLFString appends a string escaped like Apache does, so that it can be quoted.
+func appendCLFString(buf []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		buf = appendCLFByte(buf, s[i])
+	}
+	return buf
+}
+
+// appendCLFByte appends a byte, escaping the quotes, the backslashes and the control characters.
+func appendCLFByte(buf []byte, c byte) []byte {
+	switch {
+	case c == '"' || c == '\\':
+		return append(buf, '\\', c)
+	case c < 0x20 || c == 0x7f:
+		buf = append(buf, `\x`...)
+		if c < 0x10 {
+			buf = append(buf, '0')
+		}
+		return strconv.AppendUint(buf, uint64(c), 16)
+	}
+	return append(buf, c)
+}
@@ -0,0 +1,56 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+func TestAccessLogFormatter(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	logger.SetClock(log.NewManualClock(time.Date(2015, 10, 22, 8, 39, 28, 0, time.FixedZone("", -4*3600)), time.Second))
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	logger.SetFormatter(log.AccessLogFormatter)
+
+	req := httptest.NewRequest("GET", "/users/1?q=a", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.SetBasicAuth("alice", "secret")
+	req.Header.Set("User-Agent", `curl "7"`)
+	a := log.NewAccessEntry(req)
+	a.Status, a.Size = 200, 512
+	logger.Access(a)
+
+	req = httptest.NewRequest("POST", "/login", nil)
+	a = log.NewAccessEntry(req)
+	a.Status = 403
+	logger.Access(a)
+	logger.Info("not a request")
+	logger.Close()
+
+	if len(target.entries) != 3 {
+		t.Fatalf("len(target.entries) = %v, expected %v", len(target.entries), 3)
+	}
+	expected := `10.0.0.1 - alice [22/Oct/2015:08:39:28 -0400] "GET /users/1?q=a HTTP/1.1" 200 512 "-" "curl \"7\""`
+	if result := target.entries[0].String(); result != expected {
+		t.Errorf("entries[0] = %q, expected %q", result, expected)
+	}
+	if target.entries[0].Level != log.LevelInfo || target.entries[1].Level != log.LevelWarn {
+		t.Errorf("levels = %v %v, expected Info Warn", target.entries[0].Level, target.entries[1].Level)
+	}
+	expected = `192.0.2.1 - - [22/Oct/2015:08:39:29 -0400] "POST /login HTTP/1.1" 403 - "-" "-"`
+	if result := target.entries[1].String(); result != expected {
+		t.Errorf("entries[1] = %q, expected %q", result, expected)
+	}
+	expected = `- - - [22/Oct/2015:08:39:30 -0400] "-" - - "-" "-"`
+	if result := target.entries[2].String(); result != expected {
+		t.Errorf("entries[2] = %q, expected %q", result, expected)
+	}
+}
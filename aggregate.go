@@ -0,0 +1,228 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrorGroup describes the occurrences of an error, i.e. of the messages sharing a fingerprint.
+type ErrorGroup struct {
+	Fingerprint string    `json:"fingerprint"`
+	Category    string    `json:"category"`
+	Level       Level     `json:"level"`   // the most severe level of the messages
+	Message     string    `json:"message"` // the first message
+	Pattern     string    `json:"pattern"` // the normalized message
+	Frame       Frame     `json:"frame"`   // the top frame of the call stacks, if they are logged
+	Count       int       `json:"count"`
+	FirstSeen   time.Time `json:"firstSeen"`
+	LastSeen    time.Time `json:"lastSeen"`
+	// the number of messages since the previous summary passed to Emit.
+	Recent int `json:"recent"`
+}
+
+// the variable parts of the messages, replaced to compute their fingerprints
+var (
+	fingerprintUUID   = regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`)
+	fingerprintHex    = regexp.MustCompile(`\b0[xX][0-9a-fA-F]+\b|\b[0-9a-fA-F]{16,}\b`)
+	fingerprintNumber = regexp.MustCompile(`\d+(\.\d+)?`)
+	fingerprintQuoted = regexp.MustCompile(`"[^"]*"|'[^']*'`)
+)
+
+// NormalizeMessage returns the first line of a message with its variable parts, such as numbers, IDs and quoted
+// strings, replaced by placeholders, so that the messages of an error differing only by their values compare equal.
+func NormalizeMessage(message string) string {
+	if i := strings.IndexByte(message, '\n'); i >= 0 {
+		message = message[:i]
+	}
+	message = fingerprintQuoted.ReplaceAllString(message, "<str>")
+	message = fingerprintUUID.ReplaceAllString(message, "<uuid>")
+	message = fingerprintHex.ReplaceAllString(message, "<hex>")
+	return fingerprintNumber.ReplaceAllString(message, "<n>")
+}
+
+// ErrorAggregatorTarget groups the errors by fingerprint, i.e. by category, normalized message and top frame of
+// the call stack, and keeps how many times and when each occurred, as a lightweight in-process error tracker.
+// Groups returns the groups, ServeHTTP serves them in JSON, and Emit receives a summary every Interval:
+//
+//	errors := log.NewErrorAggregatorTarget()
+//	errors.Interval = time.Hour
+//	errors.Emit = func(groups []log.ErrorGroup) { alerts.Send(groups) }
+//	logger.AddTarget(errors)
+//	http.Handle("/debug/errors", requireAdmin(errors))
+type ErrorAggregatorTarget struct {
+	*Filter
+	// the interval of the summaries passed to Emit. Zero disables them.
+	Interval time.Duration
+	// receives the groups which occurred since the previous summary, the most frequent first.
+	// It is called from the goroutine of the target, so it should not block.
+	Emit func(groups []ErrorGroup)
+	// the maximum number of groups kept. The errors of the new groups are dropped beyond.
+	MaxGroups int
+
+	lock   sync.Mutex
+	groups map[string]*ErrorGroup
+	ticker *time.Ticker
+	close  chan bool
+	done   chan bool
+}
+
+// NewErrorAggregatorTarget creates an ErrorAggregatorTarget.
+// The new ErrorAggregatorTarget takes these default options:
+// MaxLevel: LevelError, MaxGroups: 1000
+func NewErrorAggregatorTarget() *ErrorAggregatorTarget {
+	return &ErrorAggregatorTarget{
+		Filter:    &Filter{MaxLevel: LevelError},
+		MaxGroups: 1000,
+		groups:    map[string]*ErrorGroup{},
+		close:     make(chan bool, 0),
+	}
+}
+
+// Open prepares ErrorAggregatorTarget for processing log messages and starts the summaries.
+func (t *ErrorAggregatorTarget) Open(io.Writer) error {
+	t.Filter.Init()
+	t.lock.Lock()
+	if t.groups == nil {
+		t.groups = map[string]*ErrorGroup{}
+	}
+	t.lock.Unlock()
+	t.ticker, t.done = nil, nil
+	if t.Interval > 0 && t.Emit != nil {
+		t.ticker = time.NewTicker(t.Interval)
+		t.done = make(chan bool)
+		go t.summarize(t.ticker, t.done)
+	}
+	return nil
+}
+
+func (t *ErrorAggregatorTarget) summarize(ticker *time.Ticker, done chan bool) {
+	for {
+		select {
+		case <-ticker.C:
+			t.emit()
+		case <-done:
+			return
+		}
+	}
+}
+
+// emit passes the groups which occurred since the previous summary to Emit.
+func (t *ErrorAggregatorTarget) emit() {
+	t.lock.Lock()
+	var groups []ErrorGroup
+	for _, g := range t.groups {
+		if g.Recent > 0 {
+			groups = append(groups, *g)
+			g.Recent = 0
+		}
+	}
+	t.lock.Unlock()
+	if len(groups) > 0 {
+		sortErrorGroups(groups)
+		t.Emit(groups)
+	}
+}
+
+// Process counts an error in its group.
+func (t *ErrorAggregatorTarget) Process(e *Entry) {
+	if e == nil {
+		if t.ticker != nil {
+			t.ticker.Stop()
+			close(t.done)
+			// the errors since the last summary are not lost
+			t.emit()
+		}
+		t.close <- true
+		return
+	}
+	if !t.Allow(e) {
+		return
+	}
+	pattern := NormalizeMessage(e.Message)
+	var frame Frame
+	if len(e.CallStack) > 0 {
+		frame = e.CallStack[0]
+	}
+	sum := sha1.Sum([]byte(e.Category + "\x00" + pattern + "\x00" + frame.File + "\x00" + frame.Func))
+	fingerprint := hex.EncodeToString(sum[:8])
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	g := t.groups[fingerprint]
+	if g == nil {
+		if t.MaxGroups > 0 && len(t.groups) >= t.MaxGroups {
+			return
+		}
+		g = &ErrorGroup{
+			Fingerprint: fingerprint,
+			Category:    e.Category,
+			Level:       e.Level,
+			Message:     e.Message,
+			Pattern:     pattern,
+			Frame:       frame,
+			FirstSeen:   e.Time,
+		}
+		t.groups[fingerprint] = g
+	}
+	if e.Level < g.Level {
+		g.Level = e.Level
+	}
+	g.Count++
+	g.Recent++
+	g.LastSeen = e.Time
+}
+
+// Close closes the error aggregator target. The groups are kept until Reset is called.
+func (t *ErrorAggregatorTarget) Close() {
+	<-t.close
+}
+
+// Groups returns the groups of errors, the most frequent first.
+func (t *ErrorAggregatorTarget) Groups() []ErrorGroup {
+	t.lock.Lock()
+	groups := make([]ErrorGroup, 0, len(t.groups))
+	for _, g := range t.groups {
+		groups = append(groups, *g)
+	}
+	t.lock.Unlock()
+	sortErrorGroups(groups)
+	return groups
+}
+
+// Reset forgets the groups of errors.
+func (t *ErrorAggregatorTarget) Reset() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.groups = map[string]*ErrorGroup{}
+}
+
+// ServeHTTP serves the groups of errors in JSON.
+func (t *ErrorAggregatorTarget) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(t.Groups())
+}
+
+// sortErrorGroups sorts groups by decreasing count, then by the time they were last seen.
+func sortErrorGroups(groups []ErrorGroup) {
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].LastSeen.After(groups[j].LastSeen)
+	})
+}
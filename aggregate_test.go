@@ -0,0 +1,73 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+func TestNormalizeMessage(t *testing.T) {
+	tests := map[string]string{
+		"user 42 not found":                                            "user <n> not found",
+		`cannot open "a.txt": code 0x1f`:                               "cannot open <str>: code <hex>",
+		"order 123e4567-e89b-12d3-a456-426614174000 failed\nat line 3": "order <uuid> failed",
+	}
+	for message, expected := range tests {
+		if normalized := log.NormalizeMessage(message); normalized != expected {
+			t.Errorf("NormalizeMessage(%q) = %q, expected %q", message, normalized, expected)
+		}
+	}
+}
+
+func TestErrorAggregatorTarget(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	logger.SetClock(log.NewManualClock(time.Date(2015, 10, 22, 8, 39, 0, 0, time.UTC), time.Second))
+	target := log.NewErrorAggregatorTarget()
+	target.Interval = time.Hour
+	summaries := make(chan []log.ErrorGroup, 1)
+	target.Emit = func(groups []log.ErrorGroup) { summaries <- groups }
+	logger.SetTarget(target)
+
+	for _, id := range []int{42, 43, 44} {
+		logger.Errorf("user %v not found", id)
+	}
+	logger.GetLogger("app.db").Errorf("user %v not found", 1)
+	logger.Warn("not an error")
+	logger.Close()
+
+	groups := target.Groups()
+	if len(groups) != 2 {
+		t.Fatalf("len(Groups()) = %v, expected %v", len(groups), 2)
+	}
+	g := groups[0]
+	if g.Count != 3 || g.Category != "app" || g.Message != "user 42 not found" || g.Pattern != "user <n> not found" {
+		t.Errorf("Groups()[0] = %+v, expected the 3 errors of app", g)
+	}
+	if !g.FirstSeen.Equal(time.Date(2015, 10, 22, 8, 39, 0, 0, time.UTC)) || !g.LastSeen.Equal(time.Date(2015, 10, 22, 8, 39, 2, 0, time.UTC)) {
+		t.Errorf("Groups()[0] = %v - %v, expected the times of the first and the last errors", g.FirstSeen, g.LastSeen)
+	}
+	// the summary of the errors since the previous one is emitted when the target is closed
+	if summary := <-summaries; len(summary) != 2 || summary[0].Recent != 3 {
+		t.Errorf("summary = %+v, expected the 2 groups", summary)
+	}
+
+	recorder := httptest.NewRecorder()
+	target.ServeHTTP(recorder, httptest.NewRequest("GET", "/debug/errors", nil))
+	var docs []map[string]interface{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &docs); err != nil || len(docs) != 2 || docs[0]["level"] != "Error" {
+		t.Errorf("ServeHTTP() = %v, %v, expected the groups in JSON", strings.TrimSpace(recorder.Body.String()), err)
+	}
+	target.Reset()
+	if len(target.Groups()) != 0 {
+		t.Errorf("len(Groups()) = %v after Reset, expected 0", len(target.Groups()))
+	}
+}
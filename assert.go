@@ -0,0 +1,42 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import "fmt"
+
+// Assert logs a message formatted like Logf, prefixed with "assertion failed: " and carrying the call stack,
+// if cond is false. The message is logged at AssertLevel, LevelError by default. It returns cond, so that
+// the caller can handle the broken invariant:
+//
+//	if !logger.Assert(len(items) <= max, "%v items, expected at most %v", len(items), max) {
+//		items = items[:max]
+//	}
+func (l *Logger) Assert(cond bool, format string, a ...interface{}) bool {
+	if cond {
+		return true
+	}
+	level := l.AssertLevel
+	if !l.Enabled(level) {
+		return false
+	}
+	message := format
+	if len(a) > 0 {
+		message = fmt.Sprintf(format, evalArgs(a)...)
+	}
+	message = "assertion failed: " + message
+	if level == LevelFatal {
+		l.newFatalEntry(level, message)
+		return false
+	}
+	entry := l.makeEntry(level, message)
+	stack := &l.current().stack
+	stackDepth := stack.depth
+	if stackDepth == 0 {
+		stackDepth = 20
+	}
+	entry.CallStack = callFrames(1, stackDepth, stack.keepFrame, true, l.callerSkip)
+	l.dispatch(entry)
+	return false
+}
@@ -0,0 +1,47 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestLoggerAssert(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	var fatal *log.Entry
+	logger.OnFatal(func(e *log.Entry) {
+		fatal = e
+	})
+
+	if !logger.Assert(true, "never logged") {
+		t.Errorf("Assert(true) = false, expected true")
+	}
+	if logger.Assert(1 > 2, "%v items, expected at most %v", 3, 2) {
+		t.Errorf("Assert(false) = true, expected false")
+	}
+	logger.AssertLevel = log.LevelFatal
+	logger.Assert(false, "broken")
+	logger.Close()
+
+	if len(target.entries) != 2 {
+		t.Fatalf("len(entries) = %v, expected 2", len(target.entries))
+	}
+	e := target.entries[0]
+	if e.Level != log.LevelError || e.Message != "assertion failed: 3 items, expected at most 2" {
+		t.Errorf("entry = %v %q, expected the failed assertion at Error", e.Level, e.Message)
+	}
+	if len(e.CallStack) == 0 || !strings.HasSuffix(e.CallStack[0].File, "assert_test.go") {
+		t.Errorf("CallStack = %v, expected it to start at the assertion", e.CallStack)
+	}
+	if fatal == nil || fatal.Message != "assertion failed: broken" {
+		t.Errorf("fatal entry = %+v, expected the failed assertion with AssertLevel Fatal", fatal)
+	}
+}
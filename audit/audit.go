@@ -0,0 +1,260 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package audit writes tamper-evident audit trails for compliance-sensitive applications.
+// Each record holds who did what on which resource, and is chained to the previous record by
+// including its hash, so that a modified, inserted or removed record is detected by Verify:
+//
+//	trail, err := audit.Open("audit.log", nil)
+//	...
+//	err = trail.Log("alice", "delete", "invoice/42", log.Fields{"reason": "duplicate"})
+//	...
+//	records, err := audit.VerifyFile("audit.log", nil)
+//
+// Anyone able to write the file can recompute the whole chain. Pass a key to chain the records
+// with HMAC-SHA256 instead, or publish the hash returned by LastHash to a separate system.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+// Record is an audit record. It is written as a line of JSON ending with its hash.
+type Record struct {
+	// the sequence number of the record in the trail, from 1.
+	Seq uint64 `json:"seq"`
+	// when the action was performed.
+	Time time.Time `json:"time"`
+	// who performed the action, e.g. a user name.
+	Actor string `json:"actor"`
+	// what was done, e.g. "delete".
+	Action string `json:"action"`
+	// what the action was performed on, e.g. "invoice/42".
+	Resource string `json:"resource"`
+	// additional details, e.g. the outcome of the action.
+	Details log.Fields `json:"details,omitempty"`
+	// the hash of the previous record, empty for the first record.
+	PrevHash string `json:"prev_hash"`
+	// the hex-encoded SHA-256 or HMAC-SHA256 of the record without its hash.
+	Hash string `json:"-"`
+}
+
+// hashPrefix and hashSuffix surround the hash at the end of a record line.
+const (
+	hashPrefix = `,"hash":"`
+	hashSuffix = `"}`
+	hashLength = sha256.Size * 2
+)
+
+// AuditLogger appends records to an audit trail.
+type AuditLogger struct {
+	// the key of the HMAC-SHA256 chaining the records. Nil means the records are chained with SHA-256.
+	Key []byte
+	// the logger the records are also logged to, at the Info level under the "audit" category, if not nil.
+	Logger *log.Logger
+
+	lock     sync.Mutex
+	w        io.Writer
+	seq      uint64
+	lastHash string
+}
+
+// New creates an AuditLogger appending to a new audit trail written to w.
+// If w has a Sync method, such as *os.File, it is called after each record.
+func New(w io.Writer) *AuditLogger {
+	return &AuditLogger{w: w}
+}
+
+// Open creates an AuditLogger appending to the audit trail in the file fileName, creating the file if needed.
+// The existing records are verified with key, which is then set as the Key of the AuditLogger, and the new
+// records are chained to the last one. Open returns a *VerifyError if the existing records do not verify.
+func Open(fileName string, key []byte) (*AuditLogger, error) {
+	file, err := os.OpenFile(fileName, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return nil, err
+	}
+	a := &AuditLogger{Key: key, w: file}
+	v := &verifier{key: key}
+	if err := v.verify(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	a.seq, a.lastHash = v.seq, v.lastHash
+	return a, nil
+}
+
+// Log appends a record of the action performed by actor on resource, with the given details.
+// It returns the error preventing the record from being written, which should abort the action
+// in a compliance-sensitive application.
+func (a *AuditLogger) Log(actor, action, resource string, details log.Fields) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	r := &Record{
+		Seq:      a.seq + 1,
+		Time:     time.Now(),
+		Actor:    actor,
+		Action:   action,
+		Resource: resource,
+		Details:  details,
+		PrevHash: a.lastHash,
+	}
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	r.Hash = hashRecord(a.Key, body)
+	line := make([]byte, 0, len(body)+len(hashPrefix)+hashLength+len(hashSuffix)+1)
+	line = append(line, body[:len(body)-1]...)
+	line = append(line, hashPrefix...)
+	line = append(line, r.Hash...)
+	line = append(line, hashSuffix...)
+	line = append(line, '\n')
+	if _, err := a.w.Write(line); err != nil {
+		return err
+	}
+	if s, ok := a.w.(interface{ Sync() error }); ok {
+		if err := s.Sync(); err != nil {
+			return err
+		}
+	}
+	a.seq, a.lastHash = r.Seq, r.Hash
+
+	if a.Logger != nil {
+		fields := log.Fields{"actor": actor, "action": action, "resource": resource, "audit_seq": r.Seq, "audit_hash": r.Hash}
+		for k, v := range details {
+			if _, ok := fields[k]; !ok {
+				fields[k] = v
+			}
+		}
+		a.Logger.GetLogger("audit").WithFields(fields).Infof("%v %v %v", actor, action, resource)
+	}
+	return nil
+}
+
+// LastHash returns the hash of the last record, which can be published to detect the rewriting of the trail.
+func (a *AuditLogger) LastHash() string {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.lastHash
+}
+
+// Close closes the writer of the audit trail if it is an io.Closer.
+func (a *AuditLogger) Close() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if c, ok := a.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// VerifyError describes the first record of an audit trail which does not verify.
+type VerifyError struct {
+	Line   int    // the line of the record, from 1
+	Reason string // why the record does not verify
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("audit: line %v: %v", e.Line, e.Reason)
+}
+
+// Verify reads an audit trail and checks that its records are intact and chained in sequence, using key
+// if the records are chained with HMAC-SHA256. It returns the records verified, and a *VerifyError
+// for the first record which does not verify.
+func Verify(r io.Reader, key []byte) ([]*Record, error) {
+	v := &verifier{key: key, keep: true}
+	err := v.verify(r)
+	return v.records, err
+}
+
+// VerifyFile verifies the audit trail in the file fileName like Verify.
+func VerifyFile(fileName string, key []byte) ([]*Record, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Verify(file, key)
+}
+
+// verifier checks the records of an audit trail.
+type verifier struct {
+	key      []byte
+	keep     bool // whether to keep the verified records
+	records  []*Record
+	seq      uint64
+	lastHash string
+}
+
+func (v *verifier) verify(r io.Reader) error {
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if len(data) == 0 && err == io.EOF {
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if err := v.check(bytes.TrimRight(data, "\r\n")); err != nil {
+			return &VerifyError{Line: line, Reason: err.Error()}
+		}
+	}
+}
+
+// check verifies a record line and chains it.
+func (v *verifier) check(data []byte) error {
+	end := len(data) - len(hashSuffix)
+	start := end - hashLength - len(hashPrefix)
+	if start < 1 || !bytes.HasSuffix(data, []byte(hashSuffix)) || !bytes.Equal(data[start:start+len(hashPrefix)], []byte(hashPrefix)) {
+		return errors.New("the record has no hash")
+	}
+	recordHash := string(data[start+len(hashPrefix) : end])
+	body := append(append([]byte{}, data[:start]...), '}')
+	if !hmac.Equal([]byte(hashRecord(v.key, body)), []byte(recordHash)) {
+		return errors.New("the record was modified")
+	}
+	r := &Record{}
+	if err := json.Unmarshal(body, r); err != nil {
+		return err
+	}
+	if r.Seq != v.seq+1 {
+		return fmt.Errorf("the record has sequence number %v, expected %v", r.Seq, v.seq+1)
+	}
+	if r.PrevHash != v.lastHash {
+		return errors.New("the record is not chained to the previous record")
+	}
+	r.Hash = recordHash
+	v.seq, v.lastHash = r.Seq, r.Hash
+	if v.keep {
+		v.records = append(v.records, r)
+	}
+	return nil
+}
+
+// hashRecord returns the hex-encoded SHA-256 of a record, or its HMAC-SHA256 if key is not nil.
+func hashRecord(key []byte, body []byte) string {
+	var h hash.Hash
+	if key != nil {
+		h = hmac.New(sha256.New, key)
+	} else {
+		h = sha256.New()
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
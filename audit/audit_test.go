@@ -0,0 +1,120 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package audit_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/admpub/log"
+	"github.com/admpub/log/audit"
+)
+
+func TestAuditLogger(t *testing.T) {
+	var buf bytes.Buffer
+	trail := audit.New(&buf)
+	trail.Log("alice", "create", "invoice/42", nil)
+	trail.Log("bob", "delete", "invoice/42", log.Fields{"reason": "duplicate"})
+
+	records, err := audit.Verify(bytes.NewReader(buf.Bytes()), nil)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Verify() = %v, %v, expected 2 records", len(records), err)
+	}
+	r := records[1]
+	if r.Seq != 2 || r.Actor != "bob" || r.Action != "delete" || r.Resource != "invoice/42" || r.Details["reason"] != "duplicate" {
+		t.Errorf("records[1] = %+v, expected bob deleting invoice/42", r)
+	}
+	if r.PrevHash != records[0].Hash || records[0].PrevHash != "" {
+		t.Errorf("records[1].PrevHash = %q, expected %q", r.PrevHash, records[0].Hash)
+	}
+	if trail.LastHash() != r.Hash {
+		t.Errorf("LastHash() = %q, expected %q", trail.LastHash(), r.Hash)
+	}
+
+	lines := strings.SplitAfter(buf.String(), "\n")
+	tests := []struct {
+		tag   string
+		trail string
+		line  int
+	}{
+		{"modified", lines[0] + strings.Replace(lines[1], "bob", "eve", 1), 2},
+		{"removed", lines[1], 1},
+		{"reordered", lines[1] + lines[0], 1},
+		{"truncated", lines[0] + lines[1][:40] + "\n", 2},
+	}
+	for _, test := range tests {
+		_, err := audit.Verify(strings.NewReader(test.trail), nil)
+		if e, ok := err.(*audit.VerifyError); !ok || e.Line != test.line {
+			t.Errorf("%v: Verify() = %v, expected an error at line %v", test.tag, err, test.line)
+		}
+	}
+}
+
+func TestAuditLoggerKey(t *testing.T) {
+	var buf bytes.Buffer
+	trail := audit.New(&buf)
+	trail.Key = []byte("secret")
+	trail.Log("alice", "login", "app", nil)
+
+	if _, err := audit.Verify(bytes.NewReader(buf.Bytes()), []byte("secret")); err != nil {
+		t.Errorf("Verify() = %v, expected no error with the key", err)
+	}
+	if _, err := audit.Verify(bytes.NewReader(buf.Bytes()), nil); err == nil {
+		t.Errorf("Verify() = nil, expected an error without the key")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logaudit")
+	if err != nil {
+		t.Fatalf("TempDir(): %v", err)
+	}
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "audit.log")
+
+	for _, actor := range []string{"alice", "bob"} {
+		trail, err := audit.Open(fileName, nil)
+		if err != nil {
+			t.Fatalf("Open() = %v", err)
+		}
+		if err := trail.Log(actor, "login", "app", nil); err != nil {
+			t.Errorf("Log() = %v", err)
+		}
+		trail.Close()
+	}
+	records, err := audit.VerifyFile(fileName, nil)
+	if err != nil || len(records) != 2 || records[1].Actor != "bob" || records[1].Seq != 2 {
+		t.Errorf("VerifyFile() = %v, %v, expected the records of both runs chained", len(records), err)
+	}
+
+	data, _ := ioutil.ReadFile(fileName)
+	ioutil.WriteFile(fileName, bytes.Replace(data, []byte("alice"), []byte("eve"), 1), 0600)
+	if _, err := audit.Open(fileName, nil); err == nil {
+		t.Errorf("Open() = nil, expected an error for a tampered trail")
+	}
+}
+
+func TestAuditLoggerLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.NewLogger()
+	logger.Sync()
+	target := log.NewConsoleTarget()
+	target.ColorMode = false
+	target.Writer = &buf
+	logger.SetTarget(target)
+
+	trail := audit.New(ioutil.Discard)
+	trail.Logger = logger
+	trail.Log("alice", "delete", "invoice/42", nil)
+	logger.Close()
+
+	if result := buf.String(); !strings.Contains(result, "|audit|") || !strings.Contains(result, "alice delete invoice/42") || !strings.Contains(result, "audit_hash") {
+		t.Errorf("logged %q, expected the audit record", result)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// BatchWriter writes a batch of log messages, e.g. in one request to a remote service.
+type BatchWriter interface {
+	WriteBatch(entries []*Entry) error
+}
+
+// BatchWriterFunc turns a function into a BatchWriter.
+type BatchWriterFunc func(entries []*Entry) error
+
+// WriteBatch calls f(entries).
+func (f BatchWriterFunc) WriteBatch(entries []*Entry) error {
+	return f(entries)
+}
+
+// BatchTarget is a target which can process several messages at once, e.g. in a single write.
+// The logger hands it the messages waiting in its queue, up to Logger.TargetBatchSize, instead of calling
+// Process for each of them. Unlike BatchingTarget, it does not wait for messages to fill a batch.
+type BatchTarget interface {
+	Target
+	// ProcessBatch processes the messages in order, filtering them as Process does.
+	// The slice is reused once ProcessBatch returns.
+	ProcessBatch(entries []*Entry)
+}
+
+// BatchingTarget accumulates log messages and hands them in batches to a BatchWriter.
+// A batch is written when it contains BatchSize messages or BatchBytes bytes, FlushInterval after
+// its first message, and when the target is closed. A batch which fails to be written is dropped.
+type BatchingTarget struct {
+	*Filter
+	// the writer the batches are handed to.
+	Writer BatchWriter
+	// the name of the target in the error messages.
+	Name string
+	// the maximum number of messages in a batch.
+	BatchSize int
+	// the size of a batch from which it is written. 0 means no limit.
+	BatchBytes int
+	// the maximum time a message is kept in memory before being written.
+	FlushInterval time.Duration
+	// the size of the message channel. New messages are dropped when it is full.
+	BufferSize int
+	// returns the size of a message counted against BatchBytes. It is the length of the formatted message by default.
+	Size func(*Entry) int
+
+	entries chan *Entry
+	close   chan bool
+}
+
+// NewBatchingTarget creates a BatchingTarget.
+// The new BatchingTarget takes these default options:
+// MaxLevel: LevelDebug, Name: BatchingTarget, BatchSize: 100, FlushInterval: 2s, BufferSize: 1024.
+// You must specify the Writer field.
+func NewBatchingTarget() *BatchingTarget {
+	return &BatchingTarget{
+		Filter:        &Filter{MaxLevel: LevelDebug},
+		Name:          "BatchingTarget",
+		BatchSize:     100,
+		FlushInterval: 2 * time.Second,
+		BufferSize:    1024,
+		close:         make(chan bool, 0),
+	}
+}
+
+// Open prepares BatchingTarget for processing log messages.
+func (t *BatchingTarget) Open(errWriter io.Writer) error {
+	t.Filter.Init()
+	if t.Writer == nil {
+		return errors.New(t.Name + ".Writer must be specified")
+	}
+	if t.BatchSize <= 0 {
+		return errors.New(t.Name + ".BatchSize must be greater than 0")
+	}
+	if t.FlushInterval <= 0 {
+		return errors.New(t.Name + ".FlushInterval must be greater than 0")
+	}
+	if t.BufferSize < 0 {
+		return errors.New(t.Name + ".BufferSize must be no less than 0")
+	}
+	t.entries = make(chan *Entry, t.BufferSize)
+
+	go t.writeBatches(errWriter)
+
+	return nil
+}
+
+// Process puts filtered log messages into a channel for batching.
+func (t *BatchingTarget) Process(e *Entry) {
+	if e == nil {
+		t.entries <- nil
+		return
+	}
+	if t.Allow(e) {
+		select {
+		case t.entries <- e:
+		default:
+		}
+	}
+}
+
+// Close writes the last batch and closes the target.
+func (t *BatchingTarget) Close() {
+	<-t.close
+}
+
+func (t *BatchingTarget) writeBatches(errWriter io.Writer) {
+	var (
+		batch []*Entry
+		size  int
+		timer = time.NewTimer(t.FlushInterval)
+	)
+	timer.Stop()
+	flush := func() {
+		timer.Stop()
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.Writer.WriteBatch(batch); err != nil {
+			fmt.Fprintf(errWriter, "%v write error: %v (%v messages dropped)\n", t.Name, err, len(batch))
+		}
+		batch = nil
+		size = 0
+	}
+	for {
+		select {
+		case entry := <-t.entries:
+			if entry == nil {
+				flush()
+				t.close <- true
+				return
+			}
+			if len(batch) == 0 {
+				timer.Reset(t.FlushInterval)
+			}
+			batch = append(batch, entry)
+			if t.BatchBytes > 0 {
+				if t.Size != nil {
+					size += t.Size(entry)
+				} else {
+					size += len(entry.FormattedMessage) + 1
+				}
+			}
+			if len(batch) >= t.BatchSize || (t.BatchBytes > 0 && size >= t.BatchBytes) {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+// batchRecorder records the sizes of the batches it is given.
+type batchRecorder struct {
+	lock    sync.Mutex
+	batches []int
+	err     error
+}
+
+func (r *batchRecorder) WriteBatch(entries []*log.Entry) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.batches = append(r.batches, len(entries))
+	return r.err
+}
+
+func (r *batchRecorder) sizes() []int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]int{}, r.batches...)
+}
+
+func TestNewBatchingTarget(t *testing.T) {
+	target := log.NewBatchingTarget()
+	if target.BatchSize != 100 {
+		t.Errorf("NewBatchingTarget.BatchSize = %v, expected %v", target.BatchSize, 100)
+	}
+	if target.FlushInterval != 2*time.Second {
+		t.Errorf("NewBatchingTarget.FlushInterval = %v, expected %v", target.FlushInterval, 2*time.Second)
+	}
+	if err := target.Open(&bytes.Buffer{}); err == nil || err.Error() != "BatchingTarget.Writer must be specified" {
+		t.Errorf("Open() = %v, expected the Writer to be required", err)
+	}
+}
+
+func TestBatchingTarget(t *testing.T) {
+	recorder := &batchRecorder{}
+	target := log.NewBatchingTarget()
+	target.Writer = recorder
+	target.BatchSize = 3
+	target.BatchBytes = 10
+	target.FlushInterval = 50 * time.Millisecond
+	if err := target.Open(&bytes.Buffer{}); err != nil {
+		t.Fatalf("Open(): %v", err)
+	}
+
+	for _, message := range []string{"a", "b", "c", "d", "0123456789", "e"} {
+		target.Process(&log.Entry{Level: log.LevelInfo, FormattedMessage: message})
+	}
+	// the last message is written by the flush interval
+	time.Sleep(200 * time.Millisecond)
+	target.Process(&log.Entry{Level: log.LevelInfo, FormattedMessage: "f"})
+	target.Process(&log.Entry{Level: log.LevelTrace, FormattedMessage: "filtered"})
+	target.Process(nil)
+	target.Close()
+
+	if sizes := recorder.sizes(); len(sizes) != 4 || sizes[0] != 3 || sizes[1] != 2 || sizes[2] != 1 || sizes[3] != 1 {
+		t.Errorf("batches = %v, expected [3 2 1 1]", sizes)
+	}
+}
+
+func TestBatchingTargetError(t *testing.T) {
+	recorder := &batchRecorder{err: errors.New("unavailable")}
+	target := log.NewBatchingTarget()
+	target.Writer = recorder
+	target.Name = "QueueTarget"
+	errWriter := &bytes.Buffer{}
+	if err := target.Open(errWriter); err != nil {
+		t.Fatalf("Open(): %v", err)
+	}
+	target.Process(&log.Entry{Level: log.LevelInfo})
+	target.Process(&log.Entry{Level: log.LevelInfo})
+	target.Process(nil)
+	target.Close()
+
+	if s := errWriter.String(); !strings.Contains(s, "QueueTarget write error: unavailable (2 messages dropped)") {
+		t.Errorf("error output = %q, expected the write error", s)
+	}
+}
+
+type batchTarget struct {
+	*log.Filter
+	release  chan bool
+	messages []string
+	batches  []int
+	close    chan bool
+}
+
+func (t *batchTarget) Open(io.Writer) error {
+	return nil
+}
+
+func (t *batchTarget) Process(e *log.Entry) {
+	if e == nil {
+		t.close <- true
+		return
+	}
+	t.ProcessBatch([]*log.Entry{e})
+}
+
+func (t *batchTarget) ProcessBatch(entries []*log.Entry) {
+	if t.release != nil {
+		// the next messages wait in the queue
+		<-t.release
+		t.release = nil
+	}
+	for _, e := range entries {
+		t.messages = append(t.messages, e.Message)
+	}
+	t.batches = append(t.batches, len(entries))
+}
+
+func (t *batchTarget) Close() {
+	<-t.close
+}
+
+func TestBatchTarget(t *testing.T) {
+	logger := log.NewLogger()
+	logger.TargetBatchSize = 5
+	// the messages are queued in order
+	logger.MaxGoroutines = 0
+	target := &batchTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, release: make(chan bool), close: make(chan bool)}
+	logger.SetTarget(target)
+
+	for i := 0; i < 12; i++ {
+		logger.Info(i)
+	}
+	for logger.TargetStatus()[0].QueueDepth < 7 {
+		time.Sleep(time.Millisecond)
+	}
+	target.release <- true
+	logger.Close()
+
+	if strings.Join(target.messages, ",") != "0,1,2,3,4,5,6,7,8,9,10,11" {
+		t.Errorf("messages = %v, expected the messages in order", target.messages)
+	}
+	largest := 0
+	for _, n := range target.batches {
+		if n > largest {
+			largest = n
+		}
+	}
+	if largest != 5 {
+		t.Errorf("batches = %v, expected batches of up to 5 messages", target.batches)
+	}
+}
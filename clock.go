@@ -0,0 +1,79 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the time of the log messages. It is set through Logger.SetClock.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SystemClock is the default clock of the loggers, which returns the current time.
+var SystemClock Clock = systemClock{}
+
+// clockHolder wraps a Clock, as atomic.Value requires values of the same type.
+type clockHolder struct {
+	clock Clock
+}
+
+// ManualClock is a clock controlled by the program, giving deterministic timestamps to tests and replay tools.
+type ManualClock struct {
+	lock sync.Mutex
+	now  time.Time
+	step time.Duration
+}
+
+// NewManualClock creates a ManualClock starting at the given time, which advances by step every time it is read.
+func NewManualClock(start time.Time, step time.Duration) *ManualClock {
+	return &ManualClock{now: start, step: step}
+}
+
+// Now returns the time of the clock, and then advances it by the step.
+func (c *ManualClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := c.now
+	c.now = c.now.Add(c.step)
+	return now
+}
+
+// Set changes the time of the clock.
+func (c *ManualClock) Set(t time.Time) {
+	c.lock.Lock()
+	c.now = t
+	c.lock.Unlock()
+}
+
+// Add advances the clock by the given duration.
+func (c *ManualClock) Add(d time.Duration) {
+	c.lock.Lock()
+	c.now = c.now.Add(d)
+	c.lock.Unlock()
+}
+
+// SetClock changes the clock giving the time of the messages of the logger and of the loggers sharing its targets.
+// It can be called while messages are being logged.
+func (l *Logger) SetClock(clock Clock) *Logger {
+	l.clock.Store(clockHolder{clock})
+	return l
+}
+
+// now returns the time of the clock set by SetClock, or the current time.
+func (l *coreLogger) now() time.Time {
+	if h, ok := l.clock.Load().(clockHolder); ok {
+		return h.clock.Now()
+	}
+	return time.Now()
+}
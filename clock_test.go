@@ -0,0 +1,66 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+func TestManualClock(t *testing.T) {
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	clock := log.NewManualClock(start, time.Second)
+	if now := clock.Now(); !now.Equal(start) {
+		t.Errorf("Now() = %v, expected %v", now, start)
+	}
+	if now := clock.Now(); !now.Equal(start.Add(time.Second)) {
+		t.Errorf("Now() = %v, expected %v", now, start.Add(time.Second))
+	}
+	clock.Add(time.Minute)
+	if now := clock.Now(); !now.Equal(start.Add(time.Minute + 2*time.Second)) {
+		t.Errorf("Now() = %v, expected %v", now, start.Add(time.Minute+2*time.Second))
+	}
+	clock.Set(start)
+	if now := clock.Now(); !now.Equal(start) {
+		t.Errorf("Now() = %v after Set, expected %v", now, start)
+	}
+}
+
+func TestLoggerClock(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	defer logger.Close()
+
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	logger.SetClock(log.NewManualClock(start, time.Millisecond))
+	logger.Info("t1")
+	logger.GetLogger("db").Warn("t2")
+	logger.Debug("t3")
+
+	if len(target.entries) != 3 {
+		t.Fatalf("len(target.entries) = %v, expected %v", len(target.entries), 3)
+	}
+	for i, e := range target.entries {
+		if expected := start.Add(time.Duration(i) * time.Millisecond); !e.Time.Equal(expected) {
+			t.Errorf("entries[%v].Time = %v, expected %v", i, e.Time, expected)
+		}
+		if e.Seq != uint64(i+1) {
+			t.Errorf("entries[%v].Seq = %v, expected %v", i, e.Seq, i+1)
+		}
+	}
+	if s := target.entries[0].String(); s[:19] != "2020-01-02 03:04:05" {
+		t.Errorf("entries[0] = %q, expected the time of the clock", s)
+	}
+
+	logger.SetClock(log.SystemClock)
+	logger.Info("t4")
+	if e := target.entries[3]; time.Since(e.Time) > time.Minute || e.Seq != 4 {
+		t.Errorf("entries[3] = %v #%v, expected the current time and 4", e.Time, e.Seq)
+	}
+}
@@ -0,0 +1,521 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// limits imposed by the CloudWatch Logs PutLogEvents API.
+const (
+	cloudWatchMaxBatchEvents = 10000
+	cloudWatchMaxBatchBytes  = 1048576
+	cloudWatchEventOverhead  = 26
+	cloudWatchMaxEventBytes  = 262144 - cloudWatchEventOverhead
+	cloudWatchMaxBatchSpan   = 24 * time.Hour
+)
+
+// awsMetadataTimeout is the timeout for requests to the ECS and EC2 credential endpoints.
+const awsMetadataTimeout = 2 * time.Second
+
+// CloudWatchTarget sends log messages to a log stream of Amazon CloudWatch Logs.
+// Messages are sent in batches through the PutLogEvents API. Credentials are taken
+// from the AccessKeyID/SecretAccessKey fields, the standard AWS environment variables,
+// the ECS container credentials endpoint or the EC2 instance metadata service, in that order.
+type CloudWatchTarget struct {
+	*Filter
+	// the AWS region, e.g. "us-east-1". Defaults to the AWS_REGION environment variable.
+	Region string
+	// the name of the log group. It is created when it does not exist and CreateGroup is true.
+	LogGroup string
+	// the name of the log stream. It is created when it does not exist.
+	LogStream string
+	// whether to create the log group when it does not exist.
+	CreateGroup bool
+	// static credentials. Leave them empty to use the default credential chain.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// the service endpoint. Defaults to https://logs.{Region}.amazonaws.com.
+	Endpoint string
+	// the size of the message channel.
+	BufferSize int
+	// the maximum time a message is kept in memory before being sent.
+	FlushInterval time.Duration
+	// how many times a throttled or failed request is retried.
+	MaxRetries int
+	// the HTTP client used to call the service.
+	Client *http.Client
+
+	entries  chan *Entry
+	close    chan bool
+	seqToken string
+
+	credLock sync.Mutex
+	creds    *awsCredentials
+}
+
+// NewCloudWatchTarget creates a CloudWatchTarget.
+// The new CloudWatchTarget takes these default options:
+// MaxLevel: LevelDebug, CreateGroup: true, BufferSize: 1024, FlushInterval: 5s, MaxRetries: 5.
+// You must specify the LogGroup and LogStream fields.
+func NewCloudWatchTarget() *CloudWatchTarget {
+	return &CloudWatchTarget{
+		Filter:        &Filter{MaxLevel: LevelDebug},
+		CreateGroup:   true,
+		BufferSize:    1024,
+		FlushInterval: 5 * time.Second,
+		MaxRetries:    5,
+		close:         make(chan bool, 0),
+	}
+}
+
+// Open prepares CloudWatchTarget for processing log messages.
+func (t *CloudWatchTarget) Open(errWriter io.Writer) error {
+	t.Filter.Init()
+	if t.Region == "" {
+		t.Region = os.Getenv("AWS_REGION")
+	}
+	if t.Region == "" {
+		t.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if t.Region == "" && t.Endpoint == "" {
+		return errors.New("CloudWatchTarget.Region must be specified")
+	}
+	if t.LogGroup == "" {
+		return errors.New("CloudWatchTarget.LogGroup must be specified")
+	}
+	if t.LogStream == "" {
+		return errors.New("CloudWatchTarget.LogStream must be specified")
+	}
+	if t.BufferSize < 0 {
+		return errors.New("CloudWatchTarget.BufferSize must be no less than 0")
+	}
+	if t.FlushInterval <= 0 {
+		return errors.New("CloudWatchTarget.FlushInterval must be greater than 0")
+	}
+	if t.Endpoint == "" {
+		t.Endpoint = "https://logs." + t.Region + ".amazonaws.com"
+	}
+	if t.Client == nil {
+		t.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	t.seqToken = ""
+	if err := t.prepareStream(); err != nil {
+		return err
+	}
+	t.entries = make(chan *Entry, t.BufferSize)
+
+	go t.sendMessages(errWriter)
+
+	return nil
+}
+
+// Process puts filtered log messages into a channel for sending to CloudWatch Logs.
+func (t *CloudWatchTarget) Process(e *Entry) {
+	if e == nil {
+		t.entries <- nil
+		return
+	}
+	if t.Allow(e) {
+		select {
+		case t.entries <- e:
+		default:
+		}
+	}
+}
+
+// Close closes the CloudWatch target.
+func (t *CloudWatchTarget) Close() {
+	<-t.close
+}
+
+type cloudWatchEvent struct {
+	Timestamp int64  `json:"timestamp"`
+	Message   string `json:"message"`
+}
+
+func (t *CloudWatchTarget) sendMessages(errWriter io.Writer) {
+	var (
+		batch []cloudWatchEvent
+		size  int
+	)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.putLogEvents(batch); err != nil {
+			fmt.Fprintf(errWriter, "CloudWatchTarget write error: %v\n", err)
+		}
+		batch = nil
+		size = 0
+	}
+	ticker := time.NewTicker(t.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case entry := <-t.entries:
+			if entry == nil {
+				flush()
+				t.close <- true
+				return
+			}
+			event := cloudWatchEvent{
+				Timestamp: entry.Time.UnixNano() / int64(time.Millisecond),
+				Message:   entry.String(),
+			}
+			if len(event.Message) > cloudWatchMaxEventBytes {
+				event.Message = truncateUTF8(event.Message, cloudWatchMaxEventBytes)
+			}
+			n := len(event.Message) + cloudWatchEventOverhead
+			if len(batch) > 0 && (len(batch) >= cloudWatchMaxBatchEvents || size+n > cloudWatchMaxBatchBytes ||
+				time.Duration(event.Timestamp-batch[0].Timestamp)*time.Millisecond >= cloudWatchMaxBatchSpan) {
+				flush()
+			}
+			batch = append(batch, event)
+			size += n
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// putLogEvents sends a batch of events, refreshing the sequence token when the service rejects it.
+func (t *CloudWatchTarget) putLogEvents(batch []cloudWatchEvent) error {
+	// events in a batch must be in chronological order
+	sort.SliceStable(batch, func(i, j int) bool {
+		return batch[i].Timestamp < batch[j].Timestamp
+	})
+	for attempt := 0; ; attempt++ {
+		req := map[string]interface{}{
+			"logGroupName":  t.LogGroup,
+			"logStreamName": t.LogStream,
+			"logEvents":     batch,
+		}
+		if t.seqToken != "" {
+			req["sequenceToken"] = t.seqToken
+		}
+		var resp struct {
+			NextSequenceToken string `json:"nextSequenceToken"`
+		}
+		err := t.call("PutLogEvents", req, &resp)
+		if err == nil {
+			t.seqToken = resp.NextSequenceToken
+			return nil
+		}
+		if e, ok := err.(*cloudWatchError); ok && attempt < t.MaxRetries {
+			switch e.Type {
+			case "InvalidSequenceTokenException":
+				t.seqToken = e.ExpectedSequenceToken
+				continue
+			case "DataAlreadyAcceptedException":
+				t.seqToken = e.ExpectedSequenceToken
+				return nil
+			}
+		}
+		return err
+	}
+}
+
+// prepareStream creates the log group and the log stream if they do not exist yet.
+func (t *CloudWatchTarget) prepareStream() error {
+	if t.CreateGroup {
+		err := t.call("CreateLogGroup", map[string]string{"logGroupName": t.LogGroup}, nil)
+		if err != nil && !isCloudWatchError(err, "ResourceAlreadyExistsException") {
+			return err
+		}
+	}
+	err := t.call("CreateLogStream", map[string]string{
+		"logGroupName":  t.LogGroup,
+		"logStreamName": t.LogStream,
+	}, nil)
+	if err != nil && !isCloudWatchError(err, "ResourceAlreadyExistsException") {
+		return err
+	}
+	return nil
+}
+
+type cloudWatchError struct {
+	Status                int
+	Type                  string `json:"__type"`
+	Message               string `json:"message"`
+	ExpectedSequenceToken string `json:"expectedSequenceToken"`
+}
+
+func (e *cloudWatchError) Error() string {
+	return fmt.Sprintf("CloudWatch Logs error (%d %s): %s", e.Status, e.Type, e.Message)
+}
+
+func (e *cloudWatchError) retryable() bool {
+	switch e.Type {
+	case "ThrottlingException", "ServiceUnavailableException", "LimitExceededException":
+		return true
+	}
+	return e.Status == http.StatusTooManyRequests || e.Status >= 500
+}
+
+func isCloudWatchError(err error, typ string) bool {
+	e, ok := err.(*cloudWatchError)
+	return ok && e.Type == typ
+}
+
+// truncateUTF8 shortens s to at most n bytes without splitting a multi-byte character.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// call invokes a CloudWatch Logs API action, retrying with exponential backoff on throttling.
+// Failures to resolve the credentials are not retried.
+func (t *CloudWatchTarget) call(action string, in interface{}, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	backoff := 100 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		creds, err := t.credentials()
+		if err != nil {
+			return err
+		}
+		err = t.do(action, body, creds, out)
+		e, ok := err.(*cloudWatchError)
+		if err == nil || attempt >= t.MaxRetries || (ok && !e.retryable()) {
+			return err
+		}
+		time.Sleep(backoff)
+		if backoff < 10*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+func (t *CloudWatchTarget) do(action string, body []byte, creds *awsCredentials, out interface{}) error {
+	req, err := http.NewRequest("POST", t.Endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "Logs_20140328."+action)
+	signAWSRequestV4(req, body, creds, t.Region, "logs", time.Now())
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		e := &cloudWatchError{Status: resp.StatusCode}
+		json.Unmarshal(data, e)
+		if i := strings.LastIndex(e.Type, "#"); i >= 0 {
+			e.Type = e.Type[i+1:]
+		}
+		return e
+	}
+	if out != nil && len(data) > 0 {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
+
+type awsCredentials struct {
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	SessionToken    string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+// credentials resolves the AWS credentials, caching temporary ones until shortly before they expire.
+func (t *CloudWatchTarget) credentials() (*awsCredentials, error) {
+	if t.AccessKeyID != "" {
+		return &awsCredentials{AccessKeyID: t.AccessKeyID, SecretAccessKey: t.SecretAccessKey, SessionToken: t.SessionToken}, nil
+	}
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		return &awsCredentials{
+			AccessKeyID:     id,
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	}
+
+	t.credLock.Lock()
+	defer t.credLock.Unlock()
+	if t.creds != nil && time.Now().Add(5*time.Minute).Before(t.creds.Expiration) {
+		return t.creds, nil
+	}
+	// the metadata endpoints are link-local, so a short timeout avoids hanging outside of AWS
+	client := &http.Client{Transport: t.Client.Transport, Timeout: awsMetadataTimeout}
+	creds, err := fetchAWSRoleCredentials(client)
+	if err != nil {
+		return nil, err
+	}
+	t.creds = creds
+	return creds, nil
+}
+
+// fetchAWSRoleCredentials obtains temporary credentials from the ECS container endpoint or the EC2 instance metadata service.
+func fetchAWSRoleCredentials(client *http.Client) (*awsCredentials, error) {
+	var (
+		req *http.Request
+		err error
+	)
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); uri != "" {
+		req, err = http.NewRequest("GET", "http://169.254.170.2"+uri, nil)
+	} else if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI"); uri != "" {
+		req, err = http.NewRequest("GET", uri, nil)
+		if err == nil && os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN") != "" {
+			req.Header.Set("Authorization", os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN"))
+		}
+	} else {
+		req, err = ec2RoleCredentialsRequest(client)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to find AWS credentials: %v", err)
+	}
+	data, err := fetchAWSMetadata(client, req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find AWS credentials: %v", err)
+	}
+	creds := &awsCredentials{}
+	if err := json.Unmarshal(data, creds); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+func ec2RoleCredentialsRequest(client *http.Client) (*http.Request, error) {
+	const base = "http://169.254.169.254/latest"
+	req, err := http.NewRequest("PUT", base+"/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	token, err := fetchAWSMetadata(client, req)
+	if err != nil {
+		return nil, err
+	}
+	req, _ = http.NewRequest("GET", base+"/meta-data/iam/security-credentials/", nil)
+	req.Header.Set("X-aws-ec2-metadata-token", string(token))
+	role, err := fetchAWSMetadata(client, req)
+	if err != nil {
+		return nil, err
+	}
+	req, _ = http.NewRequest("GET", base+"/meta-data/iam/security-credentials/"+strings.TrimSpace(string(role)), nil)
+	req.Header.Set("X-aws-ec2-metadata-token", string(token))
+	return req, nil
+}
+
+func fetchAWSMetadata(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v %v: %v", req.Method, req.URL, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// signAWSRequestV4 signs the request using AWS Signature Version 4.
+func signAWSRequestV4(req *http.Request, body []byte, creds *awsCredentials, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	bodyHash := sha256.Sum256(body)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := new(bytes.Buffer)
+	for _, name := range names {
+		fmt.Fprintf(canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalAWSQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+
+	scope := day + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+creds.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func canonicalAWSQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vs := values[k]
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, url.QueryEscape(k)+"="+strings.Replace(url.QueryEscape(v), "+", "%20", -1))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
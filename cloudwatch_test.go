@@ -0,0 +1,210 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/admpub/log"
+)
+
+func TestNewCloudWatchTarget(t *testing.T) {
+	target := log.NewCloudWatchTarget()
+	if target.MaxLevel != log.LevelDebug {
+		t.Errorf("NewCloudWatchTarget.MaxLevel = %v, expected %v", target.MaxLevel, log.LevelDebug)
+	}
+	if !target.CreateGroup {
+		t.Errorf("NewCloudWatchTarget.CreateGroup should be true, got false")
+	}
+}
+
+func TestCloudWatchTarget(t *testing.T) {
+	var (
+		lock    sync.Mutex
+		actions []string
+		events  []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+			t.Errorf("Unexpected Authorization header %q", r.Header.Get("Authorization"))
+		}
+		action := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "Logs_20140328.")
+		body, _ := ioutil.ReadAll(r.Body)
+		lock.Lock()
+		defer lock.Unlock()
+		actions = append(actions, action)
+		switch action {
+		case "CreateLogGroup":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"ResourceAlreadyExistsException","message":"exists"}`))
+		case "PutLogEvents":
+			var req struct {
+				LogEvents []struct{ Message string } `json:"logEvents"`
+			}
+			json.Unmarshal(body, &req)
+			for _, e := range req.LogEvents {
+				events = append(events, e.Message)
+			}
+			w.Write([]byte(`{"nextSequenceToken":"2"}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	logger := log.NewLogger()
+	logger.Sync()
+	target := log.NewCloudWatchTarget()
+	target.Region = "us-east-1"
+	target.Endpoint = server.URL
+	target.AccessKeyID = "key"
+	target.SecretAccessKey = "secret"
+	target.LogGroup = "group"
+	target.LogStream = "stream"
+	target.Categories = []string{"system.*"}
+	logger.SetTarget(target)
+
+	logger.Infof("t1: %v", 2)
+	logger.GetLogger("system.db").Infof("t2: %v", 3)
+
+	logger.Close()
+
+	lock.Lock()
+	defer lock.Unlock()
+	if strings.Join(actions, ",") != "CreateLogGroup,CreateLogStream,PutLogEvents" {
+		t.Errorf("actions = %v, expected CreateLogGroup,CreateLogStream,PutLogEvents", actions)
+	}
+	if len(events) != 1 || !strings.Contains(events[0], "t2: 3") {
+		t.Errorf("events = %q, expected a single event containing %q", events, "t2: 3")
+	}
+}
+
+// CloudWatchServer is a fake CloudWatch Logs service which validates the sequence tokens
+// of PutLogEvents requests and records the accepted batches.
+type CloudWatchServer struct {
+	lock     sync.Mutex
+	throttle int    // the number of PutLogEvents requests to reject with ThrottlingException
+	token    string // the expected sequence token
+	puts     int    // the number of PutLogEvents requests received
+	batches  [][]string
+}
+
+func (s *CloudWatchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	action := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "Logs_20140328.")
+	body, _ := ioutil.ReadAll(r.Body)
+	if action != "PutLogEvents" {
+		w.Write([]byte(`{}`))
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.puts++
+	if s.throttle > 0 {
+		s.throttle--
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"ThrottlingException","message":"Rate exceeded"}`))
+		return
+	}
+	var req struct {
+		SequenceToken string                     `json:"sequenceToken"`
+		LogEvents     []struct{ Message string } `json:"logEvents"`
+	}
+	json.Unmarshal(body, &req)
+	if req.SequenceToken != s.token {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"__type":                "InvalidSequenceTokenException",
+			"message":               "invalid token",
+			"expectedSequenceToken": s.token,
+		})
+		return
+	}
+	var batch []string
+	for _, e := range req.LogEvents {
+		batch = append(batch, e.Message)
+	}
+	s.batches = append(s.batches, batch)
+	s.token = strconv.Itoa(len(s.batches))
+	json.NewEncoder(w).Encode(map[string]string{"nextSequenceToken": s.token})
+}
+
+func newTestCloudWatchTarget(url string) *log.CloudWatchTarget {
+	target := log.NewCloudWatchTarget()
+	target.Endpoint = url
+	target.AccessKeyID = "key"
+	target.SecretAccessKey = "secret"
+	target.LogGroup = "group"
+	target.LogStream = "stream"
+	return target
+}
+
+func TestCloudWatchTargetRetries(t *testing.T) {
+	cw := &CloudWatchServer{throttle: 2, token: "abc"}
+	server := httptest.NewServer(cw)
+	defer server.Close()
+
+	logger := log.NewLogger()
+	logger.Sync()
+	logger.SetTarget(newTestCloudWatchTarget(server.URL))
+
+	logger.Info("t1")
+	logger.Close()
+
+	// two throttled requests, one with a stale sequence token, then the accepted one
+	if cw.puts != 4 {
+		t.Errorf("puts = %v, expected %v", cw.puts, 4)
+	}
+	if len(cw.batches) != 1 || len(cw.batches[0]) != 1 || !strings.Contains(cw.batches[0][0], "t1") {
+		t.Errorf("batches = %q, expected a single batch containing t1", cw.batches)
+	}
+}
+
+func TestCloudWatchTargetBatches(t *testing.T) {
+	cw := &CloudWatchServer{}
+	server := httptest.NewServer(cw)
+	defer server.Close()
+
+	logger := log.NewLogger()
+	logger.Sync()
+	logger.Formatter = func(l *log.Logger, e *log.Entry) string {
+		return e.Message
+	}
+	target := newTestCloudWatchTarget(server.URL)
+	target.BufferSize = 20000
+	logger.SetTarget(target)
+
+	// 10001 events exceed the event limit of a batch
+	for i := 0; i < 10001; i++ {
+		logger.Info("t")
+	}
+	// 5 events of 256KB exceed the 1MB size limit of a batch, and the
+	// multi-byte characters must not be split when they are truncated
+	large := strings.Repeat("é", 200000)
+	for i := 0; i < 5; i++ {
+		logger.Info(large)
+	}
+	logger.Close()
+
+	var sizes []int
+	for _, batch := range cw.batches {
+		sizes = append(sizes, len(batch))
+	}
+	if fmt.Sprint(sizes) != "[10000 4 2]" {
+		t.Fatalf("batch sizes = %v, expected [10000 4 2]", sizes)
+	}
+	msg := cw.batches[2][1]
+	if !utf8.ValidString(msg) || len(msg) > 262144-26 || len(msg) < 262144-28 {
+		t.Errorf("truncated message has %v bytes and valid UTF-8 = %v", len(msg), utf8.ValidString(msg))
+	}
+}
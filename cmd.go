@@ -0,0 +1,61 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"io"
+	"os/exec"
+	"path/filepath"
+)
+
+// CaptureCmd logs each line a command writes to its standard output and to its standard error as
+// a message of stdoutLevel and stderrLevel respectively, under the category of the name of the
+// command, e.g. "git". It must be called before the command is started. Close the returned Closer
+// once the command has exited, to log its last lines if they do not end with a newline:
+//
+//	cmd := exec.Command("git", "fetch")
+//	capture := logger.CaptureCmd(cmd, log.LevelInfo, log.LevelWarn)
+//	err := cmd.Run()
+//	capture.Close()
+func (l *Logger) CaptureCmd(cmd *exec.Cmd, stdoutLevel, stderrLevel Level) io.Closer {
+	logger := l.clone()
+	logger.Category = filepath.Base(cmd.Path)
+	capture := cmdCapture{
+		&LoggerWriter{Level: stdoutLevel, Logger: logger},
+		&LoggerWriter{Level: stderrLevel, Logger: logger},
+	}
+	cmd.Stdout, cmd.Stderr = lineWriter{capture[0]}, lineWriter{capture[1]}
+	return capture
+}
+
+// cmdCapture holds the writers of the standard output and the standard error of a command.
+type cmdCapture []*LoggerWriter
+
+func (c cmdCapture) Close() error {
+	for _, w := range c {
+		w.Close()
+	}
+	return nil
+}
+
+// lineWriter hands each line written to it to a LoggerWriter separately, so that every line is logged as a message.
+type lineWriter struct {
+	w *LoggerWriter
+}
+
+func (w lineWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.w.Write(p)
+			break
+		}
+		w.w.Write(p[:i+1])
+		p = p[i+1:]
+	}
+	return n, nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestLoggerCaptureCmd(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	logger := log.NewLogger()
+	logger.Sync()
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	cmd := exec.Command("sh", "-c", "echo o1; echo o2; echo e1 >&2; printf o3")
+	capture := logger.CaptureCmd(cmd, log.LevelInfo, log.LevelWarn)
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	capture.Close()
+	logger.Close()
+
+	levels := map[string]log.Level{}
+	for _, e := range target.entries {
+		if e.Category != "sh" {
+			t.Errorf("Category = %q, expected the name of the command", e.Category)
+		}
+		levels[e.Message] = e.Level
+	}
+	expected := map[string]log.Level{"o1": log.LevelInfo, "o2": log.LevelInfo, "o3": log.LevelInfo, "e1": log.LevelWarn}
+	if len(target.entries) != len(expected) {
+		t.Fatalf("entries = %v, expected one message per line", target.entries)
+	}
+	for message, level := range expected {
+		if l, ok := levels[message]; !ok || l != level {
+			t.Errorf("the line %q was not logged at the %v level", message, level)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+// Compressor compresses the log messages written by FileTarget and NetworkTarget and the batches
+// posted by HTTPTarget. GzipCompressor is included, and contrib/zstdlog provides a zstd Compressor.
+type Compressor interface {
+	// Encoding returns the name of the compression, e.g. "gzip". It is sent as the HTTP Content-Encoding.
+	Encoding() string
+	// NewWriter returns a writer compressing the data written to it into w.
+	NewWriter(w io.Writer) (CompressWriter, error)
+}
+
+// CompressWriter compresses the data written to it.
+// Flush writes out the data compressed so far, and Close writes out the end of the compressed stream.
+type CompressWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// GzipCompressor compresses with gzip at the default compression level.
+var GzipCompressor Compressor = NewGzipCompressor(gzip.DefaultCompression)
+
+type gzipCompressor struct {
+	level int
+}
+
+// NewGzipCompressor returns a Compressor compressing with gzip at the given level, e.g. gzip.BestSpeed.
+func NewGzipCompressor(level int) Compressor {
+	return gzipCompressor{level}
+}
+
+func (c gzipCompressor) Encoding() string {
+	return "gzip"
+}
+
+func (c gzipCompressor) NewWriter(w io.Writer) (CompressWriter, error) {
+	return gzip.NewWriterLevel(w, c.level)
+}
+
+// countingWriter counts the bytes written to a writer.
+type countingWriter struct {
+	w io.Writer
+	n *int64
+}
+
+func (w countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	*w.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,401 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package config configures loggers from JSON, YAML or TOML documents, so that
+// the logging topology can live in a configuration file instead of code.
+//
+// A configuration looks like this in JSON:
+//
+//	{
+//		"level": "info",
+//		"formatter": "json",
+//		"callStackDepth": 5,
+//		"targets": [
+//			{"type": "console"},
+//			{"type": "file", "fileName": "app.log", "maxLevel": "warn"}
+//		]
+//	}
+//
+// The settings of a target, other than "type", "name", "maxLevel" and "levels", are the exported fields
+// of the target struct, matched case-insensitively.
+//
+// When there are routes, the targets which have a "name" only receive the messages the routes choose for them,
+// as with log.RouterTarget:
+//
+//	{
+//		"targets": [
+//			{"type": "file", "name": "file", "fileName": "app.log"},
+//			{"type": "mail", "name": "oncall", "host": "smtp.example.com:587", ...}
+//		],
+//		"routes": [
+//			{"when": "level >= error AND category matches \"payment.*\"", "targets": ["oncall", "file"]}
+//		],
+//		"defaultRoute": ["file"]
+//	}
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/admpub/log"
+	"gopkg.in/yaml.v3"
+)
+
+// Config describes the configuration of a logger.
+type Config struct {
+	Level             string            `json:"level"`             // the maximum level of messages to be logged, e.g. "info"
+	CategoryLevels    map[string]string `json:"categoryLevels"`    // the maximum levels of categories and their children, e.g. {"app.db": "warn"}
+	Formatter         string            `json:"formatter"`         // the name of a registered formatter, e.g. "json"
+	CallStackDepth    int               `json:"callStackDepth"`    // the number of call stack frames logged for each message
+	CallStackFilter   string            `json:"callStackFilter"`   // a substring that the file paths of the logged frames should contain
+	CallStackIncludes []string          `json:"callStackIncludes"` // substrings one of which the file paths of the logged frames should contain
+	CallStackExcludes []string          `json:"callStackExcludes"` // substrings that the file paths of the logged frames should not contain
+	Sync              bool              `json:"sync"`              // whether to log in the synchronous mode
+	Targets           []Target          `json:"targets"`           // the targets of the logger
+	Routes            []log.Route       `json:"routes"`            // the routes sending the messages to the named targets
+	DefaultRoute      []string          `json:"defaultRoute"`      // the names of the targets of the messages matching no route
+}
+
+// Target describes a target of a logger.
+type Target struct {
+	Type     string                 // the name of a registered target type, e.g. "file"
+	Name     string                 // the name the routes refer to the target by. It is empty for a target receiving every message.
+	Settings map[string]interface{} // the other settings of the target
+}
+
+// UnmarshalJSON reads the "type" and the "name" of a target and keeps the other keys as its settings.
+func (t *Target) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &t.Settings); err != nil {
+		return err
+	}
+	t.Type, _ = t.Settings["type"].(string)
+	t.Name, _ = t.Settings["name"].(string)
+	delete(t.Settings, "type")
+	delete(t.Settings, "name")
+	return nil
+}
+
+// MarshalJSON writes the settings of a target together with its "type" and its "name".
+func (t Target) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(t.Settings)+2)
+	for k, v := range t.Settings {
+		m[k] = v
+	}
+	m["type"] = t.Type
+	if t.Name != "" {
+		m["name"] = t.Name
+	}
+	return json.Marshal(m)
+}
+
+// Unmarshaler decodes a document into v, like json.Unmarshal.
+type Unmarshaler func(data []byte, v interface{}) error
+
+var (
+	lock       sync.RWMutex
+	targets    = map[string]func() log.Target{}
+	formatters = map[string]log.Formatter{
+		"normal":  log.NormalFormatter,
+		"default": log.DefaultFormatter,
+		"json":    log.JSONFormatter,
+		"csv":     log.NewCSVFormatter(),
+		"tsv":     log.NewTSVFormatter(),
+		"access":  log.AccessLogFormatter,
+		"ecs":     log.ECSFormatter,
+		"dev":     log.DevFormatter,
+	}
+	formats = map[string]Unmarshaler{
+		"json": json.Unmarshal,
+		"yaml": yaml.Unmarshal,
+		"yml":  yaml.Unmarshal,
+		"toml": toml.Unmarshal,
+	}
+)
+
+func init() {
+	RegisterTarget("console", func() log.Target { return log.NewConsoleTarget() })
+	RegisterTarget("file", func() log.Target { return log.NewFileTarget() })
+	RegisterTarget("csv", func() log.Target { return log.NewCSVTarget() })
+	RegisterTarget("network", func() log.Target { return log.NewNetworkTarget() })
+	RegisterTarget("unix", func() log.Target { return log.NewUnixTarget() })
+	RegisterTarget("mail", func() log.Target { return log.NewMailTarget() })
+	RegisterTarget("http", func() log.Target { return log.NewHTTPTarget() })
+	RegisterTarget("splunk", func() log.Target { return log.NewSplunkTarget() })
+	RegisterTarget("otlp", func() log.Target { return log.NewOTLPTarget() })
+	RegisterTarget("cloudwatch", func() log.Target { return log.NewCloudWatchTarget() })
+	RegisterTarget("redis", func() log.Target { return log.NewRedisTarget() })
+}
+
+// RegisterTarget makes a target type available to configurations under the given name.
+func RegisterTarget(name string, factory func() log.Target) {
+	lock.Lock()
+	defer lock.Unlock()
+	targets[name] = factory
+}
+
+// RegisterFormatter makes a formatter available to configurations under the given name.
+// The "normal", "default", "json", "csv", "tsv", "access", "ecs" and "dev" formatters are registered by default.
+func RegisterFormatter(name string, formatter log.Formatter) {
+	lock.Lock()
+	defer lock.Unlock()
+	formatters[name] = formatter
+}
+
+// RegisterFormat makes a document format, identified by the file extension without the dot,
+// available to Load. The "json", "yaml", "yml" and "toml" formats are registered by default.
+func RegisterFormat(ext string, unmarshal Unmarshaler) {
+	lock.Lock()
+	defer lock.Unlock()
+	formats[strings.ToLower(ext)] = unmarshal
+}
+
+// Parse decodes a configuration of the given format, e.g. "json" or "yaml".
+func Parse(data []byte, format string) (*Config, error) {
+	lock.RLock()
+	unmarshal, ok := formats[strings.ToLower(format)]
+	lock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown configuration format %q", format)
+	}
+	var doc interface{}
+	if err := unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	// YAML and TOML documents are decoded into generic values first and then
+	// converted to JSON, so that every format shares the JSON schema of Config.
+	b, err := json.Marshal(normalize(doc))
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Load reads a configuration file. Its format is determined by the file extension.
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data, strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// Configure loads a configuration file and applies it to the logger.
+func Configure(logger *log.Logger, path string) error {
+	c, err := Load(path)
+	if err != nil {
+		return err
+	}
+	return c.Apply(logger)
+}
+
+// ConfigureBytes parses a configuration of the given format and applies it to the logger.
+func ConfigureBytes(logger *log.Logger, data []byte, format string) error {
+	c, err := Parse(data, format)
+	if err != nil {
+		return err
+	}
+	return c.Apply(logger)
+}
+
+// Apply configures the logger. The targets are created before anything is changed,
+// so that the logger is left as is if the configuration is invalid.
+// The configuration can be applied while the logger is in use.
+func (c *Config) Apply(logger *log.Logger) error {
+	level := logger.MaxLevel
+	if c.Level != "" {
+		var ok bool
+		if level, ok = log.GetLevel(c.Level); !ok {
+			return fmt.Errorf("unknown level %q", c.Level)
+		}
+	}
+	categoryLevels := make(map[string]log.Level, len(c.CategoryLevels))
+	for category, name := range c.CategoryLevels {
+		level, ok := log.GetLevel(name)
+		if !ok {
+			return fmt.Errorf("unknown level %q of category %q", name, category)
+		}
+		categoryLevels[category] = level
+	}
+	var formatter log.Formatter
+	if c.Formatter != "" {
+		lock.RLock()
+		formatter = formatters[c.Formatter]
+		lock.RUnlock()
+		if formatter == nil {
+			return fmt.Errorf("unknown formatter %q", c.Formatter)
+		}
+	}
+	if c.CallStackDepth < 0 {
+		return fmt.Errorf("callStackDepth must be no less than 0")
+	}
+	if len(c.Targets) == 0 {
+		// the logger would be closed
+		return fmt.Errorf("at least one target must be configured")
+	}
+	targets, err := c.buildTargets()
+	if err != nil {
+		return err
+	}
+
+	logger.SetMaxLevel(level)
+	// the levels of the categories which are no longer configured are removed
+	logger.SetCategoryLevels(categoryLevels)
+	if formatter != nil {
+		logger.SetFormatter(formatter)
+	}
+	logger.SetCallStack(c.CallStackDepth, c.CallStackFilter, c.CallStackIncludes, c.CallStackExcludes)
+	logger.Sync(c.Sync)
+	logger.SetTarget(targets...)
+	return nil
+}
+
+// buildTargets creates the targets. If there are routes, the named targets are the children
+// of a RouterTarget taking their place.
+func (c *Config) buildTargets() ([]log.Target, error) {
+	all := make([]log.Target, len(c.Targets))
+	for i, t := range c.Targets {
+		target, err := t.build()
+		if err != nil {
+			return nil, fmt.Errorf("targets[%v]: %v", i, err)
+		}
+		all[i] = target
+	}
+	if len(c.Routes) == 0 && len(c.DefaultRoute) == 0 {
+		return all, nil
+	}
+	var targets []log.Target
+	named := map[string]log.Target{}
+	for i, t := range c.Targets {
+		if t.Name == "" {
+			targets = append(targets, all[i])
+		} else if named[t.Name] != nil {
+			return nil, fmt.Errorf("targets[%v]: duplicate name %q", i, t.Name)
+		} else {
+			named[t.Name] = all[i]
+		}
+	}
+	check := func(names []string) error {
+		for _, name := range names {
+			if named[name] == nil {
+				return fmt.Errorf("no target is named %q", name)
+			}
+		}
+		return nil
+	}
+	for i, r := range c.Routes {
+		if _, err := log.ParseCondition(r.When); err != nil {
+			return nil, fmt.Errorf("routes[%v]: %v", i, err)
+		}
+		if err := check(r.Targets); err != nil {
+			return nil, fmt.Errorf("routes[%v]: %v", i, err)
+		}
+	}
+	if err := check(c.DefaultRoute); err != nil {
+		return nil, fmt.Errorf("defaultRoute: %v", err)
+	}
+	router := log.NewRouterTarget()
+	router.Targets = named
+	router.Routes = c.Routes
+	router.Default = c.DefaultRoute
+	return append(targets, router), nil
+}
+
+// build creates the target and applies its settings.
+func (t Target) build() (log.Target, error) {
+	lock.RLock()
+	factory := targets[t.Type]
+	lock.RUnlock()
+	if factory == nil {
+		return nil, fmt.Errorf("unknown target type %q", t.Type)
+	}
+	target := factory()
+
+	settings := make(map[string]interface{}, len(t.Settings))
+	var maxLevel, levels interface{}
+	for k, v := range t.Settings {
+		switch strings.ToLower(k) {
+		case "maxlevel":
+			maxLevel = v
+		case "levels":
+			levels = v
+		default:
+			settings[k] = v
+		}
+	}
+	b, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, target); err != nil {
+		return nil, err
+	}
+
+	if maxLevel != nil {
+		level, err := parseLevel(maxLevel)
+		if err != nil {
+			return nil, err
+		}
+		target.SetLevel(level)
+	}
+	if levels != nil {
+		list, ok := levels.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("levels must be a list")
+		}
+		ls := make([]log.Level, len(list))
+		for i, v := range list {
+			if ls[i], err = parseLevel(v); err != nil {
+				return nil, err
+			}
+		}
+		target.SetLevels(ls...)
+	}
+	return target, nil
+}
+
+// parseLevel accepts a level name or number.
+func parseLevel(v interface{}) (log.Level, error) {
+	switch l := v.(type) {
+	case string:
+		if level, ok := log.GetLevel(l); ok {
+			return level, nil
+		}
+	case float64:
+		return log.Level(l), nil
+	}
+	return 0, fmt.Errorf("unknown level %v", v)
+}
+
+// normalize converts the maps with non-string keys produced by some YAML decoders into maps with string keys.
+func normalize(v interface{}) interface{} {
+	switch m := v.(type) {
+	case map[interface{}]interface{}:
+		n := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			n[fmt.Sprint(k)] = normalize(v)
+		}
+		return n
+	case map[string]interface{}:
+		for k, v := range m {
+			m[k] = normalize(v)
+		}
+		return m
+	case []interface{}:
+		for i, v := range m {
+			m[i] = normalize(v)
+		}
+		return m
+	}
+	return v
+}
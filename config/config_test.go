@@ -0,0 +1,225 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config_test
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/admpub/log"
+	"github.com/admpub/log/config"
+)
+
+type MemoryTarget struct {
+	*log.Filter
+	Option1 string
+	Option2 int
+	entries []*log.Entry
+	ready   chan bool
+}
+
+func (m *MemoryTarget) Open(io.Writer) error {
+	m.Filter.Init()
+	return nil
+}
+
+func (m *MemoryTarget) Process(e *log.Entry) {
+	if e == nil {
+		m.ready <- true
+	} else if m.Allow(e) {
+		m.entries = append(m.entries, e)
+	}
+}
+
+func (m *MemoryTarget) Close() {
+	<-m.ready
+}
+
+func init() {
+	config.RegisterTarget("memory", func() log.Target {
+		return &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	})
+}
+
+func TestConfigure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log.json")
+	ioutil.WriteFile(path, []byte(`{
+		"level": "info",
+		"categoryLevels": {"app.db": "error"},
+		"formatter": "json",
+		"callStackDepth": 2,
+		"sync": true,
+		"targets": [
+			{"type": "memory", "option1": "abc", "Option2": 3, "maxLevel": "warn"},
+			{"type": "memory", "levels": ["Info"], "categories": ["app.*"]}
+		]
+	}`), 0644)
+
+	logger := log.NewLogger()
+	if err := config.Configure(logger, path); err != nil {
+		t.Fatalf("Configure(): %v", err)
+	}
+	defer logger.Close()
+
+	if logger.MaxLevel != log.LevelInfo || logger.CallStackDepth != 2 || !logger.SyncMode {
+		t.Errorf("logger = %v %v %v, expected Info, 2 and the synchronous mode", logger.MaxLevel, logger.CallStackDepth, logger.SyncMode)
+	}
+	if len(logger.Targets) != 2 {
+		t.Fatalf("len(logger.Targets) = %v, expected %v", len(logger.Targets), 2)
+	}
+	m1 := logger.Targets[0].(*MemoryTarget)
+	m2 := logger.Targets[1].(*MemoryTarget)
+	if m1.Option1 != "abc" || m1.Option2 != 3 || m1.MaxLevel != log.LevelWarn {
+		t.Errorf("m1 = %v %v %v, expected abc, 3 and Warn", m1.Option1, m1.Option2, m1.MaxLevel)
+	}
+
+	logger.Debug("d")
+	logger.Warn("w")
+	logger.GetLogger("app.web").Info("i")
+	logger.GetLogger("app.db").Warn("skipped")
+	if len(m1.entries) != 1 || m1.entries[0].Message != "w" {
+		t.Errorf("len(m1.entries) = %v, expected only w", len(m1.entries))
+	}
+	if len(m2.entries) != 1 || m2.entries[0].Message != "i" {
+		t.Errorf("len(m2.entries) = %v, expected only i", len(m2.entries))
+	}
+	if m2.entries[0].FormattedMessage[0] != '{' {
+		t.Errorf("FormattedMessage = %q, expected JSON", m2.entries[0].FormattedMessage)
+	}
+}
+
+func TestConfigureRoutes(t *testing.T) {
+	logger := log.NewLogger()
+	err := config.ConfigureBytes(logger, []byte(`{
+		"sync": true,
+		"targets": [
+			{"type": "memory", "option1": "all"},
+			{"type": "memory", "name": "errors"},
+			{"type": "memory", "name": "rest"}
+		],
+		"routes": [{"when": "level >= error AND category matches \"payment.*\"", "targets": ["errors"]}],
+		"defaultRoute": ["rest"]
+	}`), "json")
+	if err != nil {
+		t.Fatalf("ConfigureBytes(): %v", err)
+	}
+	if len(logger.Targets) != 2 {
+		t.Fatalf("len(logger.Targets) = %v, expected the unnamed target and the router", len(logger.Targets))
+	}
+	all := logger.Targets[0].(*MemoryTarget)
+	router := logger.Targets[1].(*log.RouterTarget)
+	logger.GetLogger("payment.card").Error("declined")
+	logger.GetLogger("payment.card").Info("charged")
+	logger.GetLogger("app").Error("failed")
+	logger.Close()
+
+	errors, rest := router.Targets["errors"].(*MemoryTarget), router.Targets["rest"].(*MemoryTarget)
+	if len(all.entries) != 3 || all.Option1 != "all" {
+		t.Errorf("len(all.entries) = %v, expected %v", len(all.entries), 3)
+	}
+	if len(errors.entries) != 1 || errors.entries[0].Message != "declined" {
+		t.Errorf("len(errors.entries) = %v, expected only declined", len(errors.entries))
+	}
+	if len(rest.entries) != 2 || rest.entries[0].Message != "charged" || rest.entries[1].Message != "failed" {
+		t.Errorf("len(rest.entries) = %v, expected charged and failed", len(rest.entries))
+	}
+}
+
+func TestConfigureInvalid(t *testing.T) {
+	logger := log.NewLogger()
+	defer logger.Close()
+	targets := logger.Targets
+
+	tests := []string{
+		`{"level": "verbose"}`,
+		`{"formatter": "xml"}`,
+		`{"categoryLevels": {"app": "loud"}}`,
+		`{"targets": [{"type": "memory"}, {"type": "carrier-pigeon"}]}`,
+		`{"targets": [{"type": "memory", "maxLevel": "loud"}]}`,
+		`{"targets": [`,
+		`{"level": "info", "targets": []}`,
+		`{"targets": [{"type": "memory", "name": "m"}], "routes": [{"when": "level >= loud", "targets": ["m"]}]}`,
+		`{"targets": [{"type": "memory", "name": "m"}], "routes": [{"targets": ["n"]}]}`,
+		`{"targets": [{"type": "memory", "name": "m"}, {"type": "memory", "name": "m"}], "defaultRoute": ["m"]}`,
+	}
+	for _, test := range tests {
+		if err := config.ConfigureBytes(logger, []byte(test), "json"); err == nil {
+			t.Errorf("ConfigureBytes(%v) = nil, expected an error", test)
+		}
+	}
+	if len(logger.Targets) != len(targets) || logger.Targets[0] != targets[0] {
+		t.Errorf("the targets were changed by an invalid configuration")
+	}
+	if _, err := config.Parse([]byte(`level = "info"`), "ini"); err == nil {
+		t.Errorf("Parse() = nil, expected an error for an unregistered format")
+	}
+}
+
+func TestParseFormats(t *testing.T) {
+	docs := map[string]string{
+		"json": `{"level": "warn", "categoryLevels": {"app.db": "error"}, "targets": [{"type": "memory", "option1": "abc", "maxLevel": "info"}]}`,
+		"yaml": `
+level: warn
+categoryLevels:
+  app.db: error
+targets:
+  - type: memory
+    option1: abc
+    maxLevel: info
+`,
+		"toml": `
+level = "warn"
+
+[categoryLevels]
+"app.db" = "error"
+
+[[targets]]
+type = "memory"
+option1 = "abc"
+maxLevel = "info"
+`,
+	}
+	for format, doc := range docs {
+		c, err := config.Parse([]byte(doc), format)
+		if err != nil {
+			t.Errorf("Parse(%v): %v", format, err)
+			continue
+		}
+		if c.Level != "warn" || c.CategoryLevels["app.db"] != "error" {
+			t.Errorf("Parse(%v) = %+v, expected level warn and app.db at error", format, c)
+		}
+		if len(c.Targets) != 1 || c.Targets[0].Type != "memory" || c.Targets[0].Settings["option1"] != "abc" || c.Targets[0].Settings["maxLevel"] != "info" {
+			t.Errorf("Parse(%v).Targets = %+v, expected a memory target", format, c.Targets)
+		}
+	}
+}
+
+func TestRegisterFormat(t *testing.T) {
+	// a stand-in for a YAML decoder, which produces maps with interface keys
+	config.RegisterFormat("fake", func(data []byte, v interface{}) error {
+		*(v.(*interface{})) = map[interface{}]interface{}{
+			"level": "error",
+			"targets": []interface{}{
+				map[interface{}]interface{}{"type": "memory", "option1": string(data)},
+			},
+		}
+		return nil
+	})
+	c, err := config.Parse([]byte("xyz"), "FAKE")
+	if err != nil {
+		t.Fatalf("Parse(): %v", err)
+	}
+	if c.Level != "error" || len(c.Targets) != 1 || c.Targets[0].Type != "memory" || c.Targets[0].Settings["option1"] != "xyz" {
+		t.Errorf("Parse() = %+v, expected level error and a memory target", c)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+// Watcher reloads a configuration file when it changes and applies it to a logger.
+// The logger keeps running while its level, formatter and targets are replaced, so no messages are dropped.
+// An invalid configuration is reported to the ErrorWriter of the logger and the logger is left as is.
+type Watcher struct {
+	Interval time.Duration   // how often the file is checked for changes
+	OnReload func(err error) // called after each attempt to apply the changed file, if set
+
+	logger *log.Logger
+	path   string
+	data   []byte
+	stop   chan bool
+	wg     sync.WaitGroup
+}
+
+// NewWatcher creates a Watcher of the configuration file at path.
+// The new Watcher takes these default options:
+// Interval: 2 seconds.
+func NewWatcher(logger *log.Logger, path string) *Watcher {
+	return &Watcher{
+		Interval: 2 * time.Second,
+		logger:   logger,
+		path:     path,
+	}
+}
+
+// Start applies the configuration file and starts watching it.
+// It returns an error without watching the file if the configuration cannot be applied.
+func (w *Watcher) Start() error {
+	if w.Interval <= 0 {
+		return fmt.Errorf("Watcher.Interval must be greater than 0")
+	}
+	data, err := ioutil.ReadFile(w.path)
+	if err != nil {
+		return err
+	}
+	if err := w.apply(data); err != nil {
+		return err
+	}
+	w.data = data
+	w.stop = make(chan bool)
+	w.wg.Add(1)
+	go w.watch()
+	return nil
+}
+
+// Stop stops watching the configuration file.
+func (w *Watcher) Stop() {
+	close(w.stop)
+	w.wg.Wait()
+}
+
+func (w *Watcher) watch() {
+	defer w.wg.Done()
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+		data, err := ioutil.ReadFile(w.path)
+		if err != nil || bytes.Equal(data, w.data) {
+			// the file may be missing for a moment while it is being replaced
+			continue
+		}
+		w.data = data
+		err = w.apply(data)
+		if err != nil {
+			fmt.Fprintf(w.logger.ErrorWriter, "Failed to reload the log configuration %v: %v\n", w.path, err)
+		}
+		if w.OnReload != nil {
+			w.OnReload(err)
+		}
+	}
+}
+
+func (w *Watcher) apply(data []byte) error {
+	c, err := Parse(data, strings.TrimPrefix(filepath.Ext(w.path), "."))
+	if err != nil {
+		return err
+	}
+	return c.Apply(w.logger)
+}
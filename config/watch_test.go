@@ -0,0 +1,154 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+	"github.com/admpub/log/config"
+)
+
+// lockedBuffer is a bytes.Buffer safe for concurrent use.
+type lockedBuffer struct {
+	sync.Mutex
+	bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.Buffer.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.Buffer.String()
+}
+
+func TestWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logwatch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log.json")
+	ioutil.WriteFile(path, []byte(`{"level": "error", "sync": true, "targets": [{"type": "memory"}]}`), 0644)
+
+	errOut := &lockedBuffer{}
+	logger := log.NewLogger()
+	logger.ErrorWriter = errOut
+	defer logger.Close()
+
+	w := config.NewWatcher(logger, path)
+	w.Interval = 10 * time.Millisecond
+	reloaded := make(chan error, 10)
+	w.OnReload = func(err error) {
+		reloaded <- err
+	}
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start(): %v", err)
+	}
+	defer w.Stop()
+	if !logger.Enabled(log.LevelError) || logger.Enabled(log.LevelWarn) {
+		t.Errorf("the level was not applied")
+	}
+
+	ioutil.WriteFile(path, []byte(`{"level": "error", "targets": [`), 0644)
+	select {
+	case err := <-reloaded:
+		if err == nil {
+			t.Errorf("OnReload(nil), expected an error for the invalid configuration")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the invalid configuration was not reloaded")
+	}
+	if !strings.Contains(errOut.String(), "Failed to reload the log configuration") {
+		t.Errorf("the error output is %q, expected the reload error", errOut.String())
+	}
+
+	ioutil.WriteFile(path, []byte(`{"level": "debug", "sync": true, "targets": [{"type": "memory"}]}`), 0644)
+	select {
+	case err := <-reloaded:
+		if err != nil {
+			t.Errorf("OnReload(%v), expected nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the configuration was not reloaded")
+	}
+	if !logger.Enabled(log.LevelDebug) {
+		t.Errorf("the new level was not applied")
+	}
+}
+
+func TestWatcherReloadWhileLogging(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logwatch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log.json")
+	ioutil.WriteFile(path, []byte(`{"categoryLevels": {"app.db": "error"}, "callStackDepth": 2, "targets": [{"type": "memory"}]}`), 0644)
+
+	logger := log.NewLogger()
+	logger.ErrorWriter = &lockedBuffer{}
+	defer logger.Close()
+	w := config.NewWatcher(logger, path)
+	w.Interval = 10 * time.Millisecond
+	reloaded := make(chan error, 10)
+	w.OnReload = func(err error) {
+		reloaded <- err
+	}
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start(): %v", err)
+	}
+	defer w.Stop()
+	if level := logger.EffectiveLevel("app.db"); level != log.LevelError {
+		t.Errorf("EffectiveLevel(app.db) = %v, expected %v", level, log.LevelError)
+	}
+
+	done := make(chan bool)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		db := logger.GetLogger("app.db")
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				db.Error("t1")
+			}
+		}
+	}()
+	// the call stack options and the category levels are replaced while messages are logged
+	ioutil.WriteFile(path, []byte(`{"callStackDepth": 5, "callStackExcludes": ["testing.go"], "targets": [{"type": "memory"}]}`), 0644)
+	select {
+	case err := <-reloaded:
+		if err != nil {
+			t.Errorf("OnReload(%v), expected nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the configuration was not reloaded")
+	}
+	close(done)
+	wg.Wait()
+
+	if level := logger.EffectiveLevel("app.db"); level != log.LevelDebug {
+		t.Errorf("EffectiveLevel(app.db) = %v, expected the level removed from the configuration", level)
+	}
+	if logger.CallStackDepth != 5 || len(logger.CallStackExcludes) != 1 {
+		t.Errorf("CallStackDepth = %v, CallStackExcludes = %v, expected the reloaded options", logger.CallStackDepth, logger.CallStackExcludes)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
+	"unicode/utf8"
 
 	ct "github.com/admpub/go-colortext"
 )
@@ -19,11 +21,15 @@ type colorSetting struct {
 }
 
 var colorBrushes = map[Level]colorSetting{
-	LevelDebug: colorSetting{ct.Cyan, true},    // cyan
-	LevelInfo:  colorSetting{ct.Green, true},   // green
-	LevelWarn:  colorSetting{ct.Yellow, true},  // yellow
-	LevelError: colorSetting{ct.Red, true},     // red
-	LevelFatal: colorSetting{ct.Magenta, true}, // magenta
+	LevelDebug:     colorSetting{ct.Cyan, true},    // cyan
+	LevelInfo:      colorSetting{ct.Green, true},   // green
+	LevelWarn:      colorSetting{ct.Yellow, true},  // yellow
+	LevelError:     colorSetting{ct.Red, true},     // red
+	LevelFatal:     colorSetting{ct.Magenta, true}, // magenta
+	LevelTrace:     colorSetting{ct.Blue, true},    // blue
+	LevelCritical:  colorSetting{ct.Magenta, true}, // magenta
+	LevelAlert:     colorSetting{ct.Magenta, true}, // magenta
+	LevelEmergency: colorSetting{ct.Magenta, true}, // magenta
 }
 
 // ConsoleTarget writes filtered log messages to console window.
@@ -31,7 +37,13 @@ type ConsoleTarget struct {
 	*Filter
 	ColorMode bool      // whether to use colors to differentiate log levels
 	Writer    io.Writer // the writer to write log messages
-	close     chan bool
+	// the widths the level and the category of the messages written by the text formatters are padded to,
+	// so that the messages of different categories are aligned. A longer category is shortened to its end,
+	// preceded by "...". Zero leaves them as they are.
+	LevelWidth    int
+	CategoryWidth int
+
+	close chan bool
 }
 
 // NewConsoleTarget creates a ConsoleTarget.
@@ -65,6 +77,9 @@ func (t *ConsoleTarget) Process(e *Entry) {
 		return
 	}
 	msg := e.String()
+	if t.LevelWidth > 0 || t.CategoryWidth > 0 {
+		msg = t.align(e, msg)
+	}
 	if t.ColorMode {
 		if t.Colorize(e.Level) {
 			defer ct.ResetColor()
@@ -73,6 +88,42 @@ func (t *ConsoleTarget) Process(e *Entry) {
 	fmt.Fprintln(t.Writer, msg)
 }
 
+// align pads the level and the category of a message formatted by a text formatter, which writes
+// them between vertical bars. The other messages are returned as they are.
+func (t *ConsoleTarget) align(e *Entry, msg string) string {
+	level := e.Level.String()
+	columns := "|" + level + "|" + e.Category + "|"
+	i := strings.Index(msg, columns)
+	if i < 0 || strings.IndexByte(msg[:i], '\n') >= 0 {
+		return msg
+	}
+	category := e.Category
+	if n := utf8.RuneCountInString(category); t.CategoryWidth > 3 && n > t.CategoryWidth {
+		// keep the end of the category, which is the most specific
+		for ; n > t.CategoryWidth-3; n-- {
+			_, size := utf8.DecodeRuneInString(category)
+			category = category[size:]
+		}
+		category = "..." + category
+	}
+	buf := make([]byte, 0, len(msg)+t.LevelWidth+t.CategoryWidth)
+	buf = append(buf, msg[:i+1]...)
+	buf = appendPadded(buf, level, t.LevelWidth)
+	buf = append(buf, '|')
+	buf = appendPadded(buf, category, t.CategoryWidth)
+	buf = append(buf, '|')
+	return string(append(buf, msg[i+len(columns):]...))
+}
+
+// appendPadded appends a string padded with spaces to the width.
+func appendPadded(buf []byte, s string, width int) []byte {
+	buf = append(buf, s...)
+	for n := utf8.RuneCountInString(s); n < width; n++ {
+		buf = append(buf, ' ')
+	}
+	return buf
+}
+
 func (t *ConsoleTarget) Colorize(level Level) bool {
 	cs, ok := colorBrushes[level]
 	if ok {
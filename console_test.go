@@ -90,3 +90,38 @@ func TestConsoleTargetAddSpace(t *testing.T) {
 		t.Errorf("Expected %q not found from `%q`", "a b c", string(writer.bytes))
 	}
 }
+
+func TestConsoleTargetAlign(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	target := &ConsoleTargetMock{
+		done:          make(chan bool, 0),
+		ConsoleTarget: log.NewConsoleTarget(),
+	}
+	writer := &MemoryWriter{}
+	target.Writer = writer
+	target.ColorMode = false
+	target.LevelWidth = 5
+	target.CategoryWidth = 8
+	logger.SetTarget(target)
+	logger.GetLogger("db").Info("t1")
+	logger.GetLogger("app.models.user").Error("t2")
+	logger.GetLogger("app", log.JSONFormatter).Warn("t3")
+
+	logger.Close()
+	<-target.done
+
+	lines := strings.Split(strings.TrimSuffix(string(writer.bytes), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("lines = %q, expected 3 lines", lines)
+	}
+	if !strings.HasSuffix(lines[0], "|Info |db      |t1") {
+		t.Errorf("lines[0] = %q, expected the level and the category padded", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "|Error|....user|t2") {
+		t.Errorf("lines[1] = %q, expected the category shortened", lines[1])
+	}
+	if !strings.HasPrefix(lines[2], "{") {
+		t.Errorf("lines[2] = %q, expected the JSON message as it is", lines[2])
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package echolog provides an echo middleware logging the requests through a logger.
+package echolog
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/admpub/log"
+	"github.com/labstack/echo/v4"
+)
+
+// Middleware returns an echo middleware which replaces middleware.Logger and middleware.Recover:
+//
+//	e := echo.New()
+//	e.Use(echolog.Middleware(logger))
+//
+// It logs one message per request under the "echo" category with the method, path, uri, proto, route,
+// status, latency, size, client_ip, referer and user_agent fields, and the error returned by the handler.
+// log.AccessLogFormatter writes the messages as an access log in the combined log format.
+// The error is passed to the HTTP error handler of echo before logging, so that the logged status is the one sent.
+// The requests answered with a 4xx status are logged as warnings and those with a 5xx status as errors.
+// A panic in a handler is recovered, answered with status 500 and logged as an error with its call stack.
+func Middleware(l *log.Logger) echo.MiddlewareFunc {
+	logger := l.GetLogger("echo")
+	return func(h echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			panicked, stack, err := next(h, c)
+			if err != nil {
+				c.Error(err)
+			}
+
+			req, res := c.Request(), c.Response()
+			fields := log.Fields{
+				"method":     req.Method,
+				"path":       req.URL.Path,
+				"uri":        req.RequestURI,
+				"proto":      req.Proto,
+				"referer":    req.Referer(),
+				"user_agent": req.UserAgent(),
+				"route":      c.Path(),
+				"status":     res.Status,
+				"latency":    time.Since(start).String(),
+				"size":       res.Size,
+				"client_ip":  c.RealIP(),
+			}
+			level := log.LevelInfo
+			message := fmt.Sprintf("%v %v", req.Method, req.URL.Path)
+			if panicked != nil {
+				level, message = log.LevelError, fmt.Sprintf("panic: %v", panicked)
+				fields["stack"] = stack
+			} else if res.Status >= http.StatusInternalServerError {
+				level = log.LevelError
+			} else if res.Status >= http.StatusBadRequest {
+				level = log.LevelWarn
+			}
+			rl := logger.WithContext(req.Context()).WithFields(fields)
+			if err != nil && panicked == nil {
+				rl = rl.WithError(err)
+			}
+			rl.Log(level, message)
+			return nil
+		}
+	}
+}
+
+// next calls the handler and turns its panic into an error.
+func next(h echo.HandlerFunc, c echo.Context) (panicked interface{}, stack string, err error) {
+	defer func() {
+		if panicked = recover(); panicked != nil {
+			// skip GetCallStack, this function and the runtime, so that the stack starts at the panic
+			stack = log.GetCallStack(3, 20, "")
+			err = echo.NewHTTPError(http.StatusInternalServerError)
+		}
+	}()
+	err = h(c)
+	return
+}
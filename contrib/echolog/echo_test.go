@@ -0,0 +1,87 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package echolog_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/admpub/log"
+	"github.com/admpub/log/contrib/echolog"
+	"github.com/labstack/echo/v4"
+)
+
+type memoryTarget struct {
+	*log.Filter
+	entries []*log.Entry
+	ready   chan bool
+}
+
+func (m *memoryTarget) Open(io.Writer) error {
+	return nil
+}
+
+func (m *memoryTarget) Process(e *log.Entry) {
+	if e == nil {
+		m.ready <- true
+	} else {
+		m.entries = append(m.entries, e)
+	}
+}
+
+func (m *memoryTarget) Close() {
+	<-m.ready
+}
+
+func TestMiddleware(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	target := &memoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	defer logger.Close()
+
+	e := echo.New()
+	e.Use(echolog.Middleware(logger))
+	e.GET("/users/:id", func(c echo.Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+	e.GET("/fail", func(c echo.Context) error {
+		return &echo.HTTPError{Code: http.StatusForbidden, Message: "denied"}
+	})
+	e.GET("/panic", func(c echo.Context) error {
+		panic("boom")
+	})
+
+	for _, path := range []string{"/users/1", "/fail", "/panic"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if len(target.entries) != 3 {
+		t.Fatalf("len(target.entries) = %v, expected %v", len(target.entries), 3)
+	}
+	entry := target.entries[0]
+	if entry.Level != log.LevelInfo || entry.Message != "GET /users/1" || entry.Category != "echo" {
+		t.Errorf("entries[0] = %v %q %v, expected Info \"GET /users/1\" echo", entry.Level, entry.Message, entry.Category)
+	}
+	if entry.Fields["route"] != "/users/:id" || entry.Fields["status"] != 200 || entry.Fields["size"] != int64(2) || entry.Fields["client_ip"] != "10.0.0.1" {
+		t.Errorf("entries[0].Fields = %v, unexpected", entry.Fields)
+	}
+	entry = target.entries[1]
+	if entry.Level != log.LevelWarn || entry.Fields["status"] != 403 || entry.Error == nil || !strings.Contains(entry.Error.Error(), "denied") {
+		t.Errorf("entries[1] = %v %v %v, expected Warn 403 denied", entry.Level, entry.Fields["status"], entry.Error)
+	}
+	entry = target.entries[2]
+	if entry.Level != log.LevelError || entry.Message != "panic: boom" || entry.Fields["status"] != 500 {
+		t.Errorf("entries[2] = %v %q %v, expected Error \"panic: boom\" 500", entry.Level, entry.Message, entry.Fields["status"])
+	}
+	if stack, _ := entry.Fields["stack"].(string); !strings.Contains(stack, "echo_test.go") {
+		t.Errorf("entries[2].Fields[stack] = %q, expected the panicking handler", stack)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package ginlog provides a gin middleware logging the requests through a logger.
+package ginlog
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/admpub/log"
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware returns a gin middleware which replaces gin.Logger and gin.Recovery:
+//
+//	router := gin.New()
+//	router.Use(ginlog.Middleware(logger))
+//
+// It logs one message per request under the "gin" category with the method, path, uri, proto, route,
+// status, latency, size, client_ip, referer and user_agent fields, and the last error attached to the gin context.
+// log.AccessLogFormatter writes the messages as an access log in the combined log format.
+// The requests answered with a 4xx status are logged as warnings and those with a 5xx status as errors.
+// A panic in a handler is recovered, answered with status 500 and logged as an error with its call stack.
+func Middleware(l *log.Logger) gin.HandlerFunc {
+	logger := l.GetLogger("gin")
+	return func(c *gin.Context) {
+		start := time.Now()
+		panicked, stack := next(c)
+
+		status := c.Writer.Status()
+		size := c.Writer.Size()
+		if size < 0 {
+			size = 0
+		}
+		fields := log.Fields{
+			"method":     c.Request.Method,
+			"path":       c.Request.URL.Path,
+			"uri":        c.Request.RequestURI,
+			"proto":      c.Request.Proto,
+			"referer":    c.Request.Referer(),
+			"user_agent": c.Request.UserAgent(),
+			"route":      c.FullPath(),
+			"status":     status,
+			"latency":    time.Since(start).String(),
+			"size":       size,
+			"client_ip":  c.ClientIP(),
+		}
+		level := log.LevelInfo
+		message := fmt.Sprintf("%v %v", c.Request.Method, c.Request.URL.Path)
+		if panicked != nil {
+			level, message = log.LevelError, fmt.Sprintf("panic: %v", panicked)
+			fields["stack"] = stack
+		} else if status >= http.StatusInternalServerError {
+			level = log.LevelError
+		} else if status >= http.StatusBadRequest {
+			level = log.LevelWarn
+		}
+		rl := logger.WithContext(c.Request.Context()).WithFields(fields)
+		if err := c.Errors.Last(); err != nil {
+			rl = rl.WithError(err.Err)
+		}
+		rl.Log(level, message)
+	}
+}
+
+// next calls the remaining handlers and recovers their panic.
+func next(c *gin.Context) (panicked interface{}, stack string) {
+	defer func() {
+		if panicked = recover(); panicked != nil {
+			// skip GetCallStack, this function and the runtime, so that the stack starts at the panic
+			stack = log.GetCallStack(3, 20, "")
+			c.AbortWithStatus(http.StatusInternalServerError)
+		}
+	}()
+	c.Next()
+	return
+}
@@ -0,0 +1,93 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ginlog_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/admpub/log"
+	"github.com/admpub/log/contrib/ginlog"
+	"github.com/gin-gonic/gin"
+)
+
+type memoryTarget struct {
+	*log.Filter
+	entries []*log.Entry
+	ready   chan bool
+}
+
+func (m *memoryTarget) Open(io.Writer) error {
+	return nil
+}
+
+func (m *memoryTarget) Process(e *log.Entry) {
+	if e == nil {
+		m.ready <- true
+	} else {
+		m.entries = append(m.entries, e)
+	}
+}
+
+func (m *memoryTarget) Close() {
+	<-m.ready
+}
+
+func TestMiddleware(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	target := &memoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	defer logger.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ginlog.Middleware(logger))
+	router.GET("/users/:id", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	router.GET("/fail", func(c *gin.Context) {
+		c.Error(errors.New("denied"))
+		c.String(http.StatusForbidden, "no")
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	for _, path := range []string{"/users/1", "/fail", "/panic"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if len(target.entries) != 3 {
+		t.Fatalf("len(target.entries) = %v, expected %v", len(target.entries), 3)
+	}
+	e := target.entries[0]
+	if e.Level != log.LevelInfo || e.Message != "GET /users/1" || e.Category != "gin" {
+		t.Errorf("entries[0] = %v %q %v, expected Info \"GET /users/1\" gin", e.Level, e.Message, e.Category)
+	}
+	if e.Fields["route"] != "/users/:id" || e.Fields["status"] != 200 || e.Fields["size"] != 2 || e.Fields["client_ip"] != "10.0.0.1" {
+		t.Errorf("entries[0].Fields = %v, unexpected", e.Fields)
+	}
+	if line := log.AccessLogFormatter(logger, e); !strings.HasPrefix(line, `10.0.0.1 - - [`) || !strings.HasSuffix(line, `] "GET /users/1 HTTP/1.1" 200 2 "-" "-"`) {
+		t.Errorf("AccessLogFormatter() = %q, unexpected", line)
+	}
+	e = target.entries[1]
+	if e.Level != log.LevelWarn || e.Fields["status"] != 403 || e.Error == nil || e.Error.Error() != "denied" {
+		t.Errorf("entries[1] = %v %v %v, expected Warn 403 denied", e.Level, e.Fields["status"], e.Error)
+	}
+	e = target.entries[2]
+	if e.Level != log.LevelError || e.Message != "panic: boom" || e.Fields["status"] != 500 {
+		t.Errorf("entries[2] = %v %q %v, expected Error \"panic: boom\" 500", e.Level, e.Message, e.Fields["status"])
+	}
+	if stack, _ := e.Fields["stack"].(string); !strings.Contains(stack, "gin_test.go") {
+		t.Errorf("entries[2].Fields[stack] = %q, expected the panicking handler", stack)
+	}
+}
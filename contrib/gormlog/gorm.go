@@ -0,0 +1,116 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package gormlog routes the logging of GORM through a logger.
+package gormlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/admpub/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/utils"
+)
+
+var _ logger.Interface = (*GORMLogger)(nil)
+
+// GORMLogger implements the logger of GORM. It logs each SQL statement with its rows affected and
+// duration as fields, and the statements slower than SlowThreshold as warnings:
+//
+//	db, err := gorm.Open(dialector, &gorm.Config{Logger: gormlog.NewGORMLogger(logger)})
+type GORMLogger struct {
+	// the logger of the "gorm" category.
+	Logger *log.Logger
+	// the level of the SQL statements which are neither slow nor failed.
+	QueryLevel log.Level
+	// the duration from which a SQL statement is logged as a warning. Zero disables it.
+	SlowThreshold time.Duration
+	// whether the failed statements which found no record are logged as the other statements.
+	IgnoreRecordNotFoundError bool
+	// the level set by GORM through LogMode. The messages are also filtered by the levels of the logger.
+	LogLevel logger.LogLevel
+}
+
+// NewGORMLogger creates a GORMLogger logging through the "gorm" category of the given logger.
+// The new GORMLogger takes these default options:
+// QueryLevel: LevelDebug, SlowThreshold: 200ms, IgnoreRecordNotFoundError: true, LogLevel: logger.Info.
+func NewGORMLogger(l *log.Logger) *GORMLogger {
+	return &GORMLogger{
+		Logger:                    l.GetLogger("gorm"),
+		QueryLevel:                log.LevelDebug,
+		SlowThreshold:             200 * time.Millisecond,
+		IgnoreRecordNotFoundError: true,
+		LogLevel:                  logger.Info,
+	}
+}
+
+// LogMode returns a copy of the GORMLogger with the given level.
+func (g *GORMLogger) LogMode(level logger.LogLevel) logger.Interface {
+	c := *g
+	c.LogLevel = level
+	return &c
+}
+
+func (g *GORMLogger) Info(ctx context.Context, format string, args ...interface{}) {
+	if g.LogLevel >= logger.Info {
+		g.Logger.WithContext(ctx).Infof(format, args...)
+	}
+}
+
+func (g *GORMLogger) Warn(ctx context.Context, format string, args ...interface{}) {
+	if g.LogLevel >= logger.Warn {
+		g.Logger.WithContext(ctx).Warnf(format, args...)
+	}
+}
+
+func (g *GORMLogger) Error(ctx context.Context, format string, args ...interface{}) {
+	if g.LogLevel >= logger.Error {
+		g.Logger.WithContext(ctx).Errorf(format, args...)
+	}
+}
+
+// Trace logs a SQL statement executed by GORM.
+func (g *GORMLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if g.LogLevel <= logger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	var (
+		level   log.Level
+		message string
+	)
+	switch {
+	case err != nil && g.LogLevel >= logger.Error && !(g.IgnoreRecordNotFoundError && errors.Is(err, gorm.ErrRecordNotFound)):
+		level, message = log.LevelError, "SQL error"
+	case g.SlowThreshold > 0 && elapsed > g.SlowThreshold && g.LogLevel >= logger.Warn:
+		level, message = log.LevelWarn, fmt.Sprintf("Slow SQL >= %v", g.SlowThreshold)
+	case g.LogLevel >= logger.Info:
+		level, message = g.QueryLevel, "SQL"
+	default:
+		return
+	}
+	if !g.Logger.Enabled(level) {
+		return
+	}
+
+	sql, rows := fc()
+	fields := log.Fields{
+		"sql":     sql,
+		"elapsed": elapsed.String(),
+		"source":  utils.FileWithLineNum(),
+	}
+	// GORM reports -1 when the number of rows is unknown
+	if rows >= 0 {
+		fields["rows"] = rows
+	}
+	l := g.Logger.WithContext(ctx).WithFields(fields)
+	if level == log.LevelError {
+		l = l.WithError(err)
+	}
+	l.Log(level, message)
+}
@@ -0,0 +1,94 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gormlog_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+	"github.com/admpub/log/contrib/gormlog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type memoryTarget struct {
+	*log.Filter
+	entries []*log.Entry
+	ready   chan bool
+}
+
+func (m *memoryTarget) Open(io.Writer) error {
+	return nil
+}
+
+func (m *memoryTarget) Process(e *log.Entry) {
+	if e == nil {
+		m.ready <- true
+	} else if m.Allow(e) {
+		m.entries = append(m.entries, e)
+	}
+}
+
+func (m *memoryTarget) Close() {
+	<-m.ready
+}
+
+func TestGORMLogger(t *testing.T) {
+	l := log.NewLogger()
+	l.Sync()
+	target := &memoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	l.SetTarget(target)
+	defer l.Close()
+
+	g := gormlog.NewGORMLogger(l)
+	ctx := context.Background()
+	query := func() (string, int64) { return "SELECT 1", 1 }
+	g.Trace(ctx, time.Now(), query, nil)
+	g.Trace(ctx, time.Now().Add(-time.Second), query, nil)
+	g.Trace(ctx, time.Now(), func() (string, int64) { return "DELETE", -1 }, errors.New("locked"))
+	g.Trace(ctx, time.Now(), query, gorm.ErrRecordNotFound)
+	g.LogMode(logger.Warn).Trace(ctx, time.Now(), query, nil)
+	g.LogMode(logger.Silent).Warn(ctx, "t%v", 1)
+	g.Warn(ctx, "t%v", 2)
+
+	expected := []struct {
+		level   log.Level
+		message string
+	}{
+		{log.LevelDebug, "SQL"},
+		{log.LevelWarn, "Slow SQL >= 200ms"},
+		{log.LevelError, "SQL error"},
+		{log.LevelDebug, "SQL"},
+		{log.LevelWarn, "t2"},
+	}
+	if len(target.entries) != len(expected) {
+		t.Fatalf("len(target.entries) = %v, expected %v", len(target.entries), len(expected))
+	}
+	for i, e := range target.entries {
+		if e.Level != expected[i].level || e.Message != expected[i].message || e.Category != "gorm" {
+			t.Errorf("entries[%v] = %v %q %v, expected %v %q gorm", i, e.Level, e.Message, e.Category, expected[i].level, expected[i].message)
+		}
+	}
+	if e := target.entries[0]; e.Fields["sql"] != "SELECT 1" || e.Fields["rows"] != int64(1) {
+		t.Errorf("entries[0].Fields = %v, expected sql and rows", e.Fields)
+	}
+	if e := target.entries[2]; e.Error == nil || e.Error.Error() != "locked" {
+		t.Errorf("entries[2].Error = %v, expected locked", e.Error)
+	}
+	if _, ok := target.entries[2].Fields["rows"]; ok {
+		t.Errorf("entries[2].Fields has rows, expected none when the number is unknown")
+	}
+
+	// the statements not enabled in the logger are not formatted
+	l.SetMaxLevel(log.LevelInfo)
+	g.Trace(ctx, time.Now(), func() (string, int64) {
+		t.Errorf("the SQL statement is built although debug messages are not logged")
+		return "", 0
+	}, nil)
+}
@@ -0,0 +1,92 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package logrlog implements logr.LogSink, so that the libraries logging through logr log through a logger.
+package logrlog
+
+import (
+	"fmt"
+
+	"github.com/admpub/log"
+	"github.com/go-logr/logr"
+)
+
+var _ logr.LogSink = (*LogSink)(nil)
+
+// LogSink logs the messages of logr under the "logr" category of a logger. The names given by
+// logr.Logger.WithName are appended to the category, and the key/value pairs become fields.
+// The messages of verbosity 0 are logged as information, and the more verbose messages as debug messages.
+type LogSink struct {
+	logger *log.Logger
+}
+
+// NewLogSink creates a LogSink logging through the "logr" category of the given logger.
+func NewLogSink(l *log.Logger) *LogSink {
+	return &LogSink{logger: l.GetLogger("logr")}
+}
+
+// New creates a logr.Logger logging through the given logger:
+//
+//	ctrl.SetLogger(logrlog.New(logger))
+func New(l *log.Logger) logr.Logger {
+	return logr.New(NewLogSink(l))
+}
+
+// Init does nothing, as the call stacks are not adjusted for logr.
+func (s *LogSink) Init(info logr.RuntimeInfo) {
+}
+
+// Enabled reports whether the messages of the given verbosity are logged.
+func (s *LogSink) Enabled(level int) bool {
+	return s.logger.Enabled(levelOf(level))
+}
+
+// Info logs a message of the given verbosity.
+func (s *LogSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	s.withValues(keysAndValues).Log(levelOf(level), msg)
+}
+
+// Error logs an error message.
+func (s *LogSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	l := s.withValues(keysAndValues)
+	if err != nil {
+		l = l.WithError(err)
+	}
+	l.Log(log.LevelError, msg)
+}
+
+// WithValues returns a LogSink attaching the given key/value pairs to its messages.
+func (s *LogSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	return &LogSink{logger: s.withValues(keysAndValues)}
+}
+
+// WithName returns a LogSink whose category is the category of s followed by the given name.
+func (s *LogSink) WithName(name string) logr.LogSink {
+	return &LogSink{logger: s.logger.GetLogger(s.logger.Category + "." + name)}
+}
+
+func (s *LogSink) withValues(keysAndValues []interface{}) *log.Logger {
+	if len(keysAndValues) == 0 {
+		return s.logger
+	}
+	fields := make(log.Fields, (len(keysAndValues)+1)/2)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key := fmt.Sprint(keysAndValues[i])
+		if i+1 < len(keysAndValues) {
+			fields[key] = keysAndValues[i+1]
+		} else {
+			// logr reports the keys without value this way
+			fields[key] = "<no-value>"
+		}
+	}
+	return s.logger.WithFields(fields)
+}
+
+// levelOf maps a logr verbosity to a level.
+func levelOf(verbosity int) log.Level {
+	if verbosity > 0 {
+		return log.LevelDebug
+	}
+	return log.LevelInfo
+}
@@ -0,0 +1,80 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logrlog_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/admpub/log"
+	"github.com/admpub/log/contrib/logrlog"
+)
+
+type memoryTarget struct {
+	*log.Filter
+	entries []*log.Entry
+	ready   chan bool
+}
+
+func (m *memoryTarget) Open(io.Writer) error {
+	return nil
+}
+
+func (m *memoryTarget) Process(e *log.Entry) {
+	if e == nil {
+		m.ready <- true
+	} else {
+		m.entries = append(m.entries, e)
+	}
+}
+
+func (m *memoryTarget) Close() {
+	<-m.ready
+}
+
+func TestLogSink(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	target := &memoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	defer logger.Close()
+	logger.SetMaxLevel(log.LevelDebug)
+
+	l := logrlog.New(logger).WithName("manager").WithValues("controller", "pod")
+	l.Info("t1", "count", 2)
+	l.V(1).Info("t2", "odd")
+	l.Error(errors.New("failed"), "t3")
+
+	expected := []struct {
+		level   log.Level
+		message string
+	}{{log.LevelInfo, "t1"}, {log.LevelDebug, "t2"}, {log.LevelError, "t3"}}
+	if len(target.entries) != len(expected) {
+		t.Fatalf("len(target.entries) = %v, expected %v", len(target.entries), len(expected))
+	}
+	for i, e := range target.entries {
+		if e.Level != expected[i].level || e.Message != expected[i].message || e.Category != "logr.manager" {
+			t.Errorf("entries[%v] = %v %q %v, expected %v %q logr.manager", i, e.Level, e.Message, e.Category, expected[i].level, expected[i].message)
+		}
+		if e.Fields["controller"] != "pod" {
+			t.Errorf("entries[%v].Fields = %v, expected controller=pod", i, e.Fields)
+		}
+	}
+	if target.entries[0].Fields["count"] != 2 {
+		t.Errorf("entries[0].Fields = %v, expected count=2", target.entries[0].Fields)
+	}
+	if target.entries[1].Fields["odd"] != "<no-value>" {
+		t.Errorf("entries[1].Fields = %v, expected odd=<no-value>", target.entries[1].Fields)
+	}
+	if e := target.entries[2].Error; e == nil || e.Error() != "failed" {
+		t.Errorf("entries[2].Error = %v, expected failed", e)
+	}
+
+	logger.SetMaxLevel(log.LevelInfo)
+	if !l.Enabled() || l.V(1).Enabled() {
+		t.Errorf("V(1).Enabled() = true, expected false when debug messages are not logged")
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package mongolog provides a log target which saves log messages in a MongoDB collection.
+package mongolog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/admpub/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoTarget inserts log messages as documents into a MongoDB collection.
+// The collection can be capped, or expire its documents through a TTL index.
+// MongoDB does not support TTL indexes on capped collections, so Capped and TTL are mutually exclusive.
+type MongoTarget struct {
+	*log.Filter
+	// the connection string, e.g. "mongodb://localhost:27017".
+	URI string
+	// the database and collection names.
+	Database   string
+	Collection string
+	// whether to create the collection as a capped collection when it does not exist.
+	Capped bool
+	// the maximum size in bytes of a capped collection.
+	CappedSize int64
+	// the maximum number of documents of a capped collection. Zero means no limit.
+	CappedMaxDocuments int64
+	// the lifetime of a document before MongoDB removes it. Zero means documents never expire.
+	TTL time.Duration
+	// the maximum number of connections in the connection pool.
+	MaxPoolSize uint64
+	// the maximum number of documents inserted in one batch.
+	BatchSize int
+	// the maximum time a message is kept in memory before being inserted.
+	FlushInterval time.Duration
+	// the timeout of database operations.
+	Timeout time.Duration
+	// the size of the message channel.
+	BufferSize int
+
+	client     *mongo.Client
+	collection *mongo.Collection
+	entries    chan *log.Entry
+	close      chan bool
+}
+
+// Document is the document inserted for each log message.
+type Document struct {
+	Time      time.Time              `bson:"time"`
+	Level     string                 `bson:"level"`
+	Severity  int                    `bson:"severity"`
+	Category  string                 `bson:"category"`
+	Message   string                 `bson:"message"`
+	Fields    map[string]interface{} `bson:"fields,omitempty"`
+	CallStack log.CallStack          `bson:"callStack,omitempty"`
+}
+
+// NewMongoTarget creates a MongoTarget.
+// The new MongoTarget takes these default options:
+// MaxLevel: LevelDebug, URI: mongodb://localhost:27017, Collection: logs, CappedSize: 100MB,
+// MaxPoolSize: 10, BatchSize: 100, FlushInterval: 2s, Timeout: 10s, BufferSize: 1024.
+// You must specify the Database field.
+func NewMongoTarget() *MongoTarget {
+	return &MongoTarget{
+		Filter:        &log.Filter{MaxLevel: log.LevelDebug},
+		URI:           "mongodb://localhost:27017",
+		Collection:    "logs",
+		CappedSize:    100 << 20,
+		MaxPoolSize:   10,
+		BatchSize:     100,
+		FlushInterval: 2 * time.Second,
+		Timeout:       10 * time.Second,
+		BufferSize:    1024,
+		close:         make(chan bool, 0),
+	}
+}
+
+// Open prepares MongoTarget for processing log messages.
+func (t *MongoTarget) Open(errWriter io.Writer) error {
+	t.Filter.Init()
+	if t.URI == "" {
+		return errors.New("MongoTarget.URI must be specified")
+	}
+	if t.Database == "" {
+		return errors.New("MongoTarget.Database must be specified")
+	}
+	if t.Collection == "" {
+		return errors.New("MongoTarget.Collection must be specified")
+	}
+	if t.Capped && t.TTL > 0 {
+		return errors.New("MongoTarget.TTL cannot be used with a capped collection")
+	}
+	if t.Capped && t.CappedSize <= 0 {
+		return errors.New("MongoTarget.CappedSize must be greater than 0")
+	}
+	if t.BatchSize <= 0 {
+		return errors.New("MongoTarget.BatchSize must be greater than 0")
+	}
+	if t.FlushInterval <= 0 {
+		return errors.New("MongoTarget.FlushInterval must be greater than 0")
+	}
+	if t.BufferSize < 0 {
+		return errors.New("MongoTarget.BufferSize must be no less than 0")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), t.Timeout)
+	defer cancel()
+	opts := options.Client().ApplyURI(t.URI)
+	if t.MaxPoolSize > 0 {
+		opts.SetMaxPoolSize(t.MaxPoolSize)
+	}
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("MongoTarget was unable to connect: %v", err)
+	}
+	if err := t.prepareCollection(ctx, client.Database(t.Database)); err != nil {
+		client.Disconnect(context.Background())
+		return err
+	}
+	t.client = client
+	t.collection = client.Database(t.Database).Collection(t.Collection)
+	t.entries = make(chan *log.Entry, t.BufferSize)
+
+	go t.insertMessages(errWriter)
+
+	return nil
+}
+
+// prepareCollection creates the capped collection and the TTL index if needed.
+func (t *MongoTarget) prepareCollection(ctx context.Context, db *mongo.Database) error {
+	if t.Capped {
+		opts := options.CreateCollection().SetCapped(true).SetSizeInBytes(t.CappedSize)
+		if t.CappedMaxDocuments > 0 {
+			opts.SetMaxDocuments(t.CappedMaxDocuments)
+		}
+		err := db.CreateCollection(ctx, t.Collection, opts)
+		var cmdErr mongo.CommandError
+		if err != nil && !(errors.As(err, &cmdErr) && cmdErr.Name == "NamespaceExists") {
+			return fmt.Errorf("MongoTarget was unable to create the collection: %v", err)
+		}
+	}
+	if t.TTL > 0 {
+		_, err := db.Collection(t.Collection).Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "time", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(t.TTL / time.Second)),
+		})
+		if err != nil {
+			return fmt.Errorf("MongoTarget was unable to create the TTL index: %v", err)
+		}
+	}
+	return nil
+}
+
+// Process puts filtered log messages into a channel for inserting into MongoDB.
+func (t *MongoTarget) Process(e *log.Entry) {
+	if e == nil {
+		t.entries <- nil
+		return
+	}
+	if t.Allow(e) {
+		select {
+		case t.entries <- e:
+		default:
+		}
+	}
+}
+
+// Close closes the MongoDB target.
+func (t *MongoTarget) Close() {
+	<-t.close
+	if t.client != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), t.Timeout)
+		t.client.Disconnect(ctx)
+		cancel()
+		t.client = nil
+	}
+}
+
+func (t *MongoTarget) insertMessages(errWriter io.Writer) {
+	var batch []interface{}
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), t.Timeout)
+		_, err := t.collection.InsertMany(ctx, batch, options.InsertMany().SetOrdered(false))
+		cancel()
+		if err != nil {
+			fmt.Fprintf(errWriter, "MongoTarget write error: %v\n", err)
+		}
+		batch = nil
+	}
+	ticker := time.NewTicker(t.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case entry := <-t.entries:
+			if entry == nil {
+				flush()
+				t.close <- true
+				return
+			}
+			batch = append(batch, NewDocument(entry))
+			if len(batch) >= t.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// NewDocument converts a log entry into the document inserted by MongoTarget.
+func NewDocument(e *log.Entry) *Document {
+	return &Document{
+		Time:      e.Time,
+		Level:     e.Level.String(),
+		Severity:  int(e.Level),
+		Category:  e.Category,
+		Message:   e.Message,
+		Fields:    e.Fields,
+		CallStack: e.CallStack,
+	}
+}
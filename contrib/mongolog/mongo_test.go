@@ -0,0 +1,47 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mongolog_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+	"github.com/admpub/log/contrib/mongolog"
+)
+
+func TestNewMongoTarget(t *testing.T) {
+	target := mongolog.NewMongoTarget()
+	if target.MaxLevel != log.LevelDebug {
+		t.Errorf("NewMongoTarget.MaxLevel = %v, expected %v", target.MaxLevel, log.LevelDebug)
+	}
+	if target.Collection != "logs" {
+		t.Errorf("NewMongoTarget.Collection = %v, expected %v", target.Collection, "logs")
+	}
+}
+
+func TestMongoTargetOpen(t *testing.T) {
+	target := mongolog.NewMongoTarget()
+	target.Database = "app"
+	target.Capped = true
+	target.TTL = time.Hour
+	if err := target.Open(nil); err == nil {
+		t.Errorf("Open() should fail when both Capped and TTL are set")
+	}
+}
+
+func TestNewDocument(t *testing.T) {
+	now := time.Now()
+	doc := mongolog.NewDocument(&log.Entry{
+		Level:    log.LevelError,
+		Category: "app",
+		Message:  "t1",
+		Time:     now,
+		Fields:   log.Fields{"id": 1},
+	})
+	if doc.Level != "Error" || doc.Severity != int(log.LevelError) || doc.Message != "t1" || doc.Fields["id"] != 1 || !doc.Time.Equal(now) {
+		t.Errorf("NewDocument() = %+v, unexpected value", doc)
+	}
+}
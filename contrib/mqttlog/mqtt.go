@@ -0,0 +1,164 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package mqttlog provides a log target which publishes log messages to an MQTT broker.
+package mqttlog
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/admpub/log"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// MQTTTarget publishes log messages to MQTT topics. The topic is built from the Topic template
+// in which "{id}" is replaced by the client ID, "{level}" by the lower-cased level name and
+// "{category}" by the category, e.g. "devices/{id}/logs/{level}". The characters "/", "+" and "#"
+// are replaced by "_" in the client ID and the category.
+type MQTTTarget struct {
+	*log.Filter
+	// the broker URL, e.g. "tcp://localhost:1883" or "ssl://broker.example.com:8883".
+	Broker string
+	// the client ID, which also replaces "{id}" in the topic template.
+	ClientID string
+	// the credentials used to connect. Leave them empty if not required.
+	Username string
+	Password string
+	// the TLS configuration used for "ssl://" and "wss://" brokers.
+	TLSConfig *tls.Config
+	// the topic template.
+	Topic string
+	// the quality of service level: 0 (at most once), 1 (at least once) or 2 (exactly once).
+	QoS byte
+	// whether the broker should retain the last message of each topic.
+	Retained bool
+	// how long to wait for the connection and for the acknowledgement of each message.
+	Timeout time.Duration
+	// the size of the message channel.
+	BufferSize int
+
+	client  mqtt.Client
+	entries chan *log.Entry
+	close   chan bool
+}
+
+// NewMQTTTarget creates an MQTTTarget.
+// The new MQTTTarget takes these default options:
+// MaxLevel: LevelDebug, Broker: tcp://localhost:1883, Topic: devices/{id}/logs/{level}, QoS: 0,
+// Timeout: 10s, BufferSize: 1024.
+// You must specify the ClientID field.
+func NewMQTTTarget() *MQTTTarget {
+	return &MQTTTarget{
+		Filter:     &log.Filter{MaxLevel: log.LevelDebug},
+		Broker:     "tcp://localhost:1883",
+		Topic:      "devices/{id}/logs/{level}",
+		Timeout:    10 * time.Second,
+		BufferSize: 1024,
+		close:      make(chan bool, 0),
+	}
+}
+
+// Open prepares MQTTTarget for processing log messages.
+// The client keeps reconnecting in the background if the broker cannot be reached.
+func (t *MQTTTarget) Open(errWriter io.Writer) error {
+	t.Filter.Init()
+	if t.Broker == "" {
+		return errors.New("MQTTTarget.Broker must be specified")
+	}
+	if t.ClientID == "" {
+		return errors.New("MQTTTarget.ClientID must be specified")
+	}
+	if t.Topic == "" {
+		return errors.New("MQTTTarget.Topic must be specified")
+	}
+	if t.QoS > 2 {
+		return errors.New("MQTTTarget.QoS must be 0, 1 or 2")
+	}
+	if t.BufferSize < 0 {
+		return errors.New("MQTTTarget.BufferSize must be no less than 0")
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(t.Broker).
+		SetClientID(t.ClientID).
+		SetUsername(t.Username).
+		SetPassword(t.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectTimeout(t.Timeout).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			fmt.Fprintf(errWriter, "MQTTTarget connection lost: %v\n", err)
+		})
+	if t.TLSConfig != nil {
+		opts.SetTLSConfig(t.TLSConfig)
+	}
+	t.client = mqtt.NewClient(opts)
+	// with ConnectRetry enabled the token completes on the first attempt while retrying continues
+	if token := t.client.Connect(); token.WaitTimeout(t.Timeout) && token.Error() != nil {
+		fmt.Fprintf(errWriter, "MQTTTarget connect error: %v\n", token.Error())
+	}
+	t.entries = make(chan *log.Entry, t.BufferSize)
+
+	go t.sendMessages(errWriter)
+
+	return nil
+}
+
+// Process puts filtered log messages into a channel for publishing.
+func (t *MQTTTarget) Process(e *log.Entry) {
+	if e == nil {
+		t.entries <- nil
+		return
+	}
+	if t.Allow(e) {
+		select {
+		case t.entries <- e:
+		default:
+		}
+	}
+}
+
+// Close closes the MQTT target.
+func (t *MQTTTarget) Close() {
+	<-t.close
+}
+
+// topicLevelReplacer replaces the characters which must not appear within a level of a topic name:
+// the level separator and the wildcards, which are a protocol error when publishing.
+var topicLevelReplacer = strings.NewReplacer("/", "_", "+", "_", "#", "_")
+
+// TopicOf returns the topic an entry is published to.
+func (t *MQTTTarget) TopicOf(e *log.Entry) string {
+	return strings.NewReplacer(
+		"{id}", topicLevelReplacer.Replace(t.ClientID),
+		"{level}", strings.ToLower(e.Level.String()),
+		"{category}", topicLevelReplacer.Replace(e.Category),
+	).Replace(t.Topic)
+}
+
+func (t *MQTTTarget) sendMessages(errWriter io.Writer) {
+	for {
+		entry := <-t.entries
+		if entry == nil {
+			t.client.Disconnect(uint(t.Timeout / time.Millisecond))
+			t.close <- true
+			break
+		}
+		token := t.client.Publish(t.TopicOf(entry), t.QoS, t.Retained, entry.String())
+		if t.QoS > 0 {
+			if !token.WaitTimeout(t.Timeout) {
+				fmt.Fprintf(errWriter, "MQTTTarget write error: no acknowledgement within %v\n", t.Timeout)
+				continue
+			}
+		}
+		if err := token.Error(); err != nil {
+			fmt.Fprintf(errWriter, "MQTTTarget write error: %v\n", err)
+		}
+	}
+}
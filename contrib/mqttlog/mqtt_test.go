@@ -0,0 +1,42 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mqttlog_test
+
+import (
+	"testing"
+
+	"github.com/admpub/log"
+	"github.com/admpub/log/contrib/mqttlog"
+)
+
+func TestNewMQTTTarget(t *testing.T) {
+	target := mqttlog.NewMQTTTarget()
+	if target.MaxLevel != log.LevelDebug {
+		t.Errorf("NewMQTTTarget.MaxLevel = %v, expected %v", target.MaxLevel, log.LevelDebug)
+	}
+	if target.QoS != 0 {
+		t.Errorf("NewMQTTTarget.QoS = %v, expected %v", target.QoS, 0)
+	}
+}
+
+func TestMQTTTargetTopicOf(t *testing.T) {
+	target := mqttlog.NewMQTTTarget()
+	target.ClientID = "sensor-7"
+	topic := target.TopicOf(&log.Entry{Category: "app", Level: log.LevelWarn})
+	if topic != "devices/sensor-7/logs/warn" {
+		t.Errorf("TopicOf() = %q, expected %q", topic, "devices/sensor-7/logs/warn")
+	}
+	target.Topic = "{category}/{level}"
+	topic = target.TopicOf(&log.Entry{Category: "a/b", Level: log.LevelError})
+	if topic != "a_b/error" {
+		t.Errorf("TopicOf() = %q, expected %q", topic, "a_b/error")
+	}
+	target.Topic = "{id}/{category}"
+	target.ClientID = "c#1"
+	topic = target.TopicOf(&log.Entry{Category: "a+b#", Level: log.LevelError})
+	if topic != "c_1/a_b_" {
+		t.Errorf("TopicOf() = %q, expected %q", topic, "c_1/a_b_")
+	}
+}
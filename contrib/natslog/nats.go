@@ -0,0 +1,206 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package natslog provides a log target which publishes log messages to NATS subjects.
+package natslog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/admpub/log"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// NATSTarget publishes log messages to NATS subjects derived from their categories and levels,
+// e.g. "logs.app.error". With JetStream enabled the messages are published asynchronously to a
+// stream and the acknowledgements are checked, so they are persisted by the server.
+type NATSTarget struct {
+	*log.Filter
+	// the server URLs, separated by commas.
+	URL string
+	// the connection name reported to the server.
+	Name string
+	// the authentication token. Leave it empty if not required.
+	Token string
+	// the path of a credentials file. Leave it empty if not required.
+	CredentialsFile string
+	// the first token of the subjects.
+	SubjectPrefix string
+	// whether to publish through JetStream. A stream must be bound to the subjects.
+	JetStream bool
+	// the maximum number of unacknowledged JetStream publishes.
+	MaxPending int
+	// the time to wait between connection attempts. Connecting is retried forever, so the
+	// target can be opened while the server is unavailable.
+	ReconnectWait time.Duration
+	// the time to wait for pending acknowledgements when closing.
+	FlushTimeout time.Duration
+	// the size of the message channel.
+	BufferSize int
+
+	conn    *nats.Conn
+	js      jetstream.JetStream
+	entries chan *log.Entry
+	close   chan bool
+}
+
+// NewNATSTarget creates a NATSTarget.
+// The new NATSTarget takes these default options:
+// MaxLevel: LevelDebug, URL: nats://127.0.0.1:4222, SubjectPrefix: logs, MaxPending: 256,
+// ReconnectWait: 2s, FlushTimeout: 5s, BufferSize: 1024.
+func NewNATSTarget() *NATSTarget {
+	return &NATSTarget{
+		Filter:        &log.Filter{MaxLevel: log.LevelDebug},
+		URL:           nats.DefaultURL,
+		SubjectPrefix: "logs",
+		MaxPending:    256,
+		ReconnectWait: 2 * time.Second,
+		FlushTimeout:  5 * time.Second,
+		BufferSize:    1024,
+		close:         make(chan bool, 0),
+	}
+}
+
+// Open prepares NATSTarget for processing log messages.
+func (t *NATSTarget) Open(errWriter io.Writer) error {
+	t.Filter.Init()
+	if t.URL == "" {
+		return errors.New("NATSTarget.URL must be specified")
+	}
+	if t.BufferSize < 0 {
+		return errors.New("NATSTarget.BufferSize must be no less than 0")
+	}
+	if t.MaxPending <= 0 {
+		return errors.New("NATSTarget.MaxPending must be greater than 0")
+	}
+
+	opts := []nats.Option{
+		// keep connecting in the background when the server is not available yet
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(t.ReconnectWait),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				fmt.Fprintf(errWriter, "NATSTarget disconnected: %v\n", err)
+			}
+		}),
+		nats.ErrorHandler(func(_ *nats.Conn, _ *nats.Subscription, err error) {
+			fmt.Fprintf(errWriter, "NATSTarget error: %v\n", err)
+		}),
+	}
+	if t.Name != "" {
+		opts = append(opts, nats.Name(t.Name))
+	}
+	if t.Token != "" {
+		opts = append(opts, nats.Token(t.Token))
+	}
+	if t.CredentialsFile != "" {
+		opts = append(opts, nats.UserCredentials(t.CredentialsFile))
+	}
+	conn, err := nats.Connect(t.URL, opts...)
+	if err != nil {
+		return fmt.Errorf("NATSTarget was unable to connect: %v", err)
+	}
+	t.js = nil
+	if t.JetStream {
+		t.js, err = jetstream.New(conn,
+			jetstream.WithPublishAsyncMaxPending(t.MaxPending),
+			jetstream.WithPublishAsyncErrHandler(func(_ jetstream.JetStream, msg *nats.Msg, err error) {
+				fmt.Fprintf(errWriter, "NATSTarget publish to %v not acknowledged: %v\n", msg.Subject, err)
+			}),
+		)
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("NATSTarget was unable to use JetStream: %v", err)
+		}
+	}
+	t.conn = conn
+	t.entries = make(chan *log.Entry, t.BufferSize)
+
+	go t.sendMessages(errWriter)
+
+	return nil
+}
+
+// Process puts filtered log messages into a channel for publishing.
+func (t *NATSTarget) Process(e *log.Entry) {
+	if e == nil {
+		t.entries <- nil
+		return
+	}
+	if t.Allow(e) {
+		select {
+		case t.entries <- e:
+		default:
+		}
+	}
+}
+
+// Close closes the NATS target.
+func (t *NATSTarget) Close() {
+	<-t.close
+}
+
+// Subject returns the subject an entry is published to.
+func (t *NATSTarget) Subject(e *log.Entry) string {
+	category := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '*', '>':
+			return '_'
+		}
+		return r
+	}, e.Category)
+	// subjects must not contain empty tokens
+	tokens := strings.Split(category, ".")
+	for i, token := range tokens {
+		if token == "" {
+			tokens[i] = "_"
+		}
+	}
+	subject := strings.Join(tokens, ".") + "." + strings.ToLower(e.Level.String())
+	if t.SubjectPrefix != "" {
+		subject = t.SubjectPrefix + "." + subject
+	}
+	return subject
+}
+
+func (t *NATSTarget) sendMessages(errWriter io.Writer) {
+	for {
+		entry := <-t.entries
+		if entry == nil {
+			t.flush(errWriter)
+			t.conn.Close()
+			t.close <- true
+			break
+		}
+		var err error
+		if t.js != nil {
+			_, err = t.js.PublishAsync(t.Subject(entry), []byte(entry.String()))
+		} else {
+			err = t.conn.Publish(t.Subject(entry), []byte(entry.String()))
+		}
+		if err != nil {
+			fmt.Fprintf(errWriter, "NATSTarget write error: %v\n", err)
+		}
+	}
+}
+
+// flush waits until the published messages have been sent and acknowledged.
+func (t *NATSTarget) flush(errWriter io.Writer) {
+	if t.js != nil {
+		select {
+		case <-t.js.PublishAsyncComplete():
+		case <-time.After(t.FlushTimeout):
+			fmt.Fprintf(errWriter, "NATSTarget gave up waiting for %v acknowledgements\n", t.js.PublishAsyncPending())
+		}
+	}
+	if err := t.conn.FlushTimeout(t.FlushTimeout); err != nil {
+		fmt.Fprintf(errWriter, "NATSTarget flush error: %v\n", err)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package natslog_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+	"github.com/admpub/log/contrib/natslog"
+)
+
+func TestNewNATSTarget(t *testing.T) {
+	target := natslog.NewNATSTarget()
+	if target.MaxLevel != log.LevelDebug {
+		t.Errorf("NewNATSTarget.MaxLevel = %v, expected %v", target.MaxLevel, log.LevelDebug)
+	}
+	if target.SubjectPrefix != "logs" {
+		t.Errorf("NewNATSTarget.SubjectPrefix = %v, expected %v", target.SubjectPrefix, "logs")
+	}
+}
+
+func TestNATSTargetSubject(t *testing.T) {
+	tests := []struct {
+		prefix   string
+		category string
+		level    log.Level
+		expected string
+	}{
+		{"logs", "app", log.LevelError, "logs.app.error"},
+		{"logs", "system.db", log.LevelInfo, "logs.system.db.info"},
+		{"", "app", log.LevelWarn, "app.warn"},
+		{"logs", "a b>*", log.LevelDebug, "logs.a_b__.debug"},
+		{"logs", "", log.LevelInfo, "logs._.info"},
+		{"logs", "system..db.", log.LevelInfo, "logs.system._.db._.info"},
+	}
+	target := natslog.NewNATSTarget()
+	for _, test := range tests {
+		target.SubjectPrefix = test.prefix
+		subject := target.Subject(&log.Entry{Category: test.category, Level: test.level})
+		if subject != test.expected {
+			t.Errorf("Subject(%q, %v) = %q, expected %q", test.category, test.level, subject, test.expected)
+		}
+	}
+}
+
+func TestNATSTargetServerDown(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	target := natslog.NewNATSTarget()
+	target.URL = "nats://127.0.0.1:1"
+	target.FlushTimeout = 100 * time.Millisecond
+	logger.SetTarget(target)
+
+	if len(logger.Targets) != 1 {
+		t.Errorf("len(logger.Targets) = %v, expected %v", len(logger.Targets), 1)
+	}
+	logger.Info("t1")
+	logger.Close()
+}
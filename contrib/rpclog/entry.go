@@ -0,0 +1,234 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package rpclog provides a log target which streams log entries to a collector over gRPC,
+// and a reference collector server. The wire format is defined in log.proto, so collectors
+// can also be implemented in other languages.
+package rpclog
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/admpub/log"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// Entry is the protobuf message admpub.log.v1.Entry.
+type Entry struct {
+	TimeUnixNano int64
+	Level        int32
+	Category     string
+	Message      string
+	Fields       map[string]string
+	CallStack    string
+	Formatted    string
+}
+
+// Ack is the protobuf message admpub.log.v1.Ack.
+type Ack struct {
+	Received uint64
+}
+
+// NewEntry converts a log entry into its protobuf message. Field values are converted to strings.
+func NewEntry(e *log.Entry) *Entry {
+	m := &Entry{
+		TimeUnixNano: e.Time.UnixNano(),
+		Level:        int32(e.Level),
+		Category:     e.Category,
+		Message:      e.Message,
+		CallStack:    e.CallStack.String(),
+		Formatted:    e.String(),
+	}
+	if len(e.Fields) > 0 {
+		m.Fields = make(map[string]string, len(e.Fields))
+		for k, v := range e.Fields {
+			m.Fields[k] = fmt.Sprint(v)
+		}
+	}
+	return m
+}
+
+// LogEntry converts the message back into a log entry.
+func (m *Entry) LogEntry() *log.Entry {
+	e := &log.Entry{
+		Time:             time.Unix(0, m.TimeUnixNano),
+		Level:            log.Level(m.Level),
+		Category:         m.Category,
+		Message:          m.Message,
+		CallStack:        log.ParseCallStack(m.CallStack),
+		FormattedMessage: m.Formatted,
+	}
+	if len(m.Fields) > 0 {
+		e.Fields = make(log.Fields, len(m.Fields))
+		for k, v := range m.Fields {
+			e.Fields[k] = v
+		}
+	}
+	return e
+}
+
+// Marshal encodes the message in the protobuf wire format.
+func (m *Entry) Marshal() []byte {
+	var b []byte
+	if m.TimeUnixNano != 0 {
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(m.TimeUnixNano))
+	}
+	if m.Level != 0 {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(int64(m.Level)))
+	}
+	b = appendString(b, 3, m.Category)
+	b = appendString(b, 4, m.Message)
+	for k, v := range m.Fields {
+		var kv []byte
+		kv = appendString(kv, 1, k)
+		kv = appendString(kv, 2, v)
+		b = protowire.AppendTag(b, 5, protowire.BytesType)
+		b = protowire.AppendBytes(b, kv)
+	}
+	b = appendString(b, 6, m.CallStack)
+	b = appendString(b, 7, m.Formatted)
+	return b
+}
+
+// Unmarshal decodes the message from the protobuf wire format.
+func (m *Entry) Unmarshal(b []byte) error {
+	*m = Entry{}
+	return consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			m.TimeUnixNano = int64(v)
+			return n, nil
+		case num == 2 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			m.Level = int32(v)
+			return n, nil
+		case num == 3 && typ == protowire.BytesType:
+			return consumeString(b, &m.Category)
+		case num == 4 && typ == protowire.BytesType:
+			return consumeString(b, &m.Message)
+		case num == 5 && typ == protowire.BytesType:
+			kv, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return n, nil
+			}
+			var key, value string
+			err := consumeFields(kv, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+				switch {
+				case num == 1 && typ == protowire.BytesType:
+					return consumeString(b, &key)
+				case num == 2 && typ == protowire.BytesType:
+					return consumeString(b, &value)
+				}
+				return protowire.ConsumeFieldValue(num, typ, b), nil
+			})
+			if m.Fields == nil {
+				m.Fields = make(map[string]string)
+			}
+			m.Fields[key] = value
+			return n, err
+		case num == 6 && typ == protowire.BytesType:
+			return consumeString(b, &m.CallStack)
+		case num == 7 && typ == protowire.BytesType:
+			return consumeString(b, &m.Formatted)
+		}
+		return protowire.ConsumeFieldValue(num, typ, b), nil
+	})
+}
+
+// Marshal encodes the message in the protobuf wire format.
+func (m *Ack) Marshal() []byte {
+	var b []byte
+	if m.Received != 0 {
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, m.Received)
+	}
+	return b
+}
+
+// Unmarshal decodes the message from the protobuf wire format.
+func (m *Ack) Unmarshal(b []byte) error {
+	*m = Ack{}
+	return consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if num == 1 && typ == protowire.VarintType {
+			v, n := protowire.ConsumeVarint(b)
+			m.Received = v
+			return n, nil
+		}
+		return protowire.ConsumeFieldValue(num, typ, b), nil
+	})
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func consumeString(b []byte, s *string) (int, error) {
+	v, n := protowire.ConsumeString(b)
+	*s = v
+	return n, nil
+}
+
+func consumeFields(b []byte, field func(protowire.Number, protowire.Type, []byte) (int, error)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		n, err := field(num, typ, b)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// Codec is a gRPC codec which encodes Entry and Ack messages and delegates other
+// messages to the protobuf runtime. It is registered under the "proto" content subtype,
+// so it interoperates with clients and servers generated from log.proto.
+type Codec struct{}
+
+// Marshal encodes a message.
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	switch m := v.(type) {
+	case *Entry:
+		return m.Marshal(), nil
+	case *Ack:
+		return m.Marshal(), nil
+	case proto.Message:
+		return proto.Marshal(m)
+	}
+	return nil, fmt.Errorf("rpclog: cannot marshal %T", v)
+}
+
+// Unmarshal decodes a message.
+func (Codec) Unmarshal(data []byte, v interface{}) error {
+	switch m := v.(type) {
+	case *Entry:
+		return m.Unmarshal(data)
+	case *Ack:
+		return m.Unmarshal(data)
+	case proto.Message:
+		return proto.Unmarshal(data, m)
+	}
+	return fmt.Errorf("rpclog: cannot unmarshal %T", v)
+}
+
+// Name returns the content subtype of the codec.
+func (Codec) Name() string {
+	return "proto"
+}
@@ -0,0 +1,100 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpclog
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/admpub/log"
+	"google.golang.org/grpc/grpclog"
+)
+
+var _ grpclog.LoggerV2 = (*GRPCLogger)(nil)
+
+// GRPCLogger routes the internal logging of gRPC through a logger, so that services log in one format:
+//
+//	grpclog.SetLoggerV2(rpclog.NewGRPCLogger(logger))
+type GRPCLogger struct {
+	// the logger of the "grpc" category.
+	Logger *log.Logger
+	// the level of the messages gRPC logs as information, which are numerous.
+	InfoLevel log.Level
+	// the verbosity reported to gRPC by V. gRPC logs its verbose messages of levels up to it.
+	Verbosity int
+}
+
+// NewGRPCLogger creates a GRPCLogger logging through the "grpc" category of the given logger.
+// The new GRPCLogger takes these default options:
+// InfoLevel: LevelInfo, Verbosity: 0.
+func NewGRPCLogger(logger *log.Logger) *GRPCLogger {
+	return &GRPCLogger{
+		Logger:    logger.GetLogger("grpc"),
+		InfoLevel: log.LevelInfo,
+	}
+}
+
+func (g *GRPCLogger) Info(args ...interface{}) {
+	g.Logger.Log(g.InfoLevel, fmt.Sprint(args...))
+}
+
+func (g *GRPCLogger) Infoln(args ...interface{}) {
+	g.Logger.Log(g.InfoLevel, sprintln(args...))
+}
+
+func (g *GRPCLogger) Infof(format string, args ...interface{}) {
+	g.Logger.Logf(g.InfoLevel, format, args...)
+}
+
+func (g *GRPCLogger) Warning(args ...interface{}) {
+	g.Logger.Log(log.LevelWarn, fmt.Sprint(args...))
+}
+
+func (g *GRPCLogger) Warningln(args ...interface{}) {
+	g.Logger.Log(log.LevelWarn, sprintln(args...))
+}
+
+func (g *GRPCLogger) Warningf(format string, args ...interface{}) {
+	g.Logger.Logf(log.LevelWarn, format, args...)
+}
+
+func (g *GRPCLogger) Error(args ...interface{}) {
+	g.Logger.Log(log.LevelError, fmt.Sprint(args...))
+}
+
+func (g *GRPCLogger) Errorln(args ...interface{}) {
+	g.Logger.Log(log.LevelError, sprintln(args...))
+}
+
+func (g *GRPCLogger) Errorf(format string, args ...interface{}) {
+	g.Logger.Logf(log.LevelError, format, args...)
+}
+
+// Fatal logs a fatal message and exits the program, as gRPC requires, unless the fatal action of the logger already did.
+func (g *GRPCLogger) Fatal(args ...interface{}) {
+	g.Logger.Log(log.LevelFatal, fmt.Sprint(args...))
+	os.Exit(1)
+}
+
+func (g *GRPCLogger) Fatalln(args ...interface{}) {
+	g.Logger.Log(log.LevelFatal, sprintln(args...))
+	os.Exit(1)
+}
+
+func (g *GRPCLogger) Fatalf(format string, args ...interface{}) {
+	g.Logger.Logf(log.LevelFatal, format, args...)
+	os.Exit(1)
+}
+
+// V reports whether the verbose messages of the given level are logged.
+func (g *GRPCLogger) V(l int) bool {
+	return l <= g.Verbosity && g.Logger.Enabled(g.InfoLevel)
+}
+
+// sprintln formats like fmt.Sprintln without the trailing new line.
+func sprintln(args ...interface{}) string {
+	s := fmt.Sprintln(args...)
+	return s[:len(s)-1]
+}
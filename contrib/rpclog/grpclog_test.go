@@ -0,0 +1,71 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpclog_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/admpub/log"
+	"github.com/admpub/log/contrib/rpclog"
+)
+
+type memoryTarget struct {
+	*log.Filter
+	entries []*log.Entry
+	ready   chan bool
+}
+
+func (m *memoryTarget) Open(io.Writer) error {
+	return nil
+}
+
+func (m *memoryTarget) Process(e *log.Entry) {
+	if e == nil {
+		m.ready <- true
+	} else {
+		m.entries = append(m.entries, e)
+	}
+}
+
+func (m *memoryTarget) Close() {
+	<-m.ready
+}
+
+func TestGRPCLogger(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	target := &memoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	defer logger.Close()
+
+	g := rpclog.NewGRPCLogger(logger)
+	g.InfoLevel = log.LevelDebug
+	g.Info("t1")
+	g.Warningln("t2", 3)
+	g.Errorf("t%v", 4)
+
+	expected := []struct {
+		level   log.Level
+		message string
+	}{{log.LevelDebug, "t1"}, {log.LevelWarn, "t2 3"}, {log.LevelError, "t4"}}
+	if len(target.entries) != len(expected) {
+		t.Fatalf("len(target.entries) = %v, expected %v", len(target.entries), len(expected))
+	}
+	for i, e := range target.entries {
+		if e.Level != expected[i].level || e.Message != expected[i].message || e.Category != "grpc" {
+			t.Errorf("entries[%v] = %v %q %v, expected %v %q grpc", i, e.Level, e.Message, e.Category, expected[i].level, expected[i].message)
+		}
+	}
+
+	g.Verbosity = 2
+	if !g.V(2) || g.V(3) {
+		t.Errorf("V() does not follow the verbosity %v", g.Verbosity)
+	}
+	logger.SetMaxLevel(log.LevelInfo)
+	if g.V(0) {
+		t.Errorf("V(0) = true, expected false when the info level of gRPC is not logged")
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpclog_test
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/admpub/log"
+	"github.com/admpub/log/contrib/rpclog"
+	"google.golang.org/grpc"
+)
+
+func TestEntryMarshal(t *testing.T) {
+	m := &rpclog.Entry{
+		TimeUnixNano: 1445517568000000000,
+		Level:        int32(log.LevelWarn),
+		Category:     "app",
+		Message:      "t1",
+		Fields:       map[string]string{"a": "1", "b": "x"},
+		Formatted:    "app|t1",
+	}
+	m2 := &rpclog.Entry{}
+	if err := m2.Unmarshal(m.Marshal()); err != nil {
+		t.Fatalf("Unmarshal(): %v", err)
+	}
+	if m2.TimeUnixNano != m.TimeUnixNano || m2.Level != m.Level || m2.Category != m.Category ||
+		m2.Message != m.Message || m2.Formatted != m.Formatted || len(m2.Fields) != 2 || m2.Fields["b"] != "x" {
+		t.Errorf("Unmarshal() = %+v, expected %+v", m2, m)
+	}
+}
+
+func TestGRPCTarget(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen(): %v", err)
+	}
+	var (
+		lock     sync.Mutex
+		received []*log.Entry
+	)
+	gs := grpc.NewServer(rpclog.ServerOption())
+	server := &rpclog.Server{
+		Token: "secret",
+		Handle: func(ctx context.Context, e *log.Entry) {
+			lock.Lock()
+			received = append(received, e)
+			lock.Unlock()
+		},
+	}
+	server.Register(gs)
+	go gs.Serve(listener)
+	defer gs.Stop()
+
+	logger := log.NewLogger()
+	logger.Sync()
+	target := rpclog.NewGRPCTarget()
+	target.Address = listener.Addr().String()
+	target.Token = "secret"
+	target.Categories = []string{"system.*"}
+	logger.SetTarget(target)
+
+	logger.Infof("t1: %v", 2)
+	logger.GetLogger("system.db").WithFields(log.Fields{"id": 7}).Warnf("t2: %v", 3)
+
+	logger.Close()
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(received) != 1 {
+		t.Fatalf("len(received) = %v, expected %v", len(received), 1)
+	}
+	e := received[0]
+	if e.Category != "system.db" || e.Level != log.LevelWarn || e.Message != "t2: 3" || e.Fields["id"] != "7" {
+		t.Errorf("received %+v, unexpected value", e)
+	}
+}
+
+type ErrorWriter struct {
+	lock     sync.Mutex
+	messages []string
+}
+
+func (w *ErrorWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.messages = append(w.messages, string(p))
+	return len(p), nil
+}
+
+func TestGRPCTargetRejected(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen(): %v", err)
+	}
+	gs := grpc.NewServer(rpclog.ServerOption())
+	server := &rpclog.Server{
+		Token:  "secret",
+		Handle: func(ctx context.Context, e *log.Entry) {},
+	}
+	server.Register(gs)
+	go gs.Serve(listener)
+	defer gs.Stop()
+
+	errWriter := &ErrorWriter{}
+	logger := log.NewLogger()
+	logger.Sync()
+	logger.ErrorWriter = errWriter
+	target := rpclog.NewGRPCTarget()
+	target.Address = listener.Addr().String()
+	target.Token = "wrong"
+	logger.SetTarget(target)
+
+	logger.Info("t1")
+	logger.Close()
+
+	// the status of the collector must be reported rather than a bare EOF
+	errWriter.lock.Lock()
+	defer errWriter.lock.Unlock()
+	found := false
+	for _, m := range errWriter.messages {
+		if strings.Contains(m, "Unauthenticated") && strings.Contains(m, "may be lost") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected the rejection to be reported, got %q", errWriter.messages)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpclog
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/admpub/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Server is a reference implementation of the Collector service which aggregates the entries
+// streamed by GRPCTarget instances. It must be registered on a gRPC server created with the
+// ServerOption option, e.g.:
+//
+//	gs := grpc.NewServer(rpclog.ServerOption())
+//	rpclog.NewServer(logger).Register(gs)
+type Server struct {
+	// the bearer token clients must present. Leave it empty to accept any client.
+	Token string
+	// Handle is called for every received entry.
+	Handle func(ctx context.Context, e *log.Entry)
+}
+
+// NewServer creates a Server which logs the received entries through the given logger,
+// keeping their categories, levels and fields.
+func NewServer(l *log.Logger) *Server {
+	return &Server{
+		Handle: func(ctx context.Context, e *log.Entry) {
+			logger := l.GetLogger(e.Category)
+			if len(e.Fields) > 0 {
+				logger = logger.WithFields(e.Fields)
+			}
+			logger.Log(e.Level, e.Message)
+		},
+	}
+}
+
+// ServerOption returns the gRPC server option installing the codec used by the Collector service.
+// Other services on the same server keep working because the codec delegates their messages to the protobuf runtime.
+func ServerOption() grpc.ServerOption {
+	return grpc.ForceServerCodec(Codec{})
+}
+
+// Register registers the Collector service on a gRPC server.
+func (s *Server) Register(gs *grpc.Server) {
+	gs.RegisterService(&serviceDesc, s)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "admpub.log.v1.Collector",
+	HandlerType: (*interface{})(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Stream",
+			ClientStreams: true,
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(*Server).stream(stream)
+			},
+		},
+	},
+	Metadata: "log.proto",
+}
+
+func (s *Server) stream(stream grpc.ServerStream) error {
+	ctx := stream.Context()
+	if s.Token != "" {
+		md, _ := metadata.FromIncomingContext(ctx)
+		auth := md.Get("authorization")
+		if len(auth) == 0 || strings.TrimPrefix(auth[0], "Bearer ") != s.Token {
+			return status.Error(codes.Unauthenticated, "invalid token")
+		}
+	}
+	ack := &Ack{}
+	for {
+		m := &Entry{}
+		if err := stream.RecvMsg(m); err != nil {
+			if err == io.EOF {
+				return stream.SendMsg(ack)
+			}
+			return err
+		}
+		ack.Received++
+		if s.Handle != nil {
+			s.Handle(ctx, m.LogEntry())
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpclog
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/admpub/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+const streamMethod = "/admpub.log.v1.Collector/Stream"
+
+var streamDesc = &grpc.StreamDesc{StreamName: "Stream", ClientStreams: true}
+
+// GRPCTarget streams log entries to a collector over a client-side gRPC stream.
+type GRPCTarget struct {
+	*log.Filter
+	// the address of the collector, e.g. "collector.example.com:4317".
+	Address string
+	// the TLS configuration. The connection is not encrypted if both TLSConfig and TLS are nil.
+	TLSConfig *tls.Config
+	// the TLS options, used if TLSConfig is nil, e.g. for mutual TLS.
+	TLS *log.TLSOptions
+	// the token sent as a bearer token in the "authorization" metadata. Leave it empty if not required.
+	Token string
+	// how long to wait for the acknowledgement of the collector when closing.
+	Timeout time.Duration
+	// the size of the message channel.
+	BufferSize int
+
+	conn    *grpc.ClientConn
+	stream  grpc.ClientStream
+	sent    int // the number of entries sent on the current stream
+	cancel  context.CancelFunc
+	entries chan *log.Entry
+	close   chan bool
+}
+
+// NewGRPCTarget creates a GRPCTarget.
+// The new GRPCTarget takes these default options:
+// MaxLevel: LevelDebug, Timeout: 5s, BufferSize: 1024.
+// You must specify the Address field.
+func NewGRPCTarget() *GRPCTarget {
+	return &GRPCTarget{
+		Filter:     &log.Filter{MaxLevel: log.LevelDebug},
+		Timeout:    5 * time.Second,
+		BufferSize: 1024,
+		close:      make(chan bool, 0),
+	}
+}
+
+// Open prepares GRPCTarget for processing log messages.
+func (t *GRPCTarget) Open(errWriter io.Writer) error {
+	t.Filter.Init()
+	if t.Address == "" {
+		return errors.New("GRPCTarget.Address must be specified")
+	}
+	if t.BufferSize < 0 {
+		return errors.New("GRPCTarget.BufferSize must be no less than 0")
+	}
+	creds := insecure.NewCredentials()
+	if t.TLSConfig != nil {
+		creds = credentials.NewTLS(t.TLSConfig)
+	} else if t.TLS != nil {
+		config, err := t.TLS.Config()
+		if err != nil {
+			return fmt.Errorf("GRPCTarget.TLS is invalid: %v", err)
+		}
+		creds = credentials.NewTLS(config)
+	}
+	conn, err := grpc.NewClient(t.Address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return fmt.Errorf("GRPCTarget was unable to create the client: %v", err)
+	}
+	t.conn = conn
+	t.stream = nil
+	t.entries = make(chan *log.Entry, t.BufferSize)
+
+	go t.sendMessages(errWriter)
+
+	return nil
+}
+
+// Process puts filtered log messages into a channel for streaming.
+func (t *GRPCTarget) Process(e *log.Entry) {
+	if e == nil {
+		t.entries <- nil
+		return
+	}
+	if t.Allow(e) {
+		select {
+		case t.entries <- e:
+		default:
+		}
+	}
+}
+
+// Close closes the gRPC target.
+func (t *GRPCTarget) Close() {
+	<-t.close
+}
+
+func (t *GRPCTarget) sendMessages(errWriter io.Writer) {
+	for {
+		entry := <-t.entries
+		if entry == nil {
+			if err := t.closeStream(); err != nil {
+				fmt.Fprintf(errWriter, "GRPCTarget close error: %v\n", err)
+			}
+			t.conn.Close()
+			t.close <- true
+			break
+		}
+		if err := t.write(errWriter, NewEntry(entry)); err != nil {
+			fmt.Fprintf(errWriter, "GRPCTarget write error: %v\n", err)
+		}
+	}
+}
+
+// write sends an entry, opening a new stream if the current one is broken.
+func (t *GRPCTarget) write(errWriter io.Writer, m *Entry) error {
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if t.stream == nil {
+			if err = t.openStream(); err != nil {
+				continue
+			}
+		}
+		if err = t.stream.SendMsg(m); err == nil {
+			t.sent++
+			return nil
+		}
+		if err == io.EOF {
+			// the stream was ended by the collector, and the real error is its status
+			err = t.streamError(t.stream.RecvMsg(&Ack{}))
+			fmt.Fprintf(errWriter, "GRPCTarget stream error: %v\n", err)
+		}
+		t.cancel()
+		t.stream = nil
+	}
+	return err
+}
+
+// streamError describes the error which ended the current stream, including how many entries may have been lost.
+func (t *GRPCTarget) streamError(err error) error {
+	if err == nil || err == io.EOF {
+		err = errors.New("the stream was ended by the collector")
+	}
+	if t.sent == 0 {
+		return err
+	}
+	return fmt.Errorf("%v (%v entries sent on the stream may be lost)", err, t.sent)
+}
+
+func (t *GRPCTarget) openStream() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	if t.Token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+t.Token)
+	}
+	stream, err := t.conn.NewStream(ctx, streamDesc, streamMethod, grpc.ForceCodec(Codec{}))
+	if err != nil {
+		cancel()
+		return err
+	}
+	t.stream = stream
+	t.sent = 0
+	t.cancel = cancel
+	return nil
+}
+
+// closeStream half-closes the stream and waits for the acknowledgement of the collector.
+func (t *GRPCTarget) closeStream() error {
+	if t.stream == nil {
+		return nil
+	}
+	defer func() {
+		t.cancel()
+		t.stream = nil
+	}()
+	if err := t.stream.CloseSend(); err != nil {
+		return err
+	}
+	timer := time.AfterFunc(t.Timeout, t.cancel)
+	defer timer.Stop()
+	if err := t.stream.RecvMsg(&Ack{}); err != nil {
+		return t.streamError(err)
+	}
+	return nil
+}
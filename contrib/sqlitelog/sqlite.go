@@ -0,0 +1,310 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sqlitelog provides a log target which saves log messages in a local SQLite database.
+package sqlitelog
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+// SQLiteTarget saves log messages in a local SQLite database file which can later be inspected with QueryEntries.
+// The database is accessed through database/sql, so the application must import an SQLite driver,
+// e.g. github.com/mattn/go-sqlite3 (driver name "sqlite3") or modernc.org/sqlite (driver name "sqlite").
+type SQLiteTarget struct {
+	*log.Filter
+	// the path of the database file.
+	FileName string
+	// the name of the registered database/sql driver.
+	DriverName string
+	// the name of the table storing the log messages.
+	Table string
+	// entries older than MaxAge are deleted. Zero means no limit.
+	MaxAge time.Duration
+	// only the newest MaxRows entries are kept. Zero means no limit.
+	MaxRows int64
+	// how often the pruning by MaxAge and MaxRows is performed.
+	PruneInterval time.Duration
+	// the size of the message channel.
+	BufferSize int
+
+	db      *sql.DB
+	dbLock  sync.RWMutex
+	entries chan *log.Entry
+	close   chan bool
+}
+
+// NewSQLiteTarget creates an SQLiteTarget.
+// The new SQLiteTarget takes these default options:
+// MaxLevel: LevelDebug, DriverName: "sqlite3", Table: "logs", PruneInterval: 1m, BufferSize: 1024.
+// You must specify the FileName field.
+func NewSQLiteTarget() *SQLiteTarget {
+	return &SQLiteTarget{
+		Filter:        &log.Filter{MaxLevel: log.LevelDebug},
+		DriverName:    "sqlite3",
+		Table:         "logs",
+		PruneInterval: time.Minute,
+		BufferSize:    1024,
+		close:         make(chan bool, 0),
+	}
+}
+
+// Open prepares SQLiteTarget for processing log messages.
+func (t *SQLiteTarget) Open(errWriter io.Writer) error {
+	t.Filter.Init()
+	if t.FileName == "" {
+		return errors.New("SQLiteTarget.FileName must be specified")
+	}
+	if t.Table == "" || strings.ContainsAny(t.Table, "\"` ;") {
+		return errors.New("SQLiteTarget.Table must be a valid table name")
+	}
+	if t.BufferSize < 0 {
+		return errors.New("SQLiteTarget.BufferSize must be no less than 0")
+	}
+	if t.PruneInterval <= 0 {
+		return errors.New("SQLiteTarget.PruneInterval must be greater than 0")
+	}
+	db, err := sql.Open(t.DriverName, t.FileName)
+	if err != nil {
+		return fmt.Errorf("SQLiteTarget was unable to open the database: %v", err)
+	}
+	statements := []string{
+		`PRAGMA journal_mode=WAL`,
+		`CREATE TABLE IF NOT EXISTS "` + t.Table + `" (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			time INTEGER NOT NULL,
+			level INTEGER NOT NULL,
+			category TEXT NOT NULL,
+			message TEXT NOT NULL,
+			fields TEXT NOT NULL,
+			call_stack TEXT NOT NULL,
+			formatted TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS "` + t.Table + `_time" ON "` + t.Table + `" (time)`,
+	}
+	for _, stmt := range statements {
+		if _, err = db.Exec(stmt); err != nil {
+			db.Close()
+			return fmt.Errorf("SQLiteTarget was unable to prepare the database: %v", err)
+		}
+	}
+	t.dbLock.Lock()
+	t.db = db
+	t.dbLock.Unlock()
+	t.entries = make(chan *log.Entry, t.BufferSize)
+
+	go t.saveMessages(errWriter)
+
+	return nil
+}
+
+// Process puts filtered log messages into a channel for saving in the database.
+func (t *SQLiteTarget) Process(e *log.Entry) {
+	if e == nil {
+		t.entries <- nil
+		return
+	}
+	if t.Allow(e) {
+		select {
+		case t.entries <- e:
+		default:
+		}
+	}
+}
+
+// Close closes the SQLite target.
+func (t *SQLiteTarget) Close() {
+	<-t.close
+	t.dbLock.Lock()
+	defer t.dbLock.Unlock()
+	if t.db != nil {
+		t.db.Close()
+		t.db = nil
+	}
+}
+
+func (t *SQLiteTarget) saveMessages(errWriter io.Writer) {
+	ticker := time.NewTicker(t.PruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case entry := <-t.entries:
+			if entry == nil {
+				t.close <- true
+				return
+			}
+			// save whatever else is queued in the same transaction
+			batch := []*log.Entry{entry}
+			closing := false
+		drain:
+			for len(batch) < 512 {
+				select {
+				case e := <-t.entries:
+					if e == nil {
+						closing = true
+						break drain
+					}
+					batch = append(batch, e)
+				default:
+					break drain
+				}
+			}
+			if err := t.insert(batch); err != nil {
+				fmt.Fprintf(errWriter, "SQLiteTarget write error: %v\n", err)
+			}
+			if closing {
+				t.close <- true
+				return
+			}
+		case <-ticker.C:
+			if err := t.Prune(); err != nil {
+				fmt.Fprintf(errWriter, "SQLiteTarget prune error: %v\n", err)
+			}
+		}
+	}
+}
+
+func (t *SQLiteTarget) insert(entries []*log.Entry) error {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`INSERT INTO "` + t.Table + `" (time, level, category, message, fields, call_stack, formatted) VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, e := range entries {
+		fields := []byte("{}")
+		if len(e.Fields) > 0 {
+			if fields, err = json.Marshal(e.Fields); err != nil {
+				fields = []byte("{}")
+			}
+		}
+		if _, err = stmt.Exec(e.Time.UnixNano(), int(e.Level), e.Category, e.Message, string(fields), e.CallStack.String(), e.String()); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Prune deletes the entries exceeding MaxAge and MaxRows.
+// It is called every PruneInterval, but may also be called directly while the target is open.
+func (t *SQLiteTarget) Prune() error {
+	t.dbLock.RLock()
+	defer t.dbLock.RUnlock()
+	if t.db == nil {
+		return errors.New("SQLiteTarget is not open")
+	}
+	if t.MaxAge > 0 {
+		if _, err := t.db.Exec(`DELETE FROM "`+t.Table+`" WHERE time < ?`, time.Now().Add(-t.MaxAge).UnixNano()); err != nil {
+			return err
+		}
+	}
+	if t.MaxRows > 0 {
+		_, err := t.db.Exec(`DELETE FROM "`+t.Table+`" WHERE id <= (SELECT id FROM "`+t.Table+`" ORDER BY id DESC LIMIT 1 OFFSET ?)`, t.MaxRows)
+		return err
+	}
+	return nil
+}
+
+// SQLiteQuery specifies the conditions used by SQLiteTarget.QueryEntries.
+// Zero-valued fields are not used as conditions.
+type SQLiteQuery struct {
+	Since    time.Time  // entries logged at or after this time
+	Until    time.Time  // entries logged before this time
+	MaxLevel *log.Level // the maximum severity level
+	Category string     // the category. A "*" suffix is used for prefix matching.
+	Contains string     // a substring of the message
+	Limit    int        // the maximum number of entries returned
+	Desc     bool       // whether to return the newest entries first
+}
+
+// QueryEntries returns the saved entries matching the given conditions.
+// QueryEntries can only be called while the target is open.
+func (t *SQLiteTarget) QueryEntries(q SQLiteQuery) ([]*log.Entry, error) {
+	t.dbLock.RLock()
+	defer t.dbLock.RUnlock()
+	if t.db == nil {
+		return nil, errors.New("SQLiteTarget is not open")
+	}
+	var (
+		where []string
+		args  []interface{}
+	)
+	if !q.Since.IsZero() {
+		where = append(where, "time >= ?")
+		args = append(args, q.Since.UnixNano())
+	}
+	if !q.Until.IsZero() {
+		where = append(where, "time < ?")
+		args = append(args, q.Until.UnixNano())
+	}
+	if q.MaxLevel != nil {
+		where = append(where, "level <= ?")
+		args = append(args, int(*q.MaxLevel))
+	}
+	if strings.HasSuffix(q.Category, "*") {
+		where = append(where, "substr(category, 1, ?) = ?")
+		prefix := q.Category[:len(q.Category)-1]
+		args = append(args, len(prefix), prefix)
+	} else if q.Category != "" {
+		where = append(where, "category = ?")
+		args = append(args, q.Category)
+	}
+	if q.Contains != "" {
+		where = append(where, "instr(message, ?) > 0")
+		args = append(args, q.Contains)
+	}
+	query := `SELECT time, level, category, message, fields, call_stack, formatted FROM "` + t.Table + `"`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	if q.Desc {
+		query += " ORDER BY id DESC"
+	} else {
+		query += " ORDER BY id"
+	}
+	if q.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", q.Limit)
+	}
+
+	rows, err := t.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []*log.Entry
+	for rows.Next() {
+		var (
+			nano   int64
+			level  int
+			fields string
+			stack  string
+			e      = &log.Entry{}
+		)
+		if err := rows.Scan(&nano, &level, &e.Category, &e.Message, &fields, &stack, &e.FormattedMessage); err != nil {
+			return nil, err
+		}
+		e.CallStack = log.ParseCallStack(stack)
+		e.Time = time.Unix(0, nano)
+		e.Level = log.Level(level)
+		if fields != "{}" {
+			json.Unmarshal([]byte(fields), &e.Fields)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,85 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlitelog_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+	"github.com/admpub/log/contrib/sqlitelog"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestNewSQLiteTarget(t *testing.T) {
+	target := sqlitelog.NewSQLiteTarget()
+	if target.MaxLevel != log.LevelDebug {
+		t.Errorf("NewSQLiteTarget.MaxLevel = %v, expected %v", target.MaxLevel, log.LevelDebug)
+	}
+	if target.DriverName != "sqlite3" {
+		t.Errorf("NewSQLiteTarget.DriverName = %v, expected %v", target.DriverName, "sqlite3")
+	}
+}
+
+func TestSQLiteTarget(t *testing.T) {
+	dbFile := "app.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	logger := log.NewLogger()
+	logger.Sync()
+	target := sqlitelog.NewSQLiteTarget()
+	target.FileName = dbFile
+	target.MaxRows = 2
+	target.PruneInterval = time.Hour
+	logger.SetTarget(target)
+	defer logger.Close()
+
+	logger.Infof("t1: %v", 2)
+	logger.GetLogger("system.db").WithFields(log.Fields{"id": "x"}).Errorf("t2: %v", 3)
+	logger.GetLogger("system.db").Warnf("t3: %v", 4)
+	logger.GetLogger("app").Infof("t4: %v", 5)
+
+	// the messages are saved asynchronously
+	var (
+		entries []*log.Entry
+		err     error
+	)
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if entries, err = target.QueryEntries(sqlitelog.SQLiteQuery{}); err != nil {
+			t.Fatalf("QueryEntries(): %v", err)
+		}
+		if len(entries) == 4 {
+			break
+		}
+	}
+	if len(entries) != 4 {
+		t.Fatalf("QueryEntries() returned %v entries, expected %v", len(entries), 4)
+	}
+	if entries[1].Fields["id"] != "x" {
+		t.Errorf("entries[1].Fields = %v, expected id=x", entries[1].Fields)
+	}
+
+	level := log.LevelError
+	entries, err = target.QueryEntries(sqlitelog.SQLiteQuery{Category: "system.*", MaxLevel: &level})
+	if err != nil {
+		t.Fatalf("QueryEntries(): %v", err)
+	}
+	if len(entries) != 1 || entries[0].Message != "t2: 3" {
+		t.Errorf("QueryEntries() returned %v entries, expected only t2", len(entries))
+	}
+
+	if err := target.Prune(); err != nil {
+		t.Fatalf("Prune(): %v", err)
+	}
+	entries, err = target.QueryEntries(sqlitelog.SQLiteQuery{})
+	if err != nil {
+		t.Fatalf("QueryEntries(): %v", err)
+	}
+	if len(entries) != 2 || entries[0].Message != "t3: 4" {
+		t.Errorf("QueryEntries() returned %v entries after pruning, expected t3 and t4", len(entries))
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package zstdlog provides a zstd log.Compressor for the file, network and HTTP targets.
+package zstdlog
+
+import (
+	"io"
+
+	"github.com/admpub/log"
+	"github.com/klauspost/compress/zstd"
+)
+
+var _ log.Compressor = (*Compressor)(nil)
+
+// Compressor compresses with zstd, which is faster than gzip for a similar ratio:
+//
+//	target := log.NewFileTarget()
+//	target.FileName = "app.log.zst"
+//	target.Compression = zstdlog.NewCompressor()
+type Compressor struct {
+	// the compression level.
+	Level zstd.EncoderLevel
+}
+
+// NewCompressor creates a Compressor compressing at the default level.
+func NewCompressor() *Compressor {
+	return &Compressor{Level: zstd.SpeedDefault}
+}
+
+// Encoding returns "zstd".
+func (c *Compressor) Encoding() string {
+	return "zstd"
+}
+
+// NewWriter returns a zstd encoder writing to w.
+func (c *Compressor) NewWriter(w io.Writer) (log.CompressWriter, error) {
+	return zstd.NewWriter(w, zstd.WithEncoderLevel(c.Level))
+}
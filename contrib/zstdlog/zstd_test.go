@@ -0,0 +1,51 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstdlog_test
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/admpub/log"
+	"github.com/admpub/log/contrib/zstdlog"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestCompressor(t *testing.T) {
+	logFile := "app.log.zst"
+	os.Remove(logFile)
+	defer os.Remove(logFile)
+
+	logger := log.NewLogger()
+	logger.Sync()
+	target := log.NewFileTarget()
+	target.FileName = logFile
+	target.Rotate = false
+	target.Compression = zstdlog.NewCompressor()
+	logger.SetTarget(target)
+	logger.Info("t1")
+	logger.Error("t2")
+	logger.Close()
+
+	file, err := os.Open(logFile)
+	if err != nil {
+		t.Fatalf("Open(): %v", err)
+	}
+	defer file.Close()
+	reader, err := zstd.NewReader(file)
+	if err != nil {
+		t.Fatalf("NewReader(): %v", err)
+	}
+	defer reader.Close()
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("ReadAll(): %v", err)
+	}
+	if lines := string(data); len(lines) == 0 || lines[len(lines)-1] != '\n' || !strings.Contains(lines, "t1") || !strings.Contains(lines, "t2") {
+		t.Errorf("decompressed log file = %q, expected t1 and t2", lines)
+	}
+}
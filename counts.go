@@ -0,0 +1,58 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+// count counts a message in the Levels of Stats and in Counts.
+func (l *coreLogger) count(entry *Entry) {
+	l.levelCounts.add(entry.Level)
+	counts, ok := l.catCounts.Load(entry.Category)
+	if !ok {
+		counts, _ = l.catCounts.LoadOrStore(entry.Category, &levelCounts{})
+	}
+	counts.(*levelCounts).add(entry.Level)
+}
+
+// Counts returns the number of messages logged by level, for all the categories, since the logger was created
+// or ResetCounts was called. The heartbeats are not counted. It lets a test check that no error was logged:
+//
+//	if n := logger.Counts()[log.LevelError]; n > 0 {
+//		t.Errorf("%v errors logged", n)
+//	}
+func (l *coreLogger) Counts() map[Level]uint64 {
+	counts := map[Level]uint64{}
+	for _, categoryCounts := range l.CategoryCounts() {
+		for level, n := range categoryCounts {
+			counts[level] += n
+		}
+	}
+	return counts
+}
+
+// CategoryCounts returns the number of messages logged by category and level, like Counts.
+func (l *coreLogger) CategoryCounts() map[string]map[Level]uint64 {
+	counts := map[string]map[Level]uint64{}
+	l.catCounts.Range(func(category, c interface{}) bool {
+		for level, n := range c.(*levelCounts).get() {
+			if n == 0 {
+				continue
+			}
+			if counts[category.(string)] == nil {
+				counts[category.(string)] = map[Level]uint64{}
+			}
+			counts[category.(string)][level] = n
+		}
+		return true
+	})
+	return counts
+}
+
+// ResetCounts sets the numbers of messages returned by Counts and CategoryCounts to zero,
+// e.g. between the test cases sharing a logger. The Levels of Stats are not reset.
+func (l *coreLogger) ResetCounts() {
+	l.catCounts.Range(func(_, c interface{}) bool {
+		c.(*levelCounts).reset()
+		return true
+	})
+}
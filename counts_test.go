@@ -0,0 +1,51 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestLoggerCounts(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	db := logger.GetLogger("db")
+	logger.Info("m1")
+	logger.Info("m2")
+	logger.Error("e1")
+	db.Error("e2")
+	db.Debug("d1")
+
+	expected := map[log.Level]uint64{log.LevelInfo: 2, log.LevelError: 2, log.LevelDebug: 1}
+	if counts := logger.Counts(); !reflect.DeepEqual(counts, expected) {
+		t.Errorf("Counts() = %v, expected %v", counts, expected)
+	}
+	expectedCategories := map[string]map[log.Level]uint64{
+		"app": {log.LevelInfo: 2, log.LevelError: 1},
+		"db":  {log.LevelError: 1, log.LevelDebug: 1},
+	}
+	if counts := logger.CategoryCounts(); !reflect.DeepEqual(counts, expectedCategories) {
+		t.Errorf("CategoryCounts() = %v, expected %v", counts, expectedCategories)
+	}
+
+	logger.ResetCounts()
+	if counts := logger.Counts(); len(counts) != 0 {
+		t.Errorf("Counts() = %v, expected no message after ResetCounts", counts)
+	}
+	db.Warn("w1")
+	expected = map[log.Level]uint64{log.LevelWarn: 1}
+	if counts := logger.Counts(); !reflect.DeepEqual(counts, expected) {
+		t.Errorf("Counts() = %v, expected %v", counts, expected)
+	}
+	if levels := logger.Stats().Levels; levels[log.LevelError] != 2 {
+		t.Errorf("Stats().Levels = %v, expected them not to be reset", levels)
+	}
+	logger.Close()
+}
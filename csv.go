@@ -0,0 +1,183 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+)
+
+// DefaultCSVColumns are the columns of the records written by the CSV formatters and CSVTarget by default.
+var DefaultCSVColumns = []string{"time", "level", "category", "message", "fields", "error", "callStack"}
+
+// CSVFormat formats log messages as CSV records, which can be loaded into spreadsheets or queried as
+// external tables. A column is one of "time", "level", "category", "message", "seq", "traceId", "spanId",
+// "error", "callStack", "raw", which holds the binary data in base64, and "fields", which holds all the
+// fields as key=value pairs, or else the name of a field, which holds the value of the field. The values are
+// quoted as required by RFC 4180.
+type CSVFormat struct {
+	// the columns of the records, in order.
+	Columns []string
+	// the separator of the values, e.g. '\t' for TSV.
+	Comma rune
+}
+
+// NewCSVFormatter returns a Formatter writing the given columns, or DefaultCSVColumns, as comma-separated values.
+func NewCSVFormatter(columns ...string) Formatter {
+	return NewFormatter(newCSVFormat(',', columns).Append)
+}
+
+// NewTSVFormatter returns a Formatter writing the given columns, or DefaultCSVColumns, as tab-separated values.
+func NewTSVFormatter(columns ...string) Formatter {
+	return NewFormatter(newCSVFormat('\t', columns).Append)
+}
+
+func newCSVFormat(comma rune, columns []string) *CSVFormat {
+	if len(columns) == 0 {
+		columns = DefaultCSVColumns
+	}
+	return &CSVFormat{Columns: append([]string{}, columns...), Comma: comma}
+}
+
+// Header returns the header record, which holds the names of the columns.
+func (f *CSVFormat) Header() string {
+	var buf []byte
+	for i, column := range f.Columns {
+		if i > 0 {
+			buf = appendRune(buf, f.comma())
+		}
+		buf = f.appendValue(buf, []byte(column))
+	}
+	return string(buf)
+}
+
+// Append appends a message formatted as a CSV record, without a line break. It can be used as an AppendFormatter.
+func (f *CSVFormat) Append(buf []byte, l *Logger, e *Entry) []byte {
+	var value []byte
+	for i, column := range f.Columns {
+		if i > 0 {
+			buf = appendRune(buf, f.comma())
+		}
+		value = value[:0]
+		switch column {
+		case "time":
+			value = appendTime(value, l, e.Time, time.RFC3339Nano)
+		case "level":
+			value = append(value, e.Level.String()...)
+		case "category":
+			value = append(value, e.Category...)
+		case "message":
+			value = append(value, e.Message...)
+		case "seq":
+			value = strconv.AppendUint(value, e.Seq, 10)
+		case "traceId":
+			value = append(value, e.TraceID...)
+		case "spanId":
+			value = append(value, e.SpanID...)
+		case "error":
+			if e.Error != nil {
+				value = append(value, e.Error.Error()...)
+			}
+		case "callStack":
+			value = appendCallStack(value, l, e.CallStack)
+			if len(value) > 0 {
+				// drop the line break preceding the first frame
+				value = value[1:]
+			}
+		case "fields":
+			value = e.Fields.appendTo(value)
+		case "raw":
+			value = appendBase64(value, e.Raw)
+		default:
+			if v, ok := e.Fields[column]; ok {
+				value = appendValue(value, v)
+			}
+		}
+		buf = f.appendValue(buf, value)
+	}
+	return buf
+}
+
+func (f *CSVFormat) comma() rune {
+	if f.Comma == 0 {
+		return ','
+	}
+	return f.Comma
+}
+
+// appendValue appends a value, quoted if it holds the separator, a quote, a line break or leading space.
+func (f *CSVFormat) appendValue(buf []byte, value []byte) []byte {
+	comma := f.comma()
+	quoted := len(value) > 0 && (value[0] == ' ' || value[0] == '\t') && comma != '\t' ||
+		bytes.ContainsAny(value, "\"\r\n") || bytes.ContainsRune(value, comma)
+	if !quoted {
+		return append(buf, value...)
+	}
+	buf = append(buf, '"')
+	for _, c := range value {
+		if c == '"' {
+			buf = append(buf, '"')
+		}
+		buf = append(buf, c)
+	}
+	return append(buf, '"')
+}
+
+func appendRune(buf []byte, r rune) []byte {
+	if r < 0x80 {
+		return append(buf, byte(r))
+	}
+	return append(buf, string(r)...)
+}
+
+// CSVTarget writes filtered log messages as CSV records to a file. Each file, including those created by
+// the rotation, starts with the header record. The messages are formatted by the target, so that the
+// logger may format them differently for its other targets.
+type CSVTarget struct {
+	*FileTarget
+	// the columns of the records, in order. See CSVFormat.
+	Columns []string
+	// the separator of the values, e.g. '\t' for TSV.
+	Comma rune
+
+	format *CSVFormat
+}
+
+// NewCSVTarget creates a CSVTarget.
+// The new CSVTarget takes the default options of FileTarget and these default options:
+// Columns: DefaultCSVColumns, Comma: ','
+// You must specify the FileName field.
+func NewCSVTarget() *CSVTarget {
+	return &CSVTarget{
+		FileTarget: NewFileTarget(),
+		Columns:    append([]string{}, DefaultCSVColumns...),
+		Comma:      ',',
+	}
+}
+
+// Open prepares CSVTarget for processing log messages.
+func (t *CSVTarget) Open(errWriter io.Writer) error {
+	if len(t.Columns) == 0 {
+		return errors.New("CSVTarget.Columns must be specified")
+	}
+	if t.Comma == '"' || t.Comma == '\r' || t.Comma == '\n' {
+		return errors.New("CSVTarget.Comma is invalid: " + strconv.QuoteRune(t.Comma))
+	}
+	t.format = newCSVFormat(t.Comma, t.Columns)
+	t.FileTarget.Header = t.format.Header()
+	return t.FileTarget.Open(errWriter)
+}
+
+// Process writes an allowed log message as a CSV record.
+func (t *CSVTarget) Process(e *Entry) {
+	if e != nil {
+		// the entry is the target's own copy
+		e.FormattedMessage = formatWith(t.format.Append, nil, e)
+	}
+	t.FileTarget.Process(e)
+}
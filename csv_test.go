@@ -0,0 +1,88 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"encoding/csv"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+func TestCSVFormatter(t *testing.T) {
+	logger := log.NewLogger()
+	logger.SetUTC(true)
+	e := &log.Entry{
+		Level:    log.LevelError,
+		Category: "app",
+		Message:  "say \"hi\", then\nbye",
+		Time:     time.Date(2015, 1, 2, 3, 4, 5, 0, time.UTC),
+		Fields:   log.Fields{"user": "alice", "n": 2},
+		Error:    errors.New("failed"),
+	}
+	formatter := log.NewCSVFormatter("level", "message", "user", "missing", "error", "time")
+	result := formatter(logger, e)
+	expected := "Error,\"say \"\"hi\"\", then\nbye\",alice,,failed,2015-01-02T03:04:05Z"
+	if result != expected {
+		t.Errorf("NewCSVFormatter() = %q, expected %q", result, expected)
+	}
+	record, err := csv.NewReader(strings.NewReader(result)).Read()
+	if err != nil || len(record) != 6 || record[1] != e.Message {
+		t.Errorf("csv.Reader.Read() = %q, %v, expected the message %q", record, err, e.Message)
+	}
+
+	result = log.NewTSVFormatter("category", "fields")(logger, e)
+	if expected := "app\tn=2 user=alice"; result != expected {
+		t.Errorf("NewTSVFormatter() = %q, expected %q", result, expected)
+	}
+	result = log.NewCSVFormatter()(logger, e)
+	if !strings.HasPrefix(result, "2015-01-02T03:04:05Z,Error,app,") {
+		t.Errorf("NewCSVFormatter() = %q, expected the default columns", result)
+	}
+
+	format := log.CSVFormat{Columns: []string{"a b", "c,d"}}
+	if header := format.Header(); header != "a b,\"c,d\"" {
+		t.Errorf("Header() = %q, expected %q", header, "a b,\"c,d\"")
+	}
+}
+
+func TestCSVTarget(t *testing.T) {
+	logFile := "app.csv"
+	os.Remove(logFile)
+	defer os.Remove(logFile)
+
+	// the header is written only to the new file
+	for _, message := range []string{"t1", "t2, t3"} {
+		logger := log.NewLogger()
+		logger.Sync()
+		target := log.NewCSVTarget()
+		target.FileName = logFile
+		target.Columns = []string{"level", "message", "user"}
+		logger.SetTarget(target)
+		logger.With("user", "bob").Info(message)
+		logger.Close()
+	}
+
+	bytes, err := ioutil.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "level,message,user\nInfo,t1,bob\nInfo,\"t2, t3\",bob\n"
+	if string(bytes) != expected {
+		t.Errorf("file = %q, expected %q", bytes, expected)
+	}
+
+	target := log.NewCSVTarget()
+	target.FileName = logFile
+	target.Comma = '"'
+	if err := target.Open(ioutil.Discard); err == nil {
+		t.Errorf("Open() = nil, expected an error for an invalid separator")
+	}
+}
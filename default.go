@@ -1,83 +1,281 @@
 package log
 
-import "io"
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+)
 
+// DefaultLog holds the default logger of the earlier versions. Assigning DefaultLog.Logger still replaces the
+// default logger, but it is not safe while other goroutines are logging, and SetDefault does not update it.
+//
+// Deprecated: use Default and SetDefault.
 var DefaultLog = &defaultLogger{Logger: New()}
 
 type defaultLogger struct {
 	*Logger
 }
 
+// defaultState is the default logger set by SetDefault.
+type defaultState struct {
+	logger   *Logger
+	assigned *Logger // DefaultLog.Logger when SetDefault was called
+}
+
+var (
+	defaultLock sync.Mutex
+	defaultLog  atomic.Value // the *defaultState
+)
+
+func init() {
+	defaultLog.Store(&defaultState{logger: DefaultLog.Logger, assigned: DefaultLog.Logger})
+}
+
+// Default returns the process-wide default logger used by the package-level functions.
+func Default() *Logger {
+	s := defaultLog.Load().(*defaultState)
+	if l := DefaultLog.Logger; l != s.assigned {
+		// DefaultLog.Logger was assigned since the last call to SetDefault
+		return l
+	}
+	return s.logger
+}
+
+// SetDefault replaces the default logger used by the package-level functions.
+// It can be called while other goroutines are logging.
+func SetDefault(l *Logger) {
+	defaultLock.Lock()
+	defer defaultLock.Unlock()
+	defaultLog.Store(&defaultState{logger: l, assigned: DefaultLog.Logger})
+}
+
 func GetLogger(category string, formatter ...Formatter) *Logger {
-	return DefaultLog.GetLogger(category, formatter...)
+	return Default().GetLogger(category, formatter...)
 }
 
 func Sync(args ...bool) *Logger {
-	return DefaultLog.Sync(args...)
+	return Default().Sync(args...)
 }
 
 func SetTarget(targets ...Target) *Logger {
-	return DefaultLog.SetTarget(targets...)
+	return Default().SetTarget(targets...)
 }
 
 func SetFatalAction(action Action) *Logger {
-	return DefaultLog.SetFatalAction(action)
+	return Default().SetFatalAction(action)
+}
+
+func SetFatalFunc(fn ActionFunc) *Logger {
+	return Default().SetFatalFunc(fn)
+}
+
+func SetExitCode(code int) *Logger {
+	return Default().SetExitCode(code)
 }
 
 func AddTarget(targets ...Target) *Logger {
-	return DefaultLog.AddTarget(targets...)
+	return Default().AddTarget(targets...)
 }
 
 func SetLevel(level string) *Logger {
-	return DefaultLog.SetLevel(level)
+	return Default().SetLevel(level)
 }
 
 func Fatalf(format string, a ...interface{}) {
-	DefaultLog.Fatalf(format, a...)
+	Default().Fatalf(format, a...)
 }
 
 func Errorf(format string, a ...interface{}) {
-	DefaultLog.Errorf(format, a...)
+	Default().Errorf(format, a...)
 }
 
 func Warnf(format string, a ...interface{}) {
-	DefaultLog.Warnf(format, a...)
+	Default().Warnf(format, a...)
 }
 
 func Infof(format string, a ...interface{}) {
-	DefaultLog.Infof(format, a...)
+	Default().Infof(format, a...)
 }
 
 func Debugf(format string, a ...interface{}) {
-	DefaultLog.Debugf(format, a...)
+	Default().Debugf(format, a...)
 }
 
 func Fatal(a ...interface{}) {
-	DefaultLog.Fatal(a...)
+	Default().Fatal(a...)
 }
 
 func Error(a ...interface{}) {
-	DefaultLog.Error(a...)
+	Default().Error(a...)
 }
 
 func Warn(a ...interface{}) {
-	DefaultLog.Warn(a...)
+	Default().Warn(a...)
 }
 
 func Info(a ...interface{}) {
-	DefaultLog.Info(a...)
+	Default().Info(a...)
 }
 
 func Debug(a ...interface{}) {
-	DefaultLog.Debug(a...)
+	Default().Debug(a...)
+}
+
+func DebugDump(label string, data []byte) {
+	Default().DebugDump(label, data)
+}
+
+func Assert(cond bool, format string, a ...interface{}) bool {
+	return Default().Assert(cond, format, a...)
+}
+
+func Deprecated(feature, removal string) {
+	Default().Deprecated(feature, removal)
+}
+
+func Criticalf(format string, a ...interface{}) {
+	Default().Criticalf(format, a...)
+}
+
+func Tracef(format string, a ...interface{}) {
+	Default().Tracef(format, a...)
+}
+
+func Critical(a ...interface{}) {
+	Default().Critical(a...)
+}
+
+func Trace(a ...interface{}) {
+	Default().Trace(a...)
+}
+
+func Logf(level Level, format string, a ...interface{}) {
+	Default().Logf(level, format, a...)
+}
+
+func Log(level Level, a ...interface{}) {
+	Default().Log(level, a...)
+}
+
+func Logw(level Level, message string, keysAndValues ...interface{}) {
+	Default().Logw(level, message, keysAndValues...)
+}
+
+func Fatalw(message string, keysAndValues ...interface{}) {
+	Default().Fatalw(message, keysAndValues...)
+}
+
+func Errorw(message string, keysAndValues ...interface{}) {
+	Default().Errorw(message, keysAndValues...)
+}
+
+func Warnw(message string, keysAndValues ...interface{}) {
+	Default().Warnw(message, keysAndValues...)
+}
+
+func Infow(message string, keysAndValues ...interface{}) {
+	Default().Infow(message, keysAndValues...)
+}
+
+func Debugw(message string, keysAndValues ...interface{}) {
+	Default().Debugw(message, keysAndValues...)
+}
+
+func Print(a ...interface{}) {
+	Default().Print(a...)
+}
+
+func Printf(format string, a ...interface{}) {
+	Default().Printf(format, a...)
+}
+
+func Println(a ...interface{}) {
+	Default().Println(a...)
+}
+
+func Panic(a ...interface{}) {
+	Default().Panic(a...)
+}
+
+func Panicf(format string, a ...interface{}) {
+	Default().Panicf(format, a...)
+}
+
+func Panicln(a ...interface{}) {
+	Default().Panicln(a...)
+}
+
+func Fatalln(a ...interface{}) {
+	Default().Fatalln(a...)
+}
+
+func ErrorFn(fn func() string) {
+	Default().ErrorFn(fn)
+}
+
+func WarnFn(fn func() string) {
+	Default().WarnFn(fn)
+}
+
+func InfoFn(fn func() string) {
+	Default().InfoFn(fn)
+}
+
+func DebugFn(fn func() string) {
+	Default().DebugFn(fn)
+}
+
+func Enabled(level Level) bool {
+	return Default().Enabled(level)
+}
+
+func IsDebugEnabled() bool {
+	return Default().IsDebugEnabled()
+}
+
+func WithFields(fields Fields) *Logger {
+	return Default().WithFields(fields)
+}
+
+func With(keysAndValues ...interface{}) *Logger {
+	return Default().With(keysAndValues...)
+}
+
+func WithContext(ctx context.Context) *Logger {
+	return Default().WithContext(ctx)
+}
+
+func WithError(err error) *Logger {
+	return Default().WithError(err)
+}
+
+func ErrIf(err error, message string, keysAndValues ...interface{}) bool {
+	return Default().ErrIf(err, message, keysAndValues...)
+}
+
+func WarnIf(err error, message string, keysAndValues ...interface{}) bool {
+	return Default().WarnIf(err, message, keysAndValues...)
+}
+
+func FatalIf(err error, message string, keysAndValues ...interface{}) bool {
+	return Default().FatalIf(err, message, keysAndValues...)
+}
+
+func SetFormatter(formatter Formatter) *Logger {
+	return Default().SetFormatter(formatter)
+}
+
+func Close() {
+	Default().Close()
 }
 
 func Writer(level Level) io.Writer {
-	return DefaultLog.Writer(level)
+	return Default().Writer(level)
 }
 
 func UseCommonTargets(levelName string, targetNames ...string) *Logger {
-	DefaultLog.SetLevel(levelName)
+	Default().SetLevel(levelName)
 	targets := []Target{}
 
 	for _, targetName := range targetNames {
@@ -90,28 +288,28 @@ func UseCommonTargets(levelName string, targetNames ...string) *Logger {
 
 		case "file":
 			//输出到文件
-			if DefaultLog.MaxLevel >= LevelInfo {
+			if Default().MaxLevel >= LevelInfo {
 				fileTarget := NewFileTarget()
 				fileTarget.FileName = `logs/{date:20060102}_info.log`
 				fileTarget.Filter.Levels = map[Level]bool{LevelInfo: true}
 				fileTarget.MaxBytes = 10 * 1024 * 1024
 				targets = append(targets, fileTarget)
 			}
-			if DefaultLog.MaxLevel >= LevelWarn {
+			if Default().MaxLevel >= LevelWarn {
 				fileTarget := NewFileTarget()
 				fileTarget.FileName = `logs/{date:20060102}_warn.log` //按天分割日志
 				fileTarget.Filter.Levels = map[Level]bool{LevelWarn: true}
 				fileTarget.MaxBytes = 10 * 1024 * 1024
 				targets = append(targets, fileTarget)
 			}
-			if DefaultLog.MaxLevel >= LevelError {
+			if Default().MaxLevel >= LevelError {
 				fileTarget := NewFileTarget()
 				fileTarget.FileName = `logs/{date:20060102}_error.log` //按天分割日志
 				fileTarget.Filter.MaxLevel = LevelError
 				fileTarget.MaxBytes = 10 * 1024 * 1024
 				targets = append(targets, fileTarget)
 			}
-			if DefaultLog.MaxLevel == LevelDebug {
+			if Default().MaxLevel == LevelDebug {
 				fileTarget := NewFileTarget()
 				fileTarget.FileName = `logs/{date:20060102}_debug.log`
 				fileTarget.Filter.Levels = map[Level]bool{LevelDebug: true}
@@ -122,5 +320,5 @@ func UseCommonTargets(levelName string, targetNames ...string) *Logger {
 	}
 	SetTarget(targets...)
 	SetFatalAction(ActionExit)
-	return DefaultLog.Logger
+	return Default()
 }
@@ -0,0 +1,79 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestSetDefault(t *testing.T) {
+	original := log.Default()
+	defer log.SetDefault(original)
+
+	logger := log.NewLogger("default")
+	logger.Sync()
+	target := &MemoryTarget{
+		Filter: &log.Filter{MaxLevel: log.LevelDebug},
+		ready:  make(chan bool, 0),
+	}
+	logger.SetTarget(target)
+	defer logger.Close()
+
+	log.SetDefault(logger)
+	if log.Default() != logger {
+		t.Fatalf("Default() did not return the logger set by SetDefault")
+	}
+	log.Info("t1")
+	log.Debugf("t%v", 2)
+	log.WithFields(log.Fields{"id": 1}).Error("t3")
+	log.DebugFn(func() string { return "t4" })
+
+	if len(target.entries) != 4 {
+		t.Fatalf("len(target.entries) = %v, expected %v", len(target.entries), 4)
+	}
+	for i, msg := range []string{"t1", "t2", "t3", "t4"} {
+		if e := target.entries[i]; e.Message != msg || e.Category != "default" {
+			t.Errorf("entries[%v] = %v %v, expected %v of default", i, e.Message, e.Category, msg)
+		}
+	}
+	if target.entries[2].Fields["id"] != 1 {
+		t.Errorf("entries[2].Fields = %v, expected id=1", target.entries[2].Fields)
+	}
+}
+
+func TestDefaultLogAssigned(t *testing.T) {
+	original := log.DefaultLog.Logger
+	defer func() {
+		log.DefaultLog.Logger = original
+	}()
+
+	logger := log.NewLogger("assigned")
+	logger.Sync()
+	target := &MemoryTarget{
+		Filter: &log.Filter{MaxLevel: log.LevelDebug},
+		ready:  make(chan bool, 0),
+	}
+	logger.SetTarget(target)
+	defer logger.Close()
+
+	log.DefaultLog.Logger = logger
+	if log.Default() != logger {
+		t.Fatalf("Default() did not return the logger assigned to DefaultLog")
+	}
+	log.Info("t1")
+	if len(target.entries) != 1 || target.entries[0].Category != "assigned" {
+		t.Errorf("len(target.entries) = %v, expected the message logged through the assigned logger", len(target.entries))
+	}
+
+	other := log.NewLogger("other")
+	defer other.Close()
+	log.SetDefault(other)
+	if log.Default() != other {
+		t.Errorf("Default() did not return the logger set by SetDefault after DefaultLog was assigned")
+	}
+	log.SetDefault(original)
+}
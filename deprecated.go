@@ -0,0 +1,40 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import "fmt"
+
+// deprecations counts the calls of Deprecated by feature, for all the loggers of the process.
+var deprecations occurrences
+
+// Deprecated logs a warning that a feature is deprecated and will be removed in the given version, or at some
+// point if removal is empty. It is meant for the libraries embedding the logger: it is called by a deprecated
+// function, and the warning carries the call site of that function in the "caller" field, so that the users can
+// find the code to change. The warning is logged only once per feature in the process, whatever the logger:
+//
+//	func Connect(addr string) *Conn {
+//		log.Deprecated("mylib.Connect", "v2.0.0")
+//		return Dial(context.Background(), addr)
+//	}
+func (l *Logger) Deprecated(feature, removal string) {
+	if !l.Enabled(LevelWarn) || deprecations.add(feature) != 1 {
+		return
+	}
+	message := fmt.Sprintf("%v is deprecated", feature)
+	if removal != "" {
+		message += fmt.Sprintf(" and will be removed in %v", removal)
+	}
+	fields := Fields{"feature": feature}
+	if removal != "" {
+		fields["removal"] = removal
+	}
+	// the first frame is the deprecated function, and the second one its caller
+	stack := callFrames(1, 2, func(string) bool { return true }, true, l.callerSkip)
+	if len(stack) > 0 {
+		caller := stack[len(stack)-1]
+		fields["caller"] = fmt.Sprintf("%v:%v", caller.File, caller.Line)
+	}
+	l.WithFields(fields).Warn(message)
+}
@@ -0,0 +1,53 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+func legacyConnect(l *log.Logger, feature string) {
+	l.Deprecated(feature, "v2.0.0")
+}
+
+func TestLoggerDeprecated(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	other := log.NewLogger()
+	other.Sync()
+	otherTarget := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	other.SetTarget(otherTarget)
+
+	// the features are unique to each run of the test, as the warnings are logged once per process
+	feature := fmt.Sprintf("log_test.legacyConnect.%v", time.Now().UnixNano())
+	_, file, line, _ := runtime.Caller(0)
+	legacyConnect(logger, feature)
+	legacyConnect(logger, feature)
+	legacyConnect(other, feature)
+	logger.Deprecated(feature+".Option", "")
+	logger.Close()
+	other.Close()
+
+	if len(target.entries) != 2 || len(otherTarget.entries) != 0 {
+		t.Fatalf("len(entries) = %v and %v, expected one warning per feature", len(target.entries), len(otherTarget.entries))
+	}
+	e := target.entries[0]
+	if e.Level != log.LevelWarn || e.Message != feature+" is deprecated and will be removed in v2.0.0" {
+		t.Errorf("entry = %v %q, expected the deprecation warning", e.Level, e.Message)
+	}
+	if caller := fmt.Sprintf("%v:%v", file, line+1); e.Fields["caller"] != caller || e.Fields["removal"] != "v2.0.0" {
+		t.Errorf("Fields = %v, expected the caller %v", e.Fields, caller)
+	}
+	if e := target.entries[1]; e.Message != feature+".Option is deprecated" || e.Fields["removal"] != nil {
+		t.Errorf("entry = %q %v, expected no removal version", e.Message, e.Fields)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// devBadges are the level badges written by DevFormatter, all of the same width.
+var devBadges = map[Level]string{
+	LevelTrace:     "TRACE",
+	LevelDebug:     "DEBUG",
+	LevelInfo:      "INFO ",
+	LevelWarn:      "WARN ",
+	LevelError:     "ERROR",
+	LevelFatal:     "FATAL",
+	LevelCritical:  "CRIT ",
+	LevelAlert:     "ALERT",
+	LevelEmergency: "EMERG",
+}
+
+// devColors are the ANSI escape sequences coloring the level badges, in the colors of ConsoleTarget.
+var devColors = map[Level]string{
+	LevelTrace:     "\x1b[1;34m",
+	LevelDebug:     "\x1b[1;36m",
+	LevelInfo:      "\x1b[1;32m",
+	LevelWarn:      "\x1b[1;33m",
+	LevelError:     "\x1b[1;31m",
+	LevelFatal:     "\x1b[1;35m",
+	LevelCritical:  "\x1b[1;35m",
+	LevelAlert:     "\x1b[1;35m",
+	LevelEmergency: "\x1b[1;35m",
+}
+
+const (
+	devIndent = "    "
+	devDim    = "\x1b[2m"
+	devBold   = "\x1b[1m"
+	devReset  = "\x1b[0m"
+)
+
+// DevFormat formats log messages for reading in a terminal during development: a line with the time,
+// a level badge, the category and the message, followed by one indented line per field, with the keys aligned,
+// and by the indented call stacks and hex dump of the binary data. Use a machine-readable formatter such as
+// JSONFormatter in production, e.g. by setting the "formatter" of the configuration or the LOG_FORMAT
+// environment variable to "dev" or "json".
+type DevFormat struct {
+	// whether to color the level badges and dim the keys and the call stacks with ANSI escape sequences.
+	// ConsoleTarget.ColorMode should then be false.
+	Color bool
+	// the layout of the time. It defaults to "15:04:05.000".
+	TimeLayout string
+}
+
+// DevFormatter formats log messages like DevFormat, with colors.
+func DevFormatter(l *Logger, e *Entry) string {
+	return formatWith(devFormat.Append, l, e)
+}
+
+var devFormat = &DevFormat{Color: true}
+
+// NewDevFormatter returns a Formatter formatting log messages like DevFormat, with or without colors.
+func NewDevFormatter(color bool) Formatter {
+	return NewFormatter((&DevFormat{Color: color}).Append)
+}
+
+// Append appends a message formatted for development. It can be used as an AppendFormatter.
+func (f *DevFormat) Append(buf []byte, l *Logger, e *Entry) []byte {
+	layout := f.TimeLayout
+	if layout == "" {
+		layout = "15:04:05.000"
+	}
+	buf = f.style(buf, devDim)
+	buf = appendTime(buf, l, e.Time, layout)
+	buf = f.style(buf, devReset)
+	buf = append(buf, ' ')
+	if f.Color {
+		if color, ok := devColors[e.Level]; ok {
+			buf = append(buf, color...)
+		} else {
+			buf = append(buf, devBold...)
+		}
+	}
+	if badge, ok := devBadges[e.Level]; ok {
+		buf = append(buf, badge...)
+	} else {
+		name := strings.ToUpper(e.Level.String())
+		buf = append(buf, name...)
+		for n := utf8.RuneCountInString(name); n < 5; n++ {
+			buf = append(buf, ' ')
+		}
+	}
+	buf = f.style(buf, devReset)
+	if e.Category != "" {
+		buf = append(buf, ' ')
+		buf = f.style(buf, devBold)
+		buf = append(buf, e.Category...)
+		buf = f.style(buf, devReset)
+	}
+	buf = append(buf, ' ')
+	buf = appendIndented(buf, e.Message, devIndent)
+
+	var array [16]string
+	keys := e.Fields.sortedKeys(&array)
+	width := 0
+	for _, k := range keys {
+		if n := utf8.RuneCountInString(k); n > width {
+			width = n
+		}
+	}
+	if e.TraceID != "" && width < len("trace_id") {
+		width = len("trace_id")
+	}
+	if e.Error != nil && width < len("error") {
+		width = len("error")
+	}
+	if e.TraceID != "" {
+		buf = f.appendField(buf, "trace_id", width, e.TraceID)
+		if e.SpanID != "" {
+			buf = f.appendField(buf, "span_id", width, e.SpanID)
+		}
+	}
+	for _, k := range keys {
+		buf = f.appendField(buf, k, width, string(appendValue(nil, e.Fields[k])))
+	}
+	if e.Error != nil {
+		buf = f.appendField(buf, "error", width, e.Error.Error()+" ("+reflect.TypeOf(e.Error).String()+")")
+		buf = f.appendStack(buf, ErrorStack(e.Error))
+	}
+	buf = f.appendStack(buf, string(appendHexDump(nil, e.Raw)))
+	return f.appendStack(buf, string(appendCallStack(nil, l, e.CallStack)))
+}
+
+// appendField appends a field on a new indented line, with the key padded to the width.
+func (f *DevFormat) appendField(buf []byte, key string, width int, value string) []byte {
+	buf = append(buf, '\n')
+	buf = append(buf, devIndent...)
+	buf = f.style(buf, devDim)
+	buf = append(buf, key...)
+	for n := utf8.RuneCountInString(key); n < width; n++ {
+		buf = append(buf, ' ')
+	}
+	buf = append(buf, " = "...)
+	buf = f.style(buf, devReset)
+	return appendIndented(buf, value, devIndent+devIndent)
+}
+
+// appendStack appends a call stack, made of lines each starting with a line break, indented.
+func (f *DevFormat) appendStack(buf []byte, stack string) []byte {
+	if stack == "" {
+		return buf
+	}
+	if stack[0] != '\n' {
+		stack = "\n" + stack
+	}
+	buf = f.style(buf, devDim)
+	buf = appendIndented(buf, stack, devIndent)
+	return f.style(buf, devReset)
+}
+
+// style appends an ANSI escape sequence if the colors are enabled.
+func (f *DevFormat) style(buf []byte, sequence string) []byte {
+	if f.Color {
+		buf = append(buf, sequence...)
+	}
+	return buf
+}
+
+// appendIndented appends a text with its lines but the first indented.
+func appendIndented(buf []byte, text string, indent string) []byte {
+	for {
+		i := strings.IndexByte(text, '\n')
+		if i < 0 {
+			return append(buf, text...)
+		}
+		buf = append(buf, text[:i+1]...)
+		buf = append(buf, indent...)
+		text = text[i+1:]
+	}
+}
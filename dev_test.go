@@ -0,0 +1,47 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+func TestDevFormatter(t *testing.T) {
+	e := &log.Entry{
+		Level:     log.LevelWarn,
+		Category:  "app.db",
+		Message:   "slow query\nSELECT 1",
+		Time:      time.Date(2015, 10, 22, 8, 39, 28, 5e6, time.UTC),
+		Fields:    log.Fields{"user": "alice", "elapsed_ms": 1200},
+		Error:     errors.New("timeout"),
+		CallStack: log.CallStack{{File: "/app/db.go", Line: 12}, {File: "/app/main.go", Line: 5}},
+	}
+	result := log.NewDevFormatter(false)(log.NewLogger(), e)
+	expected := "08:39:28.005 WARN  app.db slow query\n" +
+		"    SELECT 1\n" +
+		"    elapsed_ms = 1200\n" +
+		"    user       = alice\n" +
+		"    error      = timeout (*errors.errorString)\n" +
+		"    /app/db.go:12\n" +
+		"    /app/main.go:5"
+	if result != expected {
+		t.Errorf("NewDevFormatter(false) = %q, expected %q", result, expected)
+	}
+
+	result = log.DevFormatter(log.NewLogger(), &log.Entry{Level: log.LevelError, Message: "failed", Time: e.Time})
+	if expected := "\x1b[2m08:39:28.005\x1b[0m \x1b[1;31mERROR\x1b[0m failed"; result != expected {
+		t.Errorf("DevFormatter() = %q, expected %q", result, expected)
+	}
+
+	format := &log.DevFormat{TimeLayout: time.RFC3339}
+	if result := string(format.Append(nil, nil, e)); !strings.HasPrefix(result, "2015-10-22T08:39:28Z WARN ") {
+		t.Errorf("DevFormat.Append() = %q, expected the time in RFC3339", result)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"sync/atomic"
+)
+
+// DropRule describes messages to be dropped. A message matches the rule when it meets all the conditions
+// which are set, and a rule without conditions matches no message.
+type DropRule struct {
+	Name     string            `json:"name"`     // identifies the rule
+	Message  string            `json:"message"`  // a regular expression the message should match
+	Category string            `json:"category"` // the category of the message
+	Fields   map[string]string `json:"fields"`   // the values of fields, compared with their values formatted by fmt.Sprint
+}
+
+// DropRuleStatus describes a rule of a DropFilter and the number of messages it has dropped.
+type DropRuleStatus struct {
+	DropRule
+	Matches uint64 `json:"matches"`
+}
+
+// dropRule is a DropRule in use. The counter is first to be aligned for atomic operations.
+type dropRule struct {
+	matches uint64
+	rule    DropRule
+	message *regexp.Regexp
+}
+
+func (r *dropRule) match(e *Entry) bool {
+	if r.rule.Category != "" && r.rule.Category != e.Category {
+		return false
+	}
+	for name, value := range r.rule.Fields {
+		v, ok := e.Fields[name]
+		if !ok || fmt.Sprint(v) != value {
+			return false
+		}
+	}
+	return r.message == nil || r.message.MatchString(e.Message)
+}
+
+// DropFilter drops the messages matching its rules, which can be added and removed while logging,
+// so that known noisy messages are suppressed in production without a deploy. Add its Hook to a logger
+// so that the messages are dropped before they are queued:
+//
+//	drops := log.NewDropFilter()
+//	logger.AddHook(drops.Hook)
+//	drops.Add(log.DropRule{Name: "health checks", Category: "http", Fields: map[string]string{"uri": "/health"}})
+//
+// Fatal messages are never dropped.
+type DropFilter struct {
+	lock  sync.Mutex   // serializes the changes of the rules
+	rules atomic.Value // the []*dropRule in use, replaced as a whole
+}
+
+// NewDropFilter creates a DropFilter without rules.
+func NewDropFilter() *DropFilter {
+	return &DropFilter{}
+}
+
+// Add adds a rule, or replaces the rule with the same name, whose count is reset.
+// An error is returned if the rule has no name or no condition, or if its message pattern is invalid.
+func (f *DropFilter) Add(rule DropRule) error {
+	if rule.Name == "" {
+		return errors.New("DropRule.Name must be specified")
+	}
+	if rule.Message == "" && rule.Category == "" && len(rule.Fields) == 0 {
+		return fmt.Errorf("DropRule %q has no condition", rule.Name)
+	}
+	r := &dropRule{rule: rule}
+	if rule.Message != "" {
+		var err error
+		if r.message, err = regexp.Compile(rule.Message); err != nil {
+			return fmt.Errorf("DropRule %q: %v", rule.Name, err)
+		}
+	}
+	fields := make(map[string]string, len(rule.Fields))
+	for name, value := range rule.Fields {
+		fields[name] = value
+	}
+	r.rule.Fields = fields
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	rules := []*dropRule{}
+	for _, old := range f.current() {
+		if old.rule.Name != rule.Name {
+			rules = append(rules, old)
+		}
+	}
+	f.rules.Store(append(rules, r))
+	return nil
+}
+
+// Remove removes the rule with the given name. It returns false if there is no such rule.
+func (f *DropFilter) Remove(name string) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	rules := []*dropRule{}
+	for _, r := range f.current() {
+		if r.rule.Name != name {
+			rules = append(rules, r)
+		}
+	}
+	if len(rules) == len(f.current()) {
+		return false
+	}
+	f.rules.Store(rules)
+	return true
+}
+
+// Rules returns the rules in the order they were added, with the number of messages each has dropped.
+func (f *DropFilter) Rules() []DropRuleStatus {
+	rules := f.current()
+	statuses := make([]DropRuleStatus, 0, len(rules))
+	for _, r := range rules {
+		statuses = append(statuses, DropRuleStatus{DropRule: r.rule, Matches: atomic.LoadUint64(&r.matches)})
+	}
+	return statuses
+}
+
+func (f *DropFilter) current() []*dropRule {
+	rules, _ := f.rules.Load().([]*dropRule)
+	return rules
+}
+
+// Hook drops the entry if it matches a rule, which is credited with it.
+func (f *DropFilter) Hook(e *Entry) *Entry {
+	if e.Level == LevelFatal {
+		return e
+	}
+	for _, r := range f.current() {
+		if r.match(e) {
+			atomic.AddUint64(&r.matches, 1)
+			return nil
+		}
+	}
+	return e
+}
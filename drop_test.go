@@ -0,0 +1,57 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestDropFilter(t *testing.T) {
+	drops := log.NewDropFilter()
+	if err := drops.Add(log.DropRule{Name: "empty"}); err == nil {
+		t.Errorf("Add() = nil for a rule without condition, expected an error")
+	}
+	if err := drops.Add(log.DropRule{Name: "bad", Message: "("}); err == nil {
+		t.Errorf("Add() = nil for an invalid pattern, expected an error")
+	}
+	if err := drops.Add(log.DropRule{Name: "health", Category: "http", Fields: map[string]string{"uri": "/health", "status": "200"}}); err != nil {
+		t.Errorf("Add() = %v, expected no error", err)
+	}
+	if err := drops.Add(log.DropRule{Name: "noise", Message: `^cache (hit|miss)`}); err != nil {
+		t.Errorf("Add() = %v, expected no error", err)
+	}
+
+	logger := log.NewLogger()
+	logger.Sync()
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	logger.AddHook(drops.Hook)
+	http := logger.GetLogger("http")
+	http.With("uri", "/health", "status", 200).Info("request")
+	http.With("uri", "/health", "status", 500).Info("request failed")
+	http.With("uri", "/users", "status", 200).Info("request")
+	logger.Info("cache hit")
+	logger.Info("cache hit")
+	drops.Remove("noise")
+	if drops.Remove("noise") {
+		t.Errorf("Remove() = true for a removed rule, expected false")
+	}
+	logger.Info("cache miss")
+	logger.Close()
+
+	var messages []string
+	for _, e := range target.entries {
+		messages = append(messages, e.Message)
+	}
+	if len(messages) != 3 || messages[0] != "request failed" || messages[1] != "request" || messages[2] != "cache miss" {
+		t.Errorf("messages = %q, expected those not matching the rules", messages)
+	}
+	rules := drops.Rules()
+	if len(rules) != 1 || rules[0].Name != "health" || rules[0].Matches != 1 {
+		t.Errorf("Rules() = %+v, expected the health rule with 1 match", rules)
+	}
+}
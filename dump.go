@@ -0,0 +1,58 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+)
+
+// DefaultMaxDumpSize is the maximum number of bytes attached to a message by DebugDump when MaxDumpSize is 0.
+const DefaultMaxDumpSize = 4 << 10
+
+// DebugDump logs a debug message attaching binary data, e.g. a packet when debugging a protocol, so that the dump
+// lands in the same log stream as the other messages. The message is the label followed by the size of the data.
+// The data beyond MaxDumpSize is left out. The text formatters write the data as a hex dump following the message,
+// and JSONFormatter in base64 as "raw".
+func (l *Logger) DebugDump(label string, data []byte) {
+	if !l.Enabled(LevelDebug) {
+		return
+	}
+	max := l.MaxDumpSize
+	if max == 0 {
+		max = DefaultMaxDumpSize
+	}
+	message := label + " (" + strconv.Itoa(len(data)) + " bytes"
+	if max > 0 && len(data) > max {
+		message += ", first " + strconv.Itoa(max) + " dumped"
+		data = data[:max]
+	}
+	entry := l.makeEntry(LevelDebug, message+")")
+	// the data may be modified by the caller once logged
+	entry.Raw = append([]byte(nil), data...)
+	if stack := &l.current().stack; stack.depth > 0 {
+		entry.CallStack = callFrames(1, stack.depth, stack.keepFrame, true, l.callerSkip)
+	}
+	l.dispatch(entry)
+}
+
+// appendHexDump appends binary data as a hex dump like hex.Dump, starting on a new line.
+func appendHexDump(buf []byte, data []byte) []byte {
+	if len(data) == 0 {
+		return buf
+	}
+	dump := hex.Dump(data)
+	buf = append(buf, '\n')
+	return append(buf, dump[:len(dump)-1]...)
+}
+
+// appendBase64 appends binary data encoded in standard base64.
+func appendBase64(buf []byte, data []byte) []byte {
+	n := len(buf)
+	buf = append(buf, make([]byte, base64.StdEncoding.EncodedLen(len(data)))...)
+	base64.StdEncoding.Encode(buf[n:], data)
+	return buf
+}
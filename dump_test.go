@@ -0,0 +1,46 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestDebugDump(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	logger.MaxDumpSize = 20
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+
+	data := []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	logger.DebugDump("request", data)
+	data[0] = 'P'
+	logger.GetLogger("app", log.JSONFormatter).DebugDump("ping", []byte{0, 1, 2})
+	logger.SetMaxLevel(log.LevelInfo)
+	logger.DebugDump("skipped", data)
+	logger.Close()
+
+	if len(target.entries) != 2 {
+		t.Fatalf("len(target.entries) = %v, expected %v", len(target.entries), 2)
+	}
+	e := target.entries[0]
+	if e.Level != log.LevelDebug || e.Message != "request (37 bytes, first 20 dumped)" || !bytes.Equal(e.Raw, []byte("GET / HTTP/1.1\r\nHost")) {
+		t.Errorf("entries[0] = %v %q %q, expected the first 20 bytes", e.Level, e.Message, e.Raw)
+	}
+	expected := "|Debug|app|request (37 bytes, first 20 dumped)\n" +
+		"00000000  47 45 54 20 2f 20 48 54  54 50 2f 31 2e 31 0d 0a  |GET / HTTP/1.1..|\n" +
+		"00000010  48 6f 73 74                                       |Host|"
+	if !strings.HasSuffix(e.String(), expected) {
+		t.Errorf("entries[0] = %q, expected the hex dump %q", e.String(), expected)
+	}
+	if e := target.entries[1]; !strings.Contains(e.String(), `"message":"ping (3 bytes)"`) || !strings.Contains(e.String(), `"raw":"AAEC"`) {
+		t.Errorf("entries[1] = %q, expected the data in base64", e.String())
+	}
+}
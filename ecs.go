@@ -0,0 +1,131 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ECSVersion is the version of the Elastic Common Schema written by ECSFormatter.
+const ECSVersion = "1.6.0"
+
+// ecsTimeLayout is the layout of @timestamp, which ECS requires in ISO 8601.
+const ecsTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// ECSFieldNames maps the names of the fields to the names of the matching ECS fields, which ECSFormatter
+// writes instead. It holds the fields of the HTTP middlewares and of Logger.Access, and more may be added
+// before logging. The other fields are written under their own names.
+var ECSFieldNames = map[string]string{
+	"client_ip":  "client.ip",
+	"user":       "user.name",
+	"method":     "http.request.method",
+	"uri":        "url.original",
+	"path":       "url.path",
+	"route":      "http.route",
+	"referer":    "http.request.referrer",
+	"status":     "http.response.status_code",
+	"size":       "http.response.body.bytes",
+	"user_agent": "user_agent.original",
+}
+
+// ecsReserved are the ECS fields written from the entries. A field mapped to one of them is written under labels.
+var ecsReserved = map[string]bool{
+	"@timestamp":           true,
+	"log.level":            true,
+	"message":              true,
+	"ecs.version":          true,
+	"log.logger":           true,
+	"event.sequence":       true,
+	"trace.id":             true,
+	"span.id":              true,
+	"error.message":        true,
+	"error.type":           true,
+	"error.stack_trace":    true,
+	"log.origin.file.name": true,
+	"log.origin.file.line": true,
+	"log.origin.function":  true,
+}
+
+// ECSFormatter formats log messages as JSON documents conforming to the Elastic Common Schema, so that they
+// can be shipped to Elasticsearch as is. The entries are mapped to @timestamp, in UTC, log.level, in lower case,
+// message, log.logger for the category, event.sequence, trace.id, span.id, error.message, error.type and
+// error.stack_trace, which holds the call stack of the error, or else of the message. The first frame of
+// the call stack is written as log.origin. The fields are written under the names given by ECSFieldNames,
+// or under labels if their names clash with the fields above.
+func ECSFormatter(l *Logger, e *Entry) string {
+	return formatWith(AppendECS, l, e)
+}
+
+// AppendECS appends a message formatted like ECSFormatter does.
+func AppendECS(buf []byte, l *Logger, e *Entry) []byte {
+	buf = append(buf, `{"@timestamp":"`...)
+	buf = e.Time.UTC().AppendFormat(buf, ecsTimeLayout)
+	buf = append(buf, `","log.level":`...)
+	buf = appendJSONString(buf, strings.ToLower(e.Level.String()))
+	buf = append(buf, `,"message":`...)
+	buf = appendJSONString(buf, e.Message)
+	buf = append(buf, `,"ecs.version":"`+ECSVersion+`"`...)
+	if e.Category != "" {
+		buf = append(buf, `,"log.logger":`...)
+		buf = appendJSONString(buf, e.Category)
+	}
+	if e.Seq != 0 {
+		buf = append(buf, `,"event.sequence":`...)
+		buf = strconv.AppendUint(buf, e.Seq, 10)
+	}
+	if e.TraceID != "" {
+		buf = append(buf, `,"trace.id":`...)
+		buf = appendJSONString(buf, e.TraceID)
+	}
+	if e.SpanID != "" {
+		buf = append(buf, `,"span.id":`...)
+		buf = appendJSONString(buf, e.SpanID)
+	}
+	if e.Error != nil {
+		buf = append(buf, `,"error.message":`...)
+		buf = appendJSONString(buf, e.Error.Error())
+		buf = append(buf, `,"error.type":`...)
+		buf = appendJSONString(buf, reflect.TypeOf(e.Error).String())
+	}
+	stack := ""
+	if e.Error != nil {
+		stack = ErrorStack(e.Error)
+	}
+	if stack == "" && len(e.CallStack) > 0 {
+		stack = e.CallStack.String()
+	}
+	if stack != "" {
+		buf = append(buf, `,"error.stack_trace":`...)
+		buf = appendJSONString(buf, strings.TrimPrefix(stack, "\n"))
+	}
+	if len(e.CallStack) > 0 {
+		f := e.CallStack[0]
+		buf = append(buf, `,"log.origin.file.name":`...)
+		buf = appendJSONString(buf, f.File)
+		buf = append(buf, `,"log.origin.file.line":`...)
+		buf = strconv.AppendInt(buf, int64(f.Line), 10)
+		if f.Func != "" {
+			buf = append(buf, `,"log.origin.function":`...)
+			buf = appendJSONString(buf, f.Func)
+		}
+	}
+	var array [16]string
+	for _, k := range e.Fields.sortedKeys(&array) {
+		name := k
+		if ecsName, ok := ECSFieldNames[k]; ok {
+			name = ecsName
+		}
+		if ecsReserved[name] {
+			name = "labels." + k
+		}
+		buf = append(buf, ',')
+		buf = appendJSONString(buf, name)
+		buf = append(buf, ':')
+		buf = appendJSONValue(buf, e.Fields[k])
+	}
+	return append(buf, '}')
+}
@@ -0,0 +1,48 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+func TestECSFormatter(t *testing.T) {
+	e := &log.Entry{
+		Level:     log.LevelError,
+		Category:  "app",
+		Message:   "request failed",
+		Time:      time.Date(2015, 10, 22, 8, 39, 28, 5e6, time.FixedZone("", -4*3600)),
+		Seq:       7,
+		TraceID:   "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:    "00f067aa0ba902b7",
+		Fields:    log.Fields{"status": 500, "message": "shadowed", "tenant": "acme"},
+		Error:     errors.New("timeout"),
+		CallStack: log.CallStack{{File: "/app/main.go", Line: 12, Func: "main.main"}},
+	}
+	result := log.ECSFormatter(log.NewLogger(), e)
+	expected := `{"@timestamp":"2015-10-22T12:39:28.005Z","log.level":"error","message":"request failed","ecs.version":"1.6.0",` +
+		`"log.logger":"app","event.sequence":7,"trace.id":"4bf92f3577b34da6a3ce929d0e0e4736","span.id":"00f067aa0ba902b7",` +
+		`"error.message":"timeout","error.type":"*errors.errorString","error.stack_trace":"/app/main.go:12",` +
+		`"log.origin.file.name":"/app/main.go","log.origin.file.line":12,"log.origin.function":"main.main",` +
+		`"labels.message":"shadowed","http.response.status_code":500,"tenant":"acme"}`
+	if result != expected {
+		t.Errorf("ECSFormatter() = %v, expected %v", result, expected)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(result), &doc); err != nil {
+		t.Errorf("json.Unmarshal() = %v, expected a JSON document", err)
+	}
+
+	e = &log.Entry{Level: log.LevelInfo, Message: "started", Time: time.Date(2015, 10, 22, 8, 39, 28, 0, time.UTC)}
+	expected = `{"@timestamp":"2015-10-22T08:39:28.000Z","log.level":"info","message":"started","ecs.version":"1.6.0"}`
+	if result := log.ECSFormatter(nil, e); result != expected {
+		t.Errorf("ECSFormatter() = %v, expected %v", result, expected)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"strings"
+	"time"
+)
+
+// elevation is a level raised by ElevateFor for a category, "" for every category.
+type elevation struct {
+	category string
+	level    Level
+}
+
+// WithLevel returns a logger logging the messages up to the given level, whatever the maximum levels of
+// the logger and of its category, e.g. to log a single request or job at the Debug level.
+// The filters of the targets still apply.
+func (l *Logger) WithLevel(level Level) *Logger {
+	logger := l.clone()
+	logger.level = &level
+	return logger
+}
+
+// ElevateFor raises the maximum level of the messages to the given level for a while, then reverts it
+// automatically, e.g. to log at the Debug level during a live incident. Without categories, it applies to
+// every message, otherwise to the messages of the categories and of their children. A maximum level which
+// is already higher is left as is, and so are the levels set meanwhile, which apply once the elevation ends.
+// ElevateFor returns a function which ends the elevation early.
+func (l *Logger) ElevateFor(d time.Duration, level Level, categories ...string) (cancel func()) {
+	if len(categories) == 0 {
+		categories = []string{""}
+	}
+	var added []*elevation
+	for _, category := range categories {
+		added = append(added, &elevation{category: category, level: level})
+	}
+	l.lock.Lock()
+	l.setElevations(append(append([]*elevation{}, l.elevations...), added...))
+	l.lock.Unlock()
+
+	timer := time.AfterFunc(d, func() { l.endElevations(added) })
+	return func() {
+		timer.Stop()
+		l.endElevations(added)
+	}
+}
+
+// endElevations removes elevations, which may have been removed already.
+func (l *coreLogger) endElevations(ended []*elevation) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	removed := map[*elevation]bool{}
+	for _, e := range ended {
+		removed[e] = true
+	}
+	var elevations []*elevation
+	for _, e := range l.elevations {
+		if !removed[e] {
+			elevations = append(elevations, e)
+		}
+	}
+	l.setElevations(elevations)
+}
+
+// setElevations replaces the elevations in use. It must be called with l.lock held.
+func (l *coreLogger) setElevations(elevations []*elevation) {
+	l.elevations = elevations
+	l.update(func(c *loggerConfig) {
+		c.elevated = elevatedLevels(elevations)
+	})
+}
+
+// elevatedLevels returns the highest level each category is raised to, or nil if there is no elevation.
+func elevatedLevels(elevations []*elevation) map[string]Level {
+	if len(elevations) == 0 {
+		return nil
+	}
+	levels := map[string]Level{}
+	for _, e := range elevations {
+		if level, ok := levels[e.category]; !ok || e.level > level {
+			levels[e.category] = e.level
+		}
+	}
+	return levels
+}
+
+// elevatedLevel returns the highest level a category is raised to by the elevations of the category,
+// of its parents and of every category.
+func (c *loggerConfig) elevatedLevel(category string) (Level, bool) {
+	if len(c.elevated) == 0 {
+		return 0, false
+	}
+	var (
+		highest Level
+		found   bool
+	)
+	for {
+		if level, ok := c.elevated[category]; ok && (!found || level > highest) {
+			highest, found = level, true
+		}
+		if category == "" {
+			return highest, found
+		}
+		if i := strings.LastIndexByte(category, '.'); i >= 0 {
+			category = category[:i]
+		} else {
+			category = ""
+		}
+	}
+}
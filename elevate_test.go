@@ -0,0 +1,73 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+func TestWithLevel(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	logger.SetMaxLevel(log.LevelInfo)
+	logger.SetCategoryLevel("app.db", log.LevelError)
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+
+	verbose := logger.WithLevel(log.LevelDebug)
+	verbose.Debug("d1")
+	logger.Debug("d2")
+	verbose.GetLogger("app.db").Debug("d3")
+	logger.GetLogger("app.db").Warn("w1")
+	logger.WithLevel(log.LevelError).Warn("w2")
+	logger.Close()
+
+	if len(target.entries) != 2 || target.entries[0].Message != "d1" || target.entries[1].Message != "d3" {
+		t.Errorf("messages = %v, expected d1 and d3", len(target.entries))
+	}
+}
+
+func TestElevateFor(t *testing.T) {
+	logger := log.NewLogger()
+	defer logger.Close()
+	logger.SetMaxLevel(log.LevelInfo)
+	logger.SetCategoryLevel("app.db", log.LevelWarn)
+	db := logger.GetLogger("app.db.query")
+
+	cancel := logger.ElevateFor(time.Hour, log.LevelDebug, "app.db")
+	if !db.Enabled(log.LevelDebug) || logger.Enabled(log.LevelDebug) {
+		t.Errorf("Enabled(LevelDebug) = %v %v, expected only app.db.query to be elevated", db.Enabled(log.LevelDebug), logger.Enabled(log.LevelDebug))
+	}
+	if tree := logger.LevelTree(); !strings.Contains(tree, "app.db Warn, elevated to Debug\n") {
+		t.Errorf("LevelTree() = %q, expected the elevation", tree)
+	}
+	cancel()
+	if db.Enabled(log.LevelInfo) {
+		t.Errorf("Enabled(LevelInfo) = true once the elevation is canceled, expected false")
+	}
+
+	// a level which is already higher is left as is
+	cancel = logger.ElevateFor(time.Hour, log.LevelError)
+	if !logger.Enabled(log.LevelInfo) {
+		t.Errorf("Enabled(LevelInfo) = false, expected the elevation to Error to leave the level Info")
+	}
+	cancel()
+
+	logger.ElevateFor(20*time.Millisecond, log.LevelDebug)
+	if !db.Enabled(log.LevelDebug) || !logger.Enabled(log.LevelDebug) {
+		t.Errorf("Enabled(LevelDebug) = false, expected every category to be elevated")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for logger.Enabled(log.LevelDebug) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if logger.Enabled(log.LevelDebug) || !logger.Enabled(log.LevelInfo) {
+		t.Errorf("Enabled() = %v %v once the elevation has ended, expected the level Info", logger.Enabled(log.LevelDebug), logger.Enabled(log.LevelInfo))
+	}
+}
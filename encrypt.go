@@ -0,0 +1,81 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// An encrypted log file is a sequence of records, each made of the 4-byte big-endian length of the rest
+// of the record, a random 12-byte nonce and the AES-GCM ciphertext of a message, including its tag.
+// Package logdecrypt reads them.
+
+// EncryptionNonceSize is the size of the nonce of a record of an encrypted log file.
+const EncryptionNonceSize = 12
+
+// NewEncryptionCipher returns the AES-GCM cipher of the encrypted log files for a 16, 24 or 32-byte key.
+func NewEncryptionCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ReadKeyFile reads an encryption key from a file holding it either as raw bytes or encoded in hex or base64.
+// The surrounding white space is ignored.
+func ReadKeyFile(fileName string) ([]byte, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	text := string(bytes.TrimSpace(data))
+	if key, err := hex.DecodeString(text); err == nil && isKeySize(len(key)) {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(text); err == nil && isKeySize(len(key)) {
+		return key, nil
+	}
+	if isKeySize(len(text)) {
+		return []byte(text), nil
+	}
+	if isKeySize(len(data)) {
+		return data, nil
+	}
+	return nil, errors.New("the key file must hold a 16, 24 or 32-byte key")
+}
+
+func isKeySize(n int) bool {
+	return n == 16 || n == 24 || n == 32
+}
+
+// encryptWriter encrypts each write into a record of an encrypted log file.
+type encryptWriter struct {
+	w    io.Writer
+	aead cipher.AEAD
+}
+
+func (w encryptWriter) Write(p []byte) (int, error) {
+	record := make([]byte, 4+EncryptionNonceSize, 4+EncryptionNonceSize+len(p)+w.aead.Overhead())
+	nonce := record[4:]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return 0, err
+	}
+	record = w.aead.Seal(record, nonce, p, nil)
+	binary.BigEndian.PutUint32(record, uint32(len(record)-4))
+	if _, err := w.w.Write(record); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,42 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestReadKeyFile(t *testing.T) {
+	keyFile := "log.key"
+	defer os.Remove(keyFile)
+	for _, content := range []string{"30313233343536373839616263646566\n", "MDEyMzQ1Njc4OWFiY2RlZg==", "0123456789abcdef"} {
+		ioutil.WriteFile(keyFile, []byte(content), 0600)
+		if k, err := log.ReadKeyFile(keyFile); err != nil || string(k) != "0123456789abcdef" {
+			t.Errorf("ReadKeyFile() = %q, %v for %q, expected the key", k, err, content)
+		}
+	}
+	ioutil.WriteFile(keyFile, []byte("short"), 0600)
+	if _, err := log.ReadKeyFile(keyFile); err == nil {
+		t.Errorf("ReadKeyFile() = nil error for a short key, expected an error")
+	}
+}
+
+func TestFileTargetEncryption(t *testing.T) {
+	target := log.NewFileTarget()
+	target.FileName = "app.log"
+	target.EncryptionKey = []byte("short")
+	if err := target.Open(ioutil.Discard); err == nil {
+		t.Errorf("Open() = nil, expected an error for an invalid key")
+	}
+	target.EncryptionKey = nil
+	target.EncryptionKeyFile = "missing.key"
+	if err := target.Open(ioutil.Discard); err == nil {
+		t.Errorf("Open() = nil, expected an error for a missing key file")
+	}
+}
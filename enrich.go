@@ -0,0 +1,44 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import "os"
+
+// Enricher attaches static fields, such as the host name and the process ID, to every entry,
+// so that the targets do not need to add them separately. The fields of an entry take precedence
+// over the static fields of the same names. Add its Hook to a logger once it is configured:
+//
+//	enricher := log.NewEnricher("1.2.0")
+//	enricher.Fields["env"] = "prod"
+//	logger.AddHook(enricher.Hook)
+type Enricher struct {
+	Fields Fields // the fields attached to every entry
+}
+
+// NewEnricher creates an Enricher attaching the host name ("host"), the process ID ("pid")
+// and, if not empty, the application version ("version").
+func NewEnricher(version string) *Enricher {
+	fields := Fields{"pid": os.Getpid()}
+	if host, err := os.Hostname(); err == nil {
+		fields["host"] = host
+	}
+	if version != "" {
+		fields["version"] = version
+	}
+	return &Enricher{Fields: fields}
+}
+
+// Hook attaches the static fields to an entry. It can be added to a logger with Logger.AddHook.
+func (r *Enricher) Hook(e *Entry) *Entry {
+	fields := make(Fields, len(r.Fields)+len(e.Fields))
+	for name, value := range r.Fields {
+		fields[name] = value
+	}
+	for name, value := range e.Fields {
+		fields[name] = value
+	}
+	e.Fields = fields
+	return e
+}
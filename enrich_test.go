@@ -0,0 +1,36 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestEnricher(t *testing.T) {
+	enricher := log.NewEnricher("1.2.0")
+	enricher.Fields["env"] = "prod"
+	if enricher.Fields["pid"] != os.Getpid() {
+		t.Errorf("Fields[pid] = %v, expected %v", enricher.Fields["pid"], os.Getpid())
+	}
+	if host, _ := os.Hostname(); enricher.Fields["host"] != host {
+		t.Errorf("Fields[host] = %v, expected %v", enricher.Fields["host"], host)
+	}
+
+	own := log.Fields{"env": "dev", "id": 1}
+	e := enricher.Hook(&log.Entry{Fields: own})
+	if e.Fields["version"] != "1.2.0" || e.Fields["env"] != "dev" || e.Fields["id"] != 1 {
+		t.Errorf("Fields = %v, expected version 1.2.0, env dev and id 1", e.Fields)
+	}
+	if len(own) != 2 {
+		t.Errorf("the fields of the entry were modified")
+	}
+
+	if _, ok := log.NewEnricher("").Fields["version"]; ok {
+		t.Errorf("NewEnricher(\"\") should not attach a version")
+	}
+}
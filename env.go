@@ -0,0 +1,85 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FromEnv creates a root logger like NewLogger and configures it from these environment variables:
+//
+//	LOG_LEVEL    the maximum level of messages to be logged, e.g. "info"
+//	LOG_FORMAT   the formatter: "json", "normal", "default" or "dev"
+//	LOG_SYNC     whether to log in the synchronous mode, e.g. "true"
+//	LOG_FILE     the name of a file to log to in addition to the console
+//	LOG_CONSOLE  whether to log to the console, "true" by default
+//
+// Invalid values are reported to the ErrorWriter of the logger and ignored.
+func FromEnv(args ...string) *Logger {
+	l := NewLogger(args...)
+	invalid := func(name, value string) {
+		fmt.Fprintf(l.ErrorWriter, "Invalid value of %v: %q\n", name, value)
+	}
+
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		if level, ok := GetLevel(v); ok {
+			l.SetMaxLevel(level)
+		} else {
+			invalid("LOG_LEVEL", v)
+		}
+	}
+	// the dev formatter colors the messages itself
+	colorMode := true
+	if v := os.Getenv("LOG_FORMAT"); v != "" {
+		switch strings.ToLower(v) {
+		case "json":
+			l.SetFormatter(JSONFormatter)
+		case "normal":
+			l.SetFormatter(NormalFormatter)
+		case "default":
+			l.SetFormatter(DefaultFormatter)
+		case "dev":
+			l.SetFormatter(DevFormatter)
+			colorMode = false
+		default:
+			invalid("LOG_FORMAT", v)
+		}
+	}
+	if v := os.Getenv("LOG_SYNC"); v != "" {
+		if sync, err := strconv.ParseBool(v); err == nil {
+			l.Sync(sync)
+		} else {
+			invalid("LOG_SYNC", v)
+		}
+	}
+
+	console := true
+	if v := os.Getenv("LOG_CONSOLE"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			console = b
+		} else {
+			invalid("LOG_CONSOLE", v)
+		}
+	}
+	file := os.Getenv("LOG_FILE")
+	if !console || file != "" || !colorMode {
+		var targets []Target
+		if console {
+			target := NewConsoleTarget()
+			target.ColorMode = colorMode
+			targets = append(targets, target)
+		}
+		if file != "" {
+			target := NewFileTarget()
+			target.FileName = file
+			targets = append(targets, target)
+		}
+		l.SetTarget(targets...)
+	}
+	return l
+}
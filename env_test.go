@@ -0,0 +1,75 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestFromEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logenv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "app.log")
+
+	t.Setenv("LOG_LEVEL", "warn")
+	t.Setenv("LOG_FORMAT", "json")
+	t.Setenv("LOG_SYNC", "true")
+	t.Setenv("LOG_FILE", file)
+	t.Setenv("LOG_CONSOLE", "false")
+
+	logger := log.FromEnv("env")
+	if logger.MaxLevel != log.LevelWarn || !logger.SyncMode || logger.Category != "env" {
+		t.Errorf("logger = %v %v %v, expected Warn, the synchronous mode and env", logger.MaxLevel, logger.SyncMode, logger.Category)
+	}
+	if len(logger.Targets) != 1 {
+		t.Fatalf("len(logger.Targets) = %v, expected %v", len(logger.Targets), 1)
+	}
+	if _, ok := logger.Targets[0].(*log.FileTarget); !ok {
+		t.Errorf("logger.Targets[0] is %T, expected *log.FileTarget", logger.Targets[0])
+	}
+	logger.Info("skipped")
+	logger.Error("logged")
+	logger.Close()
+
+	data, _ := ioutil.ReadFile(file)
+	if s := string(data); strings.Contains(s, "skipped") || !strings.Contains(s, `"message":"logged"`) {
+		t.Errorf("the file contains %q, expected only the error in JSON", s)
+	}
+}
+
+func TestFromEnvInvalid(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "loud")
+	t.Setenv("LOG_FORMAT", "")
+	t.Setenv("LOG_SYNC", "")
+	t.Setenv("LOG_FILE", "")
+	t.Setenv("LOG_CONSOLE", "")
+
+	var buf bytes.Buffer
+	stderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+	logger := log.FromEnv()
+	os.Stderr = stderr
+	w.Close()
+	buf.ReadFrom(r)
+	logger.Close()
+
+	if logger.MaxLevel != log.LevelDebug {
+		t.Errorf("logger.MaxLevel = %v, expected %v", logger.MaxLevel, log.LevelDebug)
+	}
+	if !strings.Contains(buf.String(), `Invalid value of LOG_LEVEL: "loud"`) {
+		t.Errorf("the error output is %q, expected the invalid LOG_LEVEL", buf.String())
+	}
+}
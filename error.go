@@ -0,0 +1,70 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// StackTracer is implemented by errors which record the call stack where they were created.
+// The errors of packages such as github.com/pkg/errors, whose StackTrace method returns
+// a value printing the stack with the "%+v" verb, are supported as well.
+type StackTracer interface {
+	StackTrace() string
+}
+
+// WithError returns a logger whose messages carry the given error.
+// The built-in formatters write the error message, its type and its call stack, if any.
+func (l *Logger) WithError(err error) *Logger {
+	logger := l.clone()
+	logger.err = err
+	return logger
+}
+
+// ErrIf logs an error message carrying err, with the given alternating keys and values attached as fields,
+// if err is not nil. It returns whether err is not nil, so that the caller can return early:
+//
+//	if logger.ErrIf(conn.Close(), "closing the connection", "addr", addr) {
+//		return
+//	}
+func (l *Logger) ErrIf(err error, message string, keysAndValues ...interface{}) bool {
+	return l.logIf(LevelError, err, message, keysAndValues)
+}
+
+// WarnIf logs a warning message carrying err if err is not nil, like ErrIf.
+func (l *Logger) WarnIf(err error, message string, keysAndValues ...interface{}) bool {
+	return l.logIf(LevelWarn, err, message, keysAndValues)
+}
+
+// FatalIf logs a fatal message carrying err if err is not nil, like ErrIf.
+// The fatal action of the logger is then performed, see SetFatalAction.
+func (l *Logger) FatalIf(err error, message string, keysAndValues ...interface{}) bool {
+	return l.logIf(LevelFatal, err, message, keysAndValues)
+}
+
+func (l *Logger) logIf(level Level, err error, message string, keysAndValues []interface{}) bool {
+	if err == nil {
+		return false
+	}
+	l.WithError(err).Logw(level, message, keysAndValues...)
+	return true
+}
+
+// ErrorStack returns the call stack recorded by err or by the first error it wraps which records one.
+// It returns an empty string if there is none.
+func ErrorStack(err error) string {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if st, ok := err.(StackTracer); ok {
+			return st.StackTrace()
+		}
+		method := reflect.ValueOf(err).MethodByName("StackTrace")
+		if method.IsValid() && method.Type().NumIn() == 0 && method.Type().NumOut() == 1 {
+			return fmt.Sprintf("%+v", method.Call(nil)[0].Interface())
+		}
+	}
+	return ""
+}
@@ -0,0 +1,124 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+type stackError struct {
+	msg string
+}
+
+func (e *stackError) Error() string      { return e.msg }
+func (e *stackError) StackTrace() string { return "main.go:10" }
+
+// frames mimics the StackTrace type of github.com/pkg/errors, which prints the stack with %+v.
+type frames []string
+
+func (f frames) Format(s fmt.State, verb rune) {
+	if s.Flag('+') {
+		fmt.Fprint(s, "\n"+strings.Join(f, "\n"))
+	}
+}
+
+type pkgError struct{}
+
+func (e pkgError) Error() string      { return "pkg" }
+func (e pkgError) StackTrace() frames { return frames{"a.go:1", "b.go:2"} }
+
+func TestErrorStack(t *testing.T) {
+	wrapped := fmt.Errorf("query: %w", &stackError{"timeout"})
+	if stack := log.ErrorStack(wrapped); stack != "main.go:10" {
+		t.Errorf("ErrorStack() = %q, expected %q", stack, "main.go:10")
+	}
+	if stack := log.ErrorStack(pkgError{}); stack != "\na.go:1\nb.go:2" {
+		t.Errorf("ErrorStack() = %q, expected %q", stack, "\na.go:1\nb.go:2")
+	}
+	if stack := log.ErrorStack(errors.New("plain")); stack != "" {
+		t.Errorf("ErrorStack() = %q, expected %q", stack, "")
+	}
+}
+
+func TestLoggerWithError(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	target := &MemoryTarget{
+		Filter: &log.Filter{MaxLevel: log.LevelDebug},
+		ready:  make(chan bool, 0),
+	}
+	logger.SetTarget(target)
+	defer logger.Close()
+
+	err := fmt.Errorf("query: %w", &stackError{"timeout"})
+	logger.WithError(err).Error("failed")
+	logger.GetLogger("sub", log.JSONFormatter).WithError(err).Error("failed")
+	logger.Info("ok")
+
+	e := target.entries[0]
+	if e.Error != err {
+		t.Errorf("Error = %v, expected %v", e.Error, err)
+	}
+	expected := `|failed error="query: timeout" error_type=*fmt.wrapError` + "\nmain.go:10"
+	if !strings.HasSuffix(e.FormattedMessage, expected) {
+		t.Errorf("FormattedMessage = %q, expected the suffix %q", e.FormattedMessage, expected)
+	}
+
+	var jsonl log.JSONL
+	if err := json.Unmarshal([]byte(target.entries[1].FormattedMessage), &jsonl); err != nil {
+		t.Fatalf("json.Unmarshal(): %v", err)
+	}
+	if jsonl.Error == nil || jsonl.Error.Message != "query: timeout" || jsonl.Error.Stack != "main.go:10" {
+		t.Errorf("JSONL.Error = %+v, expected the error with its stack", jsonl.Error)
+	}
+	if target.entries[2].Error != nil {
+		t.Errorf("Error = %v, expected nil", target.entries[2].Error)
+	}
+}
+
+func TestLoggerErrIf(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	target := &MemoryTarget{
+		Filter: &log.Filter{MaxLevel: log.LevelDebug},
+		ready:  make(chan bool, 0),
+	}
+	logger.SetTarget(target)
+	var fatal *log.Entry
+	logger.OnFatal(func(e *log.Entry) {
+		fatal = e
+	})
+
+	err := errors.New("refused")
+	if logger.ErrIf(nil, "connecting") || logger.WarnIf(nil, "closing") || logger.FatalIf(nil, "listening") {
+		t.Errorf("ErrIf(nil) = true, expected false")
+	}
+	if !logger.ErrIf(err, "connecting", "addr", "localhost:1") {
+		t.Errorf("ErrIf(err) = false, expected true")
+	}
+	logger.WarnIf(err, "closing")
+	logger.FatalIf(err, "listening")
+	logger.Close()
+
+	if len(target.entries) != 3 {
+		t.Fatalf("len(entries) = %v, expected 3", len(target.entries))
+	}
+	e := target.entries[0]
+	if e.Level != log.LevelError || e.Message != "connecting" || e.Error != err || e.Fields["addr"] != "localhost:1" {
+		t.Errorf("entry = %+v, expected the error with its fields", e)
+	}
+	if e := target.entries[1]; e.Level != log.LevelWarn || e.Error != err {
+		t.Errorf("entry = %+v, expected a warning carrying the error", e)
+	}
+	if fatal == nil || fatal.Message != "listening" || fatal.Error != err {
+		t.Errorf("fatal entry = %+v, expected the fatal message carrying the error", fatal)
+	}
+}
@@ -5,13 +5,16 @@
 package log
 
 import (
+	"crypto/cipher"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/admpub/queueChan"
@@ -32,8 +35,24 @@ type FileTarget struct {
 	// maximum number of bytes allowed for a log file. Zero means no limit.
 	// This field is ignored when Rotate is false.
 	MaxBytes int64
+	// the compression of the log file, e.g. GzipCompressor. Nil means no compression. The file name should have
+	// the matching extension, e.g. app.log.gz. The compressed data is written out when the buffer of the compressor
+	// is full and when the file is rotated or closed. MaxBytes then counts the compressed bytes.
+	Compression Compressor
+	// the 16, 24 or 32-byte AES key the log file is encrypted with, or the file holding it.
+	// The log file is then made of AES-GCM encrypted records which can be read with package logdecrypt.
+	// Each message is encrypted separately, so that the messages written before a crash can be decrypted.
+	EncryptionKey     []byte
+	EncryptionKeyFile string
+	// a line written at the beginning of each new log file, e.g. the header record of a CSV file.
+	Header string
+	// the number of the last entries kept for Tail.
+	TailSize int
 
 	fd           *os.File
+	aead         cipher.AEAD    // the cipher encrypting the log file, if any
+	writer       io.Writer      // writes to fd, compressing and encrypting as configured
+	zw           CompressWriter // the compressor, if Compression is set
 	currentBytes int64
 	errWriter    io.Writer
 	close        chan bool
@@ -42,11 +61,13 @@ type FileTarget struct {
 	scaned       bool
 	filePrefix   string
 	queue        queueChan.QueueChan
+	tailLock     sync.Mutex // guards tail
+	tail         *tailer    // the last entries written, followed by Tail
 }
 
 // NewFileTarget creates a FileTarget.
 // The new FileTarget takes these default options:
-// MaxLevel: LevelDebug, Rotate: true, BackupCount: 10, MaxBytes: 1 << 20
+// MaxLevel: LevelDebug, Rotate: true, BackupCount: 10, MaxBytes: 1 << 20, TailSize: 100
 // You must specify the FileName field.
 func NewFileTarget() *FileTarget {
 	return &FileTarget{
@@ -54,6 +75,7 @@ func NewFileTarget() *FileTarget {
 		Rotate:      true,
 		BackupCount: 10,
 		MaxBytes:    1 << 20, // 1MB
+		TailSize:    100,
 		close:       make(chan bool, 0),
 	}
 }
@@ -64,6 +86,9 @@ func (t *FileTarget) Open(errWriter io.Writer) (err error) {
 	if t.FileName == `` {
 		return errors.New("FileTarget.FileName must be set")
 	}
+	if t.aead, err = t.encryptionCipher(); err != nil {
+		return err
+	}
 	t.timeFormat = ``
 	t.openedFile = ``
 	p := strings.Index(t.FileName, `{date:`)
@@ -134,12 +159,49 @@ func (t *FileTarget) Open(errWriter io.Writer) (err error) {
 	if err != nil {
 		return fmt.Errorf("FileTarget was unable to create a log file: %v", err)
 	}
+	if err = t.initWriter(); err != nil {
+		t.fd.Close()
+		t.fd = nil
+		return fmt.Errorf("FileTarget was unable to create a log file: %v", err)
+	}
 	if t.Rotate {
 		t.recordOldLogs()
 	}
+	t.tailLock.Lock()
+	t.tail = newTailer(t.TailSize)
+	t.tailLock.Unlock()
 	return nil
 }
 
+// Check checks that the current log file can be written, or created if it does not exist yet, see Checker.
+func (t *FileTarget) Check() error {
+	fileName := t.fileName()
+	if fileName == `` {
+		return errors.New("FileTarget.FileName must be set")
+	}
+	fd, err := os.OpenFile(fileName, os.O_WRONLY|os.O_APPEND, 0)
+	if err == nil {
+		return fd.Close()
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	// the missing directories are created with the file: the nearest existing one must be writable
+	dir := filepath.Dir(fileName)
+	for {
+		if _, err = os.Stat(dir); !os.IsNotExist(err) || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	fd, err = ioutil.TempFile(dir, `.log-check-`)
+	if err != nil {
+		return err
+	}
+	fd.Close()
+	return os.Remove(fd.Name())
+}
+
 type logFiles []*logFileInfo
 
 type logFileInfo struct {
@@ -212,7 +274,13 @@ func (t *FileTarget) recordOldLogs() {
 // Process saves an allowed log message into the log file.
 func (t *FileTarget) Process(e *Entry) {
 	if e == nil {
-		t.fd.Close()
+		t.closeFile()
+		t.tailLock.Lock()
+		if t.tail != nil {
+			t.tail.close()
+			t.tail = nil
+		}
+		t.tailLock.Unlock()
 		t.close <- true
 		return
 	}
@@ -220,21 +288,121 @@ func (t *FileTarget) Process(e *Entry) {
 		if t.Rotate {
 			t.rotate(int64(len(e.String()) + 1))
 		}
-		n, err := t.fd.Write([]byte(e.String() + "\n"))
-		t.currentBytes += int64(n)
+		if t.fd == nil {
+			return
+		}
+		_, err := t.writer.Write([]byte(e.String() + "\n"))
 		if err != nil {
 			fmt.Fprintf(t.errWriter, "FileTarge write error: %v\n", err)
 		}
+		t.tail.add(e)
 	}
 }
 
+// ProcessBatch writes several log messages at once, see BatchTarget. The messages of an encrypted file
+// are still written one by one, so that each is encrypted on its own.
+func (t *FileTarget) ProcessBatch(entries []*Entry) {
+	if t.aead != nil {
+		for _, e := range entries {
+			t.Process(e)
+		}
+		return
+	}
+	var buf []byte
+	write := func() {
+		if len(buf) == 0 {
+			return
+		}
+		if _, err := t.writer.Write(buf); err != nil {
+			fmt.Fprintf(t.errWriter, "FileTarge write error: %v\n", err)
+		}
+		buf = buf[:0]
+	}
+	for _, e := range entries {
+		if t.fd == nil || !t.Allow(e) {
+			continue
+		}
+		message := e.String()
+		if t.Rotate {
+			bytes := int64(len(message) + 1)
+			if len(buf) > 0 && t.rotationDue(t.fileName(), int64(len(buf)), bytes) {
+				// the messages are written to the file before it is rotated
+				write()
+			}
+			if len(buf) == 0 {
+				t.rotate(bytes)
+				if t.fd == nil {
+					continue
+				}
+			}
+		}
+		buf = append(append(buf, message...), '\n')
+		t.tail.add(e)
+	}
+	write()
+}
+
 // Close closes the file target.
 func (t *FileTarget) Close() {
 	<-t.close
-	if t.fd != nil {
-		t.fd.Close()
-		t.fd = nil
+	t.closeFile()
+}
+
+// initWriter prepares the writer of the file just opened, which compresses, encrypts and counts the bytes written,
+// and writes the header if the file is new.
+func (t *FileTarget) initWriter() (err error) {
+	t.writer = countingWriter{t.fd, &t.currentBytes}
+	if t.aead != nil {
+		t.writer = encryptWriter{t.writer, t.aead}
+	}
+	t.zw = nil
+	if t.Compression != nil {
+		if t.zw, err = t.Compression.NewWriter(t.writer); err != nil {
+			return err
+		}
+		t.writer = t.zw
+	}
+	if t.Header != "" {
+		if info, err := t.fd.Stat(); err == nil && info.Size() == 0 {
+			_, err = t.writer.Write([]byte(t.Header + "\n"))
+			return err
+		}
+	}
+	return nil
+}
+
+// encryptionCipher returns the cipher encrypting the log file, or nil if it is not encrypted.
+func (t *FileTarget) encryptionCipher() (cipher.AEAD, error) {
+	key := t.EncryptionKey
+	if t.EncryptionKeyFile != "" {
+		var err error
+		if key, err = ReadKeyFile(t.EncryptionKeyFile); err != nil {
+			return nil, fmt.Errorf("FileTarget was unable to read the encryption key: %v", err)
+		}
+	}
+	if key == nil {
+		return nil, nil
+	}
+	aead, err := NewEncryptionCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("FileTarget.EncryptionKey is invalid: %v", err)
+	}
+	return aead, nil
+}
+
+// closeFile writes out the compressed data, if any, and closes the file.
+func (t *FileTarget) closeFile() {
+	if t.fd == nil {
+		return
 	}
+	if t.zw != nil {
+		if err := t.zw.Close(); err != nil {
+			fmt.Fprintf(t.errWriter, "FileTarget write error: %v\n", err)
+		}
+		t.zw = nil
+	}
+	t.fd.Close()
+	t.fd = nil
 }
 
 func (t *FileTarget) fileName() string {
@@ -244,12 +412,18 @@ func (t *FileTarget) fileName() string {
 	return t.FileName
 }
 
+// rotationDue returns whether the file should be rotated before writing a message of the given number of bytes
+// after pending bytes which are not written yet.
+func (t *FileTarget) rotationDue(fileName string, pending, bytes int64) bool {
+	return t.openedFile != fileName || t.currentBytes+pending+bytes > t.MaxBytes && bytes <= t.MaxBytes
+}
+
 func (t *FileTarget) rotate(bytes int64) {
 	fileName := t.fileName()
-	if t.openedFile == fileName && (t.currentBytes+bytes <= t.MaxBytes || bytes > t.MaxBytes) {
+	if !t.rotationDue(fileName, 0, bytes) {
 		return
 	}
-	t.fd.Close()
+	t.closeFile()
 	t.currentBytes = 0
 	var err error
 	if t.BackupCount > 0 {
@@ -302,6 +476,11 @@ func (t *FileTarget) rotate(bytes int64) {
 	*/
 	t.createDir(fileName)
 	t.fd, err = os.OpenFile(fileName, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0660)
+	if err == nil {
+		if err = t.initWriter(); err != nil {
+			t.fd.Close()
+		}
+	}
 	if err != nil {
 		t.fd = nil
 		fmt.Fprintf(t.errWriter, "FileTarget was unable to create a log file: %v\n", err)
@@ -5,9 +5,12 @@
 package log_test
 
 import (
+	"compress/gzip"
+
 	"github.com/admpub/log"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -55,3 +58,81 @@ func TestFileTarget(t *testing.T) {
 		t.Errorf("Expected %q not found", "t2: 3")
 	}
 }
+
+func TestFileTargetCompression(t *testing.T) {
+	logFile := "app.log.gz"
+	os.Remove(logFile)
+	defer os.Remove(logFile)
+
+	// the second run appends a new gzip member to the file
+	for _, message := range []string{"t1", "t2"} {
+		logger := log.NewLogger()
+		logger.Sync()
+		target := log.NewFileTarget()
+		target.FileName = logFile
+		target.Compression = log.GzipCompressor
+		logger.SetTarget(target)
+		logger.Info(message)
+		logger.Close()
+	}
+
+	file, err := os.Open(logFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("gzip.NewReader(): %v", err)
+	}
+	bytes, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(bytes)), "\n"); len(lines) != 2 || !strings.Contains(lines[0], "t1") || !strings.Contains(lines[1], "t2") {
+		t.Errorf("decompressed log file = %q, expected t1 and t2", bytes)
+	}
+}
+
+func TestFileTargetProcessBatch(t *testing.T) {
+	logFile := "app-batch.log"
+	os.Remove(logFile)
+	defer func() {
+		matches, _ := filepath.Glob(logFile + "*")
+		for _, match := range matches {
+			os.Remove(match)
+		}
+	}()
+
+	target := log.NewFileTarget()
+	target.FileName = logFile
+	target.MaxBytes = 12
+	target.Categories = []string{"app"}
+	if err := target.Open(os.Stderr); err != nil {
+		t.Fatalf("Open(): %v", err)
+	}
+	target.ProcessBatch([]*log.Entry{
+		{Category: "app", Level: log.LevelInfo, FormattedMessage: "m1"},
+		{Category: "system", Level: log.LevelInfo, FormattedMessage: "s1"},
+		{Category: "app", Level: log.LevelInfo, FormattedMessage: "m2"},
+		{Category: "app", Level: log.LevelInfo, FormattedMessage: "long one"},
+		{Category: "app", Level: log.LevelInfo, FormattedMessage: "m3"},
+	})
+	go target.Process(nil)
+	target.Close()
+
+	bytes, err := ioutil.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(bytes) != "long one\nm3\n" {
+		t.Errorf("file = %q, expected the messages written after the rotation", bytes)
+	}
+	matches, _ := filepath.Glob(logFile + ".*")
+	if len(matches) != 1 {
+		t.Fatalf("backups = %v, expected 1", matches)
+	}
+	if bytes, _ = ioutil.ReadFile(matches[0]); string(bytes) != "m1\nm2\n" {
+		t.Errorf("backup = %q, expected the messages written before the rotation", bytes)
+	}
+}
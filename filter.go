@@ -14,8 +14,13 @@ type Filter struct {
 	catPrefixes []string
 
 	MaxLevel   Level          // the maximum severity level that is allowed
-	Levels     map[Level]bool // 此属性被设置时，MaxLevel 无效
+	Levels     map[Level]bool // the allowed severity levels. MaxLevel is ignored when it is set. 此属性被设置时，MaxLevel 无效
 	Categories []string       // the allowed message categories. Categories can use "*" as a suffix for wildcard matching.
+
+	// how the target writes the messages spanning several lines. The default writes them as they are.
+	Multiline MultilinePolicy
+	// the prefix of the continuation lines with MultilineIndent. It defaults to DefaultMultilinePrefix.
+	MultilinePrefix string
 }
 
 // Init initializes the filter.
@@ -30,9 +35,6 @@ func (t *Filter) Init() {
 			t.catNames[cat] = true
 		}
 	}
-	if t.Levels != nil {
-		t.MaxLevel = -1
-	}
 }
 
 // Allow checks if a message meets the severity level and category requirements.
@@ -40,14 +42,12 @@ func (t *Filter) Allow(e *Entry) bool {
 	if e == nil {
 		return true
 	}
-	if t.MaxLevel > -1 {
-		if e.Level > t.MaxLevel {
-			return false
-		}
-	} else {
-		if t.Levels == nil || !t.Levels[e.Level] {
+	if t.Levels != nil {
+		if !t.Levels[e.Level] {
 			return false
 		}
+	} else if e.Level > t.MaxLevel {
+		return false
 	}
 	if t.catNames[e.Category] {
 		return true
@@ -64,9 +64,11 @@ func (t *Filter) SetLevel(level interface{}) {
 	if name, ok := level.(string); ok {
 		if le, ok := GetLevel(name); ok {
 			t.MaxLevel = le
+			t.Levels = nil
 		}
 	} else if id, ok := level.(Level); ok {
 		t.MaxLevel = id
+		t.Levels = nil
 	}
 }
 
@@ -35,3 +35,27 @@ func TestFilterAllow(t *testing.T) {
 		}
 	}
 }
+
+func TestFilterLevels(t *testing.T) {
+	filter := log.Filter{MaxLevel: log.LevelWarn}
+	filter.Init()
+	for level, expected := range map[log.Level]bool{log.LevelEmergency: true, log.LevelCritical: true, log.LevelWarn: true, log.LevelInfo: false, log.LevelTrace: false} {
+		if filter.Allow(&log.Entry{Level: level}) != expected {
+			t.Errorf("filter.Allow(%v) = %v, expected %v", level, !expected, expected)
+		}
+	}
+
+	filter.SetLevels(log.LevelCritical, log.LevelInfo)
+	filter.Init()
+	for level, expected := range map[log.Level]bool{log.LevelCritical: true, log.LevelInfo: true, log.LevelError: false} {
+		if filter.Allow(&log.Entry{Level: level}) != expected {
+			t.Errorf("filter.Allow(%v) = %v, expected %v", level, !expected, expected)
+		}
+	}
+
+	// SetLevel goes back to the maximum level
+	filter.SetLevel("error")
+	if !filter.Allow(&log.Entry{Level: log.LevelError}) || filter.Allow(&log.Entry{Level: log.LevelInfo}) {
+		t.Errorf("filter.SetLevel() did not replace the allowed levels")
+	}
+}
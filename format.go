@@ -0,0 +1,508 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// AppendFormatter appends a formatted log message to buf and returns the extended buffer.
+// Unlike Formatter, it does not produce intermediate strings. NewFormatter turns it into a Formatter.
+type AppendFormatter func(buf []byte, l *Logger, e *Entry) []byte
+
+// maxPooledBuffer is the capacity above which a buffer is not reused, so that a few large messages
+// do not keep memory allocated.
+const maxPooledBuffer = 64 << 10
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 512)
+		return &b
+	},
+}
+
+// NewFormatter returns a Formatter which formats the messages with f into pooled buffers.
+func NewFormatter(f AppendFormatter) Formatter {
+	return func(l *Logger, e *Entry) string {
+		return formatWith(f, l, e)
+	}
+}
+
+func formatWith(f AppendFormatter, l *Logger, e *Entry) string {
+	p := bufferPool.Get().(*[]byte)
+	b := f((*p)[:0], l, e)
+	s := string(b)
+	if cap(b) <= maxPooledBuffer {
+		*p = b
+		bufferPool.Put(p)
+	}
+	return s
+}
+
+// DefaultFormatter is the default formatter used to format every log message.
+func DefaultFormatter(l *Logger, e *Entry) string {
+	return formatWith(AppendDefault, l, e)
+}
+
+func NormalFormatter(l *Logger, e *Entry) string {
+	return formatWith(AppendNormal, l, e)
+}
+
+func JSONFormatter(l *Logger, e *Entry) string {
+	return formatWith(AppendJSON, l, e)
+}
+
+// AppendDefault appends a message formatted like DefaultFormatter does.
+func AppendDefault(buf []byte, l *Logger, e *Entry) []byte {
+	buf = appendTime(buf, l, e.Time, time.RFC3339)
+	return appendText(buf, l, e)
+}
+
+// AppendNormal appends a message formatted like NormalFormatter does.
+func AppendNormal(buf []byte, l *Logger, e *Entry) []byte {
+	buf = appendTime(buf, l, e.Time, dateTimeLayout)
+	return appendText(buf, l, e)
+}
+
+// dateTimeLayout is the layout of the time written by NormalFormatter and JSONFormatter.
+const dateTimeLayout = "2006-01-02 15:04:05"
+
+// timeFormat is the layout and the location of the times written by the built-in formatters.
+type timeFormat struct {
+	layout   string
+	location *time.Location
+}
+
+// SetTimeFormat changes the layout of the times written by the built-in formatters for the logger and
+// the loggers sharing its targets, e.g. time.RFC3339Nano. An empty layout restores the layout of each formatter.
+// It can be called while messages are being logged.
+func (l *Logger) SetTimeFormat(layout string) *Logger {
+	l.updateTimeFormat(func(f *timeFormat) {
+		f.layout = layout
+	})
+	return l
+}
+
+// SetTimeLocation changes the location the times written by the built-in formatters are converted to,
+// e.g. time.UTC. Nil keeps the location of the clock, the local time by default.
+// It can be called while messages are being logged.
+func (l *Logger) SetTimeLocation(location *time.Location) *Logger {
+	l.updateTimeFormat(func(f *timeFormat) {
+		f.location = location
+	})
+	return l
+}
+
+// SetUTC makes the built-in formatters write the times in UTC, or in the location of the clock if utc is false.
+func (l *Logger) SetUTC(utc bool) *Logger {
+	if utc {
+		return l.SetTimeLocation(time.UTC)
+	}
+	return l.SetTimeLocation(nil)
+}
+
+func (l *coreLogger) updateTimeFormat(modify func(f *timeFormat)) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	f := timeFormat{}
+	if current, ok := l.timeFormat.Load().(*timeFormat); ok {
+		f = *current
+	}
+	modify(&f)
+	l.timeFormat.Store(&f)
+}
+
+// appendTime appends a time in the layout and location set for the logger, or in the given layout.
+func appendTime(buf []byte, l *Logger, t time.Time, layout string) []byte {
+	if l != nil && l.coreLogger != nil {
+		if f, ok := l.timeFormat.Load().(*timeFormat); ok {
+			if f.location != nil {
+				t = t.In(f.location)
+			}
+			if f.layout != "" {
+				layout = f.layout
+			}
+		}
+	}
+	if layout == dateTimeLayout {
+		return appendDateTime(buf, t)
+	}
+	return t.AppendFormat(buf, layout)
+}
+
+// appendText appends the part of a message following the time, as written by the text formatters.
+func appendText(buf []byte, l *Logger, e *Entry) []byte {
+	buf = append(buf, '|')
+	buf = append(buf, e.Level.String()...)
+	buf = append(buf, '|')
+	buf = append(buf, e.Category...)
+	buf = append(buf, '|')
+	buf = append(buf, e.Message...)
+	buf = appendFields(buf, e)
+	buf = appendHexDump(buf, e.Raw)
+	buf = appendCallStack(buf, l, e.CallStack)
+	return appendErrorStack(buf, e)
+}
+
+// appendDateTime appends a time in the format 2006-01-02 15:04:05.
+func appendDateTime(buf []byte, t time.Time) []byte {
+	year, month, day := t.Date()
+	hour, min, sec := t.Clock()
+	if year < 0 || year > 9999 {
+		return t.AppendFormat(buf, dateTimeLayout)
+	}
+	buf = appendDigits(buf, year, 4)
+	buf = append(buf, '-')
+	buf = appendDigits(buf, int(month), 2)
+	buf = append(buf, '-')
+	buf = appendDigits(buf, day, 2)
+	buf = append(buf, ' ')
+	buf = appendDigits(buf, hour, 2)
+	buf = append(buf, ':')
+	buf = appendDigits(buf, min, 2)
+	buf = append(buf, ':')
+	return appendDigits(buf, sec, 2)
+}
+
+// appendDigits appends a non-negative number padded with zeros to the given width.
+func appendDigits(buf []byte, n int, width int) []byte {
+	var digits [4]byte
+	for i := width - 1; i >= 0; i-- {
+		digits[i] = byte('0' + n%10)
+		n /= 10
+	}
+	return append(buf, digits[:width]...)
+}
+
+// appendFields appends the trace, the error and the fields of an entry, each preceded by a space.
+func appendFields(buf []byte, e *Entry) []byte {
+	if e.TraceID != "" {
+		buf = append(buf, " trace_id="...)
+		buf = append(buf, e.TraceID...)
+		if e.SpanID != "" {
+			buf = append(buf, " span_id="...)
+			buf = append(buf, e.SpanID...)
+		}
+	}
+	buf = appendError(buf, e)
+	if len(e.Fields) > 0 {
+		buf = append(buf, ' ')
+		buf = e.Fields.appendTo(buf)
+	}
+	return buf
+}
+
+// appendError appends the error of an entry as it is written by the text formatters.
+func appendError(buf []byte, e *Entry) []byte {
+	if e.Error == nil {
+		return buf
+	}
+	buf = append(buf, " error="...)
+	buf = strconv.AppendQuote(buf, e.Error.Error())
+	buf = append(buf, " error_type="...)
+	return append(buf, reflect.TypeOf(e.Error).String()...)
+}
+
+// appendErrorStack appends the call stack of the error of an entry, starting on a new line.
+func appendErrorStack(buf []byte, e *Entry) []byte {
+	if e.Error == nil {
+		return buf
+	}
+	stack := ErrorStack(e.Error)
+	if stack != "" && stack[0] != '\n' {
+		buf = append(buf, '\n')
+	}
+	return append(buf, stack...)
+}
+
+// sortedKeys returns the keys of the fields in order. The keys of a few fields are sorted
+// into the given array, so that they are not allocated.
+func (f Fields) sortedKeys(array *[16]string) []string {
+	keys := array[:0]
+	if len(f) > len(array) {
+		keys = make([]string, 0, len(f))
+	}
+	for k := range f {
+		keys = append(keys, k)
+	}
+	if len(keys) > len(array) {
+		sort.Strings(keys)
+		return keys
+	}
+	for i := 1; i < len(keys); i++ {
+		for j := i; j > 0 && keys[j] < keys[j-1]; j-- {
+			keys[j], keys[j-1] = keys[j-1], keys[j]
+		}
+	}
+	return keys
+}
+
+// appendTo appends the fields as space-separated key=value pairs sorted by key.
+func (f Fields) appendTo(buf []byte) []byte {
+	var array [16]string
+	for i, k := range f.sortedKeys(&array) {
+		if i > 0 {
+			buf = append(buf, ' ')
+		}
+		buf = append(buf, k...)
+		buf = append(buf, '=')
+		buf = appendValue(buf, f[k])
+	}
+	return buf
+}
+
+// appendValue appends a value formatted like fmt does with %v.
+func appendValue(buf []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case string:
+		return append(buf, v...)
+	case int:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int64:
+		return strconv.AppendInt(buf, v, 10)
+	case int32:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case uint:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint64:
+		return strconv.AppendUint(buf, v, 10)
+	case bool:
+		return strconv.AppendBool(buf, v)
+	case float64:
+		return strconv.AppendFloat(buf, v, 'g', -1, 64)
+	}
+	return append(buf, fmt.Sprint(v)...)
+}
+
+type JSONL struct {
+	Time      string          `bson:"time" json:"time"`
+	Level     string          `bson:"level" json:"level"`
+	Category  string          `bson:"category" json:"category"`
+	Message   json.RawMessage `bson:"message" json:"message"`
+	Fields    Fields          `bson:"fields,omitempty" json:"fields,omitempty"`
+	TraceID   string          `bson:"traceId,omitempty" json:"traceId,omitempty"`
+	SpanID    string          `bson:"spanId,omitempty" json:"spanId,omitempty"`
+	Error     *JSONError      `bson:"error,omitempty" json:"error,omitempty"`
+	CallStack CallStack       `bson:"callStack,omitempty" json:"callStack,omitempty"`
+	Raw       []byte          `bson:"raw,omitempty" json:"raw,omitempty"`
+}
+
+// JSONError is the error of an entry formatted by JSONFormatter.
+type JSONError struct {
+	Message string `bson:"message" json:"message"`
+	Type    string `bson:"type" json:"type"`
+	Stack   string `bson:"stack,omitempty" json:"stack,omitempty"`
+}
+
+// AppendJSON appends a message formatted like JSONFormatter does, as the JSON encoding of JSONL.
+// A message which is a JSON object, array or string is embedded as is.
+// A field value which cannot be encoded in JSON is written as a string.
+func AppendJSON(buf []byte, l *Logger, e *Entry) []byte {
+	buf = append(buf, `{"time":`...)
+	buf = appendJSONTime(buf, l, e.Time)
+	buf = append(buf, `,"level":`...)
+	buf = appendJSONString(buf, e.Level.String())
+	buf = append(buf, `,"category":`...)
+	buf = appendJSONString(buf, e.Category)
+	buf = append(buf, `,"message":`...)
+	buf = appendJSONMessage(buf, e.Message)
+	if len(e.Fields) > 0 {
+		buf = append(buf, `,"fields":{`...)
+		var array [16]string
+		for i, k := range e.Fields.sortedKeys(&array) {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = appendJSONString(buf, k)
+			buf = append(buf, ':')
+			buf = appendJSONValue(buf, e.Fields[k])
+		}
+		buf = append(buf, '}')
+	}
+	if e.TraceID != "" {
+		buf = append(buf, `,"traceId":`...)
+		buf = appendJSONString(buf, e.TraceID)
+	}
+	if e.SpanID != "" {
+		buf = append(buf, `,"spanId":`...)
+		buf = appendJSONString(buf, e.SpanID)
+	}
+	if e.Error != nil {
+		buf = append(buf, `,"error":{"message":`...)
+		buf = appendJSONString(buf, e.Error.Error())
+		buf = append(buf, `,"type":`...)
+		buf = appendJSONString(buf, reflect.TypeOf(e.Error).String())
+		if stack := ErrorStack(e.Error); stack != "" {
+			buf = append(buf, `,"stack":`...)
+			buf = appendJSONString(buf, stack)
+		}
+		buf = append(buf, '}')
+	}
+	if len(e.CallStack) > 0 {
+		buf = append(buf, `,"callStack":[`...)
+		for i, f := range e.CallStack {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, `{"file":`...)
+			buf = appendJSONString(buf, f.File)
+			buf = append(buf, `,"line":`...)
+			buf = strconv.AppendInt(buf, int64(f.Line), 10)
+			if f.Func != "" {
+				buf = append(buf, `,"func":`...)
+				buf = appendJSONString(buf, f.Func)
+			}
+			buf = append(buf, '}')
+		}
+		buf = append(buf, ']')
+	}
+	if len(e.Raw) > 0 {
+		buf = append(buf, `,"raw":"`...)
+		buf = appendBase64(buf, e.Raw)
+		buf = append(buf, '"')
+	}
+	return append(buf, '}')
+}
+
+// appendJSONTime appends a time formatted by appendTime as a JSON string.
+func appendJSONTime(buf []byte, l *Logger, t time.Time) []byte {
+	start := len(buf)
+	buf = append(buf, '"')
+	buf = appendTime(buf, l, t, dateTimeLayout)
+	for _, c := range buf[start+1:] {
+		if c < 0x20 || c >= utf8.RuneSelf || c == '"' || c == '\\' || c == '<' || c == '>' || c == '&' {
+			// a custom layout may contain characters to be escaped
+			return appendJSONString(buf[:start], string(buf[start+1:]))
+		}
+	}
+	return append(buf, '"')
+}
+
+// appendJSONMessage appends a message as a JSON string, or as is if it is a JSON object, array or string.
+func appendJSONMessage(buf []byte, message string) []byte {
+	if len(message) > 0 {
+		switch message[0] {
+		case '{', '[', '"':
+			if json.Valid([]byte(message)) {
+				var compacted, escaped bytes.Buffer
+				json.Compact(&compacted, []byte(message))
+				json.HTMLEscape(&escaped, compacted.Bytes())
+				return append(buf, escaped.Bytes()...)
+			}
+		}
+	}
+	return appendJSONString(buf, message)
+}
+
+// appendJSONValue appends the JSON encoding of a field value.
+func appendJSONValue(buf []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case string:
+		return appendJSONString(buf, v)
+	case int:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int64:
+		return strconv.AppendInt(buf, v, 10)
+	case int32:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case uint:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint64:
+		return strconv.AppendUint(buf, v, 10)
+	case bool:
+		return strconv.AppendBool(buf, v)
+	case float64:
+		if !math.IsInf(v, 0) && !math.IsNaN(v) {
+			return appendJSONFloat(buf, v)
+		}
+	case nil:
+		return append(buf, "null"...)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return appendJSONString(buf, fmt.Sprint(v))
+	}
+	return append(buf, b...)
+}
+
+// appendJSONFloat appends a finite float64 as encoding/json does.
+func appendJSONFloat(buf []byte, f float64) []byte {
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	buf = strconv.AppendFloat(buf, f, format, -1, 64)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		if n := len(buf); n >= 4 && buf[n-4] == 'e' && buf[n-3] == '-' && buf[n-2] == '0' {
+			buf[n-2] = buf[n-1]
+			buf = buf[:n-1]
+		}
+	}
+	return buf
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends a string quoted and escaped as encoding/json does, including the HTML characters.
+func appendJSONString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			buf = append(buf, s[start:i]...)
+			switch b {
+			case '\\', '"':
+				buf = append(buf, '\\', b)
+			case '\b':
+				buf = append(buf, '\\', 'b')
+			case '\f':
+				buf = append(buf, '\\', 'f')
+			case '\n':
+				buf = append(buf, '\\', 'n')
+			case '\r':
+				buf = append(buf, '\\', 'r')
+			case '\t':
+				buf = append(buf, '\\', 't')
+			default:
+				buf = append(buf, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if c == utf8.RuneError && size == 1 {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		if c == '\u2028' || c == '\u2029' {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, '\\', 'u', '2', '0', '2', hexDigits[c&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf = append(buf, s[start:]...)
+	return append(buf, '"')
+}
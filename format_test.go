@@ -0,0 +1,126 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+func benchmarkEntry() *log.Entry {
+	return &log.Entry{
+		Level:    log.LevelInfo,
+		Category: "app.db",
+		Message:  "the order was saved",
+		Time:     time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Fields:   log.Fields{"user": "alice", "order": 42, "amount": 12.5, "paid": true},
+		TraceID:  "4bf92f3577b34da6a3ce929d0e0e4736",
+		Error:    errors.New("timeout"),
+	}
+}
+
+func TestAppendFormatters(t *testing.T) {
+	logger, e := log.NewLogger(), benchmarkEntry()
+	e.Fields["latency"] = 1500 * time.Millisecond
+	expected := "2020-01-02 03:04:05|Info|app.db|the order was saved trace_id=4bf92f3577b34da6a3ce929d0e0e4736" +
+		` error="timeout" error_type=*errors.errorString amount=12.5 latency=1.5s order=42 paid=true user=alice`
+	if s := log.NormalFormatter(logger, e); s != expected {
+		t.Errorf("NormalFormatter() = %q, expected %q", s, expected)
+	}
+	if s := string(log.AppendDefault([]byte("> "), logger, e)); s != "> 2020-01-02T03:04:05Z"+expected[19:] {
+		t.Errorf("AppendDefault() = %q, expected %q", s, "> 2020-01-02T03:04:05Z"+expected[19:])
+	}
+
+	formatter := log.NewFormatter(func(buf []byte, l *log.Logger, e *log.Entry) []byte {
+		return append(append(buf, e.Category...), e.Message...)
+	})
+	if s := formatter(logger, e); s != "app.dbthe order was saved" {
+		t.Errorf("NewFormatter() = %q, expected %q", s, "app.dbthe order was saved")
+	}
+}
+
+func TestSetTimeFormat(t *testing.T) {
+	logger, e := log.NewLogger(), benchmarkEntry()
+	e.Fields = nil
+	e.Error = nil
+	e.TraceID = ""
+	logger.SetTimeFormat(time.RFC3339Nano).SetTimeLocation(time.FixedZone("CET", 3600))
+	if s := log.NormalFormatter(logger, e); !strings.HasPrefix(s, "2020-01-02T04:04:05+01:00|Info|") {
+		t.Errorf("NormalFormatter() = %q, expected the time in RFC3339 in CET", s)
+	}
+	logger.SetTimeFormat(`15:04 "MST"`).SetUTC(true)
+	if s := log.JSONFormatter(logger, e); !strings.HasPrefix(s, `{"time":"03:04 \"UTC\"",`) {
+		t.Errorf("JSONFormatter() = %q, expected the escaped time in UTC", s)
+	}
+	// the loggers sharing the targets share the time format
+	logger.SetTimeFormat("").SetUTC(false)
+	if s := log.DefaultFormatter(logger.GetLogger("app"), e); !strings.HasPrefix(s, "2020-01-02T03:04:05Z|") {
+		t.Errorf("DefaultFormatter() = %q, expected the default layout", s)
+	}
+}
+
+func TestAppendJSON(t *testing.T) {
+	logger, e := log.NewLogger(), benchmarkEntry()
+	e.Fields = log.Fields{"s": "\b\f<>", "f": 1e-7, "g": 1e21, "h": 100.0, "d": time.Second, "n": nil, "x": []int{1}}
+	for _, message := range []string{
+		`say "hi"\n`, "<a href=\"x\">&amp;</a>\u2028\xff\x01\t", `{"id": 1, "tag": "<b>"}`, `[1, 2`, `"quoted"`, "",
+	} {
+		// the output is the one of encoding/json
+		e.Message = message
+		expected := log.JSONL{
+			Time:     "2020-01-02 03:04:05",
+			Level:    "Info",
+			Category: "app.db",
+			Fields:   e.Fields,
+			TraceID:  e.TraceID,
+			Error:    &log.JSONError{Message: "timeout", Type: "*errors.errorString"},
+		}
+		if json.Valid([]byte(message)) {
+			expected.Message = json.RawMessage(message)
+		} else {
+			expected.Message, _ = json.Marshal(message)
+		}
+		b, _ := json.Marshal(expected)
+		if s := log.JSONFormatter(logger, e); s != string(b) {
+			t.Errorf("JSONFormatter() = %q, expected %q", s, b)
+		}
+	}
+
+	e.Message = "t1"
+	e.Fields = log.Fields{"nan": math.NaN()}
+	if s := log.JSONFormatter(logger, e); !strings.Contains(s, `"fields":{"nan":"NaN"}`) {
+		t.Errorf("JSONFormatter() = %q, expected the invalid value as a string", s)
+	}
+}
+
+func BenchmarkDefaultFormatter(b *testing.B) {
+	logger, e := log.NewLogger(), benchmarkEntry()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		log.DefaultFormatter(logger, e)
+	}
+}
+
+func BenchmarkNormalFormatter(b *testing.B) {
+	logger, e := log.NewLogger(), benchmarkEntry()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		log.NormalFormatter(logger, e)
+	}
+}
+
+func BenchmarkJSONFormatter(b *testing.B) {
+	logger, e := log.NewLogger(), benchmarkEntry()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		log.JSONFormatter(logger, e)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// GoroutineHook attaches the ID of the calling goroutine ("goroutine") and the number of
+// goroutines ("goroutines") to an entry, which helps debugging concurrency issues.
+// Extracting the goroutine ID takes a stack trace, so the hook is only run when added:
+//
+//	logger.AddHook(log.GoroutineHook)
+func GoroutineHook(e *Entry) *Entry {
+	fields := make(Fields, len(e.Fields)+2)
+	for name, value := range e.Fields {
+		fields[name] = value
+	}
+	fields["goroutine"] = GoroutineID()
+	fields["goroutines"] = runtime.NumGoroutine()
+	e.Fields = fields
+	return e
+}
+
+// GoroutineID returns the ID of the calling goroutine, or 0 if it cannot be determined.
+func GoroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	// the stack starts with "goroutine 123 [running]:"
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+	id, err := strconv.ParseUint(string(b), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// maxGoroutineDump is the maximum size of the stacks of all goroutines added to a fatal message.
+const maxGoroutineDump = 64 << 20
+
+// goroutineDump returns the stacks of all goroutines as written by runtime.Stack.
+func goroutineDump() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= maxGoroutineDump {
+			return buf[:n]
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+}
+
+// dumpGoroutines adds the stacks of all goroutines to the message of a fatal entry, or appends them to
+// CrashFile and adds its name to the message. The stacks are added to the message if CrashFile cannot be written.
+func (l *coreLogger) dumpGoroutines(entry *Entry) {
+	dump := goroutineDump()
+	if l.CrashFile != "" {
+		err := appendCrashFile(l.CrashFile, entry, dump)
+		if err == nil {
+			entry.Message += "\ngoroutine stacks written to " + l.CrashFile
+			return
+		}
+		fmt.Fprintf(l.ErrorWriter, "Failed to write the crash file: %v\n", err)
+	}
+	entry.Message += "\n\n" + string(dump)
+}
+
+// appendCrashFile appends the stacks of all goroutines to a crash file, after the time and the message of the fatal entry.
+func appendCrashFile(fileName string, entry *Entry, dump []byte) error {
+	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0660)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(file, "%v %v\n\n%s\n", entry.Time.Format(time.RFC3339Nano), entry.Message, dump)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,82 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestGoroutineID(t *testing.T) {
+	id := log.GoroutineID()
+	if id == 0 {
+		t.Fatalf("GoroutineID() = 0, expected a goroutine ID")
+	}
+	other := make(chan uint64)
+	go func() {
+		other <- log.GoroutineID()
+	}()
+	if o := <-other; o == id || o == 0 {
+		t.Errorf("GoroutineID() in another goroutine = %v, expected an ID other than %v", o, id)
+	}
+}
+
+func TestGoroutineHook(t *testing.T) {
+	e := log.GoroutineHook(&log.Entry{Fields: log.Fields{"id": 1}})
+	if e.Fields["goroutine"] != log.GoroutineID() {
+		t.Errorf("Fields[goroutine] = %v, expected %v", e.Fields["goroutine"], log.GoroutineID())
+	}
+	if n, ok := e.Fields["goroutines"].(int); !ok || n < 1 {
+		t.Errorf("Fields[goroutines] = %v, expected a positive number", e.Fields["goroutines"])
+	}
+	if e.Fields["id"] != 1 {
+		t.Errorf("Fields[id] = %v, expected %v", e.Fields["id"], 1)
+	}
+}
+
+// stuck blocks until done is closed, like a goroutine stuck in a deadlock.
+func stuck(done chan bool) {
+	<-done
+}
+
+func TestLoggerGoroutineDump(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logcrash")
+	if err != nil {
+		t.Fatalf("TempDir(): %v", err)
+	}
+	defer os.RemoveAll(dir)
+	done := make(chan bool)
+	defer close(done)
+	go stuck(done)
+
+	for _, crashFile := range []string{"", filepath.Join(dir, "crash.log")} {
+		logger := log.NewLogger()
+		logger.Sync()
+		logger.GoroutineDump = true
+		logger.CrashFile = crashFile
+		target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+		logger.SetTarget(target)
+		logger.Fatal("t1")
+		logger.Close()
+
+		message := target.entries[0].Message
+		dump := message
+		if crashFile != "" {
+			data, _ := ioutil.ReadFile(crashFile)
+			dump = string(data)
+			if !strings.HasSuffix(message, "goroutine stacks written to "+crashFile) {
+				t.Errorf("Message = %q, expected the name of the crash file", message)
+			}
+		}
+		if !strings.Contains(dump, "t1") || !strings.Contains(dump, "log_test.stuck") {
+			t.Errorf("the goroutine dump %q does not hold the stuck goroutine", dump)
+		}
+	}
+}
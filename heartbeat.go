@@ -0,0 +1,83 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"sync"
+	"time"
+)
+
+// HeartbeatCategory is the category of the heartbeat messages logged by Logger.Heartbeat.
+const HeartbeatCategory = "log.heartbeat"
+
+// processStart approximates the time the program started, from which the uptime is measured.
+var processStart = time.Now()
+
+// Heartbeat logs a message of the category HeartbeatCategory every interval, so that the pipelines
+// downstream can detect when the logging of a service has silently died. The heartbeats carry these fields:
+//
+//	uptime   the number of seconds since the program started
+//	messages the number of messages logged since the previous heartbeat, or since Heartbeat was called
+//	levels   the same number by level name
+//	dropped  the number of messages the targets have dropped since the previous heartbeat
+//	queue    the number of messages waiting in the queue of the logger
+//
+// The heartbeats are logged at the Info level whatever the maximum levels of the logger, and they are not
+// counted in the Levels of Stats. No heartbeat is logged while the logger is closed.
+// Heartbeat returns a function which stops the heartbeats.
+func (l *Logger) Heartbeat(interval time.Duration) (stop func()) {
+	logger := l.GetLogger(HeartbeatCategory).WithLevel(LevelInfo)
+	ticker := time.NewTicker(interval)
+	done, stopped := make(chan bool), make(chan bool)
+	stats := l.Stats()
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+			if !l.current().open {
+				continue
+			}
+			last := stats
+			stats = l.Stats()
+			logger.WithFields(heartbeatFields(last, stats)).Info("heartbeat")
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+			<-stopped
+		})
+	}
+}
+
+// heartbeatFields returns the fields of a heartbeat from the stats of the logger at the previous heartbeat and now.
+func heartbeatFields(last, stats Stats) Fields {
+	var messages uint64
+	levels := map[string]uint64{}
+	for level, count := range stats.Levels {
+		if count > last.Levels[level] {
+			levels[level.String()] = count - last.Levels[level]
+			messages += count - last.Levels[level]
+		}
+	}
+	dropped := stats.Dropped - last.Dropped
+	if dropped < 0 {
+		// the targets have been replaced
+		dropped = stats.Dropped
+	}
+	return Fields{
+		"uptime":   int64(time.Since(processStart) / time.Second),
+		"messages": messages,
+		"levels":   levels,
+		"dropped":  dropped,
+		"queue":    stats.QueueLength,
+	}
+}
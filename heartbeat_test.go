@@ -0,0 +1,63 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+func TestHeartbeat(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	logger.SetMaxLevel(log.LevelWarn)
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+
+	logger.Warn("before")
+	stop := logger.Heartbeat(20 * time.Millisecond)
+	logger.Warn("w1")
+	logger.Error("e1")
+	logger.Error("e2")
+	time.Sleep(70 * time.Millisecond)
+	stop()
+	stop()
+	logger.Close()
+
+	var beats []*log.Entry
+	for _, e := range target.entries {
+		if e.Category == log.HeartbeatCategory {
+			beats = append(beats, e)
+		}
+	}
+	if len(beats) < 2 {
+		t.Fatalf("heartbeats = %v, expected at least 2", len(beats))
+	}
+	first := beats[0]
+	if first.Level != log.LevelInfo || first.Message != "heartbeat" {
+		t.Errorf("heartbeat = %v %q, expected an Info heartbeat", first.Level, first.Message)
+	}
+	if messages := first.Fields["messages"]; messages != uint64(3) {
+		t.Errorf("messages = %v, expected 3", messages)
+	}
+	levels := first.Fields["levels"].(map[string]uint64)
+	if levels["Error"] != 2 || levels["Warn"] != 1 {
+		t.Errorf("levels = %v, expected 2 errors and 1 warning", levels)
+	}
+	for _, name := range []string{"uptime", "dropped", "queue"} {
+		if _, ok := first.Fields[name]; !ok {
+			t.Errorf("field %v is missing", name)
+		}
+	}
+	// the heartbeats are not counted
+	if messages := beats[1].Fields["messages"]; messages != uint64(0) {
+		t.Errorf("messages = %v, expected 0", messages)
+	}
+	if count := logger.Stats().Levels[log.LevelInfo]; count != 0 {
+		t.Errorf("Stats().Levels[LevelInfo] = %v, expected 0", count)
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// HTTPTarget accumulates log messages and POSTs them in batches to an HTTP endpoint,
+// either as newline-delimited JSON (NDJSON) or as a JSON array.
+type HTTPTarget struct {
+	*Filter
+	// the URL the batches are posted to.
+	URL string
+	// additional HTTP headers, e.g. for authentication.
+	Headers map[string]string
+	// whether to send a JSON array instead of newline-delimited JSON.
+	JSONArray bool
+	// whether to gzip the request bodies. It is ignored if Compression is set.
+	Gzip bool
+	// the compression of the request bodies, e.g. GzipCompressor. Nil means no compression unless Gzip is true.
+	Compression Compressor
+	// a batch is sent when it contains BatchSize messages or BatchBytes bytes of formatted messages,
+	// or FlushInterval after its first message.
+	BatchSize     int
+	BatchBytes    int
+	FlushInterval time.Duration
+	// how many times a failed request is retried. The wait between attempts starts at
+	// RetryWait and doubles after each attempt.
+	MaxRetries int
+	RetryWait  time.Duration
+	// the maximum number of messages kept in memory, including the batch being sent.
+	// New messages are dropped when the limit is reached.
+	MaxBuffer int
+	// the HTTP client used to post the batches.
+	Client *http.Client
+	// the TLS options of the HTTP client created when Client is nil, e.g. for mutual TLS.
+	TLS *TLSOptions
+
+	batcher   *BatchingTarget
+	errWriter io.Writer
+}
+
+// HTTPDocument is the JSON document sent for each log message.
+type HTTPDocument struct {
+	Time      time.Time `json:"time"`
+	Level     string    `json:"level"`
+	Category  string    `json:"category"`
+	Message   string    `json:"message"`
+	Fields    Fields    `json:"fields,omitempty"`
+	CallStack CallStack `json:"callStack,omitempty"`
+	Formatted string    `json:"formatted"`
+}
+
+// NewHTTPTarget creates an HTTPTarget.
+// The new HTTPTarget takes these default options:
+// MaxLevel: LevelDebug, BatchSize: 100, BatchBytes: 1MB, FlushInterval: 2s, MaxRetries: 3,
+// RetryWait: 500ms, MaxBuffer: 10000.
+// You must specify the URL field.
+func NewHTTPTarget() *HTTPTarget {
+	return &HTTPTarget{
+		Filter:        &Filter{MaxLevel: LevelDebug},
+		BatchSize:     100,
+		BatchBytes:    1 << 20,
+		FlushInterval: 2 * time.Second,
+		MaxRetries:    3,
+		RetryWait:     500 * time.Millisecond,
+		MaxBuffer:     10000,
+	}
+}
+
+// Open prepares HTTPTarget for processing log messages.
+func (t *HTTPTarget) Open(errWriter io.Writer) error {
+	t.Filter.Init()
+	if t.URL == "" {
+		return errors.New("HTTPTarget.URL must be specified")
+	}
+	if t.MaxBuffer < t.BatchSize {
+		return errors.New("HTTPTarget.MaxBuffer must be no less than BatchSize")
+	}
+	if t.Client == nil {
+		client, err := newHTTPClient(t.TLS)
+		if err != nil {
+			return fmt.Errorf("HTTPTarget.TLS is invalid: %v", err)
+		}
+		t.Client = client
+	}
+	t.errWriter = errWriter
+	t.batcher = &BatchingTarget{
+		Filter:        t.Filter,
+		Writer:        BatchWriterFunc(t.write),
+		Name:          "HTTPTarget",
+		BatchSize:     t.BatchSize,
+		BatchBytes:    t.BatchBytes,
+		FlushInterval: t.FlushInterval,
+		// the channel holds what is not in the batch being built or sent
+		BufferSize: t.MaxBuffer - t.BatchSize,
+		close:      make(chan bool, 0),
+	}
+	return t.batcher.Open(errWriter)
+}
+
+// Check checks that a connection to the host of the URL can be established, see Checker.
+func (t *HTTPTarget) Check() error {
+	u, err := url.Parse(t.URL)
+	if err != nil {
+		return err
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(u.Hostname(), port), checkTimeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// Process puts filtered log messages into a batch for sending.
+func (t *HTTPTarget) Process(e *Entry) {
+	t.batcher.Process(e)
+}
+
+// Close sends the last batch and closes the HTTP target.
+func (t *HTTPTarget) Close() {
+	t.batcher.Close()
+}
+
+// write encodes a batch into documents and sends them.
+func (t *HTTPTarget) write(entries []*Entry) error {
+	batch := make([]json.RawMessage, 0, len(entries))
+	for _, entry := range entries {
+		doc, err := json.Marshal(&HTTPDocument{
+			Time:      entry.Time,
+			Level:     entry.Level.String(),
+			Category:  entry.Category,
+			Message:   entry.Message,
+			Fields:    entry.Fields,
+			CallStack: entry.CallStack,
+			Formatted: entry.String(),
+		})
+		if err != nil {
+			fmt.Fprintf(t.errWriter, "HTTPTarget encode error: %v\n", err)
+			continue
+		}
+		batch = append(batch, doc)
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	return t.send(batch)
+}
+
+// send posts a batch, retrying with exponential backoff on network errors and 429/5xx responses.
+func (t *HTTPTarget) send(batch []json.RawMessage) error {
+	body := new(bytes.Buffer)
+	var w io.Writer = body
+	var zw CompressWriter
+	if c := t.compressor(); c != nil {
+		var err error
+		if zw, err = c.NewWriter(body); err != nil {
+			return err
+		}
+		w = zw
+	}
+	if t.JSONArray {
+		w.Write([]byte{'['})
+		for i, doc := range batch {
+			if i > 0 {
+				w.Write([]byte{','})
+			}
+			w.Write(doc)
+		}
+		w.Write([]byte{']'})
+	} else {
+		for _, doc := range batch {
+			w.Write(doc)
+			w.Write([]byte{'\n'})
+		}
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return err
+		}
+	}
+
+	wait := t.RetryWait
+	for attempt := 0; ; attempt++ {
+		retry, err := t.post(body.Bytes())
+		if err == nil || !retry || attempt >= t.MaxRetries {
+			return err
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+func (t *HTTPTarget) post(body []byte) (retry bool, err error) {
+	req, err := http.NewRequest("POST", t.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	if t.JSONArray {
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		req.Header.Set("Content-Type", "application/x-ndjson")
+	}
+	if c := t.compressor(); c != nil {
+		req.Header.Set("Content-Encoding", c.Encoding())
+	}
+	for k, v := range t.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return false, nil
+	}
+	data, _ := ioutil.ReadAll(resp.Body)
+	err = fmt.Errorf("%v: %s", resp.Status, bytes.TrimSpace(data))
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
+
+func (t *HTTPTarget) compressor() Compressor {
+	if t.Compression == nil && t.Gzip {
+		return GzipCompressor
+	}
+	return t.Compression
+}
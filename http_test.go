@@ -0,0 +1,115 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+func TestNewHTTPTarget(t *testing.T) {
+	target := log.NewHTTPTarget()
+	if target.MaxLevel != log.LevelDebug {
+		t.Errorf("NewHTTPTarget.MaxLevel = %v, expected %v", target.MaxLevel, log.LevelDebug)
+	}
+	if target.BatchSize != 100 {
+		t.Errorf("NewHTTPTarget.BatchSize = %v, expected %v", target.BatchSize, 100)
+	}
+}
+
+func TestHTTPTarget(t *testing.T) {
+	var (
+		attempts int
+		batches  [][]log.HTTPDocument
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("X-Token") != "abc" {
+			t.Errorf("X-Token = %q, expected %q", r.Header.Get("X-Token"), "abc")
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		var docs []log.HTTPDocument
+		if err := json.Unmarshal(data, &docs); err != nil {
+			t.Errorf("json.Unmarshal(): %v", err)
+		}
+		batches = append(batches, docs)
+	}))
+	defer server.Close()
+
+	logger := log.NewLogger()
+	logger.Sync()
+	target := log.NewHTTPTarget()
+	target.URL = server.URL
+	target.Headers = map[string]string{"X-Token": "abc"}
+	target.JSONArray = true
+	target.BatchSize = 2
+	target.RetryWait = time.Millisecond
+	logger.SetTarget(target)
+
+	logger.Info("t1")
+	logger.Warn("t2")
+	logger.Error("t3")
+
+	logger.Close()
+
+	if attempts != 3 {
+		t.Errorf("attempts = %v, expected %v", attempts, 3)
+	}
+	if len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 1 {
+		t.Fatalf("batches = %v, expected two batches of 2 and 1 messages", batches)
+	}
+	if batches[0][1].Message != "t2" || batches[0][1].Level != "Warn" || batches[1][0].Message != "t3" {
+		t.Errorf("batches = %v, unexpected content", batches)
+	}
+}
+
+func TestHTTPTargetCompression(t *testing.T) {
+	var docs []log.HTTPDocument
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "gzip" {
+			t.Errorf("Content-Encoding = %q, expected %q", r.Header.Get("Content-Encoding"), "gzip")
+		}
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("gzip.NewReader(): %v", err)
+			return
+		}
+		decoder := json.NewDecoder(reader)
+		for decoder.More() {
+			var doc log.HTTPDocument
+			if err := decoder.Decode(&doc); err != nil {
+				t.Errorf("Decode(): %v", err)
+				return
+			}
+			docs = append(docs, doc)
+		}
+	}))
+	defer server.Close()
+
+	logger := log.NewLogger()
+	logger.Sync()
+	target := log.NewHTTPTarget()
+	target.URL = server.URL
+	target.Compression = log.NewGzipCompressor(gzip.BestSpeed)
+	logger.SetTarget(target)
+	logger.Info("t1")
+	logger.Info("t2")
+	logger.Close()
+
+	if len(docs) != 2 || docs[1].Message != "t2" {
+		t.Errorf("docs = %v, expected t1 and t2", docs)
+	}
+}
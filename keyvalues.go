@@ -0,0 +1,88 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+// BadKey is the field under which a value lacking a key is attached by With and the methods
+// taking keys and values, e.g. because the number of arguments is odd or a key is not a string.
+const BadKey = "!BADKEY"
+
+// fieldsOf converts alternating keys and values into fields.
+func fieldsOf(keysAndValues []interface{}) Fields {
+	fields := make(Fields, len(keysAndValues)/2)
+	for i := 0; i < len(keysAndValues); {
+		if key, ok := keysAndValues[i].(string); ok && i+1 < len(keysAndValues) {
+			fields[key] = keysAndValues[i+1]
+			i += 2
+		} else {
+			fields[BadKey] = keysAndValues[i]
+			i++
+		}
+	}
+	return fields
+}
+
+// With returns a logger that attaches the given alternating keys and values, merged with the fields
+// of the calling logger, to every message it logs, e.g. With("user", "alice", "attempt", 3).
+// A value lacking a key is attached under BadKey.
+func (l *Logger) With(keysAndValues ...interface{}) *Logger {
+	return l.WithFields(fieldsOf(keysAndValues))
+}
+
+// Logw logs a message of a specified severity level with the given alternating keys and values
+// attached as fields, merged with the fields of the logger. A value lacking a key is attached under BadKey.
+func (l *Logger) Logw(level Level, message string, keysAndValues ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	if len(keysAndValues) > 0 {
+		l = l.WithFields(fieldsOf(keysAndValues))
+	}
+	l.newEntry(level, message)
+}
+
+// Fatalw logs a fatal message with the given keys and values. Please refer to Logw for how to use this method.
+func (l *Logger) Fatalw(message string, keysAndValues ...interface{}) {
+	l.Logw(LevelFatal, message, keysAndValues...)
+}
+
+// Errorw logs an error message with the given keys and values. Please refer to Logw for how to use this method.
+func (l *Logger) Errorw(message string, keysAndValues ...interface{}) {
+	l.Logw(LevelError, message, keysAndValues...)
+}
+
+// Warnw logs a warning message with the given keys and values. Please refer to Logw for how to use this method.
+func (l *Logger) Warnw(message string, keysAndValues ...interface{}) {
+	l.Logw(LevelWarn, message, keysAndValues...)
+}
+
+// Infow logs an informational message with the given keys and values. Please refer to Logw for how to use this method.
+func (l *Logger) Infow(message string, keysAndValues ...interface{}) {
+	l.Logw(LevelInfo, message, keysAndValues...)
+}
+
+// Debugw logs a debugging message with the given keys and values. Please refer to Logw for how to use this method.
+func (l *Logger) Debugw(message string, keysAndValues ...interface{}) {
+	l.Logw(LevelDebug, message, keysAndValues...)
+}
+
+// Criticalw logs a critical message with the given keys and values. Please refer to Logw for how to use this method.
+func (l *Logger) Criticalw(message string, keysAndValues ...interface{}) {
+	l.Logw(LevelCritical, message, keysAndValues...)
+}
+
+// Alertw logs an alert message with the given keys and values. Please refer to Logw for how to use this method.
+func (l *Logger) Alertw(message string, keysAndValues ...interface{}) {
+	l.Logw(LevelAlert, message, keysAndValues...)
+}
+
+// Emergencyw logs an emergency message with the given keys and values. Please refer to Logw for how to use this method.
+func (l *Logger) Emergencyw(message string, keysAndValues ...interface{}) {
+	l.Logw(LevelEmergency, message, keysAndValues...)
+}
+
+// Tracew logs a tracing message with the given keys and values. Please refer to Logw for how to use this method.
+func (l *Logger) Tracew(message string, keysAndValues ...interface{}) {
+	l.Logw(LevelTrace, message, keysAndValues...)
+}
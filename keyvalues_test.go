@@ -0,0 +1,44 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestLoggerInfow(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	l := logger.With("user", "alice", "attempt", 1)
+	l.Infow("t1", "attempt", 2, "ok", true)
+	l.Errorw("t2", "odd")
+	l.Warnw("t3", 42, "x")
+	l.Debugw("t4")
+	logger.Close()
+
+	tests := []struct {
+		level  log.Level
+		fields log.Fields
+	}{
+		{log.LevelInfo, log.Fields{"user": "alice", "attempt": 2, "ok": true}},
+		{log.LevelError, log.Fields{"user": "alice", "attempt": 1, log.BadKey: "odd"}},
+		{log.LevelWarn, log.Fields{"user": "alice", "attempt": 1, log.BadKey: "x"}},
+		{log.LevelDebug, log.Fields{"user": "alice", "attempt": 1}},
+	}
+	if len(target.entries) != len(tests) {
+		t.Fatalf("len(entries) = %v, expected %v", len(target.entries), len(tests))
+	}
+	for i, test := range tests {
+		e := target.entries[i]
+		if e.Level != test.level || !reflect.DeepEqual(e.Fields, test.fields) {
+			t.Errorf("entries[%v] = %v %v, expected %v %v", i, e.Level, e.Fields, test.level, test.fields)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Set parses a level name, matched case-insensitively, or a level value.
+// Together with String, it makes Level usable as a flag.Value:
+//
+//	level := log.LevelInfo
+//	flag.Var(&level, "log-level", "the maximum level of messages to be logged")
+func (l *Level) Set(s string) error {
+	s = strings.TrimSpace(s)
+	if level, ok := GetLevel(s); ok {
+		*l = level
+		return nil
+	}
+	value, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("unknown log level %q", s)
+	}
+	*l = Level(value)
+	return nil
+}
+
+// MarshalText returns the name of the level, or its value if it has no name.
+func (l Level) MarshalText() ([]byte, error) {
+	if name, ok := LevelNames[l]; ok {
+		return []byte(name), nil
+	}
+	return []byte(strconv.Itoa(int(l))), nil
+}
+
+// UnmarshalText parses a level like Set.
+func (l *Level) UnmarshalText(text []byte) error {
+	return l.Set(string(text))
+}
+
+// MarshalJSON encodes the level as a JSON string like MarshalText.
+func (l Level) MarshalJSON() ([]byte, error) {
+	text, _ := l.MarshalText()
+	return json.Marshal(string(text))
+}
+
+// UnmarshalJSON accepts a JSON string parsed like Set, or a JSON number.
+func (l *Level) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		return l.Set(s)
+	}
+	var value int
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("invalid log level %s", data)
+	}
+	*l = Level(value)
+	return nil
+}
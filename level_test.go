@@ -0,0 +1,66 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"encoding/json"
+	"flag"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestLevelSet(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected log.Level
+		ok       bool
+	}{
+		{"warn", log.LevelWarn, true},
+		{"DEBUG", log.LevelDebug, true},
+		{" Critical ", log.LevelCritical, true},
+		{"3", log.LevelInfo, true},
+		{"-3", log.LevelEmergency, true},
+		{"loud", 0, false},
+	}
+	for _, test := range tests {
+		var level log.Level
+		err := level.Set(test.input)
+		if (err == nil) != test.ok || test.ok && level != test.expected {
+			t.Errorf("Set(%q) = %v, %v, expected %v", test.input, level, err, test.expected)
+		}
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	level := log.LevelInfo
+	fs.Var(&level, "level", "")
+	if err := fs.Parse([]string{"-level", "error"}); err != nil || level != log.LevelError {
+		t.Errorf("flag -level error = %v, %v, expected %v", level, err, log.LevelError)
+	}
+}
+
+func TestLevelJSON(t *testing.T) {
+	var config struct {
+		A, B, C log.Level
+	}
+	if err := json.Unmarshal([]byte(`{"A": "warn", "B": 4, "C": "1"}`), &config); err != nil {
+		t.Fatalf("json.Unmarshal(): %v", err)
+	}
+	if config.A != log.LevelWarn || config.B != log.LevelDebug || config.C != log.LevelError {
+		t.Errorf("levels = %v %v %v, expected Warn Debug Error", config.A, config.B, config.C)
+	}
+	if err := json.Unmarshal([]byte(`{"A": true}`), &config); err == nil {
+		t.Errorf("json.Unmarshal() = nil, expected an error for a boolean")
+	}
+
+	config.A, config.B = log.LevelTrace, log.Level(42)
+	b, _ := json.Marshal(config)
+	if expected := `{"A":"Trace","B":"42","C":"Error"}`; string(b) != expected {
+		t.Errorf("json.Marshal() = %s, expected %s", b, expected)
+	}
+	if err := json.Unmarshal(b, &config); err != nil || config.B != 42 {
+		t.Errorf("the levels did not round-trip: %v, %v", config.B, err)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TruncatedFieldsKey is the field recording how many fields a SizeLimiter removed from an entry.
+const TruncatedFieldsKey = "truncated_fields"
+
+// SizeLimiter caps the size of log messages and fields, so that a single giant payload
+// does not blow up the targets. Oversized content is truncated and followed by a marker
+// such as "…(truncated 12345 bytes)". Add its Hook to a logger:
+//
+//	logger.AddHook(log.NewSizeLimiter().Hook)
+type SizeLimiter struct {
+	MaxMessageBytes int // the maximum length of a message in bytes. 0 means no limit.
+	MaxFields       int // the maximum number of fields. The fields beyond it are removed in key order. 0 means no limit.
+	MaxFieldBytes   int // the maximum length of a field value in bytes. 0 means no limit.
+}
+
+// NewSizeLimiter creates a SizeLimiter.
+// The new SizeLimiter takes these default options:
+// MaxMessageBytes: 64KB, MaxFields: 64, MaxFieldBytes: 8KB.
+func NewSizeLimiter() *SizeLimiter {
+	return &SizeLimiter{
+		MaxMessageBytes: 64 * 1024,
+		MaxFields:       64,
+		MaxFieldBytes:   8 * 1024,
+	}
+}
+
+// Hook truncates the oversized message and fields of an entry. It can be added to a logger with Logger.AddHook.
+func (s *SizeLimiter) Hook(e *Entry) *Entry {
+	e.Message = truncate(e.Message, s.MaxMessageBytes)
+	if len(e.Fields) == 0 || (s.MaxFields <= 0 || len(e.Fields) <= s.MaxFields) && s.MaxFieldBytes <= 0 {
+		return e
+	}
+
+	keys := make([]string, 0, len(e.Fields))
+	for key := range e.Fields {
+		keys = append(keys, key)
+	}
+	removed := 0
+	if s.MaxFields > 0 && len(keys) > s.MaxFields {
+		sort.Strings(keys)
+		removed = len(keys) - s.MaxFields
+		keys = keys[:s.MaxFields]
+	}
+	fields := make(Fields, len(keys)+1)
+	for _, key := range keys {
+		fields[key] = s.limitValue(e.Fields[key])
+	}
+	if removed > 0 {
+		fields[TruncatedFieldsKey] = removed
+	}
+	e.Fields = fields
+	return e
+}
+
+func (s *SizeLimiter) limitValue(value interface{}) interface{} {
+	if s.MaxFieldBytes <= 0 {
+		return value
+	}
+	switch v := value.(type) {
+	case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return value
+	case string:
+		return truncate(v, s.MaxFieldBytes)
+	default:
+		if str := fmt.Sprint(v); len(str) > s.MaxFieldBytes {
+			return truncate(str, s.MaxFieldBytes)
+		}
+		return value
+	}
+}
+
+// truncate shortens s to at most n bytes followed by a truncation marker. s is returned as is if n is not positive.
+func truncate(s string, n int) string {
+	if n <= 0 || len(s) <= n {
+		return s
+	}
+	t := truncateUTF8(s, n)
+	return t + fmt.Sprintf("…(truncated %d bytes)", len(s)-len(t))
+}
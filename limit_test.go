@@ -0,0 +1,45 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestSizeLimiter(t *testing.T) {
+	limiter := &log.SizeLimiter{MaxMessageBytes: 10, MaxFields: 2, MaxFieldBytes: 4}
+	e := &log.Entry{
+		Message: "héllo world, this is long",
+		Fields:  log.Fields{"a": "abcdef", "b": 123456789, "c": "x", "d": []int{1, 2, 3, 4}},
+	}
+	e = limiter.Hook(e)
+
+	if expected := "héllo wor…(truncated 16 bytes)"; e.Message != expected {
+		t.Errorf("Message = %q, expected %q", e.Message, expected)
+	}
+	if len(e.Fields) != 3 {
+		t.Errorf("len(Fields) = %v, expected %v", len(e.Fields), 3)
+	}
+	if e.Fields["a"] != "abcd…(truncated 2 bytes)" {
+		t.Errorf("Fields[a] = %v, expected %v", e.Fields["a"], "abcd…(truncated 2 bytes)")
+	}
+	if e.Fields["b"] != 123456789 {
+		t.Errorf("Fields[b] = %v, expected %v", e.Fields["b"], 123456789)
+	}
+	if e.Fields[log.TruncatedFieldsKey] != 2 {
+		t.Errorf("Fields[%v] = %v, expected %v", log.TruncatedFieldsKey, e.Fields[log.TruncatedFieldsKey], 2)
+	}
+
+	e = limiter.Hook(&log.Entry{Message: "short", Fields: log.Fields{"d": []int{1, 2, 3, 4}}})
+	if s, ok := e.Fields["d"].(string); !ok || !strings.HasPrefix(s, "[1 2…") {
+		t.Errorf("Fields[d] = %v, expected a truncated string", e.Fields["d"])
+	}
+	if e.Message != "short" {
+		t.Errorf("Message = %q, expected %q", e.Message, "short")
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package logdecrypt reads the log files encrypted by log.FileTarget:
+//
+//	key, err := log.ReadKeyFile("/etc/app/log.key")
+//	...
+//	err = logdecrypt.DecryptFile(os.Stdout, "app.log", key)
+//
+// A log file which is also compressed must be decompressed after being decrypted.
+package logdecrypt
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/admpub/log"
+)
+
+// ErrCorrupted is returned when a record of an encrypted log file cannot be decrypted, e.g. because
+// the key is wrong or the file was modified.
+var ErrCorrupted = errors.New("logdecrypt: the record cannot be decrypted")
+
+// maxRecordSize bounds the size of a record, so that a corrupted length does not exhaust the memory.
+const maxRecordSize = 64 << 20
+
+// Reader decrypts an encrypted log file.
+type Reader struct {
+	r    *bufio.Reader
+	aead cipher.AEAD
+	buf  []byte // the decrypted data not read yet
+}
+
+// NewReader returns a Reader decrypting the log file read from r with the given key.
+func NewReader(r io.Reader, key []byte) (*Reader, error) {
+	aead, err := log.NewEncryptionCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{r: bufio.NewReader(r), aead: aead}, nil
+}
+
+// Read reads the decrypted content of the log file. It returns io.ErrUnexpectedEOF if the file ends
+// with an incomplete record, e.g. because the program crashed while writing it, and ErrCorrupted if
+// a record cannot be decrypted.
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		record, err := r.Next()
+		if err != nil {
+			return 0, err
+		}
+		r.buf = record
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// Next decrypts the next record, which holds a message unless the file is also compressed.
+// It returns io.EOF when there is no more record.
+func (r *Reader) Next() ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r.r, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size < log.EncryptionNonceSize || size > maxRecordSize {
+		return nil, ErrCorrupted
+	}
+	record := make([]byte, size)
+	if _, err := io.ReadFull(r.r, record); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	data, err := r.aead.Open(record[log.EncryptionNonceSize:log.EncryptionNonceSize], record[:log.EncryptionNonceSize], record[log.EncryptionNonceSize:], nil)
+	if err != nil {
+		return nil, ErrCorrupted
+	}
+	return data, nil
+}
+
+// DecryptFile writes the decrypted content of the encrypted log file fileName to w.
+func DecryptFile(w io.Writer, fileName string, key []byte) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	r, err := NewReader(file, key)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
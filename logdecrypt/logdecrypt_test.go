@@ -0,0 +1,86 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logdecrypt_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/admpub/log"
+	"github.com/admpub/log/logdecrypt"
+)
+
+var key = []byte("0123456789abcdef0123456789abcdef")
+
+func writeLog(t *testing.T, fileName string, compression log.Compressor, messages ...string) {
+	logger := log.NewLogger()
+	logger.Sync()
+	target := log.NewFileTarget()
+	target.FileName = fileName
+	target.EncryptionKey = key
+	target.Compression = compression
+	logger.SetTarget(target)
+	if len(logger.Targets) != 1 {
+		t.Fatalf("the encrypted file target was not opened")
+	}
+	for _, message := range messages {
+		logger.Info(message)
+	}
+	logger.Close()
+}
+
+func TestDecryptFile(t *testing.T) {
+	logFile := "app.log"
+	os.Remove(logFile)
+	defer os.Remove(logFile)
+	writeLog(t, logFile, nil, "t1 secret", "t2")
+
+	data, _ := ioutil.ReadFile(logFile)
+	if bytes.Contains(data, []byte("secret")) {
+		t.Errorf("the log file is not encrypted")
+	}
+
+	out := &bytes.Buffer{}
+	if err := logdecrypt.DecryptFile(out, logFile, key); err != nil {
+		t.Fatalf("DecryptFile(): %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 2 || !strings.Contains(lines[0], "t1 secret") || !strings.Contains(lines[1], "t2") {
+		t.Errorf("decrypted log file = %q, expected t1 and t2", out.String())
+	}
+
+	wrongKey := []byte("fedcba9876543210")
+	if err := logdecrypt.DecryptFile(ioutil.Discard, logFile, wrongKey); err != logdecrypt.ErrCorrupted {
+		t.Errorf("DecryptFile() = %v with a wrong key, expected %v", err, logdecrypt.ErrCorrupted)
+	}
+}
+
+func TestDecryptCompressedFile(t *testing.T) {
+	logFile := "app.log.gz"
+	os.Remove(logFile)
+	defer os.Remove(logFile)
+	writeLog(t, logFile, log.GzipCompressor, "t1", "t2")
+
+	file, _ := os.Open(logFile)
+	defer file.Close()
+	r, err := logdecrypt.NewReader(file, key)
+	if err != nil {
+		t.Fatalf("NewReader(): %v", err)
+	}
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatalf("gzip.NewReader(): %v", err)
+	}
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("ReadAll(): %v", err)
+	}
+	if s := string(data); !strings.Contains(s, "t1") || !strings.Contains(s, "t2") {
+		t.Errorf("decrypted log file = %q, expected t1 and t2", s)
+	}
+}
@@ -7,12 +7,12 @@ package log
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
-	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -28,6 +28,16 @@ const (
 	LevelDebug
 )
 
+// Extended log message levels. The values of the levels above are kept for compatibility,
+// so the levels more severe than Fatal are negative, and Trace is less severe than Debug.
+// Notice, which RFC5424 places between Warn and Info, is not defined because no value is left in between.
+const (
+	LevelEmergency Level = LevelFatal - 3
+	LevelAlert     Level = LevelFatal - 2
+	LevelCritical  Level = LevelFatal - 1
+	LevelTrace     Level = LevelDebug + 1
+)
+
 const (
 	ActionNothing Action = iota
 	ActionPanic
@@ -38,27 +48,62 @@ const (
 type Level int
 type Action int
 
+// Hook is called with every log entry before it is formatted and handed to the targets.
+// It returns the entry to be logged, which may be modified, or nil to drop the message.
+// The Fields of the entry are shared with the logger, so a hook must replace them instead of modifying them.
+type Hook func(*Entry) *Entry
+
+// ActionFunc is called instead of the fatal action when a fatal message is logged.
+// It can hand the program over to the application's own shutdown.
+type ActionFunc func(*Entry)
+
 // LevelNames maps log levels to names
 var LevelNames = map[Level]string{
-	LevelDebug: "Debug",
-	LevelInfo:  "Info",
-	LevelWarn:  "Warn",
-	LevelError: "Error",
-	LevelFatal: "Fatal",
+	LevelTrace:     "Trace",
+	LevelDebug:     "Debug",
+	LevelInfo:      "Info",
+	LevelWarn:      "Warn",
+	LevelError:     "Error",
+	LevelFatal:     "Fatal",
+	LevelCritical:  "Critical",
+	LevelAlert:     "Alert",
+	LevelEmergency: "Emergency",
 }
 
 var Levels = map[string]Level{
-	"Debug": LevelDebug,
-	"Info":  LevelInfo,
-	"Warn":  LevelWarn,
-	"Error": LevelError,
-	"Fatal": LevelFatal,
+	"Trace":     LevelTrace,
+	"Debug":     LevelDebug,
+	"Info":      LevelInfo,
+	"Warn":      LevelWarn,
+	"Error":     LevelError,
+	"Fatal":     LevelFatal,
+	"Critical":  LevelCritical,
+	"Alert":     LevelAlert,
+	"Emergency": LevelEmergency,
 }
 
+// GetLevel returns the level of the given name, which is matched case-insensitively.
 func GetLevel(level string) (Level, bool) {
-	level = strings.Title(level)
-	l, y := Levels[level]
-	return l, y
+	if l, ok := Levels[strings.Title(level)]; ok {
+		return l, true
+	}
+	for name, l := range Levels {
+		if strings.EqualFold(name, level) {
+			return l, true
+		}
+	}
+	return 0, false
+}
+
+// RegisterLevel adds a custom level, or renames an existing one. Messages of a level are logged
+// when its value is no greater than the maximum level, so a smaller value is more severe.
+// RegisterLevel must be called before logging starts, e.g. in an init function.
+func RegisterLevel(value Level, name string) {
+	if old, ok := LevelNames[value]; ok {
+		delete(Levels, old)
+	}
+	LevelNames[value] = name
+	Levels[name] = value
 }
 
 // String returns the string representation of the log level
@@ -69,36 +114,104 @@ func (l Level) String() string {
 	return "Unknown"
 }
 
+// LoggerWriter logs the data written to it as messages of its level. The lines of a write ending with
+// a newline are logged as a message without the newline. The end of a write following its last newline
+// is kept until the next newline is written, and then logged as a message of its own, or until it
+// exceeds 64KB or Close is called.
 type LoggerWriter struct {
 	Level Level
 	*Logger
+
+	partial []byte // the partial line left by the previous writes
 }
 
+// partialLock guards the partial lines of the LoggerWriters. It is shared, so that a writer holds
+// no lock of its own, which would make a writer of a disabled level allocate.
+var partialLock sync.Mutex
+
+// maxPartialLine is the size from which a partial line written to a LoggerWriter is logged.
+const maxPartialLine = 64 << 10
+
 func (l *LoggerWriter) Write(p []byte) (n int, err error) {
-	var s string
 	n = len(p)
-	if p[n-1] == '\n' {
-		s = string(p[0 : n-1])
-	} else {
-		s = string(p)
+	if n == 0 || !l.Logger.Enabled(l.Level) {
+		return
+	}
+	var messages [2]string
+	count := 0
+	partialLock.Lock()
+	if end := bytes.LastIndexByte(p, '\n'); end >= 0 {
+		start := 0
+		if len(l.partial) > 0 {
+			// the first line completes the partial line
+			first := bytes.IndexByte(p, '\n')
+			messages[count] = string(append(l.partial, p[:first]...))
+			count++
+			start = first + 1
+		}
+		if start <= end {
+			messages[count] = string(p[start:end])
+			count++
+		}
+		l.partial = append(l.partial[:0], p[end+1:]...)
+	} else if l.partial = append(l.partial, p...); len(l.partial) >= maxPartialLine {
+		messages[count] = string(l.partial)
+		count++
+		l.partial = l.partial[:0]
+	}
+	partialLock.Unlock()
+	for _, message := range messages[:count] {
+		l.Logger.newEntry(l.Level, message)
 	}
-	l.Logger.newEntry(l.Level, s)
 	return
 }
 
+// Close logs the partial line left by the previous writes, if any.
+func (l *LoggerWriter) Close() error {
+	partialLock.Lock()
+	s := string(l.partial)
+	l.partial = l.partial[:0]
+	partialLock.Unlock()
+	if s != "" {
+		l.Logger.newEntry(l.Level, s)
+	}
+	return nil
+}
+
 func (l *LoggerWriter) Printf(format string, v ...interface{}) {
+	if !l.Logger.Enabled(l.Level) {
+		return
+	}
 	l.Logger.newEntry(l.Level, fmt.Sprintf(format, v...))
 }
 
+// Fields represents the structured key-value pairs attached to a log entry.
+type Fields map[string]interface{}
+
+// String returns the fields as space-separated key=value pairs sorted by key.
+func (f Fields) String() string {
+	return string(f.appendTo(nil))
+}
+
 // Entry represents a log entry.
 type Entry struct {
 	Level     Level
 	Category  string
 	Message   string
 	Time      time.Time
-	CallStack string
+	Seq       uint64          // the sequence number of the message among those of the loggers sharing the same targets, from 1.
+	CallStack CallStack       // the call stack recorded when Logger.CallStackDepth is positive, and for panics and fatal messages.
+	Fields    Fields          // the structured fields attached through Logger.WithFields. Hooks must not modify them.
+	Context   context.Context // the context attached through Logger.WithContext, or nil.
+	TraceID   string          // the ID of the trace the message belongs to, set through Logger.WithContext or Logger.WithTrace.
+	SpanID    string          // the ID of the span the message belongs to.
+	Error     error           // the error attached through Logger.WithError, or nil.
+	Raw       []byte          // the binary data attached through Logger.DebugDump, or nil. It must not be modified.
 
 	FormattedMessage string
+
+	control  *control // set on the internal entries used to control the pipeline
+	critical bool     // set on the fatal messages and those of SyncLog, which are not dropped with DropOnFull
 }
 
 // String returns the string representation of the log entry
@@ -106,6 +219,39 @@ func (e *Entry) String() string {
 	return e.FormattedMessage
 }
 
+// Clone returns a copy of the entry with its own copy of the fields and call stack, which can be modified
+// without affecting the entry.
+func (e *Entry) Clone() *Entry {
+	c := *e
+	c.Fields = e.Fields.clone()
+	if len(e.CallStack) > 0 {
+		c.CallStack = append(CallStack{}, e.CallStack...)
+	}
+	return &c
+}
+
+// snapshot returns the entry handed to the i-th of n targets, so that a target modifying its entry
+// does not affect the other targets nor the logger the fields are shared with: every target but the
+// last gets a clone, and the last gets the entry itself with its own copy of the fields.
+func (e *Entry) snapshot(i, n int) *Entry {
+	if i < n-1 {
+		return e.Clone()
+	}
+	e.Fields = e.Fields.clone()
+	return e
+}
+
+func (f Fields) clone() Fields {
+	if len(f) == 0 {
+		return f
+	}
+	c := make(Fields, len(f))
+	for name, value := range f {
+		c[name] = value
+	}
+	return c
+}
+
 // Target represents a target where the logger can send log messages to for further processing.
 type Target interface {
 	// Open prepares the target for processing log messages.
@@ -114,6 +260,7 @@ type Target interface {
 	// errWriter should be used to write errors found while processing log messages.
 	Open(errWriter io.Writer) error
 	// Process processes an incoming log message.
+	// Each target gets its own copy of the entry, which it may modify.
 	Process(*Entry)
 	// Close closes a target.
 	// Close is called when Logger.Close() is called, which gives each target
@@ -124,22 +271,175 @@ type Target interface {
 }
 
 // coreLogger maintains the log messages in a channel and sends them to various targets.
+//
+// The exported fields configure the logger when Open is called. While the logger is open,
+// the configuration in use is an immutable snapshot which is replaced atomically by
+// SetLevel, SetTarget, AddTarget and Sync, so that it can change without racing with logging.
 type coreLogger struct {
+	seq         uint64 // the sequence number of the last entry. It is first to be aligned for atomic operations.
 	lock        sync.Mutex
-	open        bool        // whether the logger is open
-	entries     chan *Entry // log entries
+	clock       atomic.Value // the clockHolder set by SetClock
+	timeFormat  atomic.Value // the *timeFormat set by SetTimeFormat and SetTimeLocation
+	stackFormat atomic.Value // the *StackFormat set by SetStackFormat
+	config      atomic.Value // the *loggerConfig in use
 	goroutines  int32
 	fatalAction Action
+	fatalFunc   ActionFunc
+	fatalHooks  []func(*Entry) // called when a fatal message is logged
+	unhealthy   atomic.Value   // the []func(TargetStatus) registered with OnUnhealthy
+	hooks       []Hook         // called with every entry before it is formatted
+	hookIDs     []uint64       // the IDs of the hooks, by which AddRemovableHook removes them
+	lastHookID  uint64
+	catLevels   map[string]Level // the maximum levels of categories set by SetCategoryLevel
+	elevations  []*elevation     // the levels raised by ElevateFor
+	exiting     int32            // set when a fatal message is exiting the program
+	occurrences occurrences      // the occurrences counted by Once and EveryN
+	levelCounts levelCounts      // the number of messages logged by level
+	catCounts   sync.Map         // the *levelCounts of each category, reset by ResetCounts
+	processing  int32            // the number of calls to the Process methods of the targets in progress
+	openErrors  []openFailure    // the targets which failed to open when the targets were last opened
+	fmtPanics   int32            // the number of panics of the formatters
+	fmtDisabled int32            // set when the formatters are replaced by DefaultFormatter after MaxPanics panics
 
 	ErrorWriter     io.Writer // the writer used to write errors caused by log targets
-	BufferSize      int       // the size of the channel storing log entries
+	BufferSize      int       // the size of the queue storing log entries
+	TargetBuffer    int       // the size of the queue in front of each target
+	TargetBatchSize int       // the maximum number of queued messages handed at once to a BatchTarget
 	CallStackDepth  int       // the number of call stack frames to be logged for each message. 0 means do not log any call stack frame.
 	CallStackFilter string    // a substring that a call stack frame file path should contain in order for the frame to be counted
-	MaxLevel        Level     // the maximum level of messages to be logged
-	Targets         []Target  // targets for sending log messages to
+	MaxLevel        Level     // the maximum level of messages to be logged, applied by Open. See SetMaxLevel.
+	Targets         []Target  // targets for sending log messages to, opened by Open. See SetTarget.
 	SyncMode        bool      // Whether the use of non-asynchronous mode （是否使用非异步模式）
 	MaxGoroutines   int32     // Max Goroutine
 	AddSpace        bool      // Add a space between two arguments.
+	ExitCode        int       // the status code passed to os.Exit when a fatal message is logged with ActionExit
+	// the number of goroutines processing the messages of each ConcurrentTarget. 0 means 1.
+	Workers int
+	// whether to queue the messages in a buffered channel of BufferSize, as the earlier versions did, instead of
+	// a lock-free ring buffer whose size is the power of two above BufferSize
+	ChannelQueue bool
+	// whether to drop the messages for a target whose queue is full, reporting their number to ErrorWriter, instead
+	// of waiting for room in the queue, so that a slow target does not hold up the others and the callers.
+	// Fatal messages and those logged by SyncLog are never dropped.
+	DropOnFull bool
+
+	// substrings one of which a call stack frame file path should contain in order for the frame to be counted,
+	// in addition to CallStackFilter
+	CallStackIncludes []string
+	// substrings that a call stack frame file path should not contain in order for the frame to be counted,
+	// e.g. the paths of logging helpers or of vendored packages
+	CallStackExcludes []string
+	// whether to add the stacks of all goroutines to fatal messages, so that deadlocks and stuck goroutines
+	// can be investigated
+	GoroutineDump bool
+	// the file the stacks of all goroutines are appended to instead of being added to fatal messages, if not empty
+	CrashFile string
+	// the maximum number of bytes attached to a message by DebugDump. The data is truncated beyond.
+	// 0 means DefaultMaxDumpSize, and a negative value no limit.
+	MaxDumpSize int
+	// the number of panics after which a formatter is replaced by DefaultFormatter, or a target stops receiving
+	// messages. The panics are reported to ErrorWriter. 0 means DefaultMaxPanics, and a negative value never.
+	MaxPanics int
+	// the level of the messages logged by Assert when an assertion fails. With LevelFatal, the fatal action
+	// is performed as well.
+	AssertLevel Level
+}
+
+// loggerConfig is a snapshot of the configuration of an open logger. It must not be modified once stored.
+type loggerConfig struct {
+	open     bool
+	maxLevel Level
+	syncMode bool
+	hooks    []Hook
+	levels   map[string]Level // the maximum levels of categories, overriding maxLevel
+	stack    stackOptions     // the call stack options
+	elevated map[string]Level // the levels raised by ElevateFor by category, "" for every category
+	pipeline *pipeline
+	targets  []Target        // the open targets
+	workers  []*targetWorker // the workers feeding the targets, one per target
+}
+
+var closedConfig = &loggerConfig{}
+
+// pipeline carries the log entries from the loggers to the dispatcher while the logger is open.
+type pipeline struct {
+	entries entryQueue    // log entries
+	lock    sync.Mutex    // guards the fields below
+	sent    *sync.Cond    // signaled when the entries of an epoch have all been sent
+	closed  bool          // whether the pipeline no longer accepts entries
+	epoch   int           // the epoch new entries are counted in, 0 or 1
+	sending [2]int        // the number of entries being sent, by epoch
+	done    chan struct{} // closed when the logger has been shut down
+}
+
+func newPipeline(size int, channel bool) *pipeline {
+	p := &pipeline{
+		entries: newEntryQueue(size, channel),
+		done:    make(chan struct{}),
+	}
+	p.sent = sync.NewCond(&p.lock)
+	return p
+}
+
+// add counts an entry about to be sent. It returns the epoch of the entry and false if the pipeline is closed.
+func (p *pipeline) add() (int, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return 0, false
+	}
+	p.sending[p.epoch]++
+	return p.epoch, true
+}
+
+// markSent marks an entry of the given epoch as sent.
+func (p *pipeline) markSent(epoch int) {
+	p.lock.Lock()
+	p.sending[epoch]--
+	if p.sending[epoch] == 0 {
+		p.sent.Broadcast()
+	}
+	p.lock.Unlock()
+}
+
+// wait waits until the entries which were being sent when it was called are in the channel.
+// The entries added meanwhile are counted in the other epoch, so that wait does not depend on them.
+// Calls to wait must not overlap.
+func (p *pipeline) wait() {
+	p.lock.Lock()
+	epoch := p.epoch
+	p.epoch = 1 - epoch
+	for p.sending[epoch] > 0 {
+		p.sent.Wait()
+	}
+	p.lock.Unlock()
+}
+
+// close waits for the entries being sent and signals the dispatcher to stop.
+func (p *pipeline) close() {
+	p.lock.Lock()
+	p.closed = true
+	for p.sending[0]+p.sending[1] > 0 {
+		p.sent.Wait()
+	}
+	p.lock.Unlock()
+	// use a nil entry to signal the close of logger
+	p.entries.put(nil)
+}
+
+// current returns the configuration in use.
+func (l *coreLogger) current() *loggerConfig {
+	if c, ok := l.config.Load().(*loggerConfig); ok {
+		return c
+	}
+	return closedConfig
+}
+
+// update replaces the configuration in use with a modified copy. It must be called with l.lock held.
+func (l *coreLogger) update(modify func(c *loggerConfig)) {
+	c := *l.current()
+	modify(&c)
+	l.config.Store(&c)
 }
 
 // Formatter formats a log message into an appropriate string.
@@ -149,21 +449,35 @@ type Formatter func(*Logger, *Entry) string
 type Logger struct {
 	*coreLogger
 	Category   string    // the category associated with this logger
-	Formatter  Formatter // message formatter
+	Formatter  Formatter // message formatter. Use SetFormatter to change it while logging.
 	categories map[string]*Logger
+	fields     Fields
+	ctx        context.Context
+	traceID    string
+	spanID     string
+	err        error
+	callerSkip int          // the number of frames added by AddCallerSkip to those skipped by the call stacks
+	formatter  atomic.Value // the Formatter set by SetFormatter
+	level      *Level       // the maximum level set by WithLevel, overriding those of the logger
+	debug      bool         // whether the context has triggered the Debug level, see ContextWithDebug
 }
 
 // NewLogger creates a root logger.
 // The new logger takes these default options:
-// ErrorWriter: os.Stderr, BufferSize: 1024, MaxLevel: LevelDebug,
+// ErrorWriter: os.Stderr, BufferSize: 1024, TargetBuffer: 1024, TargetBatchSize: 128, MaxLevel: LevelDebug,
+// AssertLevel: LevelError,
 // Category: app, Formatter: DefaultFormatter
 func NewLogger(args ...string) *Logger {
 	logger := &coreLogger{
-		ErrorWriter:   os.Stderr,
-		BufferSize:    1024,
-		MaxLevel:      LevelDebug,
-		Targets:       make([]Target, 0),
-		MaxGoroutines: 100000,
+		ErrorWriter:     os.Stderr,
+		BufferSize:      1024,
+		TargetBuffer:    1024,
+		TargetBatchSize: 128,
+		MaxLevel:        LevelDebug,
+		Targets:         make([]Target, 0),
+		MaxGoroutines:   100000,
+		ExitCode:        1,
+		AssertLevel:     LevelError,
 	}
 	category := `app`
 	if len(args) > 0 {
@@ -193,11 +507,20 @@ func (l *Logger) GetLogger(category string, formatter ...Formatter) *Logger {
 		logger = &Logger{
 			coreLogger: l.coreLogger,
 			Category:   category,
+			categories: make(map[string]*Logger),
+			fields:     l.fields,
+			ctx:        l.ctx,
+			traceID:    l.traceID,
+			spanID:     l.spanID,
+			err:        l.err,
+			callerSkip: l.callerSkip,
+			level:      l.level,
+			debug:      l.debug,
 		}
 		if len(formatter) > 0 {
 			logger.Formatter = formatter[0]
 		} else {
-			logger.Formatter = l.Formatter
+			logger.Formatter = l.currentFormatter()
 		}
 		l.categories[category] = logger
 	} else {
@@ -208,45 +531,332 @@ func (l *Logger) GetLogger(category string, formatter ...Formatter) *Logger {
 	return logger
 }
 
+// WithFields returns a logger that attaches the given fields, merged with the fields
+// of the calling logger, to every message it logs.
+func (l *Logger) WithFields(fields Fields) *Logger {
+	merged := make(Fields, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	logger := l.clone()
+	logger.fields = merged
+	return logger
+}
+
+// WithContext returns a logger whose messages carry the given context,
+// so targets can extract request-scoped values from it.
+// The trace and span IDs found in the context by SpanContext are attached to the messages,
+// and the debug messages are logged if the context is marked by ContextWithDebug.
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	logger := l.clone()
+	logger.ctx = ctx
+	if ctx != nil {
+		if traceID, spanID := SpanContext(ctx); traceID != "" {
+			logger.traceID, logger.spanID = traceID, spanID
+		}
+		logger.debug = logger.debug || DebugFromContext(ctx)
+	}
+	return logger
+}
+
+func (l *Logger) clone() *Logger {
+	return &Logger{
+		coreLogger: l.coreLogger,
+		Category:   l.Category,
+		Formatter:  l.currentFormatter(),
+		categories: make(map[string]*Logger),
+		fields:     l.fields,
+		ctx:        l.ctx,
+		traceID:    l.traceID,
+		spanID:     l.spanID,
+		err:        l.err,
+		callerSkip: l.callerSkip,
+		level:      l.level,
+		debug:      l.debug,
+	}
+}
+
+// AddCallerSkip returns a logger which skips n more frames when recording call stacks, so that
+// the call stacks of the messages logged by a helper wrapping the logger start at the caller of
+// the helper. It is cumulative: each call adds to the frames skipped by the calling logger.
+func (l *Logger) AddCallerSkip(n int) *Logger {
+	logger := l.clone()
+	logger.callerSkip = l.callerSkip + n
+	return logger
+}
+
+// Sync switches the logger to the synchronous mode, or back to the asynchronous mode with Sync(false).
 func (l *Logger) Sync(args ...bool) *Logger {
-	if len(args) < 1 {
-		l.SyncMode = true
-		return l
+	mode := len(args) < 1 || args[0]
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.SyncMode = mode
+	l.update(func(c *loggerConfig) {
+		c.syncMode = mode
+	})
+	return l
+}
+
+// AddHook adds hooks which are called in order with every entry before it is formatted.
+// A hook can enrich or modify the entry, or drop it by returning nil.
+func (l *Logger) AddHook(hooks ...Hook) *Logger {
+	l.addHooks(hooks)
+	return l
+}
+
+// AddRemovableHook adds a hook like AddHook, and returns a function removing it, e.g. when a test ends.
+func (l *Logger) AddRemovableHook(hook Hook) (remove func()) {
+	id := l.addHooks([]Hook{hook})
+	return func() {
+		l.removeHook(id)
 	}
-	l.SyncMode = args[0]
+}
+
+// addHooks adds hooks and returns the ID of the last one.
+func (l *Logger) addHooks(hooks []Hook) uint64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	ids := append([]uint64{}, l.hookIDs...)
+	for range hooks {
+		l.lastHookID++
+		ids = append(ids, l.lastHookID)
+	}
+	l.setHooks(append(append([]Hook{}, l.hooks...), hooks...), ids)
+	return l.lastHookID
+}
+
+// removeHook removes the hook of the given ID, if it has not been removed yet.
+func (l *Logger) removeHook(id uint64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for i, hookID := range l.hookIDs {
+		if hookID == id {
+			hooks := append(append([]Hook{}, l.hooks[:i]...), l.hooks[i+1:]...)
+			ids := append(append([]uint64{}, l.hookIDs[:i]...), l.hookIDs[i+1:]...)
+			l.setHooks(hooks, ids)
+			return
+		}
+	}
+}
+
+// setHooks replaces the hooks. It must be called with l.lock held.
+func (l *Logger) setHooks(hooks []Hook, ids []uint64) {
+	l.hooks = hooks
+	l.hookIDs = ids
+	l.update(func(c *loggerConfig) {
+		c.hooks = hooks
+	})
+}
+
+// SetFormatter changes the formatter of the logger. It can be called while messages are being logged.
+func (l *Logger) SetFormatter(formatter Formatter) *Logger {
+	l.formatter.Store(formatter)
+	// the new formatter is given a chance even if the previous one was disabled
+	atomic.StoreInt32(&l.fmtPanics, 0)
+	atomic.StoreInt32(&l.fmtDisabled, 0)
 	return l
 }
 
+// currentFormatter returns the formatter set by SetFormatter, or the Formatter field.
+func (l *Logger) currentFormatter() Formatter {
+	if f, ok := l.formatter.Load().(Formatter); ok {
+		return f
+	}
+	return l.Formatter
+}
+
+// format formats a message with the formatter of the logger. A panic of the formatter is reported to ErrorWriter
+// and the message is formatted by DefaultFormatter instead.
+func (l *Logger) format(e *Entry) (message string) {
+	if atomic.LoadInt32(&l.fmtDisabled) == 1 {
+		return DefaultFormatter(l, e)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			l.formatterPanicked(r)
+			message = DefaultFormatter(l, e)
+		}
+	}()
+	return l.currentFormatter()(l, e)
+}
+
+// SetTarget replaces the targets of the logger. The new targets are opened and the removed ones are closed,
+// without stopping the logger: messages logged before the call go to the old targets and the later ones
+// to the new targets. Like Open, it applies the changes made to the exported fields. Calling SetTarget
+// without targets closes the logger.
 func (l *Logger) SetTarget(targets ...Target) *Logger {
-	l.Close()
-	if len(targets) > 0 {
-		l.Targets = targets
-		l.Open()
-	} else {
+	if len(targets) == 0 {
+		l.Close()
 		l.Targets = []Target{}
+		return l
 	}
+	l.setTargets(targets)
 	return l
 }
 
 func (l *Logger) SetFatalAction(action Action) *Logger {
 	l.fatalAction = action
+	l.fatalFunc = nil
+	return l
+}
+
+// SetFatalFunc sets a function to be called instead of the fatal action when a fatal message is logged.
+func (l *Logger) SetFatalFunc(fn ActionFunc) *Logger {
+	l.fatalFunc = fn
 	return l
 }
 
+// SetExitCode sets the status code the program exits with when a fatal message is logged with ActionExit.
+func (l *Logger) SetExitCode(code int) *Logger {
+	l.ExitCode = code
+	return l
+}
+
+// AddTarget opens the given targets and adds them to the logger without stopping it.
 func (l *Logger) AddTarget(targets ...Target) *Logger {
-	l.Close()
-	l.Targets = append(l.Targets, targets...)
-	l.Open()
+	l.lock.Lock()
+	current := append([]Target{}, l.Targets...)
+	l.lock.Unlock()
+	l.setTargets(append(current, targets...))
 	return l
 }
 
+// SetLevel changes the maximum level of messages to be logged, e.g. SetLevel("Warn").
 func (l *Logger) SetLevel(level string) *Logger {
 	if le, ok := GetLevel(level); ok {
-		l.MaxLevel = le
+		l.SetMaxLevel(le)
+	}
+	return l
+}
+
+// SetMaxLevel changes the maximum level of messages to be logged. It can be called while messages are being logged.
+func (l *Logger) SetMaxLevel(level Level) *Logger {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.MaxLevel = level
+	l.update(func(c *loggerConfig) {
+		c.maxLevel = level
+	})
+	return l
+}
+
+// SetCallStack changes the call stack options of the logger, see CallStackDepth, CallStackFilter,
+// CallStackIncludes and CallStackExcludes. It can be called while messages are being logged.
+func (l *Logger) SetCallStack(depth int, filter string, includes, excludes []string) *Logger {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.CallStackDepth = depth
+	l.CallStackFilter = filter
+	l.CallStackIncludes = includes
+	l.CallStackExcludes = excludes
+	l.update(func(c *loggerConfig) {
+		c.stack = l.stackOptions()
+	})
+	return l
+}
+
+// SetCategoryLevel sets the maximum level of messages of a category and of its children in the
+// dot-separated hierarchy, e.g. "app.db" applies to "app.db.query" unless it has its own level.
+// It overrides the maximum level of the logger and can be called while messages are being logged.
+func (l *Logger) SetCategoryLevel(category string, level Level) *Logger {
+	l.setCategoryLevel(category, &level)
+	return l
+}
+
+// UnsetCategoryLevel removes the level set for a category, which then inherits the level of its parent.
+func (l *Logger) UnsetCategoryLevel(category string) *Logger {
+	l.setCategoryLevel(category, nil)
+	return l
+}
+
+// SetCategoryLevels replaces all the levels set for categories with the given ones, e.g. when a
+// configuration is reloaded. It can be called while messages are being logged.
+func (l *Logger) SetCategoryLevels(levels map[string]Level) *Logger {
+	copied := make(map[string]Level, len(levels))
+	for category, level := range levels {
+		copied[category] = level
 	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.catLevels = copied
+	l.update(func(c *loggerConfig) {
+		c.levels = copied
+	})
 	return l
 }
 
+func (l *Logger) setCategoryLevel(category string, level *Level) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	levels := make(map[string]Level, len(l.catLevels)+1)
+	for k, v := range l.catLevels {
+		levels[k] = v
+	}
+	if level != nil {
+		levels[category] = *level
+	} else {
+		delete(levels, category)
+	}
+	l.catLevels = levels
+	l.update(func(c *loggerConfig) {
+		c.levels = levels
+	})
+}
+
+// EffectiveLevel returns the maximum level of messages of a category, taking the levels
+// of its parents and the maximum level of the logger into account.
+func (l *Logger) EffectiveLevel(category string) Level {
+	return l.current().levelOf(category)
+}
+
+// LevelTree describes the effective levels of the categories which have a level set and of
+// the named loggers sharing this logger, one category per line in order, for debugging:
+//
+//	(root) Info
+//	app.db Warn
+//	app.db.query Warn (from app.db)
+func (l *Logger) LevelTree() string {
+	c := l.current()
+	seen := map[string]bool{}
+	for category := range c.levels {
+		seen[category] = true
+	}
+	for _, category := range namedCategories(l.coreLogger) {
+		seen[category] = true
+	}
+	categories := make([]string, 0, len(seen))
+	for category := range seen {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "(root) %v\n", c.maxLevel)
+	if elevated, ok := c.elevatedLevel(""); ok && elevated > c.maxLevel {
+		buf.Truncate(buf.Len() - 1)
+		fmt.Fprintf(buf, ", elevated to %v\n", elevated)
+	}
+	for _, category := range categories {
+		level, source := c.levelSource(category)
+		switch source {
+		case category:
+			fmt.Fprintf(buf, "%v %v\n", category, level)
+		case "":
+			fmt.Fprintf(buf, "%v %v (from root)\n", category, level)
+		default:
+			fmt.Fprintf(buf, "%v %v (from %v)\n", category, level, source)
+		}
+		if elevated, ok := c.elevatedLevel(category); ok && elevated > level {
+			buf.Truncate(buf.Len() - 1)
+			fmt.Fprintf(buf, ", elevated to %v\n", elevated)
+		}
+	}
+	return buf.String()
+}
+
 func (l *Logger) Fatalf(format string, a ...interface{}) {
 	l.Logf(LevelFatal, format, a...)
 }
@@ -277,18 +887,115 @@ func (l *Logger) Debugf(format string, a ...interface{}) {
 	l.Logf(LevelDebug, format, a...)
 }
 
+func (l *Logger) Criticalf(format string, a ...interface{}) {
+	l.Logf(LevelCritical, format, a...)
+}
+
+func (l *Logger) Alertf(format string, a ...interface{}) {
+	l.Logf(LevelAlert, format, a...)
+}
+
+func (l *Logger) Emergencyf(format string, a ...interface{}) {
+	l.Logf(LevelEmergency, format, a...)
+}
+
+func (l *Logger) Tracef(format string, a ...interface{}) {
+	l.Logf(LevelTrace, format, a...)
+}
+
+// LogLevelf logs a formatted message of the level of the given name, which may be a registered custom level.
+// Messages of unknown levels are reported to the ErrorWriter.
+func (l *Logger) LogLevelf(name string, format string, a ...interface{}) {
+	if level, ok := GetLevel(name); ok {
+		l.Logf(level, format, a...)
+	} else {
+		fmt.Fprintf(l.ErrorWriter, "Unknown log level %q: %v\n", name, fmt.Sprintf(format, a...))
+	}
+}
+
+// Enabled returns whether messages of the specified severity level are logged.
+// It can be used to avoid building expensive log arguments which would be discarded.
+func (l *Logger) Enabled(level Level) bool {
+	c := l.current()
+	var max Level
+	if l.level != nil {
+		max = *l.level
+	} else {
+		max = c.levelOf(l.Category)
+	}
+	if l.debug && max < LevelDebug {
+		max = LevelDebug
+	}
+	return c.open && level <= max
+}
+
+// IsDebugEnabled returns whether debug messages are logged.
+func (l *Logger) IsDebugEnabled() bool {
+	return l.Enabled(LevelDebug)
+}
+
 // Logf logs a message of a specified severity level.
+// Nothing is formatted or allocated when the level is disabled.
 func (l *Logger) Logf(level Level, format string, a ...interface{}) {
-	if level > l.MaxLevel || !l.open {
+	if !l.Enabled(level) {
 		return
 	}
 	message := format
 	if len(a) > 0 {
-		message = fmt.Sprintf(format, a...)
+		message = fmt.Sprintf(format, evalArgs(a)...)
 	}
 	l.newEntry(level, message)
 }
 
+// LogFn logs the message returned by fn at the specified severity level.
+// fn is only called when the level is enabled.
+func (l *Logger) LogFn(level Level, fn func() string) {
+	if !l.Enabled(level) {
+		return
+	}
+	l.newEntry(level, fn())
+}
+
+// ErrorFn logs the message returned by fn, which is only called when the Error level is enabled.
+func (l *Logger) ErrorFn(fn func() string) {
+	l.LogFn(LevelError, fn)
+}
+
+// WarnFn logs the message returned by fn, which is only called when the Warn level is enabled.
+func (l *Logger) WarnFn(fn func() string) {
+	l.LogFn(LevelWarn, fn)
+}
+
+// InfoFn logs the message returned by fn, which is only called when the Info level is enabled.
+func (l *Logger) InfoFn(fn func() string) {
+	l.LogFn(LevelInfo, fn)
+}
+
+// DebugFn logs the message returned by fn, which is only called when the Debug level is enabled.
+func (l *Logger) DebugFn(fn func() string) {
+	l.LogFn(LevelDebug, fn)
+}
+
+// evalArgs replaces the func() string arguments with their results.
+// Such arguments, like fmt.Stringer values, are therefore only evaluated when a message is logged.
+func evalArgs(a []interface{}) []interface{} {
+	var args []interface{}
+	for i, arg := range a {
+		if fn, ok := arg.(func() string); ok {
+			if args == nil {
+				args = make([]interface{}, len(a))
+				copy(args, a)
+			}
+			args[i] = fn()
+		}
+	}
+	if args == nil {
+		return a
+	}
+	return args
+}
+
+// Writer returns a *LoggerWriter logging the data written to it as messages of the given level.
 func (l *Logger) Writer(level Level) io.Writer {
 	return &LoggerWriter{
 		Level:  level,
@@ -296,6 +1003,21 @@ func (l *Logger) Writer(level Level) io.Writer {
 	}
 }
 
+// Writers returns a LoggerWriter for each registered level, e.g. to log the standard output of a command
+// as informational messages and its standard error as errors:
+//
+//	writers := logger.Writers()
+//	cmd.Stdout, cmd.Stderr = writers[log.LevelInfo], writers[log.LevelError]
+//
+// Close the writers once the command has exited, to log its last lines if they do not end with a newline.
+func (l *Logger) Writers() map[Level]*LoggerWriter {
+	writers := make(map[Level]*LoggerWriter, len(LevelNames))
+	for level := range LevelNames {
+		writers[level] = &LoggerWriter{Level: level, Logger: l}
+	}
+	return writers
+}
+
 func (l *Logger) Fatal(a ...interface{}) {
 	l.Log(LevelFatal, a...)
 }
@@ -326,156 +1048,602 @@ func (l *Logger) Debug(a ...interface{}) {
 	l.Log(LevelDebug, a...)
 }
 
+// Critical logs a message indicating a critical condition.
+// Please refer to Error() for how to use this method.
+func (l *Logger) Critical(a ...interface{}) {
+	l.Log(LevelCritical, a...)
+}
+
+// Alert logs a message indicating that action must be taken immediately.
+// Please refer to Error() for how to use this method.
+func (l *Logger) Alert(a ...interface{}) {
+	l.Log(LevelAlert, a...)
+}
+
+// Emergency logs a message indicating that the system is unusable.
+// Please refer to Error() for how to use this method.
+func (l *Logger) Emergency(a ...interface{}) {
+	l.Log(LevelEmergency, a...)
+}
+
+// Trace logs a message which is more detailed than debugging messages.
+// Please refer to Error() for how to use this method.
+func (l *Logger) Trace(a ...interface{}) {
+	l.Log(LevelTrace, a...)
+}
+
+// LogLevel logs a message of the level of the given name, which may be a registered custom level.
+// Messages of unknown levels are reported to the ErrorWriter.
+func (l *Logger) LogLevel(name string, a ...interface{}) {
+	if level, ok := GetLevel(name); ok {
+		l.Log(level, a...)
+	} else {
+		fmt.Fprintf(l.ErrorWriter, "Unknown log level %q: %v\n", name, fmt.Sprint(a...))
+	}
+}
+
 // Log logs a message of a specified severity level.
+// Nothing is formatted or allocated when the level is disabled.
 func (l *Logger) Log(level Level, a ...interface{}) {
-	if level > l.MaxLevel || !l.open {
+	if !l.Enabled(level) {
 		return
 	}
-	var message string
+	l.newEntry(level, l.sprint(evalArgs(a)))
+}
+
+// sprint formats the arguments of a message, separated by spaces if AddSpace is set.
+func (l *Logger) sprint(a []interface{}) string {
 	if l.AddSpace {
-		message = fmt.Sprintln(a...)
-		message = message[:len(message)-1]
-	} else {
-		message = fmt.Sprint(a...)
+		message := fmt.Sprintln(a...)
+		return message[:len(message)-1]
 	}
-	l.newEntry(level, message)
+	return fmt.Sprint(a...)
+}
+
+// SyncLog logs a message of a specified severity level like Log, and returns once the message and those logged
+// before it have been processed by the targets, while the other messages are still logged asynchronously.
+// It is meant for the critical messages logged right before the program execs another one or exits.
+func (l *Logger) SyncLog(level Level, a ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	l.logEntry(level, l.sprint(evalArgs(a)), true)
+	l.flush()
+}
+
+// SyncLogf logs a formatted message of a specified severity level like Logf, and returns once it has been
+// processed by the targets, see SyncLog.
+func (l *Logger) SyncLogf(level Level, format string, a ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	message := format
+	if len(a) > 0 {
+		message = fmt.Sprintf(format, evalArgs(a)...)
+	}
+	l.logEntry(level, message, true)
+	l.flush()
 }
 
 // Log logs a message of a specified severity level.
 func (l *Logger) newEntry(level Level, message string) {
+	l.logEntry(level, message, false)
+}
+
+// logEntry logs a message. A critical message is not dropped for a full target with DropOnFull.
+func (l *Logger) logEntry(level Level, message string, critical bool) {
 	if level == LevelFatal {
 		l.newFatalEntry(level, message)
 		return
 	}
-	entry := &Entry{
+	entry := l.makeEntry(level, message)
+	entry.critical = critical
+	if stack := &l.current().stack; stack.depth > 0 {
+		entry.CallStack = callFrames(1, stack.depth, stack.keepFrame, true, l.callerSkip)
+	}
+	l.dispatch(entry)
+}
+
+// makeEntry creates an entry carrying the category, fields, context and trace of the logger.
+func (l *Logger) makeEntry(level Level, message string) *Entry {
+	return &Entry{
 		Category: l.Category,
 		Level:    level,
 		Message:  message,
-		Time:     time.Now(),
+		Time:     l.now(),
+		Seq:      atomic.AddUint64(&l.seq, 1),
+		Fields:   l.fields,
+		Context:  l.ctx,
+		TraceID:  l.traceID,
+		SpanID:   l.spanID,
+		Error:    l.err,
+	}
+}
+
+// dispatch formats an entry and hands it to the targets.
+func (l *Logger) dispatch(entry *Entry) {
+	c := l.current()
+	critical := entry.critical
+	if entry = c.applyHooks(entry); entry == nil {
+		return
 	}
-	if l.CallStackDepth > 0 {
-		entry.CallStack = GetCallStack(3, l.CallStackDepth, l.CallStackFilter)
+	// a hook may return a new entry
+	entry.critical = critical
+	if entry.Category != HeartbeatCategory {
+		l.count(entry)
 	}
-	entry.FormattedMessage = l.Formatter(l, entry)
-	if l.SyncMode {
-		l.syncProcess(entry)
+	entry.FormattedMessage = l.format(entry)
+	if l.reentrant(c) {
+		l.writeReentrant(entry)
+	} else if c.syncMode {
+		c.syncProcess(entry)
 	} else {
-		send := func() {
-			atomic.AddInt32(&l.goroutines, 1)
-			l.entries <- entry
-		}
+		l.send(c.pipeline, entry, atomic.LoadInt32(&l.goroutines) < l.MaxGoroutines)
+	}
+}
 
-		if atomic.LoadInt32(&l.goroutines) < l.MaxGoroutines {
-			go send()
-		} else {
-			send()
-		}
+// send puts an entry into the pipeline, from a new goroutine if async is true.
+// The entry is discarded if the pipeline is closed.
+func (l *coreLogger) send(p *pipeline, entry *Entry, async bool) {
+	epoch, ok := p.add()
+	if !ok {
+		return
+	}
+	send := func() {
+		atomic.AddInt32(&l.goroutines, 1)
+		p.entries.put(entry)
+		p.markSent(epoch)
+	}
+	if !async && atomic.LoadInt32(&l.processing) > 0 && inTarget() {
+		// a target waiting for room in the queue could wait for the dispatcher, which waits for the target
+		async = true
+	}
+	if async {
+		go send()
+	} else {
+		send()
 	}
 }
 
 func (l *Logger) newFatalEntry(level Level, message string) {
-	entry := &Entry{
-		Category: l.Category,
-		Level:    level,
-		Message:  message,
-		Time:     time.Now(),
-	}
-	stackDepth := l.CallStackDepth
+	entry := l.makeEntry(level, message)
+	stack := &l.current().stack
+	stackDepth := stack.depth
 	if stackDepth == 0 {
 		stackDepth = 20
 	}
-	entry.CallStack = GetCallStack(3, stackDepth, l.CallStackFilter)
-	entry.FormattedMessage = l.Formatter(l, entry)
-	if l.SyncMode {
-		l.syncProcess(entry)
+	entry.CallStack = callFrames(1, stackDepth, stack.keepFrame, true, l.callerSkip)
+	if l.GoroutineDump {
+		l.dumpGoroutines(entry)
+	}
+	// a fatal message cannot be dropped by the hooks
+	if e := l.current().applyHooks(entry); e != nil {
+		entry = e
+	}
+	if entry.Category != HeartbeatCategory {
+		l.count(entry)
+	}
+	entry.FormattedMessage = l.format(entry)
+	l.fatal(entry)
+}
+
+// fatal processes a fatal entry: it hands the entry and the messages logged before it to the targets,
+// runs the OnFatal hooks and then calls the ActionFunc or performs the fatal action.
+func (l *coreLogger) fatal(entry *Entry) {
+	entry.critical = true
+	c := l.current()
+	if atomic.LoadInt32(&l.processing) > 0 && inTarget() {
+		// a fatal message of a target can neither be processed nor waited for
+		l.writeReentrant(entry)
+	} else if c.syncMode {
+		c.syncProcess(entry)
 	} else {
-		atomic.AddInt32(&l.goroutines, 1)
-		l.entries <- entry
+		l.send(c.pipeline, entry, false)
+		l.flush()
 	}
 
-	for {
-		goroutines := atomic.LoadInt32(&l.goroutines)
-		//fmt.Println(`waiting ...`, goroutines)
-		if goroutines <= 0 {
-			switch l.fatalAction {
-			case ActionPanic:
-				panic(entry.FormattedMessage)
-			case ActionExit:
-				entry := &Entry{
-					Category: l.Category,
-					Level:    LevelWarn,
-					Message:  message + `[Forced to exit]`,
-					Time:     time.Now(),
-				}
-				entry.FormattedMessage = l.Formatter(l, entry)
-				l.syncProcess(entry)
-				os.Exit(-1)
-			}
-			break
-		}
-		time.Sleep(time.Duration(goroutines) * time.Microsecond)
+	fn := l.fatalFunc
+	if fn == nil && l.fatalAction == ActionExit && !atomic.CompareAndSwapInt32(&l.exiting, 0, 1) {
+		// another goroutine is already exiting the program
+		select {}
 	}
+
+	l.lock.Lock()
+	hooks := l.fatalHooks
+	l.lock.Unlock()
+	for _, hook := range hooks {
+		hook(entry)
+	}
+
+	if fn != nil {
+		fn(entry)
+		return
+	}
+	switch l.fatalAction {
+	case ActionPanic:
+		panic(entry.FormattedMessage)
+	case ActionExit:
+		// closing the targets gives them a chance to write out the messages they buffer
+		l.Close()
+		os.Exit(l.ExitCode)
+	}
+}
+
+// OnFatal registers functions to be called with the entry when a fatal message is logged,
+// after the messages have been handed to the targets and before the fatal action is performed.
+// They may be used to release resources before the program exits.
+func (l *coreLogger) OnFatal(hooks ...func(*Entry)) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.fatalHooks = append(append([]func(*Entry){}, l.fatalHooks...), hooks...)
 }
 
 // Open prepares the logger and the targets for logging purpose.
-// Open must be called before any message can be logged.
+// Open must be called before any message can be logged. Calling it on an open logger applies
+// the changes made to the exported fields since, e.g. opening the appended targets.
 func (l *coreLogger) Open() error {
 	l.lock.Lock()
 	defer l.lock.Unlock()
 
-	if l.open {
-		return nil
-	}
-
 	if l.ErrorWriter == nil {
 		return errors.New("Logger.ErrorWriter must be set.")
 	}
 	if l.BufferSize < 0 {
 		return errors.New("Logger.BufferSize must be no less than 0.")
 	}
+	if l.TargetBuffer < 0 {
+		return errors.New("Logger.TargetBuffer must be no less than 0.")
+	}
 	if l.CallStackDepth < 0 {
 		return errors.New("Logger.CallStackDepth must be no less than 0.")
 	}
 
-	l.entries = make(chan *Entry, l.BufferSize)
-	var targets []Target
-	for _, target := range l.Targets {
-		if err := target.Open(l.ErrorWriter); err != nil {
-			fmt.Fprintf(l.ErrorWriter, "Failed to open target: %v\n", err)
-		} else {
-			targets = append(targets, target)
+	c := l.current()
+	if c.open {
+		l.applyFields()
+		if !sameTargets(l.Targets, c.targets) && !l.blockedByTarget("Logger.Open") {
+			l.replaceTargets(l.current(), l.Targets)
 		}
+		return nil
+	}
+	if c.pipeline != nil {
+		// a shutdown which gave up waiting may still be closing the targets
+		<-c.pipeline.done
 	}
-	l.Targets = targets
 
-	go l.process()
+	c = &loggerConfig{
+		open:     true,
+		maxLevel: l.MaxLevel,
+		syncMode: l.SyncMode,
+		hooks:    l.hooks,
+		levels:   l.catLevels,
+		stack:    l.stackOptions(),
+		elevated: elevatedLevels(l.elevations),
+		pipeline: newPipeline(l.BufferSize, l.ChannelQueue),
+	}
+	c.targets, c.workers = l.openTargets(l.Targets, nil)
+	l.Targets = c.targets
+	go l.process(c.pipeline.entries, c.workers)
 
-	l.open = true
+	l.config.Store(c)
 
 	return nil
 }
 
-// process sends the messages to targets for processing.
-func (l *coreLogger) process() {
-	for {
-		entry := <-l.entries
-		for _, target := range l.Targets {
-			target.Process(entry)
+// applyFields applies the exported fields changed since the logger was opened. It must be called with l.lock held.
+func (l *coreLogger) applyFields() {
+	l.update(func(c *loggerConfig) {
+		c.maxLevel = l.MaxLevel
+		c.syncMode = l.SyncMode
+		c.stack = l.stackOptions()
+	})
+}
+
+// openTargets opens the given targets and starts their workers.
+// The targets which already have a worker among the running ones keep it and are not opened again.
+func (l *coreLogger) openTargets(targets []Target, running []*targetWorker) ([]Target, []*targetWorker) {
+	var (
+		opened  []Target
+		workers []*targetWorker
+	)
+	l.openErrors = nil
+	for _, target := range targets {
+		var worker *targetWorker
+		for _, w := range running {
+			if w.target == target {
+				worker = w
+				break
+			}
 		}
-		atomic.AddInt32(&l.goroutines, -1)
+		if worker == nil {
+			if err := target.Open(l.ErrorWriter); err != nil {
+				fmt.Fprintf(l.ErrorWriter, "Failed to open target: %v\n", err)
+				l.openErrors = append(l.openErrors, openFailure{target, err})
+				continue
+			}
+			worker = &targetWorker{
+				logger: l,
+				target: target,
+			}
+			if _, ok := target.(BatchTarget); ok && l.TargetBatchSize > 1 {
+				worker.batchSize = l.TargetBatchSize
+			}
+			for i := l.workersOf(target); i > 0; i-- {
+				queue := make(chan *Entry, l.TargetBuffer)
+				worker.queues = append(worker.queues, queue)
+				worker.running++
+				go worker.run(queue)
+			}
+		}
+		opened = append(opened, target)
+		workers = append(workers, worker)
+	}
+	return opened, workers
+}
 
+// setTargets replaces the targets of an open logger without stopping it, or sets them and opens the logger.
+func (l *coreLogger) setTargets(targets []Target) {
+	if l.blockedByTarget("Logger.SetTarget") {
+		return
+	}
+	l.lock.Lock()
+	c := l.current()
+	if !c.open {
+		l.Targets = targets
+		l.lock.Unlock()
+		l.Open()
+		return
+	}
+	defer l.lock.Unlock()
+	l.applyFields()
+	l.replaceTargets(l.current(), targets)
+}
+
+// replaceTargets replaces the targets of an open logger. It must be called with l.lock held.
+func (l *coreLogger) replaceTargets(c *loggerConfig, targets []Target) {
+	opened, workers := l.openTargets(targets, c.workers)
+	// the dispatcher switches to the new workers after the messages already queued
+	l.control(c, &control{workers: workers})
+	for _, w := range c.workers {
+		if !w.in(workers) {
+			w.target.Close()
+		}
+	}
+	l.Targets = opened
+	l.update(func(c *loggerConfig) {
+		c.targets = opened
+		c.workers = workers
+	})
+}
+
+// sameTargets returns whether two lists hold the same targets in the same order.
+func sameTargets(a, b []Target) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// control is the payload of the internal entries used to control the pipeline.
+type control struct {
+	done    *sync.WaitGroup
+	workers []*targetWorker // the workers to dispatch the subsequent messages to, if not nil
+}
+
+// control sends a control entry through the pipeline and waits until it has been handled.
+// It must be called with l.lock held.
+func (l *coreLogger) control(c *loggerConfig, ctl *control) {
+	ctl.done = &sync.WaitGroup{}
+	ctl.done.Add(1)
+	atomic.AddInt32(&l.goroutines, 1)
+	// the messages being sent are queued before the control entry
+	c.pipeline.wait()
+	c.pipeline.entries.put(&Entry{control: ctl})
+	ctl.done.Wait()
+}
+
+// targetWorker feeds a single target from its own queue, so that a slow target
+// does not hold up the other targets.
+type targetWorker struct {
+	stats   targetStats // first to be aligned for atomic operations
+	logger  *coreLogger
+	target  Target
+	queues  []chan *Entry // the queues of the goroutines processing the messages, one per goroutine
+	running int32         // the number of goroutines which have not received the closing signal
+	// the maximum number of messages handed at once to the target if it is a BatchTarget, 0 otherwise
+	batchSize int
+	dropped   int // the number of messages dropped since the last report because the queue was full
+}
+
+func (w *targetWorker) run(queue chan *Entry) {
+	var (
+		batch   []*Entry
+		next    *Entry // the closing signal or control entry which ended a batch
+		pending bool   // whether next is to be handled
+	)
+	if w.batchSize > 0 {
+		batch = make([]*Entry, 0, w.batchSize)
+	}
+	for {
+		var entry *Entry
+		if pending {
+			entry, pending = next, false
+		} else {
+			entry = <-queue
+		}
+		if batch != nil && entry != nil && entry.control == nil {
+			// hand the target the messages waiting in the queue along with this one
+			batch, next, pending = w.collect(append(batch[:0], entry), queue)
+			if len(batch) > 1 {
+				w.handleBatch(batch)
+				continue
+			}
+		}
+		if entry != nil && entry.control != nil {
+			entry.control.done.Done()
+			continue
+		}
 		if entry == nil {
+			// the target is closed once all its goroutines are done
+			if atomic.AddInt32(&w.running, -1) == 0 {
+				atomic.StoreInt32(&w.stats.stopped, 1)
+				w.close()
+			}
 			break
 		}
+		w.handle(entry)
+	}
+}
+
+// collect adds the messages waiting in the queue to batch, up to its capacity. It stops at a closing signal
+// or control entry, which it returns with true.
+func (w *targetWorker) collect(batch []*Entry, queue chan *Entry) ([]*Entry, *Entry, bool) {
+	for len(batch) < cap(batch) {
+		select {
+		case entry := <-queue:
+			if entry == nil || entry.control != nil {
+				return batch, entry, true
+			}
+			batch = append(batch, entry)
+		default:
+			return batch, nil, false
+		}
+	}
+	return batch, nil, false
+}
+
+func (w *targetWorker) in(workers []*targetWorker) bool {
+	for _, worker := range workers {
+		if worker == w {
+			return true
+		}
+	}
+	return false
+}
+
+// dispatchBatchSize is the maximum number of entries the dispatcher takes from the queue at once.
+const dispatchBatchSize = 64
+
+// process dispatches the messages to the queues of the target workers. It waits for room in the queue
+// of a target which is full, unless DropOnFull is set: a message which is neither fatal nor logged by SyncLog
+// is then dropped for the target, and the number of dropped messages is reported to ErrorWriter once the target
+// catches up or the logger is closed.
+func (l *coreLogger) process(entries entryQueue, workers []*targetWorker) {
+	batch := make([]*Entry, 0, dispatchBatchSize)
+	for {
+		batch = entries.take(batch)
+		for _, entry := range batch {
+			if workers = l.dispatchEntry(entry, workers); entry == nil {
+				return
+			}
+		}
+	}
+}
+
+// dispatchEntry dispatches a message, or a control entry, to the queues of the target workers.
+// It returns the workers to dispatch the subsequent messages to.
+func (l *coreLogger) dispatchEntry(entry *Entry, workers []*targetWorker) []*targetWorker {
+	switch {
+	case entry != nil && entry.control != nil && entry.control.workers != nil:
+		// switch to a new set of workers, stopping those which are not part of it
+		for _, worker := range workers {
+			if !worker.in(entry.control.workers) {
+				l.reportDropped(worker)
+				worker.send(nil)
+			}
+		}
+		workers = entry.control.workers
+		entry.control.done.Done()
+	case entry == nil || entry.control != nil:
+		for _, worker := range workers {
+			if entry != nil {
+				// a barrier: done when every goroutine of every worker has reached it
+				entry.control.done.Add(len(worker.queues))
+			}
+			l.reportDropped(worker)
+			worker.send(entry)
+		}
+		if entry != nil {
+			entry.control.done.Done()
+		}
+	default:
+		for i, worker := range workers {
+			if !l.DropOnFull || entry.critical {
+				worker.queueOf(entry) <- entry.snapshot(i, len(workers))
+				continue
+			}
+			select {
+			case worker.queueOf(entry) <- entry.snapshot(i, len(workers)):
+				l.reportDropped(worker)
+			default:
+				worker.drop()
+			}
+		}
+	}
+	if entry != nil {
+		// the closing signal is not counted as in flight
+		atomic.AddInt32(&l.goroutines, -1)
+	}
+	return workers
+}
+
+func (l *coreLogger) reportDropped(worker *targetWorker) {
+	if worker.dropped > 0 {
+		fmt.Fprintf(l.ErrorWriter, "%v messages were dropped for target %T because its queue was full\n", worker.dropped, worker.target)
+		worker.dropped = 0
+	}
+}
+
+// levelOf returns the maximum level of messages of a category: the level set for the category
+// or for its nearest parent in the dot-separated hierarchy, or the maximum level of the logger,
+// unless ElevateFor has raised it.
+func (c *loggerConfig) levelOf(category string) Level {
+	level, _ := c.levelSource(category)
+	if elevated, ok := c.elevatedLevel(category); ok && elevated > level {
+		level = elevated
+	}
+	return level
+}
+
+// levelSource returns the maximum level of messages of a category and the category it is set for,
+// which is empty if it is the maximum level of the logger.
+func (c *loggerConfig) levelSource(category string) (Level, string) {
+	if len(c.levels) == 0 {
+		return c.maxLevel, ""
+	}
+	for {
+		if level, ok := c.levels[category]; ok {
+			return level, category
+		}
+		i := strings.LastIndexByte(category, '.')
+		if i < 0 {
+			return c.maxLevel, ""
+		}
+		category = category[:i]
 	}
 }
 
-func (l *coreLogger) syncProcess(entry *Entry) {
+// applyHooks passes an entry through the hooks. It returns nil if a hook dropped the entry.
+func (c *loggerConfig) applyHooks(entry *Entry) *Entry {
+	for _, hook := range c.hooks {
+		if entry = hook(entry); entry == nil {
+			return nil
+		}
+	}
+	return entry
+}
+
+func (c *loggerConfig) syncProcess(entry *Entry) {
 	if entry == nil {
 		return
 	}
-	for _, target := range l.Targets {
-		target.Process(entry)
+	for i, worker := range c.workers {
+		worker.handle(entry.snapshot(i, len(c.workers)))
 	}
 }
 
@@ -483,69 +1651,99 @@ func (l *coreLogger) syncProcess(entry *Entry) {
 // Existing messages will be processed before the targets are closed.
 // New incoming messages will be discarded after calling this method.
 func (l *coreLogger) Close() {
-	if !l.open {
-		return
+	l.Shutdown(context.Background())
+}
+
+// Shutdown closes the logger and the targets like Close, but gives up waiting when ctx is done.
+// It returns an error listing the targets which were not closed in time. The shutdown goes on in
+// the background in that case. Shutdown can be called concurrently and more than once: the calls
+// made while the logger is being shut down wait for the same shutdown.
+func (l *coreLogger) Shutdown(ctx context.Context) error {
+	if l.blockedByTarget("Logger.Shutdown") {
+		return errors.New("Logger shutdown: called by a target while it processes a message")
 	}
-	l.open = false
-	// use a nil entry to signal the close of logger
-	l.entries <- nil
-	for _, target := range l.Targets {
-		target.Close()
+	l.lock.Lock()
+	c := l.current()
+	if !c.open {
+		l.lock.Unlock()
+		if c.pipeline == nil {
+			return nil
+		}
+		select {
+		case <-c.pipeline.done:
+			return nil
+		case <-ctx.Done():
+			return fmt.Errorf("Logger shutdown: %v", ctx.Err())
+		}
 	}
-}
+	l.update(func(c *loggerConfig) {
+		c.open = false
+	})
+	l.lock.Unlock()
 
-// DefaultFormatter is the default formatter used to format every log message.
-func DefaultFormatter(l *Logger, e *Entry) string {
-	return e.Time.Format(time.RFC3339) + "|" + e.Level.String() + "|" + e.Category + "|" + e.Message + e.CallStack
-}
+	closed := make([]chan bool, len(c.workers))
+	for i := range closed {
+		closed[i] = make(chan bool)
+	}
+	go func() {
+		c.pipeline.close()
+		for i, worker := range c.workers {
+			if t, ok := worker.target.(*V2Target); ok {
+				t.closeContext(ctx)
+			} else {
+				worker.target.Close()
+			}
+			close(closed[i])
+		}
+		close(c.pipeline.done)
+	}()
 
-func NormalFormatter(l *Logger, e *Entry) string {
-	return e.Time.Format(`2006-01-02 15:04:05`) + "|" + e.Level.String() + "|" + e.Category + "|" + e.Message + e.CallStack
+	select {
+	case <-c.pipeline.done:
+		return nil
+	case <-ctx.Done():
+	}
+	var pending []string
+	for i, worker := range c.workers {
+		select {
+		case <-closed[i]:
+		default:
+			pending = append(pending, fmt.Sprintf("%T", worker.target))
+		}
+	}
+	if len(pending) == 0 {
+		return fmt.Errorf("Logger shutdown: %v while draining the messages", ctx.Err())
+	}
+	return fmt.Errorf("Logger shutdown: %v before closing %v", ctx.Err(), strings.Join(pending, ", "))
 }
 
-type JSONL struct {
-	Time      string          `bson:"time" json:"time"`
-	Level     string          `bson:"level" json:"level"`
-	Category  string          `bson:"category" json:"category"`
-	Message   json.RawMessage `bson:"message" json:"message"`
-	CallStack string          `bson:"callStack" json:"callStack"`
+// Barrier returns once every message logged before it, by this logger or any logger derived from it,
+// has been processed by every target, e.g. before checking the output of a test or before rotating the
+// files written by the targets. The targets handing the messages to their own goroutines, such as
+// NetworkTarget, may still be sending them. Barrier returns at once if the logger is synchronous or closed.
+func (l *coreLogger) Barrier() {
+	l.flush()
 }
 
-func JSONFormatter(l *Logger, e *Entry) string {
-	jsonl := &JSONL{
-		Time:      e.Time.Format(`2006-01-02 15:04:05`),
-		Level:     e.Level.String(),
-		Category:  e.Category,
-		Message:   []byte(`"` + e.Message + `"`),
-		CallStack: e.CallStack,
-	}
-	if len(e.Message) > 0 {
-		switch e.Message[0] {
-		case '{', '[', '"':
-			jsonl.Message = []byte(e.Message)
-		}
+// flush waits until the target workers have handed all queued messages to their targets,
+// including the messages which were being sent to the pipeline when it was called.
+func (l *coreLogger) flush() {
+	if c := l.current(); !c.open || c.syncMode || l.blockedByTarget("Waiting for the messages to be processed") {
+		return
 	}
-	b, err := json.Marshal(jsonl)
-	if err != nil {
-		fmt.Println(err.Error())
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	c := l.current()
+	if !c.open || c.syncMode {
+		return
 	}
-	return string(b)
+	c.pipeline.wait()
+	l.control(c, &control{})
 }
 
 // GetCallStack returns the current call stack information as a string.
 // The skip parameter specifies how many top frames should be skipped, while
 // the frames parameter specifies at most how many frames should be returned.
 func GetCallStack(skip int, frames int, filter string) string {
-	buf := new(bytes.Buffer)
-	for i, count := skip, 0; count < frames; i++ {
-		_, file, line, ok := runtime.Caller(i)
-		if !ok {
-			break
-		}
-		if filter == "" || strings.Contains(file, filter) {
-			fmt.Fprintf(buf, "\n%s:%d", file, line)
-			count++
-		}
-	}
-	return buf.String()
+	return GetCallFrames(skip+1, frames, filter).String()
 }
@@ -5,9 +5,13 @@
 package log_test
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/admpub/log"
 	"github.com/go-ozzo/ozzo-config"
@@ -173,3 +177,788 @@ func TestLoggerConfig(t *testing.T) {
 		t.Errorf("m2.Option1 = %v, Option2 = %v, expected %v and %v", m2.Option1, m2.Option2, "xyz", true)
 	}
 }
+
+func TestLoggerWithFields(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	target := &MemoryTarget{
+		Filter: &log.Filter{MaxLevel: log.LevelDebug},
+		ready:  make(chan bool, 0),
+	}
+	logger.SetTarget(target)
+
+	l1 := logger.WithFields(log.Fields{"a": 1})
+	l2 := l1.WithFields(log.Fields{"b": "x"})
+	l1.Info("t1")
+	l2.GetLogger("system").Info("t2")
+	logger.Info("t3")
+
+	logger.Close()
+
+	if len(target.entries) != 3 {
+		t.Fatalf("len(target.entries) = %v, expected %v", len(target.entries), 3)
+	}
+	expected := []string{"a=1", "a=1 b=x", ""}
+	for i, e := range target.entries {
+		if e.Fields.String() != expected[i] {
+			t.Errorf("entries[%v].Fields = %q, expected %q", i, e.Fields.String(), expected[i])
+		}
+	}
+	if target.entries[1].Category != "system" {
+		t.Errorf("entries[1].Category = %v, expected %v", target.entries[1].Category, "system")
+	}
+}
+
+func TestFormatterFields(t *testing.T) {
+	logger := log.NewLogger()
+	e := &log.Entry{
+		Level:    log.LevelInfo,
+		Category: "app",
+		Message:  "t1",
+		Time:     time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC),
+		Fields:   log.Fields{"b": 2, "a": "x"},
+	}
+	if s := log.NormalFormatter(logger, e); s != "2016-01-02 03:04:05|Info|app|t1 a=x b=2" {
+		t.Errorf("NormalFormatter() = %q, expected %q", s, "2016-01-02 03:04:05|Info|app|t1 a=x b=2")
+	}
+	if s := log.DefaultFormatter(logger, e); s != "2016-01-02T03:04:05Z|Info|app|t1 a=x b=2" {
+		t.Errorf("DefaultFormatter() = %q, expected %q", s, "2016-01-02T03:04:05Z|Info|app|t1 a=x b=2")
+	}
+	expected := `{"time":"2016-01-02 03:04:05","level":"Info","category":"app","message":"t1","fields":{"a":"x","b":2}}`
+	if s := log.JSONFormatter(logger, e); s != expected {
+		t.Errorf("JSONFormatter() = %q, expected %q", s, expected)
+	}
+
+	e.Fields = nil
+	if s := log.NormalFormatter(logger, e); s != "2016-01-02 03:04:05|Info|app|t1" {
+		t.Errorf("NormalFormatter() = %q, expected %q", s, "2016-01-02 03:04:05|Info|app|t1")
+	}
+}
+
+// GateTarget waits on gate before processing each message and reports processed messages to received.
+type GateTarget struct {
+	*log.Filter
+	gate     chan bool
+	received chan string
+	ready    chan bool
+}
+
+func (t *GateTarget) Open(io.Writer) error {
+	return nil
+}
+
+func (t *GateTarget) Process(e *log.Entry) {
+	if e == nil {
+		t.ready <- true
+		return
+	}
+	if t.gate != nil {
+		<-t.gate
+	}
+	t.received <- e.Message
+}
+
+func (t *GateTarget) Close() {
+	<-t.ready
+}
+
+func TestLoggerTargetWorkers(t *testing.T) {
+	logger := log.NewLogger()
+	slow := &GateTarget{
+		Filter:   &log.Filter{MaxLevel: log.LevelDebug},
+		gate:     make(chan bool),
+		received: make(chan string, 10),
+		ready:    make(chan bool, 0),
+	}
+	fast := &GateTarget{
+		Filter:   &log.Filter{MaxLevel: log.LevelDebug},
+		received: make(chan string, 10),
+		ready:    make(chan bool, 0),
+	}
+	logger.SetTarget(slow, fast)
+
+	logger.Info("t1")
+	logger.Info("t2")
+	logger.Info("t3")
+
+	// the fast target must not wait for the blocked slow target.
+	// The messages are sent asynchronously, so their order is not checked.
+	received := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		select {
+		case msg := <-fast.received:
+			received[msg] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("fast target received %v messages, expected %v", len(received), 3)
+		}
+	}
+	for _, msg := range []string{"t1", "t2", "t3"} {
+		if !received[msg] {
+			t.Errorf("fast target did not receive %v", msg)
+		}
+	}
+	if len(slow.received) != 0 {
+		t.Errorf("len(slow.received) = %v, expected %v", len(slow.received), 0)
+	}
+
+	close(slow.gate)
+	logger.Close()
+
+	if len(slow.received) != 3 {
+		t.Errorf("len(slow.received) = %v, expected %v", len(slow.received), 3)
+	}
+}
+
+// SlowTarget takes a millisecond to process each message.
+type SlowTarget struct {
+	MemoryTarget
+}
+
+func (t *SlowTarget) Process(e *log.Entry) {
+	if e != nil {
+		time.Sleep(time.Millisecond)
+	}
+	t.MemoryTarget.Process(e)
+}
+
+func TestLoggerTargetQueueFull(t *testing.T) {
+	logger := log.NewLogger()
+	logger.TargetBuffer = 16
+	logger.MaxGoroutines = 0
+	target := &SlowTarget{MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}}
+	logger.SetTarget(target)
+
+	// the queue of the target fills up, and the logger waits for room in it
+	for i := 0; i < 200; i++ {
+		logger.Info(i)
+	}
+	logger.Close()
+
+	if len(target.entries) != 200 {
+		t.Errorf("len(entries) = %v, expected no message to be dropped", len(target.entries))
+	}
+	for i, e := range target.entries {
+		if e.Message != fmt.Sprint(i) {
+			t.Fatalf("message = %v, expected %v", e.Message, i)
+		}
+	}
+}
+
+func TestLoggerShutdown(t *testing.T) {
+	logger := log.NewLogger()
+	slow := &GateTarget{
+		Filter:   &log.Filter{MaxLevel: log.LevelDebug},
+		gate:     make(chan bool),
+		received: make(chan string, 10),
+		ready:    make(chan bool, 0),
+	}
+	logger.SetTarget(slow)
+	logger.Info("t1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := logger.Shutdown(ctx); err == nil {
+		t.Errorf("Shutdown() = nil, expected a deadline error")
+	}
+	// a concurrent call waits for the same shutdown
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel2()
+	if err := logger.Shutdown(ctx2); err == nil {
+		t.Errorf("Shutdown() = nil, expected a deadline error")
+	}
+
+	close(slow.gate)
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := logger.Shutdown(context.Background()); err != nil {
+				t.Errorf("Shutdown() = %v, expected nil", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(slow.received) != 1 {
+		t.Errorf("len(slow.received) = %v, expected %v", len(slow.received), 1)
+	}
+	logger.Close()
+}
+
+func TestLoggerReopen(t *testing.T) {
+	logger := log.NewLogger()
+	target := &MemoryTarget{
+		Filter: &log.Filter{MaxLevel: log.LevelDebug},
+		ready:  make(chan bool, 0),
+	}
+	logger.SetTarget(target)
+	for i := 0; i < 3; i++ {
+		logger.Infof("t%v", i)
+		logger.Close()
+		if len(target.entries) != 1 || target.entries[0].Message != fmt.Sprintf("t%v", i) {
+			t.Fatalf("len(target.entries) = %v, expected %v", len(target.entries), 1)
+		}
+		if err := logger.Open(); err != nil {
+			t.Fatalf("Open(): %v", err)
+		}
+	}
+	logger.Close()
+
+	// reopening waits for a shutdown which gave up waiting
+	slow := &GateTarget{
+		Filter:   &log.Filter{MaxLevel: log.LevelDebug},
+		gate:     make(chan bool),
+		received: make(chan string, 10),
+		ready:    make(chan bool, 0),
+	}
+	logger.SetTarget(slow)
+	logger.Info("t1")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	logger.Shutdown(ctx)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		slow.gate <- true
+		close(slow.gate)
+	}()
+	logger.Open()
+	logger.Info("t2")
+	logger.Close()
+	if len(slow.received) != 2 {
+		t.Errorf("len(slow.received) = %v, expected %v", len(slow.received), 2)
+	}
+}
+
+func TestLoggerFatal(t *testing.T) {
+	logger := log.NewLogger()
+	target := &MemoryTarget{
+		Filter: &log.Filter{MaxLevel: log.LevelDebug},
+		ready:  make(chan bool, 0),
+	}
+	logger.SetTarget(target)
+	defer logger.Close()
+
+	var hooked []string
+	logger.OnFatal(func(e *log.Entry) {
+		hooked = append(hooked, e.Message)
+	})
+	for i := 0; i < 100; i++ {
+		logger.Info(i)
+	}
+	logger.Fatal("f1")
+	// the messages logged before the fatal one have been handed to the target
+	if len(target.entries) != 101 {
+		t.Errorf("len(target.entries) = %v, expected %v", len(target.entries), 101)
+	}
+	if len(hooked) != 1 || hooked[0] != "f1" {
+		t.Errorf("hooked = %v, expected %v", hooked, []string{"f1"})
+	}
+
+	logger.SetFatalAction(log.ActionPanic)
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("Fatal() did not panic")
+			}
+		}()
+		logger.Fatal("f2")
+	}()
+	if len(target.entries) != 102 {
+		t.Errorf("len(target.entries) = %v, expected %v", len(target.entries), 102)
+	}
+
+	var called *log.Entry
+	logger.SetFatalAction(log.ActionExit).SetFatalFunc(func(e *log.Entry) {
+		called = e
+	})
+	logger.Fatal("f3")
+	if called == nil || called.Message != "f3" {
+		t.Errorf("the ActionFunc was not called with f3")
+	}
+	if len(hooked) != 3 {
+		t.Errorf("len(hooked) = %v, expected %v", len(hooked), 3)
+	}
+}
+
+func TestLoggerFatalQueueFull(t *testing.T) {
+	logger := log.NewLogger()
+	logger.ErrorWriter = &lockedBuffer{}
+	logger.TargetBuffer = 1
+	logger.MaxGoroutines = 0
+	logger.DropOnFull = true
+	target := &GateTarget{
+		Filter:   &log.Filter{MaxLevel: log.LevelDebug},
+		gate:     make(chan bool),
+		received: make(chan string, 10),
+		ready:    make(chan bool, 0),
+	}
+	logger.SetTarget(target)
+	defer logger.Close()
+
+	// the target is blocked by the first message, and the others fill its queue or are dropped
+	for i := 0; i < 5; i++ {
+		logger.Infof("t%v", i)
+	}
+	called := make(chan *log.Entry, 1)
+	logger.SetFatalFunc(func(e *log.Entry) {
+		called <- e
+	})
+	go logger.Fatal("f1")
+	// the fatal message reaches the full queue before the target is unblocked
+	time.Sleep(50 * time.Millisecond)
+	close(target.gate)
+
+	select {
+	case <-called:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the ActionFunc was not called")
+	}
+	for {
+		select {
+		case message := <-target.received:
+			if message == "f1" {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("the fatal message was dropped for the full target")
+		}
+	}
+}
+
+func TestLoggerHooks(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	target := &MemoryTarget{
+		Filter: &log.Filter{MaxLevel: log.LevelDebug},
+		ready:  make(chan bool, 0),
+	}
+	logger.SetTarget(target)
+	defer logger.Close()
+
+	logger.AddHook(func(e *log.Entry) *log.Entry {
+		if e.Message == "drop" {
+			return nil
+		}
+		return e
+	}, func(e *log.Entry) *log.Entry {
+		fields := log.Fields{"host": "h1"}
+		for k, v := range e.Fields {
+			fields[k] = v
+		}
+		e.Fields = fields
+		e.Message = strings.ToUpper(e.Message)
+		return e
+	})
+	logger.WithFields(log.Fields{"id": 1}).Info("t1")
+	logger.Info("drop")
+	logger.GetLogger("sub").Info("t2")
+
+	if len(target.entries) != 2 {
+		t.Fatalf("len(target.entries) = %v, expected %v", len(target.entries), 2)
+	}
+	if e := target.entries[0]; e.Message != "T1" || e.Fields["host"] != "h1" || e.Fields["id"] != 1 {
+		t.Errorf("entries[0] = %q %v, expected T1 with host and id", e.Message, e.Fields)
+	}
+	if !strings.Contains(target.entries[0].FormattedMessage, "T1") {
+		t.Errorf("FormattedMessage = %q, expected the modified message", target.entries[0].FormattedMessage)
+	}
+	if e := target.entries[1]; e.Message != "T2" || e.Category != "sub" {
+		t.Errorf("entries[1] = %q %v, expected T2 of sub", e.Message, e.Category)
+	}
+}
+
+func TestLoggerRemovableHook(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	target := &MemoryTarget{
+		Filter: &log.Filter{MaxLevel: log.LevelDebug},
+		ready:  make(chan bool, 0),
+	}
+	logger.SetTarget(target)
+
+	var calls []string
+	hook := func(name string) log.Hook {
+		return func(e *log.Entry) *log.Entry {
+			calls = append(calls, name+":"+e.Message)
+			return e
+		}
+	}
+	logger.AddHook(hook("h1"))
+	remove := logger.AddRemovableHook(hook("h2"))
+	logger.AddHook(hook("h3"))
+	logger.Info("t1")
+	remove()
+	remove()
+	logger.Info("t2")
+	logger.Close()
+
+	expected := "h1:t1 h2:t1 h3:t1 h1:t2 h3:t2"
+	if s := strings.Join(calls, " "); s != expected {
+		t.Errorf("hook calls = %v, expected %v", s, expected)
+	}
+	if len(target.entries) != 2 {
+		t.Errorf("len(target.entries) = %v, expected %v", len(target.entries), 2)
+	}
+}
+
+func TestLoggerExtendedLevels(t *testing.T) {
+	log.RegisterLevel(log.LevelTrace+1, "Chatty")
+	defer func() {
+		delete(log.Levels, "Chatty")
+		delete(log.LevelNames, log.LevelTrace+1)
+	}()
+
+	logger := log.NewLogger()
+	logger.Sync()
+	target := &MemoryTarget{
+		Filter: &log.Filter{MaxLevel: log.LevelDebug},
+		ready:  make(chan bool, 0),
+	}
+	logger.SetTarget(target)
+	logger.SetMaxLevel(log.LevelTrace + 1)
+	defer logger.Close()
+
+	logger.Emergency("t1")
+	logger.Alert("t2")
+	logger.Critical("t3")
+	logger.Trace("t4")
+	logger.LogLevel("chatty", "t5")
+	logger.LogLevelf("CRITICAL", "t%v", 6)
+
+	levels := ""
+	for _, e := range target.entries {
+		levels += e.Level.String() + ","
+	}
+	if expected := "Emergency,Alert,Critical,Trace,Chatty,Critical,"; levels != expected {
+		t.Errorf("levels = %v, expected %v", levels, expected)
+	}
+	if level, ok := log.GetLevel("CHATTY"); !ok || level.String() != "Chatty" {
+		t.Errorf("GetLevel(CHATTY) = %v, %v, expected Chatty", level, ok)
+	}
+}
+
+func TestLoggerEnabled(t *testing.T) {
+	logger := log.NewLogger()
+	logger.MaxLevel = log.LevelInfo
+	logger.Open()
+	if !logger.Enabled(log.LevelInfo) || !logger.Enabled(log.LevelError) {
+		t.Errorf("Enabled() should be true for Info and Error")
+	}
+	if logger.Enabled(log.LevelDebug) || logger.IsDebugEnabled() {
+		t.Errorf("Enabled() should be false for Debug")
+	}
+	logger.Close()
+	if logger.Enabled(log.LevelError) {
+		t.Errorf("Enabled() should be false after Close()")
+	}
+}
+
+func TestLoggerDisabledAllocs(t *testing.T) {
+	logger := log.NewLogger()
+	logger.MaxLevel = log.LevelInfo
+	logger.Open()
+	defer logger.Close()
+	allocs := testing.AllocsPerRun(100, func() {
+		logger.Debug("t1")
+		logger.Debugf("t1: %v", 1)
+		logger.Writer(log.LevelDebug).Write([]byte("t1\n"))
+	})
+	if allocs != 0 {
+		t.Errorf("logging at a disabled level made %v allocations, expected 0", allocs)
+	}
+}
+
+func TestLoggerSyncLog(t *testing.T) {
+	logger := log.NewLogger()
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	logger.Info("async")
+	logger.SyncLog(log.LevelWarn, "before", "exec")
+	// the messages logged before are processed too
+	messages := map[string]bool{}
+	for _, e := range target.entries {
+		messages[e.Message] = true
+	}
+	if len(target.entries) != 2 || !messages["async"] || !messages["beforeexec"] {
+		t.Errorf("messages = %v, expected the messages to be processed when SyncLog returns", messages)
+	}
+	logger.SyncLogf(log.LevelError, "exit %v", 1)
+	if len(target.entries) != 3 || target.entries[2].Message != "exit 1" {
+		t.Errorf("len(entries) = %v, expected the message to be processed when SyncLogf returns", len(target.entries))
+	}
+	logger.Close()
+}
+
+func TestLoggerSyncLogQueueFull(t *testing.T) {
+	logger := log.NewLogger()
+	logger.ErrorWriter = &lockedBuffer{}
+	logger.TargetBuffer = 1
+	logger.MaxGoroutines = 0
+	logger.DropOnFull = true
+	target := &GateTarget{
+		Filter:   &log.Filter{MaxLevel: log.LevelDebug},
+		gate:     make(chan bool),
+		received: make(chan string, 10),
+		ready:    make(chan bool, 0),
+	}
+	logger.SetTarget(target)
+	defer logger.Close()
+
+	// the target is blocked by the first message, and the others fill its queue or are dropped
+	for i := 0; i < 5; i++ {
+		logger.Infof("t%v", i)
+	}
+	done := make(chan bool)
+	go func() {
+		logger.SyncLog(log.LevelWarn, "s1")
+		done <- true
+	}()
+	// the message reaches the full queue before the target is unblocked
+	time.Sleep(50 * time.Millisecond)
+	close(target.gate)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("SyncLog() did not return")
+	}
+	for {
+		select {
+		case message := <-target.received:
+			if message == "s1" {
+				return
+			}
+		default:
+			t.Fatalf("the message of SyncLog() was dropped for the full target")
+		}
+	}
+}
+
+func TestLoggerBarrier(t *testing.T) {
+	logger := log.NewLogger()
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(l *log.Logger) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				l.Info(j)
+			}
+		}(logger.GetLogger(fmt.Sprint("app.", i)).With("worker", i))
+	}
+	wg.Wait()
+	logger.Barrier()
+	if len(target.entries) != 400 {
+		t.Errorf("len(entries) = %v, expected 400 once Barrier returns", len(target.entries))
+	}
+	logger.Close()
+	logger.Barrier()
+}
+
+func TestLoggerLazyArgs(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	logger.MaxLevel = log.LevelInfo
+	logger.Open()
+	target := &MemoryTarget{
+		Filter: &log.Filter{MaxLevel: log.LevelDebug},
+		ready:  make(chan bool, 0),
+	}
+	logger.SetTarget(target)
+
+	calls := 0
+	fn := func() string {
+		calls++
+		return "x"
+	}
+	logger.Debug("t1: ", fn)
+	logger.Debugf("t2: %v", fn)
+	logger.DebugFn(fn)
+	if calls != 0 {
+		t.Errorf("calls = %v, expected %v", calls, 0)
+	}
+	logger.Info("t3: ", fn)
+	logger.Infof("t4: %v", fn)
+	logger.InfoFn(fn)
+	logger.Close()
+
+	if calls != 3 {
+		t.Errorf("calls = %v, expected %v", calls, 3)
+	}
+	expected := []string{"t3: x", "t4: x", "x"}
+	if len(target.entries) != len(expected) {
+		t.Fatalf("len(target.entries) = %v, expected %v", len(target.entries), len(expected))
+	}
+	for i, e := range target.entries {
+		if e.Message != expected[i] {
+			t.Errorf("entries[%v].Message = %q, expected %q", i, e.Message, expected[i])
+		}
+	}
+}
+
+func TestLoggerReconfigure(t *testing.T) {
+	logger := log.NewLogger()
+	m1 := &MemoryTarget{
+		Filter: &log.Filter{MaxLevel: log.LevelDebug},
+		ready:  make(chan bool, 0),
+	}
+	m2 := &MemoryTarget{
+		Filter: &log.Filter{MaxLevel: log.LevelDebug},
+		ready:  make(chan bool, 0),
+	}
+	logger.SetTarget(m1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				logger.Infof("t%v-%v", i, j)
+			}
+		}(i)
+	}
+	logger.SetLevel("Warn")
+	logger.SetMaxLevel(log.LevelDebug)
+	logger.SetFormatter(func(l *log.Logger, e *log.Entry) string {
+		return e.Message
+	})
+	logger.AddTarget(m2)
+	logger.SetTarget(m2)
+	wg.Wait()
+
+	logger.Info("last")
+	logger.Close()
+
+	if !m1.open || !m2.open {
+		t.Fatalf("both targets should have been opened")
+	}
+	for _, e := range m1.entries {
+		if e.Message == "last" {
+			t.Errorf("the removed target received a message logged after SetTarget()")
+		}
+	}
+	found := false
+	for _, e := range m2.entries {
+		if e.String() == "last" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("the new target did not receive the last message")
+	}
+}
+
+func TestLoggerOpenApplyFields(t *testing.T) {
+	logger := log.NewLogger()
+	m1 := &MemoryTarget{
+		Filter: &log.Filter{MaxLevel: log.LevelDebug},
+		ready:  make(chan bool, 0),
+	}
+	logger.Targets = []log.Target{m1}
+	logger.MaxLevel = log.LevelWarn
+	logger.Open()
+	logger.Info("t1")
+	logger.Warn("t2")
+
+	m2 := &MemoryTarget{
+		Filter: &log.Filter{MaxLevel: log.LevelDebug},
+		ready:  make(chan bool, 0),
+	}
+	logger.Targets = append(logger.Targets, m2)
+	logger.MaxLevel = log.LevelInfo
+	logger.Open()
+	logger.Info("t3")
+	logger.Close()
+
+	if !m2.open {
+		t.Fatalf("the appended target was not opened by Open()")
+	}
+	if len(m1.entries) != 2 || m1.entries[0].Message != "t2" || m1.entries[1].Message != "t3" {
+		t.Errorf("the first target received %v messages, expected t2 and t3", len(m1.entries))
+	}
+	if len(m2.entries) != 1 || m2.entries[0].Message != "t3" {
+		t.Errorf("the appended target received %v messages, expected t3", len(m2.entries))
+	}
+}
+
+// mutatingTarget modifies the entries it processes.
+type mutatingTarget struct {
+	*MemoryTarget
+}
+
+func (m *mutatingTarget) Process(e *log.Entry) {
+	if e != nil {
+		e.Message = "changed"
+		e.Fields["user"] = "changed"
+	}
+	m.MemoryTarget.Process(e)
+}
+
+func TestLoggerEntryCopies(t *testing.T) {
+	for _, sync := range []bool{true, false} {
+		logger := log.NewLogger()
+		if sync {
+			logger.Sync()
+		} else {
+			logger.MaxGoroutines = 0
+		}
+		m1 := &mutatingTarget{&MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}}
+		m2 := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+		m3 := &mutatingTarget{&MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}}
+		logger.SetTarget(m1, m2, m3)
+		l := logger.WithFields(log.Fields{"user": "alice"})
+		l.Info("t1")
+		l.Info("t2")
+		logger.Close()
+
+		if len(m2.entries) != 2 || m2.entries[0].Message != "t1" || m2.entries[1].Fields["user"] != "alice" {
+			t.Errorf("sync = %v: the entries of the second target were modified by the other targets", sync)
+		}
+		if len(m1.entries) != 2 || m1.entries[1].Fields["user"] != "changed" {
+			t.Errorf("sync = %v: the first target could not modify its entries", sync)
+		}
+	}
+}
+
+func TestEntryClone(t *testing.T) {
+	e := &log.Entry{Message: "t1", Fields: log.Fields{"user": "alice"}}
+	c := e.Clone()
+	c.Message = "t2"
+	c.Fields["user"] = "bob"
+	if e.Message != "t1" || e.Fields["user"] != "alice" {
+		t.Errorf("Clone() = %+v, modifying it changed the entry to %+v", c, e)
+	}
+}
+
+func TestLoggerWriter(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	writers := logger.Writers()
+	w := writers[log.LevelWarn]
+	io.WriteString(w, "t1\n")
+	io.WriteString(w, "t2")
+	io.WriteString(w, " continued\nt3")
+	io.WriteString(w, "\nt4\nt5\n")
+	io.WriteString(w, "t6")
+	w.Close()
+	io.WriteString(writers[log.LevelError], "t7\n")
+	logger.Close()
+
+	expected := []string{"t1", "t2 continued", "t3", "t4\nt5", "t6", "t7"}
+	if len(target.entries) != len(expected) {
+		t.Fatalf("len(entries) = %v, expected %v", len(target.entries), len(expected))
+	}
+	for i, message := range expected {
+		if e := target.entries[i]; e.Message != message {
+			t.Errorf("entries[%v].Message = %q, expected %q", i, e.Message, message)
+		}
+	}
+	if target.entries[0].Level != log.LevelWarn || target.entries[5].Level != log.LevelError {
+		t.Errorf("the messages were not logged at the levels of the writers")
+	}
+}
@@ -0,0 +1,534 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package logread parses the log lines written by the built-in formatters back into entries,
+// so that tools such as filters, converters and replayers can be built on top of the logs:
+//
+//	r := logread.NewReader(file)
+//	for {
+//		e, err := r.Read()
+//		if err == io.EOF {
+//			break
+//		}
+//		...
+//	}
+//
+// It reads the lines written by log.DefaultFormatter and log.NormalFormatter, including the call stacks
+// and the hex dumps following them, by log.JSONFormatter, and logfmt lines such as those of other
+// libraries, e.g. `time=2015-10-22T08:39:28Z level=info msg="signed in" user=alice`. The format is
+// detected line by line, so that a stream mixing them can be read.
+package logread
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+// ErrUnknownFormat is returned by ParseLine for a line which was not written by a supported formatter.
+var ErrUnknownFormat = errors.New("logread: unknown log line format")
+
+// DefaultTimeLayouts are the layouts the times of the messages are parsed with.
+var DefaultTimeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05.000Z07:00"}
+
+// maxLineSize bounds the size of a line, so that a corrupted file does not exhaust the memory.
+const maxLineSize = 16 << 20
+
+// Error is the error of a parsed entry, which holds the message, the type and the call stack of the logged error.
+type Error struct {
+	Message string
+	Type    string
+	Stack   string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ParseError describes a line which could not be parsed.
+type ParseError struct {
+	Line int // the number of the line, from 1
+	Text string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("logread: line %d: %v", e.Line, e.Err)
+}
+
+// Reader reads the entries of a log.
+type Reader struct {
+	// the layouts the times are parsed with, DefaultTimeLayouts if empty. Add the layout set with
+	// Logger.SetTimeFormat if any.
+	TimeLayouts []string
+	// the location of the times written without a time zone, e.g. by NormalFormatter. It defaults to time.Local.
+	Location *time.Location
+
+	r       *bufio.Reader
+	line    int
+	pending *string // the line read ahead, which starts the next entry
+}
+
+// NewReader returns a Reader reading the entries from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// ReadAll reads all the entries from r. The lines which cannot be parsed are skipped,
+// and the first ParseError is returned with the entries read.
+func ReadAll(r io.Reader) ([]*log.Entry, error) {
+	var entries []*log.Entry
+	err := each(r, func(e *log.Entry) {
+		entries = append(entries, e)
+	})
+	return entries, err
+}
+
+// each calls fn with the entries read from r. The lines which cannot be parsed are skipped,
+// and the first ParseError is returned once all the entries are read.
+func each(r io.Reader, fn func(e *log.Entry)) error {
+	reader := NewReader(r)
+	var parseErr error
+	for {
+		e, err := reader.Read()
+		if err == io.EOF {
+			return parseErr
+		}
+		if _, ok := err.(*ParseError); ok {
+			if parseErr == nil {
+				parseErr = err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		fn(e)
+	}
+}
+
+// ParseLine parses a line written by a supported formatter, without the lines which may follow it.
+func ParseLine(line string) (*log.Entry, error) {
+	e, _ := (&Reader{}).parse(line)
+	if e == nil {
+		return nil, ErrUnknownFormat
+	}
+	return e, nil
+}
+
+// Read returns the next entry, or io.EOF when there is none left. A line which does not start an entry
+// nor follows one is reported as a ParseError, and the next call goes on with the following line.
+// The FormattedMessage of the entries is the text they were parsed from.
+func (r *Reader) Read() (*log.Entry, error) {
+	line, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+	e, text := r.parse(line)
+	if e == nil {
+		return nil, &ParseError{Line: r.line, Text: line, Err: ErrUnknownFormat}
+	}
+	if !text {
+		return e, nil
+	}
+	// a text entry spans the following lines up to the next entry
+	var continuation []string
+	for {
+		next, err := r.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := r.parse(next); n != nil {
+			r.pending = &next
+			break
+		}
+		continuation = append(continuation, next)
+	}
+	if len(continuation) > 0 {
+		r.continueText(e, continuation)
+	}
+	return e, nil
+}
+
+// next returns the next line, without the line break.
+func (r *Reader) next() (string, error) {
+	if r.pending != nil {
+		line := *r.pending
+		r.pending = nil
+		return line, nil
+	}
+	var buf []byte
+	for {
+		chunk, isPrefix, err := r.r.ReadLine()
+		if err != nil {
+			if err == io.EOF && len(buf) > 0 {
+				break
+			}
+			return "", err
+		}
+		buf = append(buf, chunk...)
+		if !isPrefix {
+			break
+		}
+		if len(buf) > maxLineSize {
+			return "", fmt.Errorf("logread: line %d is longer than %d bytes", r.line+1, maxLineSize)
+		}
+	}
+	r.line++
+	return string(buf), nil
+}
+
+// parse parses a line starting an entry, or returns nil. It also returns whether the entry was written
+// by a text formatter, and may then be followed by more lines.
+func (r *Reader) parse(line string) (*log.Entry, bool) {
+	if strings.HasPrefix(line, "{") {
+		return r.parseJSON(line), false
+	}
+	if e := r.parseText(line); e != nil {
+		return e, true
+	}
+	return r.parseLogfmt(line), false
+}
+
+func (r *Reader) parseTime(s string) (time.Time, bool) {
+	layouts := r.TimeLayouts
+	if len(layouts) == 0 {
+		layouts = DefaultTimeLayouts
+	}
+	location := r.Location
+	if location == nil {
+		location = time.Local
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, location); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func parseLevel(s string) (log.Level, bool) {
+	var level log.Level
+	if err := level.Set(s); err != nil {
+		return 0, false
+	}
+	return level, true
+}
+
+// parseJSON parses a line written by JSONFormatter.
+func (r *Reader) parseJSON(line string) *log.Entry {
+	var doc log.JSONL
+	if err := json.Unmarshal([]byte(line), &doc); err != nil || doc.Time == "" || doc.Level == "" {
+		return nil
+	}
+	t, ok := r.parseTime(doc.Time)
+	if !ok {
+		return nil
+	}
+	level, ok := parseLevel(doc.Level)
+	if !ok {
+		return nil
+	}
+	e := &log.Entry{
+		Level:            level,
+		Category:         doc.Category,
+		Time:             t,
+		Fields:           doc.Fields,
+		TraceID:          doc.TraceID,
+		SpanID:           doc.SpanID,
+		CallStack:        doc.CallStack,
+		Raw:              doc.Raw,
+		FormattedMessage: line,
+	}
+	// a message which is a JSON string is embedded as the string itself
+	if err := json.Unmarshal(doc.Message, &e.Message); err != nil {
+		e.Message = string(doc.Message)
+	}
+	if doc.Error != nil {
+		e.Error = &Error{Message: doc.Error.Message, Type: doc.Error.Type, Stack: doc.Error.Stack}
+	}
+	return e
+}
+
+// parseText parses the first line of a message written by DefaultFormatter or NormalFormatter:
+// the time, the level, the category and the message separated by vertical bars, followed by the fields.
+func (r *Reader) parseText(line string) *log.Entry {
+	parts := strings.SplitN(line, "|", 4)
+	if len(parts) < 4 {
+		return nil
+	}
+	t, ok := r.parseTime(parts[0])
+	if !ok {
+		return nil
+	}
+	level, ok := parseLevel(parts[1])
+	if !ok {
+		return nil
+	}
+	e := &log.Entry{Level: level, Category: parts[2], Time: t, FormattedMessage: line}
+	e.Message = parseTextFields(e, parts[3])
+	return e
+}
+
+// continueText adds the lines following the first line of a text message: the rest of the message,
+// whose last line holds the fields, then the hex dump of the binary data and the call stack.
+func (r *Reader) continueText(e *log.Entry, lines []string) {
+	first := e.FormattedMessage
+	e.FormattedMessage += "\n" + strings.Join(lines, "\n")
+	i := 0
+	for i < len(lines) && !isHexDump(lines[i]) && !isFrame(lines[i]) {
+		i++
+	}
+	if i > 0 {
+		// the message spans several lines, and the fields follow the last one
+		text := strings.SplitN(first, "|", 4)[3] + "\n" + strings.Join(lines[:i], "\n")
+		e.Fields, e.TraceID, e.SpanID, e.Error = nil, "", "", nil
+		e.Message = parseTextFields(e, text)
+	}
+	var raw []byte
+	for ; i < len(lines) && isHexDump(lines[i]); i++ {
+		for _, field := range strings.Fields(lines[i][10:60]) {
+			if b, err := hex.DecodeString(field); err == nil {
+				raw = append(raw, b...)
+			}
+		}
+	}
+	e.Raw = raw
+	for ; i < len(lines) && isFrame(lines[i]); i++ {
+		e.CallStack = append(e.CallStack, log.ParseCallStack(lines[i])...)
+	}
+	if i < len(lines) {
+		if err, ok := e.Error.(*Error); ok {
+			err.Stack = strings.Join(lines[i:], "\n")
+		}
+	}
+}
+
+// isHexDump returns whether a line is a line of a dump written by hex.Dump.
+func isHexDump(line string) bool {
+	if len(line) < 62 || line[8:10] != "  " || line[60] != '|' || line[len(line)-1] != '|' {
+		return false
+	}
+	_, err := hex.DecodeString(line[:8])
+	return err == nil
+}
+
+// isFrame returns whether a line is a frame of a call stack, written as file:line.
+func isFrame(line string) bool {
+	i := strings.LastIndexByte(line, ':')
+	if i <= 0 || strings.ContainsAny(line[:i], " \t") {
+		return false
+	}
+	_, err := strconv.Atoi(line[i+1:])
+	return err == nil
+}
+
+// parseTextFields sets the trace, the error and the fields written at the end of a text message, and
+// returns the message without them. The fields are recognized as key=value pairs sorted by key following
+// the message, so that a message ending with such pairs may be taken for fields. The values of the fields are strings.
+func parseTextFields(e *log.Entry, text string) string {
+	start := strings.LastIndexByte(text, '\n') + 1
+	for i := start; i < len(text); i++ {
+		if text[i] != ' ' {
+			continue
+		}
+		if parsed, ok := parseTextPairs(text[i+1:]); ok {
+			e.TraceID, e.SpanID, e.Error = parsed.traceID, parsed.spanID, parsed.err
+			if len(parsed.fields) > 0 {
+				e.Fields = parsed.fields
+			}
+			return text[:i]
+		}
+	}
+	return text
+}
+
+type textPairs struct {
+	traceID, spanID string
+	err             error
+	fields          log.Fields
+}
+
+// parseTextPairs parses the trace, the error and the fields written by the text formatters.
+func parseTextPairs(s string) (*textPairs, bool) {
+	p := &textPairs{}
+	key, rest, ok := cutKey(s)
+	if !ok {
+		return nil, false
+	}
+	if key == "trace_id" {
+		if p.traceID, s = cutPlainValue(rest); p.traceID == "" {
+			return nil, false
+		}
+		if key, rest, ok = cutKey(s); ok && key == "span_id" {
+			p.spanID, s = cutPlainValue(rest)
+		}
+		if s == "" {
+			return p, true
+		}
+		if key, rest, ok = cutKey(s); !ok {
+			return nil, false
+		}
+	}
+	if key == "error" {
+		quoted, err := strconv.QuotedPrefix(rest)
+		if err != nil {
+			return nil, false
+		}
+		message, _ := strconv.Unquote(quoted)
+		s = strings.TrimPrefix(rest[len(quoted):], " ")
+		e := &Error{Message: message}
+		if key, rest, ok = cutKey(s); ok && key == "error_type" {
+			e.Type, s = cutPlainValue(rest)
+		}
+		p.err = e
+		if s == "" {
+			return p, true
+		}
+		if key, rest, ok = cutKey(s); !ok {
+			return nil, false
+		}
+	}
+	p.fields = log.Fields{}
+	previous := ""
+	for {
+		if previous != "" && key <= previous {
+			return nil, false
+		}
+		previous = key
+		// the value runs up to the next key sorted after this one
+		end := len(rest)
+		for i := 0; i < len(rest); i++ {
+			if rest[i] != ' ' {
+				continue
+			}
+			if next, _, ok := cutKey(rest[i+1:]); ok && next > key {
+				end = i
+				break
+			}
+		}
+		p.fields[key] = rest[:end]
+		if end == len(rest) {
+			return p, true
+		}
+		key, rest, _ = cutKey(rest[end+1:])
+	}
+}
+
+// cutKey splits s into a key made of letters, digits, '_', '.', '-' and '!' and the text following the '='.
+func cutKey(s string) (key, rest string, ok bool) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '.', c == '-', c == '!':
+			continue
+		case c == '=' && i > 0:
+			return s[:i], s[i+1:], true
+		}
+		return "", "", false
+	}
+	return "", "", false
+}
+
+// cutPlainValue splits s into a value without spaces and the text following the space after it.
+func cutPlainValue(s string) (value, rest string) {
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+// parseLogfmt parses a logfmt line holding at least a level and a message.
+func (r *Reader) parseLogfmt(line string) *log.Entry {
+	pairs, ok := splitLogfmt(line)
+	if !ok {
+		return nil
+	}
+	e := &log.Entry{FormattedMessage: line}
+	hasLevel, hasMessage := false, false
+	for _, pair := range pairs {
+		switch pair[0] {
+		case "time", "ts", "@timestamp":
+			if t, ok := r.parseTime(pair[1]); ok {
+				e.Time = t
+				continue
+			}
+		case "level", "lvl", "severity":
+			if level, ok := parseLevel(pair[1]); ok {
+				e.Level, hasLevel = level, true
+				continue
+			}
+		case "msg", "message":
+			e.Message, hasMessage = pair[1], true
+			continue
+		case "category", "logger":
+			e.Category = pair[1]
+			continue
+		case "trace_id":
+			e.TraceID = pair[1]
+			continue
+		case "span_id":
+			e.SpanID = pair[1]
+			continue
+		case "error", "err":
+			e.Error = &Error{Message: pair[1]}
+			continue
+		case "error_type":
+			if err, ok := e.Error.(*Error); ok {
+				err.Type = pair[1]
+				continue
+			}
+		}
+		if e.Fields == nil {
+			e.Fields = log.Fields{}
+		}
+		e.Fields[pair[0]] = pair[1]
+	}
+	if !hasLevel || !hasMessage {
+		return nil
+	}
+	return e
+}
+
+// splitLogfmt splits a logfmt line into key/value pairs. The quoted values are unquoted.
+func splitLogfmt(line string) ([][2]string, bool) {
+	var pairs [][2]string
+	s := strings.TrimSpace(line)
+	for s != "" {
+		key, rest, ok := cutKey(s)
+		if !ok {
+			return nil, false
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return nil, false
+			}
+			value, _ = strconv.Unquote(quoted)
+			rest = rest[len(quoted):]
+			if rest != "" && rest[0] != ' ' {
+				return nil, false
+			}
+		} else if i := strings.IndexByte(rest, ' '); i >= 0 {
+			value, rest = rest[:i], rest[i:]
+		} else {
+			value, rest = rest, ""
+		}
+		pairs = append(pairs, [2]string{key, value})
+		s = strings.TrimLeft(rest, " ")
+	}
+	return pairs, len(pairs) > 0
+}
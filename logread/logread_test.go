@@ -0,0 +1,149 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logread_test
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+	"github.com/admpub/log/logread"
+)
+
+// memoryTarget keeps the messages formatted by the logger.
+type memoryTarget struct {
+	*log.Filter
+	lines []string
+	ready chan bool
+}
+
+func (m *memoryTarget) Open(io.Writer) error {
+	return nil
+}
+
+func (m *memoryTarget) Process(e *log.Entry) {
+	if e == nil {
+		m.ready <- true
+	} else {
+		m.lines = append(m.lines, e.String())
+	}
+}
+
+func (m *memoryTarget) Close() {
+	<-m.ready
+}
+
+// logLines returns the lines written by a logger using the formatter.
+func logLines(formatter log.Formatter, fn func(l *log.Logger)) string {
+	logger := log.NewLogger()
+	logger.Sync()
+	logger.SetClock(log.NewManualClock(time.Date(2015, 10, 22, 8, 39, 28, 0, time.UTC), time.Second))
+	logger.SetFormatter(formatter)
+	target := &memoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	fn(logger)
+	logger.Close()
+	return strings.Join(target.lines, "\n") + "\n"
+}
+
+func TestReadText(t *testing.T) {
+	for _, formatter := range []log.Formatter{log.DefaultFormatter, log.NormalFormatter} {
+		text := logLines(formatter, func(l *log.Logger) {
+			l.GetLogger("app.db").Info("connected")
+			l.With("user", "alice smith", "attempts", 2).WithError(errors.New("bad \"password\"")).Warn("login failed")
+			l.WithTrace("4bf92f35", "00f067aa").Info("slow query\nSELECT 1")
+			l.DebugDump("packet", []byte{1, 2, 3})
+			l.CallStackDepth = 2
+			l.Open()
+			l.Error("failed")
+		})
+		r := logread.NewReader(strings.NewReader(text))
+		r.Location = time.UTC
+		var entries []*log.Entry
+		for {
+			e, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Read() = %v, expected no error", err)
+			}
+			entries = append(entries, e)
+		}
+		if len(entries) != 5 {
+			t.Fatalf("len(entries) = %v, expected %v in %q", len(entries), 5, text)
+		}
+
+		e := entries[0]
+		if e.Level != log.LevelInfo || e.Category != "app.db" || e.Message != "connected" || !e.Time.Equal(time.Date(2015, 10, 22, 8, 39, 28, 0, time.UTC)) {
+			t.Errorf("entries[0] = %v %v %q %v, expected Info app.db connected", e.Level, e.Category, e.Message, e.Time)
+		}
+		e = entries[1]
+		if e.Message != "login failed" || !reflect.DeepEqual(e.Fields, log.Fields{"user": "alice smith", "attempts": "2"}) {
+			t.Errorf("entries[1] = %q %v, expected the fields", e.Message, e.Fields)
+		}
+		if err, ok := e.Error.(*logread.Error); !ok || err.Message != `bad "password"` || err.Type != "*errors.errorString" {
+			t.Errorf("entries[1].Error = %#v, expected the error", e.Error)
+		}
+		e = entries[2]
+		if e.Message != "slow query\nSELECT 1" || e.TraceID != "4bf92f35" || e.SpanID != "00f067aa" {
+			t.Errorf("entries[2] = %q %q %q, expected the message on two lines and the trace", e.Message, e.TraceID, e.SpanID)
+		}
+		e = entries[3]
+		if e.Level != log.LevelDebug || e.Message != "packet (3 bytes)" || string(e.Raw) != "\x01\x02\x03" {
+			t.Errorf("entries[3] = %v %q %q, expected the dumped data", e.Level, e.Message, e.Raw)
+		}
+		e = entries[4]
+		if e.Message != "failed" || len(e.CallStack) != 2 || !strings.HasSuffix(e.CallStack[0].File, "logread_test.go") {
+			t.Errorf("entries[4] = %q %v, expected the call stack", e.Message, e.CallStack)
+		}
+		if !strings.Contains(e.String(), "\n") || !strings.HasSuffix(text, e.String()+"\n") {
+			t.Errorf("entries[4].String() = %q, expected the lines it was parsed from", e.String())
+		}
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	text := logLines(log.JSONFormatter, func(l *log.Logger) {
+		l.With("n", 2).WithError(errors.New("timeout")).Error("failed")
+		l.Info(`{"event":"started"}`)
+	})
+	entries, err := logread.ReadAll(strings.NewReader(text))
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("ReadAll() = %v, %v, expected 2 entries", len(entries), err)
+	}
+	e := entries[0]
+	if e.Level != log.LevelError || e.Message != "failed" || e.Fields["n"] != float64(2) || e.Error == nil || e.Error.Error() != "timeout" {
+		t.Errorf("entries[0] = %v %q %v %v, expected the fields and the error", e.Level, e.Message, e.Fields, e.Error)
+	}
+	if e := entries[1]; e.Message != `{"event":"started"}` {
+		t.Errorf("entries[1].Message = %q, expected the embedded JSON", e.Message)
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	e, err := logread.ParseLine(`time=2015-10-22T08:39:28Z level=warn msg="disk almost full" logger=app.fs used=95%`)
+	if err != nil {
+		t.Fatalf("ParseLine() = %v, expected no error", err)
+	}
+	if e.Level != log.LevelWarn || e.Message != "disk almost full" || e.Category != "app.fs" || e.Fields["used"] != "95%" {
+		t.Errorf("ParseLine() = %v %q %q %v, expected the logfmt pairs", e.Level, e.Message, e.Category, e.Fields)
+	}
+	if _, err := logread.ParseLine("not a log line"); err != logread.ErrUnknownFormat {
+		t.Errorf("ParseLine() = %v, expected %v", err, logread.ErrUnknownFormat)
+	}
+
+	entries, err := logread.ReadAll(strings.NewReader("garbage\n2015-10-22 08:39:28|Info|app|ok\n"))
+	if len(entries) != 1 || entries[0].Message != "ok" {
+		t.Errorf("ReadAll() = %v entries, expected the entry following the garbage", len(entries))
+	}
+	if err, ok := err.(*logread.ParseError); !ok || err.Line != 1 {
+		t.Errorf("ReadAll() = %v, expected a ParseError on line 1", err)
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logread
+
+import (
+	"io"
+
+	"github.com/admpub/log"
+)
+
+// Replay reads the entries from r and hands them to the targets with their original time, e.g. to backfill
+// a store from log files. The targets are opened by a logger in the synchronous mode and closed once all the
+// entries are handed over. The messages are formatted by log.NormalFormatter for the targets which do not
+// format them themselves: use ReplayTo with a logger using another formatter to convert the logs.
+// It returns the number of entries replayed, and the first ParseError if some lines could not be parsed.
+func Replay(r io.Reader, targets ...log.Target) (int, error) {
+	logger := log.NewLogger()
+	logger.Sync()
+	logger.SetTarget(targets...)
+	defer logger.Close()
+	return ReplayTo(r, logger)
+}
+
+// ReplayTo reads the entries from r and logs them again with the logger, keeping their time and category,
+// as Logger.Replay does. It returns the number of entries read, and the first ParseError if some lines could
+// not be parsed.
+func ReplayTo(r io.Reader, logger *log.Logger) (int, error) {
+	n := 0
+	err := each(r, func(e *log.Entry) {
+		logger.Replay(e)
+		n++
+	})
+	return n, err
+}
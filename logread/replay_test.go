@@ -0,0 +1,50 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logread_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+	"github.com/admpub/log/logread"
+)
+
+func TestReplay(t *testing.T) {
+	text := "garbage\n" +
+		"2015-10-22 08:39:28|Info|app.db|connected user=alice\n" +
+		"2015-10-22 08:39:30|Fatal|app|crashed\n"
+	target := &memoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	n, err := logread.Replay(strings.NewReader(text), target)
+	if n != 2 {
+		t.Errorf("Replay() = %v, expected %v entries", n, 2)
+	}
+	if err, ok := err.(*logread.ParseError); !ok || err.Line != 1 {
+		t.Errorf("Replay() = %v, expected a ParseError on line 1", err)
+	}
+	if len(target.lines) != 2 || target.lines[0] != "2015-10-22 08:39:28|Info|app.db|connected user=alice" ||
+		target.lines[1] != "2015-10-22 08:39:30|Fatal|app|crashed" {
+		t.Errorf("target.lines = %q, expected the entries with their original time", target.lines)
+	}
+}
+
+func TestReplayTo(t *testing.T) {
+	// convert the text lines to JSON
+	logger := log.NewLogger()
+	logger.Sync()
+	logger.SetFormatter(log.JSONFormatter)
+	logger.SetTimeLocation(time.UTC)
+	target := &memoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	r := strings.NewReader("2015-10-22T08:39:28Z|Warn|app|disk almost full used=95%\n")
+	n, err := logread.ReplayTo(r, logger)
+	logger.Close()
+
+	expected := `{"time":"2015-10-22 08:39:28","level":"Warn","category":"app","message":"disk almost full","fields":{"used":"95%"}}`
+	if n != 1 || err != nil || len(target.lines) != 1 || target.lines[0] != expected {
+		t.Errorf("ReplayTo() = %v, %v, %q, expected %v", n, err, target.lines, expected)
+	}
+}
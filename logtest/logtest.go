@@ -0,0 +1,168 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package logtest captures the messages of a logger during a test and provides assertions on them:
+//
+//	func TestConnect(t *testing.T) {
+//		logtest.Hook(t, logger)
+//		connect("localhost:1")
+//		logtest.RequireLogged(t, log.LevelError, "connection refused")
+//	}
+//
+// The test fails when it ends if a message of level Error or more severe was logged without being
+// asserted by RequireLogged, unless Recorder.AllowErrors was called.
+package logtest
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+// Recorder captures the messages logged during a test.
+type Recorder struct {
+	t           testing.TB
+	lock        sync.Mutex
+	active      bool
+	allowErrors bool
+	entries     []*log.Entry
+	asserted    map[*log.Entry]bool
+}
+
+var (
+	recorders     = map[testing.TB]*Recorder{}
+	recordersLock sync.Mutex
+)
+
+// Hook starts capturing the messages of the given logger, and of the loggers sharing its targets,
+// until the test ends. The messages are captured after the hooks added before, and whatever the targets.
+func Hook(t testing.TB, l *log.Logger) *Recorder {
+	r := &Recorder{t: t, active: true, asserted: map[*log.Entry]bool{}}
+	remove := l.AddRemovableHook(r.hook)
+	recordersLock.Lock()
+	recorders[t] = r
+	recordersLock.Unlock()
+	t.Cleanup(func() {
+		remove()
+		r.stop()
+	})
+	return r
+}
+
+func (r *Recorder) hook(e *log.Entry) *log.Entry {
+	r.lock.Lock()
+	if r.active {
+		r.entries = append(r.entries, e)
+	}
+	r.lock.Unlock()
+	return e
+}
+
+// stop stops capturing and fails the test for the unexpected error messages.
+func (r *Recorder) stop() {
+	recordersLock.Lock()
+	delete(recorders, r.t)
+	recordersLock.Unlock()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	// the entries which still reach the hook after it was removed are ignored
+	r.active = false
+	if r.allowErrors {
+		return
+	}
+	for _, e := range r.entries {
+		if e.Level <= log.LevelError && !r.asserted[e] {
+			r.t.Errorf("logtest: unexpected %v message: %v", e.Level, e.Message)
+		}
+	}
+}
+
+// AllowErrors stops the test from failing for the error messages not asserted by RequireLogged.
+func (r *Recorder) AllowErrors() *Recorder {
+	r.lock.Lock()
+	r.allowErrors = true
+	r.lock.Unlock()
+	return r
+}
+
+// Entries returns the messages captured so far.
+func (r *Recorder) Entries() []*log.Entry {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]*log.Entry{}, r.entries...)
+}
+
+// Reset discards the messages captured so far.
+func (r *Recorder) Reset() {
+	r.lock.Lock()
+	r.entries = nil
+	r.asserted = map[*log.Entry]bool{}
+	r.lock.Unlock()
+}
+
+// Find returns the captured messages of the given level whose text contains the given string.
+func (r *Recorder) Find(level log.Level, message string) []*log.Entry {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.find(level, message)
+}
+
+func (r *Recorder) find(level log.Level, message string) []*log.Entry {
+	var found []*log.Entry
+	for _, e := range r.entries {
+		if e.Level == level && strings.Contains(e.Message, message) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// RequireLogged stops the test unless a message of the given level containing the given string was captured.
+// The matching messages are marked as expected.
+func (r *Recorder) RequireLogged(level log.Level, message string) {
+	r.t.Helper()
+	r.lock.Lock()
+	found := r.find(level, message)
+	for _, e := range found {
+		r.asserted[e] = true
+	}
+	r.lock.Unlock()
+	if len(found) == 0 {
+		r.t.Fatalf("logtest: no %v message containing %q was logged", level, message)
+	}
+}
+
+// RequireNotLogged stops the test if a message of the given level containing the given string was captured.
+func (r *Recorder) RequireNotLogged(level log.Level, message string) {
+	r.t.Helper()
+	if found := r.Find(level, message); len(found) > 0 {
+		r.t.Fatalf("logtest: unexpected %v message: %v", level, found[0].Message)
+	}
+}
+
+// RequireLogged calls Recorder.RequireLogged on the recorder started by Hook for the test.
+func RequireLogged(t testing.TB, level log.Level, message string) {
+	t.Helper()
+	recorderOf(t).RequireLogged(level, message)
+}
+
+// RequireNotLogged calls Recorder.RequireNotLogged on the recorder started by Hook for the test.
+func RequireNotLogged(t testing.TB, level log.Level, message string) {
+	t.Helper()
+	recorderOf(t).RequireNotLogged(level, message)
+}
+
+func recorderOf(t testing.TB) *Recorder {
+	t.Helper()
+	recordersLock.Lock()
+	r := recorders[t]
+	recordersLock.Unlock()
+	if r == nil {
+		t.Fatalf("logtest: Hook was not called by the test")
+	}
+	return r
+}
@@ -0,0 +1,95 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logtest_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/admpub/log"
+	"github.com/admpub/log/logtest"
+)
+
+// fakeT records the failures of a test instead of failing it.
+type fakeT struct {
+	testing.TB
+	failures []string
+	cleanups []func()
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeT) Fatalf(format string, args ...interface{}) {
+	t.Errorf(format, args...)
+}
+
+func (t *fakeT) Cleanup(f func()) {
+	t.cleanups = append(t.cleanups, f)
+}
+
+func (t *fakeT) end() {
+	for _, f := range t.cleanups {
+		f()
+	}
+}
+
+func TestHook(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	logger.Open()
+	defer logger.Close()
+
+	logtest.Hook(t, logger)
+	logger.GetLogger("db").Errorf("dial: %v", "connection refused")
+	logger.Info("started")
+	logtest.RequireLogged(t, log.LevelError, "connection refused")
+	logtest.RequireNotLogged(t, log.LevelWarn, "started")
+}
+
+func TestRecorder(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	logger.Open()
+	defer logger.Close()
+
+	ft := &fakeT{TB: t}
+	r := logtest.Hook(ft, logger)
+	logger.Error("e1")
+	logger.Error("e2")
+	logger.Info("i1")
+	r.RequireLogged(log.LevelError, "e1")
+	r.RequireLogged(log.LevelInfo, "i2")
+	logtest.RequireNotLogged(ft, log.LevelInfo, "i1")
+	if len(r.Entries()) != 3 {
+		t.Errorf("len(Entries()) = %v, expected %v", len(r.Entries()), 3)
+	}
+	ft.end()
+	expected := []string{
+		`logtest: no Info message containing "i2" was logged`,
+		"logtest: unexpected Info message: i1",
+		"logtest: unexpected Error message: e2",
+	}
+	if fmt.Sprint(ft.failures) != fmt.Sprint(expected) {
+		t.Errorf("failures = %q, expected %q", ft.failures, expected)
+	}
+
+	// the messages logged after the test are not captured
+	logger.Error("e3")
+	if len(r.Entries()) != 3 {
+		t.Errorf("len(Entries()) = %v after the test, expected %v", len(r.Entries()), 3)
+	}
+
+	ft = &fakeT{TB: t}
+	logtest.Hook(ft, logger).AllowErrors()
+	logger.Error("e4")
+	ft.end()
+	if len(ft.failures) != 0 {
+		t.Errorf("failures = %q, expected none when errors are allowed", ft.failures)
+	}
+}
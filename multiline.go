@@ -0,0 +1,84 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MultilinePolicy describes how a target writes the formatted messages spanning several lines,
+// e.g. those holding call stacks.
+type MultilinePolicy int
+
+const (
+	// MultilineAsIs writes the messages as they are.
+	MultilineAsIs MultilinePolicy = iota
+	// MultilineEscape writes the line breaks as \n and \r, so that each message takes one line
+	// and the log stays machine-parseable.
+	MultilineEscape
+	// MultilineIndent prefixes the continuation lines with Filter.MultilinePrefix, so that they can be told
+	// from the first line of the next message, e.g. by the multiline patterns of log shippers.
+	MultilineIndent
+)
+
+// DefaultMultilinePrefix is the prefix of the continuation lines with MultilineIndent when MultilinePrefix is empty.
+const DefaultMultilinePrefix = "\t"
+
+var multilineNames = []string{"asis", "escape", "indent"}
+
+// String returns the name of the policy: "asis", "escape" or "indent".
+func (p MultilinePolicy) String() string {
+	if p >= 0 && int(p) < len(multilineNames) {
+		return multilineNames[p]
+	}
+	return fmt.Sprintf("MultilinePolicy(%d)", int(p))
+}
+
+// MarshalText returns the name of the policy.
+func (p MultilinePolicy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText parses the name of a policy, so that it can be set in configurations.
+func (p *MultilinePolicy) UnmarshalText(text []byte) error {
+	name := strings.ToLower(strings.TrimSpace(string(text)))
+	for i, n := range multilineNames {
+		if name == n {
+			*p = MultilinePolicy(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown multiline policy %q", text)
+}
+
+// fold applies the multiline policy of the target to the formatted message of the entry, which is
+// the target's own copy. It is called by the workers before the target processes the entry.
+func (t *Filter) fold(e *Entry) {
+	if t == nil {
+		return
+	}
+	switch t.Multiline {
+	case MultilineEscape:
+		if strings.ContainsAny(e.FormattedMessage, "\r\n") {
+			e.FormattedMessage = multilineEscaper.Replace(e.FormattedMessage)
+		}
+	case MultilineIndent:
+		if strings.IndexByte(e.FormattedMessage, '\n') >= 0 {
+			prefix := t.MultilinePrefix
+			if prefix == "" {
+				prefix = DefaultMultilinePrefix
+			}
+			e.FormattedMessage = strings.Replace(e.FormattedMessage, "\n", "\n"+prefix, -1)
+		}
+	}
+}
+
+var multilineEscaper = strings.NewReplacer("\n", `\n`, "\r", `\r`)
+
+// folder is implemented by the targets embedding Filter.
+type folder interface {
+	fold(e *Entry)
+}
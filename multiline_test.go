@@ -0,0 +1,57 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestMultilinePolicy(t *testing.T) {
+	tests := []struct {
+		policy   log.MultilinePolicy
+		prefix   string
+		expected string
+	}{
+		{log.MultilineAsIs, "", "|Error|app|failed\nat main.go:1\r\n"},
+		{log.MultilineEscape, "", `|Error|app|failed\nat main.go:1\r\n`},
+		{log.MultilineIndent, "", "|Error|app|failed\n\tat main.go:1\r\n\t"},
+		{log.MultilineIndent, "> ", "|Error|app|failed\n> at main.go:1\r\n> "},
+	}
+	for _, test := range tests {
+		for _, sync := range []bool{true, false} {
+			logger := log.NewLogger()
+			logger.Sync(sync)
+			logger.MaxGoroutines = 0
+			target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+			target.Multiline, target.MultilinePrefix = test.policy, test.prefix
+			// the other target gets the message as it is
+			other := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+			logger.SetTarget(target, other)
+			logger.Error("failed\nat main.go:1\r\n")
+			logger.Close()
+
+			if len(target.entries) != 1 || len(other.entries) != 1 {
+				t.Fatalf("len(entries) = %v %v, expected 1", len(target.entries), len(other.entries))
+			}
+			if result := target.entries[0].String(); result[19:] != test.expected {
+				t.Errorf("%v: message = %q, expected %q", test.policy, result[19:], test.expected)
+			}
+			if result := other.entries[0].String(); result[19:] != tests[0].expected {
+				t.Errorf("%v: the other message = %q, expected %q", test.policy, result[19:], tests[0].expected)
+			}
+		}
+	}
+
+	var filter log.Filter
+	if err := json.Unmarshal([]byte(`{"multiline":"indent"}`), &filter); err != nil || filter.Multiline != log.MultilineIndent {
+		t.Errorf("json.Unmarshal() = %v, %v, expected %v", filter.Multiline, err, log.MultilineIndent)
+	}
+	if err := json.Unmarshal([]byte(`{"multiline":"fold"}`), &filter); err == nil {
+		t.Errorf("json.Unmarshal() = nil, expected an error for an unknown policy")
+	}
+}
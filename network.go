@@ -5,6 +5,7 @@
 package log
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
@@ -31,10 +32,19 @@ type NetworkTarget struct {
 	Persistent bool
 	// the size of the message channel.
 	BufferSize int
-
-	entries chan *Entry
-	conn    net.Conn
-	close   chan bool
+	// the compression of the messages, e.g. GzipCompressor. Nil means no compression. It requires a stream
+	// network such as "tcp" or "unix". A persistent connection carries a single compressed stream which is
+	// flushed after each message, while a connection per message carries a compressed stream per message.
+	Compression Compressor
+	// the TLS options of the connections. Nil means the connections are not encrypted.
+	// It requires a stream network such as "tcp".
+	TLS *TLSOptions
+
+	tlsConfig *tls.Config
+	entries   chan *Entry
+	conn      net.Conn
+	zw        CompressWriter // the compressor of the persistent connection
+	close     chan bool
 }
 
 // NewNetworkTarget creates a NetworkTarget.
@@ -63,6 +73,20 @@ func (t *NetworkTarget) Open(errWriter io.Writer) error {
 	if t.Address == "" {
 		return errors.New("NetworkTarget.Address must be specified")
 	}
+	if t.Compression != nil && !isStreamNetwork(t.Network) {
+		return errors.New("NetworkTarget.Compression requires a stream network")
+	}
+	t.tlsConfig = nil
+	if t.TLS != nil {
+		if !isStreamNetwork(t.Network) {
+			return errors.New("NetworkTarget.TLS requires a stream network")
+		}
+		config, err := t.TLS.Config()
+		if err != nil {
+			return fmt.Errorf("NetworkTarget.TLS is invalid: %v", err)
+		}
+		t.tlsConfig = config
+	}
 
 	t.entries = make(chan *Entry, t.BufferSize)
 	t.conn = nil
@@ -78,6 +102,24 @@ func (t *NetworkTarget) Open(errWriter io.Writer) error {
 	return nil
 }
 
+// Check checks that a connection to the server can be established, see Checker.
+func (t *NetworkTarget) Check() error {
+	var (
+		conn net.Conn
+		err  error
+	)
+	dialer := &net.Dialer{Timeout: checkTimeout}
+	if t.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, t.Network, t.Address, t.tlsConfig)
+	} else {
+		conn, err = dialer.Dial(t.Network, t.Address)
+	}
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
 // Process puts filtered log messages into a channel for sending over network.
 func (t *NetworkTarget) Process(e *Entry) {
 	if t.Allow(e) {
@@ -91,19 +133,21 @@ func (t *NetworkTarget) Process(e *Entry) {
 // Close closes the network target.
 func (t *NetworkTarget) Close() {
 	<-t.close
-	if t.conn != nil {
-		t.conn.Close()
-		t.conn = nil
-	}
+	t.disconnect()
 }
 
 func (t *NetworkTarget) connect() error {
-	if t.conn != nil {
-		t.conn.Close()
-		t.conn = nil
+	t.disconnect()
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	if t.tlsConfig != nil {
+		conn, err = tls.Dial(t.Network, t.Address, t.tlsConfig)
+	} else {
+		conn, err = net.Dial(t.Network, t.Address)
 	}
-
-	conn, err := net.Dial(t.Network, t.Address)
 	if err != nil {
 		return err
 	}
@@ -113,32 +157,85 @@ func (t *NetworkTarget) connect() error {
 	}
 
 	t.conn = conn
+	if t.Compression != nil {
+		if t.zw, err = t.Compression.NewWriter(conn); err != nil {
+			t.disconnect()
+			return err
+		}
+	}
 	return nil
 }
 
+// disconnect ends the compressed stream, if any, and closes the connection.
+func (t *NetworkTarget) disconnect() {
+	if t.conn == nil {
+		return
+	}
+	if t.zw != nil {
+		t.zw.Close()
+		t.zw = nil
+	}
+	t.conn.Close()
+	t.conn = nil
+}
+
 func (t *NetworkTarget) sendMessages(errWriter io.Writer) {
 	for {
 		entry := <-t.entries
 		if entry == nil {
-			if t.conn != nil {
-				t.conn.Close()
-			}
+			t.disconnect()
 			t.close <- true
 			break
 		}
-		if err := t.write(entry.String() + "\n"); err != nil {
+		if err := t.WriteEntry(entry); err != nil {
 			fmt.Fprintf(errWriter, "NetworkTarget write error: %v\n", err)
 		}
 	}
 }
 
+// WriteEntry sends a log message and returns the network error. A persistent connection
+// which failed is opened again by the next call.
+func (t *NetworkTarget) WriteEntry(e *Entry) error {
+	if t.Persistent && t.conn == nil {
+		if err := t.connect(); err != nil {
+			return err
+		}
+	}
+	err := t.write(e.String() + "\n")
+	if err != nil && t.Persistent {
+		t.disconnect()
+	}
+	return err
+}
+
 func (t *NetworkTarget) write(message string) error {
 	if !t.Persistent {
 		if err := t.connect(); err != nil {
 			return err
 		}
-		defer t.conn.Close()
+		defer t.disconnect()
 	}
-	_, err := t.conn.Write([]byte(message))
-	return err
+	if t.zw == nil {
+		_, err := t.conn.Write([]byte(message))
+		return err
+	}
+	if _, err := t.zw.Write([]byte(message)); err != nil {
+		return err
+	}
+	if !t.Persistent {
+		// the compressed stream is ended before the connection is closed
+		err := t.zw.Close()
+		t.zw = nil
+		return err
+	}
+	return t.zw.Flush()
+}
+
+// isStreamNetwork returns whether the network carries a stream rather than datagrams.
+func isStreamNetwork(network string) bool {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "unix":
+		return true
+	}
+	return false
 }
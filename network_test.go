@@ -5,6 +5,9 @@
 package log_test
 
 import (
+	"compress/gzip"
+	"io/ioutil"
+
 	"github.com/admpub/log"
 	"net"
 	"strings"
@@ -83,3 +86,47 @@ func TestNetworkTarget(t *testing.T) {
 		t.Errorf("Expected %q not found", "t2: 3")
 	}
 }
+
+func TestNetworkTargetCompression(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen(): %v", err)
+	}
+	defer listener.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- err.Error()
+			return
+		}
+		defer conn.Close()
+		reader, err := gzip.NewReader(conn)
+		if err != nil {
+			received <- err.Error()
+			return
+		}
+		data, _ := ioutil.ReadAll(reader)
+		received <- string(data)
+	}()
+
+	target := log.NewNetworkTarget()
+	target.Network = "udp"
+	target.Address = listener.Addr().String()
+	target.Compression = log.GzipCompressor
+	if err := target.Open(ioutil.Discard); err == nil {
+		t.Errorf("Open() = nil, expected an error for a datagram network")
+	}
+
+	logger := log.NewLogger()
+	logger.Sync()
+	target.Network = "tcp"
+	logger.SetTarget(target)
+	logger.Info("t1")
+	logger.Info("t2")
+	logger.Close()
+
+	if result := <-received; !strings.Contains(result, "t1") || !strings.Contains(result, "t2") {
+		t.Errorf("received %q, expected t1 and t2", result)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import "sync"
+
+// occurrences counts the occurrences of the keys of Once and EveryN.
+type occurrences struct {
+	lock   sync.Mutex
+	counts map[string]uint64
+}
+
+// add counts an occurrence of key and returns the number of occurrences so far.
+func (o *occurrences) add(key string) uint64 {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.counts == nil {
+		o.counts = map[string]uint64{}
+	}
+	o.counts[key]++
+	return o.counts[key]
+}
+
+// Once logs a message only the first time it is called with the given key by the logger or the loggers
+// sharing its targets, e.g. a deprecation warning in a function called repeatedly:
+//
+//	logger.Once("legacy-config", log.LevelWarn, "the %v setting is deprecated", name)
+//
+// The keys are kept in memory, so they should come from a bounded set.
+func (l *Logger) Once(key string, level Level, format string, a ...interface{}) {
+	if l.occurrences.add(key) == 1 {
+		l.Logf(level, format, a...)
+	}
+}
+
+// EveryN logs a message the first time it is called with the given key and then every n calls,
+// with the number of calls so far in the "occurrences" field, e.g. for the errors of the items of a large batch:
+//
+//	logger.EveryN("parse-item", 1000, log.LevelError, "cannot parse item %v: %v", i, err)
+//
+// The keys are kept in memory, so they should come from a bounded set.
+func (l *Logger) EveryN(key string, n int, level Level, format string, a ...interface{}) {
+	count := l.occurrences.add(key)
+	if n <= 1 || (count-1)%uint64(n) == 0 {
+		l.WithFields(Fields{"occurrences": count}).Logf(level, format, a...)
+	}
+}
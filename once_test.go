@@ -0,0 +1,53 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestOnce(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	defer logger.Close()
+
+	for i := 0; i < 3; i++ {
+		logger.Once("a", log.LevelWarn, "a%v", i)
+		// the loggers sharing the targets share the keys
+		logger.GetLogger("app").Once("b", log.LevelInfo, "b%v", i)
+		logger.GetLogger("app").Once("a", log.LevelInfo, "c%v", i)
+	}
+
+	if len(target.entries) != 2 || target.entries[0].Message != "a0" || target.entries[1].Message != "b0" {
+		t.Errorf("%v messages were logged, expected a0 and b0", len(target.entries))
+	}
+}
+
+func TestEveryN(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	defer logger.Close()
+
+	for i := 1; i <= 7; i++ {
+		logger.EveryN("item", 3, log.LevelError, "item %v", i)
+	}
+
+	var messages []string
+	for _, e := range target.entries {
+		messages = append(messages, e.Message)
+	}
+	if len(messages) != 3 || messages[0] != "item 1" || messages[1] != "item 4" || messages[2] != "item 7" {
+		t.Errorf("messages = %v, expected items 1, 4 and 7", messages)
+	}
+	if n := target.entries[1].Fields["occurrences"]; n != uint64(4) {
+		t.Errorf("occurrences = %v, expected %v", n, 4)
+	}
+}
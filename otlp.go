@@ -0,0 +1,257 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// OTLPTarget exports log messages as OpenTelemetry log records using the OTLP/HTTP protocol with JSON encoding.
+// It supports neither OTLP/gRPC nor the protobuf encoding of OTLP/HTTP: the collector must accept JSON on its
+// HTTP logs endpoint, e.g. http://collector:4318/v1/logs.
+// Entry fields become record attributes. The records carry the trace and span IDs of the entry, or those extracted
+// from its context through the SpanContext function, so that logs can be correlated with traces.
+type OTLPTarget struct {
+	*Filter
+	// the OTLP/HTTP logs endpoint.
+	Endpoint string
+	// additional HTTP headers, e.g. for authentication.
+	Headers map[string]string
+	// the value of the service.name resource attribute.
+	ServiceName string
+	// extra resource attributes.
+	ResourceAttributes Fields
+	// extracts the hex encoded trace and span IDs from the context of an entry which has no trace ID.
+	// With the OpenTelemetry API this is typically:
+	//   sc := trace.SpanContextFromContext(ctx)
+	//   return sc.TraceID().String(), sc.SpanID().String()
+	SpanContext func(ctx context.Context) (traceID string, spanID string)
+	// the maximum number of records sent in one request.
+	BatchSize int
+	// the maximum time a message is kept in memory before being sent.
+	FlushInterval time.Duration
+	// the size of the message channel.
+	BufferSize int
+	// the HTTP client used to call the collector.
+	Client *http.Client
+	// the TLS options of the HTTP client created when Client is nil, e.g. for mutual TLS.
+	TLS *TLSOptions
+
+	batcher *BatchingTarget
+}
+
+// NewOTLPTarget creates an OTLPTarget.
+// The new OTLPTarget takes these default options:
+// MaxLevel: LevelDebug, Endpoint: http://localhost:4318/v1/logs, BatchSize: 100,
+// FlushInterval: 2s, BufferSize: 1024.
+func NewOTLPTarget() *OTLPTarget {
+	return &OTLPTarget{
+		Filter:        &Filter{MaxLevel: LevelDebug},
+		Endpoint:      "http://localhost:4318/v1/logs",
+		BatchSize:     100,
+		FlushInterval: 2 * time.Second,
+		BufferSize:    1024,
+	}
+}
+
+// Open prepares OTLPTarget for processing log messages.
+func (t *OTLPTarget) Open(errWriter io.Writer) error {
+	t.Filter.Init()
+	if t.Endpoint == "" {
+		return errors.New("OTLPTarget.Endpoint must be specified")
+	}
+	if t.Client == nil {
+		client, err := newHTTPClient(t.TLS)
+		if err != nil {
+			return fmt.Errorf("OTLPTarget.TLS is invalid: %v", err)
+		}
+		t.Client = client
+	}
+	t.batcher = &BatchingTarget{
+		Filter:        t.Filter,
+		Writer:        BatchWriterFunc(t.write),
+		Name:          "OTLPTarget",
+		BatchSize:     t.BatchSize,
+		FlushInterval: t.FlushInterval,
+		BufferSize:    t.BufferSize,
+		close:         make(chan bool, 0),
+	}
+	return t.batcher.Open(errWriter)
+}
+
+// Process puts filtered log messages into a batch for exporting.
+func (t *OTLPTarget) Process(e *Entry) {
+	t.batcher.Process(e)
+}
+
+// Close exports the last batch and closes the OTLP target.
+func (t *OTLPTarget) Close() {
+	t.batcher.Close()
+}
+
+// otlpSeverities maps log levels to OpenTelemetry severity numbers.
+var otlpSeverities = map[Level]int{
+	LevelEmergency: 24,
+	LevelAlert:     23,
+	LevelCritical:  22,
+	LevelFatal:     21,
+	LevelError:     17,
+	LevelWarn:      13,
+	LevelInfo:      9,
+	LevelDebug:     5,
+	LevelTrace:     1,
+}
+
+type otlpValue map[string]interface{}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string         `json:"timeUnixNano"`
+	ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+	SeverityNumber       int            `json:"severityNumber"`
+	SeverityText         string         `json:"severityText"`
+	Body                 otlpValue      `json:"body"`
+	Attributes           []otlpKeyValue `json:"attributes,omitempty"`
+	TraceID              string         `json:"traceId,omitempty"`
+	SpanID               string         `json:"spanId,omitempty"`
+}
+
+func otlpAnyValue(v interface{}) otlpValue {
+	switch val := v.(type) {
+	case string:
+		return otlpValue{"stringValue": val}
+	case bool:
+		return otlpValue{"boolValue": val}
+	case int:
+		return otlpValue{"intValue": strconv.FormatInt(int64(val), 10)}
+	case int8:
+		return otlpValue{"intValue": strconv.FormatInt(int64(val), 10)}
+	case int16:
+		return otlpValue{"intValue": strconv.FormatInt(int64(val), 10)}
+	case int32:
+		return otlpValue{"intValue": strconv.FormatInt(int64(val), 10)}
+	case int64:
+		return otlpValue{"intValue": strconv.FormatInt(val, 10)}
+	case uint8:
+		return otlpValue{"intValue": strconv.FormatUint(uint64(val), 10)}
+	case uint16:
+		return otlpValue{"intValue": strconv.FormatUint(uint64(val), 10)}
+	case uint32:
+		return otlpValue{"intValue": strconv.FormatUint(uint64(val), 10)}
+	case uint:
+		return otlpUintValue(uint64(val))
+	case uint64:
+		return otlpUintValue(val)
+	case uintptr:
+		return otlpUintValue(uint64(val))
+	case float32:
+		return otlpValue{"doubleValue": float64(val)}
+	case float64:
+		return otlpValue{"doubleValue": val}
+	case error:
+		return otlpValue{"stringValue": val.Error()}
+	}
+	return otlpValue{"stringValue": fmt.Sprint(v)}
+}
+
+// otlpUintValue encodes an unsigned integer as an intValue, which holds a signed 64-bit integer.
+// The integers beyond its range are encoded as strings rather than wrapped around.
+func otlpUintValue(val uint64) otlpValue {
+	if val > math.MaxInt64 {
+		return otlpValue{"stringValue": strconv.FormatUint(val, 10)}
+	}
+	return otlpValue{"intValue": strconv.FormatUint(val, 10)}
+}
+
+func otlpAttributes(fields Fields) []otlpKeyValue {
+	attrs := make([]otlpKeyValue, 0, len(fields))
+	for k, v := range fields {
+		attrs = append(attrs, otlpKeyValue{Key: k, Value: otlpAnyValue(v)})
+	}
+	return attrs
+}
+
+func (t *OTLPTarget) record(e *Entry) otlpLogRecord {
+	r := otlpLogRecord{
+		TimeUnixNano:         strconv.FormatInt(e.Time.UnixNano(), 10),
+		ObservedTimeUnixNano: strconv.FormatInt(time.Now().UnixNano(), 10),
+		SeverityNumber:       otlpSeverities[e.Level],
+		SeverityText:         e.Level.String(),
+		Body:                 otlpAnyValue(e.Message),
+		Attributes:           otlpAttributes(e.Fields),
+	}
+	r.Attributes = append(r.Attributes, otlpKeyValue{Key: "log.category", Value: otlpAnyValue(e.Category)})
+	if len(e.CallStack) > 0 {
+		r.Attributes = append(r.Attributes, otlpKeyValue{Key: "code.stacktrace", Value: otlpAnyValue(e.CallStack.String())})
+	}
+	r.TraceID, r.SpanID = e.TraceID, e.SpanID
+	if r.TraceID == "" && t.SpanContext != nil && e.Context != nil {
+		r.TraceID, r.SpanID = t.SpanContext(e.Context)
+	}
+	return r
+}
+
+func (t *OTLPTarget) write(entries []*Entry) error {
+	records := make([]otlpLogRecord, len(entries))
+	for i, e := range entries {
+		records[i] = t.record(e)
+	}
+	resource := Fields{}
+	for k, v := range t.ResourceAttributes {
+		resource[k] = v
+	}
+	if t.ServiceName != "" {
+		resource["service.name"] = t.ServiceName
+	}
+	payload := map[string]interface{}{
+		"resourceLogs": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{"attributes": otlpAttributes(resource)},
+				"scopeLogs": []interface{}{
+					map[string]interface{}{
+						"scope":      map[string]string{"name": "github.com/admpub/log"},
+						"logRecords": records,
+					},
+				},
+			},
+		},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", t.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		data, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%v: %s", resp.Status, bytes.TrimSpace(data))
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+type traceKey struct{}
+
+func TestOTLPTarget(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		body += string(data)
+	}))
+	defer server.Close()
+
+	logger := log.NewLogger()
+	logger.Sync()
+	target := log.NewOTLPTarget()
+	target.Endpoint = server.URL
+	target.ServiceName = "svc"
+	target.SpanContext = func(ctx context.Context) (string, string) {
+		return ctx.Value(traceKey{}).(string), "00f067aa0ba902b7"
+	}
+	logger.SetTarget(target)
+
+	ctx := context.WithValue(context.Background(), traceKey{}, "4bf92f3577b34da6a3ce929d0e0e4736")
+	logger.WithContext(ctx).WithFields(log.Fields{
+		"user": "alice", "port": uint16(8080), "offset": int8(-3), "bytes": uint64(1 << 40), "huge": uint64(1 << 63),
+	}).Errorf("t1: %v", 2)
+
+	logger.Close()
+
+	expected := []string{
+		`"severityNumber":17`,
+		`"body":{"stringValue":"t1: 2"}`,
+		`"traceId":"4bf92f3577b34da6a3ce929d0e0e4736"`,
+		`{"key":"user","value":{"stringValue":"alice"}}`,
+		`{"key":"port","value":{"intValue":"8080"}}`,
+		`{"key":"offset","value":{"intValue":"-3"}}`,
+		`{"key":"bytes","value":{"intValue":"1099511627776"}}`,
+		`{"key":"huge","value":{"stringValue":"9223372036854775808"}}`,
+		`{"key":"service.name","value":{"stringValue":"svc"}}`,
+	}
+	for _, s := range expected {
+		if !strings.Contains(body, s) {
+			t.Errorf("Expected %q not found in %q", s, body)
+		}
+	}
+}
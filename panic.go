@@ -0,0 +1,76 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// DefaultMaxPanics is the number of panics after which a formatter or a target is disabled when MaxPanics is 0.
+const DefaultMaxPanics = 3
+
+// maxPanics returns the number of panics after which a formatter or a target is disabled, or 0 for never.
+func (l *coreLogger) maxPanics() int32 {
+	switch {
+	case l.MaxPanics == 0:
+		return DefaultMaxPanics
+	case l.MaxPanics < 0:
+		return 0
+	}
+	return int32(l.MaxPanics)
+}
+
+// formatterPanicked reports a panic of the formatter to ErrorWriter, and disables the formatter
+// once it has panicked MaxPanics times.
+func (l *coreLogger) formatterPanicked(r interface{}) {
+	fmt.Fprintf(l.ErrorWriter, "Formatter panic: %v\n%s", r, debug.Stack())
+	if n := atomic.AddInt32(&l.fmtPanics, 1); n == l.maxPanics() {
+		atomic.StoreInt32(&l.fmtDisabled, 1)
+		fmt.Fprintf(l.ErrorWriter, "Formatter disabled after %v panics, the messages are formatted by DefaultFormatter\n", n)
+	}
+}
+
+// process hands a message to the target, turning a panic of the target into an error.
+func (w *targetWorker) process(entry *Entry) (err error) {
+	defer w.recoverPanic(&err)
+	if t, ok := w.target.(*V2Target); ok {
+		return t.process(entry)
+	}
+	w.target.Process(entry)
+	return nil
+}
+
+// processBatch hands several messages to the BatchTarget, turning a panic of the target into an error.
+func (w *targetWorker) processBatch(entries []*Entry) (err error) {
+	defer w.recoverPanic(&err)
+	w.target.(BatchTarget).ProcessBatch(entries)
+	return nil
+}
+
+// close hands the closing signal to the target, even if it is disabled, so that its Close method returns.
+func (w *targetWorker) close() {
+	var err error
+	defer w.recoverPanic(&err)
+	w.target.Process(nil)
+}
+
+// recoverPanic recovers from a panic of the target, reports it to ErrorWriter and stores it in err.
+// The target is disabled once it has panicked MaxPanics times: its messages are then counted as failed
+// instead of being handed to it. It must be deferred directly.
+func (w *targetWorker) recoverPanic(err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	*err = fmt.Errorf("panic: %v", r)
+	l := w.logger
+	fmt.Fprintf(l.ErrorWriter, "%T panic: %v\n%s", w.target, r, debug.Stack())
+	if n := atomic.AddInt32(&w.stats.panics, 1); n == l.maxPanics() {
+		atomic.StoreInt32(&w.stats.disabled, 1)
+		fmt.Fprintf(l.ErrorWriter, "%T disabled after %v panics\n", w.target, n)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+// panickingTarget panics on the messages containing "bad".
+type panickingTarget struct {
+	MemoryTarget
+}
+
+func (t *panickingTarget) Process(e *log.Entry) {
+	if e != nil && strings.Contains(e.Message, "bad") {
+		panic("bad message")
+	}
+	t.MemoryTarget.Process(e)
+}
+
+func TestFormatterPanic(t *testing.T) {
+	var errors bytes.Buffer
+	logger := log.NewLogger()
+	logger.ErrorWriter = &errors
+	logger.MaxPanics = 2
+	logger.Sync()
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	logger.SetFormatter(func(*log.Logger, *log.Entry) string {
+		panic("broken formatter")
+	})
+	logger.Info("m1")
+	logger.Info("m2")
+	logger.Info("m3")
+	logger.SetFormatter(func(l *log.Logger, e *log.Entry) string {
+		return "custom " + e.Message
+	})
+	logger.Info("m4")
+	logger.Close()
+
+	var messages []string
+	for _, e := range target.entries {
+		messages = append(messages, e.FormattedMessage)
+	}
+	if len(messages) != 4 || !strings.HasSuffix(messages[2], "m3") || messages[3] != "custom m4" {
+		t.Errorf("messages = %q, expected the messages to be formatted by DefaultFormatter until the formatter is replaced", messages)
+	}
+	if n := strings.Count(errors.String(), "Formatter panic: broken formatter"); n != 2 {
+		t.Errorf("panics reported = %v, expected 2", n)
+	}
+	if !strings.Contains(errors.String(), "Formatter disabled after 2 panics") {
+		t.Errorf("ErrorWriter = %q, expected the formatter to be disabled", errors.String())
+	}
+}
+
+func TestTargetPanic(t *testing.T) {
+	var errors bytes.Buffer
+	logger := log.NewLogger()
+	logger.ErrorWriter = &errors
+	logger.MaxPanics = 2
+	logger.MaxGoroutines = 0
+	target := &panickingTarget{MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}}
+	logger.SetTarget(target)
+	for _, message := range []string{"m1", "bad 1", "m2", "bad 2", "m3"} {
+		logger.Info(message)
+	}
+	logger.Barrier()
+
+	status := logger.TargetStatus()[0]
+	if !status.Disabled || status.Processed != 4 || status.Failed != 3 || status.LastError == nil {
+		t.Errorf("TargetStatus() = %+v, expected the target to be disabled after 2 panics", status)
+	}
+	if n := strings.Count(errors.String(), "*log_test.panickingTarget panic: bad message"); n != 2 {
+		t.Errorf("panics reported = %v, expected 2", n)
+	}
+	if !strings.Contains(errors.String(), "*log_test.panickingTarget disabled after 2 panics") {
+		t.Errorf("ErrorWriter = %q, expected the target to be disabled", errors.String())
+	}
+	// the disabled target still receives the closing signal
+	logger.Close()
+	var messages []string
+	for _, e := range target.entries {
+		messages = append(messages, e.Message)
+	}
+	if strings.Join(messages, ",") != "m1,m2" {
+		t.Errorf("messages = %v, expected m1,m2", messages)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import "hash/fnv"
+
+// ConcurrentTarget is a target whose Process may be called by several goroutines at once, so that the CPU-heavy
+// processing of its messages, such as compression, encryption or marshaling, can use several cores. The logger
+// runs Workers goroutines for such a target, and hands the messages of a category to the same goroutine, so that
+// they are processed in the order they are queued. Process(nil) is called once all the goroutines are done.
+type ConcurrentTarget interface {
+	Target
+	// ProcessConcurrently returns whether Process may be called concurrently with the target as configured.
+	ProcessConcurrently() bool
+}
+
+// workersOf returns the number of goroutines processing the messages of a target.
+func (l *coreLogger) workersOf(target Target) int {
+	if t, ok := target.(ConcurrentTarget); ok && l.Workers > 1 && t.ProcessConcurrently() {
+		return l.Workers
+	}
+	return 1
+}
+
+// queueOf returns the queue of the goroutine processing the messages of the category of an entry.
+func (w *targetWorker) queueOf(entry *Entry) chan *Entry {
+	if len(w.queues) == 1 {
+		return w.queues[0]
+	}
+	h := fnv.New32a()
+	h.Write([]byte(entry.Category))
+	return w.queues[h.Sum32()%uint32(len(w.queues))]
+}
+
+// send sends a closing signal or a barrier to all the goroutines of the worker.
+func (w *targetWorker) send(entry *Entry) {
+	for _, queue := range w.queues {
+		queue <- entry
+	}
+}
+
+func (w *targetWorker) queueDepth() int {
+	depth := 0
+	for _, queue := range w.queues {
+		depth += len(queue)
+	}
+	return depth
+}
+
+func (w *targetWorker) queueCapacity() int {
+	capacity := 0
+	for _, queue := range w.queues {
+		capacity += cap(queue)
+	}
+	return capacity
+}
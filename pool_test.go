@@ -0,0 +1,96 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+type concurrentTarget struct {
+	*log.Filter
+	lock     sync.Mutex
+	messages map[string][]string
+	closes   int
+	close    chan bool
+}
+
+func (t *concurrentTarget) Open(io.Writer) error {
+	t.Filter.Init()
+	t.messages = map[string][]string{}
+	return nil
+}
+
+func (t *concurrentTarget) Process(e *log.Entry) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if e == nil {
+		t.closes++
+		t.close <- true
+		return
+	}
+	t.messages[e.Category] = append(t.messages[e.Category], e.Message)
+}
+
+func (t *concurrentTarget) ProcessConcurrently() bool {
+	return true
+}
+
+func (t *concurrentTarget) Close() {
+	<-t.close
+}
+
+func TestWorkers(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Workers = 4
+	// the messages of each goroutine are queued in order
+	logger.MaxGoroutines = 0
+	target := &concurrentTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, close: make(chan bool, 1)}
+	memory := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target, memory)
+
+	if capacity := logger.TargetStatus()[0].QueueCapacity; capacity != 4*logger.TargetBuffer {
+		t.Errorf("QueueCapacity = %v, expected %v", capacity, 4*logger.TargetBuffer)
+	}
+	if capacity := logger.TargetStatus()[1].QueueCapacity; capacity != logger.TargetBuffer {
+		t.Errorf("QueueCapacity = %v, expected %v for a target which is not concurrent", capacity, logger.TargetBuffer)
+	}
+	var wg sync.WaitGroup
+	for c := 0; c < 8; c++ {
+		wg.Add(1)
+		go func(l *log.Logger) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				l.Info(i)
+			}
+		}(logger.GetLogger(fmt.Sprintf("app.c%v", c)))
+	}
+	wg.Wait()
+	logger.Close()
+
+	if target.closes != 1 {
+		t.Errorf("Process(nil) was called %v times, expected once", target.closes)
+	}
+	if len(target.messages) != 8 {
+		t.Fatalf("categories = %v, expected 8", len(target.messages))
+	}
+	for category, messages := range target.messages {
+		for i, message := range messages {
+			if message != fmt.Sprint(i) {
+				t.Fatalf("message %v of %v = %v, expected the messages of a category in order", i, category, message)
+			}
+		}
+		if len(messages) != 50 {
+			t.Errorf("len(messages) of %v = %v, expected 50", category, len(messages))
+		}
+	}
+	if len(memory.entries) != 400 {
+		t.Errorf("len(entries) = %v, expected 400", len(memory.entries))
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The Print, Panic and Fatalln methods format their arguments like the functions of the standard log package,
+// so that a logger can replace a standard logger in code migrating from it. The messages are logged
+// at the Info level by the Print methods and at the Error level by the Panic methods.
+
+// Print logs an informational message formatted with fmt.Sprint, like log.Print.
+func (l *Logger) Print(a ...interface{}) {
+	if l.Enabled(LevelInfo) {
+		l.newEntry(LevelInfo, fmt.Sprint(evalArgs(a)...))
+	}
+}
+
+// Printf logs an informational message formatted with fmt.Sprintf, like log.Printf.
+func (l *Logger) Printf(format string, a ...interface{}) {
+	if l.Enabled(LevelInfo) {
+		l.newEntry(LevelInfo, fmt.Sprintf(format, evalArgs(a)...))
+	}
+}
+
+// Println logs an informational message formatted with fmt.Sprintln, like log.Println.
+func (l *Logger) Println(a ...interface{}) {
+	if l.Enabled(LevelInfo) {
+		l.newEntry(LevelInfo, sprintln(evalArgs(a)))
+	}
+}
+
+// Panic logs an error message formatted with fmt.Sprint, then panics with the message, like log.Panic.
+func (l *Logger) Panic(a ...interface{}) {
+	l.panic(fmt.Sprint(evalArgs(a)...))
+}
+
+// Panicf logs an error message formatted with fmt.Sprintf, then panics with the message, like log.Panicf.
+func (l *Logger) Panicf(format string, a ...interface{}) {
+	l.panic(fmt.Sprintf(format, evalArgs(a)...))
+}
+
+// Panicln logs an error message formatted with fmt.Sprintln, then panics with the message, like log.Panicln.
+func (l *Logger) Panicln(a ...interface{}) {
+	l.panic(sprintln(evalArgs(a)))
+}
+
+// Fatalln logs a fatal message formatted with fmt.Sprintln. Like Fatal and Fatalf, it then performs
+// the fatal action, which must be set to ActionExit to exit the program like log.Fatalln.
+func (l *Logger) Fatalln(a ...interface{}) {
+	if l.Enabled(LevelFatal) {
+		l.newEntry(LevelFatal, sprintln(evalArgs(a)))
+	}
+}
+
+// panic logs an error message and waits until it is handed to the targets, as the panic may
+// end the program, then panics with the message.
+func (l *Logger) panic(message string) {
+	if l.Enabled(LevelError) {
+		l.newEntry(LevelError, message)
+		l.flush()
+	}
+	panic(message)
+}
+
+// sprintln formats the arguments with fmt.Sprintln, without the final newline.
+func sprintln(a []interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(a...), "\n")
+}
@@ -0,0 +1,50 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestLoggerPrint(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	logger.Print("a", 1, 2, "b")
+	logger.Println("a", 1, 2, "b")
+	logger.Printf("a%v", 1)
+	logger.Fatalln("c", 3)
+	func() {
+		defer func() {
+			if r := recover(); r != "d 4" {
+				t.Errorf("Panicln() panicked with %v, expected the message", r)
+			}
+		}()
+		logger.Panicln("d", 4)
+	}()
+	logger.Close()
+
+	tests := []struct {
+		level   log.Level
+		message string
+	}{
+		{log.LevelInfo, "a1 2b"},
+		{log.LevelInfo, "a 1 2 b"},
+		{log.LevelInfo, "a1"},
+		{log.LevelFatal, "c 3"},
+		{log.LevelError, "d 4"},
+	}
+	if len(target.entries) != len(tests) {
+		t.Fatalf("len(entries) = %v, expected %v", len(target.entries), len(tests))
+	}
+	for i, test := range tests {
+		if e := target.entries[i]; e.Level != test.level || e.Message != test.message {
+			t.Errorf("entries[%v] = %v %q, expected %v %q", i, e.Level, e.Message, test.level, test.message)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// entryQueue is the bounded queue carrying the entries from the loggers to the dispatcher.
+// It has many producers and a single consumer.
+type entryQueue interface {
+	// put adds an entry, waiting while the queue is full.
+	put(e *Entry)
+	// take waits until the queue holds entries, and returns up to cap(batch) of them in batch.
+	take(batch []*Entry) []*Entry
+	len() int
+	cap() int
+}
+
+// newEntryQueue creates a ring buffer holding at least size entries, or a channel if channel is true.
+func newEntryQueue(size int, channel bool) entryQueue {
+	if channel {
+		return chanQueue(make(chan *Entry, size))
+	}
+	return newRingQueue(size)
+}
+
+// chanQueue is an entryQueue backed by a buffered channel, as in the earlier versions of the package.
+type chanQueue chan *Entry
+
+func (q chanQueue) put(e *Entry) {
+	q <- e
+}
+
+func (q chanQueue) take(batch []*Entry) []*Entry {
+	batch = append(batch[:0], <-q)
+	for len(batch) < cap(batch) {
+		select {
+		case e := <-q:
+			batch = append(batch, e)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+func (q chanQueue) len() int {
+	return len(q)
+}
+
+func (q chanQueue) cap() int {
+	return cap(q)
+}
+
+// ringQueue is a bounded lock-free multi-producer single-consumer ring buffer. Each slot holds a sequence
+// number telling whether it is free for the entry at a position or holds it, so that the producers only
+// contend on claiming positions. The producers block on a condition only when the queue is full, and the
+// consumer on a channel only when it is empty.
+type ringQueue struct {
+	tail uint64 // the position of the next entry to put. It is first to be aligned for atomic operations.
+	_    [56]byte
+	head uint64 // the position of the next entry to take, only advanced by the consumer
+	mask uint64
+
+	seqs    []uint64 // the sequence numbers of the slots
+	entries []*Entry
+
+	sleeping int32         // set when the consumer waits for entries
+	wake     chan struct{} // wakes the consumer up
+	waiting  int32         // the number of producers waiting for room
+	lock     sync.Mutex
+	notFull  *sync.Cond
+}
+
+// newRingQueue creates a ringQueue whose size is the power of two above size, and at least 2.
+func newRingQueue(size int) *ringQueue {
+	n := 2
+	for n < size {
+		n <<= 1
+	}
+	q := &ringQueue{
+		mask:    uint64(n - 1),
+		seqs:    make([]uint64, n),
+		entries: make([]*Entry, n),
+		wake:    make(chan struct{}, 1),
+	}
+	for i := range q.seqs {
+		q.seqs[i] = uint64(i)
+	}
+	q.notFull = sync.NewCond(&q.lock)
+	return q
+}
+
+func (q *ringQueue) put(e *Entry) {
+	for !q.tryPut(e) {
+		q.waitNotFull()
+	}
+	if atomic.LoadInt32(&q.sleeping) == 1 && atomic.CompareAndSwapInt32(&q.sleeping, 1, 0) {
+		q.wake <- struct{}{}
+	}
+}
+
+// tryPut adds an entry unless the queue is full.
+func (q *ringQueue) tryPut(e *Entry) bool {
+	for {
+		pos := atomic.LoadUint64(&q.tail)
+		i := pos & q.mask
+		seq := atomic.LoadUint64(&q.seqs[i])
+		switch {
+		case seq == pos:
+			if atomic.CompareAndSwapUint64(&q.tail, pos, pos+1) {
+				q.entries[i] = e
+				atomic.StoreUint64(&q.seqs[i], pos+1)
+				return true
+			}
+		case int64(seq-pos) < 0:
+			// the slot still holds the entry put a round earlier
+			return false
+		}
+	}
+}
+
+// waitNotFull waits until the slot at the tail is free.
+func (q *ringQueue) waitNotFull() {
+	q.lock.Lock()
+	atomic.AddInt32(&q.waiting, 1)
+	for {
+		pos := atomic.LoadUint64(&q.tail)
+		if int64(atomic.LoadUint64(&q.seqs[pos&q.mask])-pos) >= 0 {
+			break
+		}
+		q.notFull.Wait()
+	}
+	atomic.AddInt32(&q.waiting, -1)
+	q.lock.Unlock()
+}
+
+func (q *ringQueue) take(batch []*Entry) []*Entry {
+	batch = batch[:0]
+	for {
+		for len(batch) < cap(batch) {
+			pos := q.head
+			i := pos & q.mask
+			if atomic.LoadUint64(&q.seqs[i]) != pos+1 {
+				break
+			}
+			batch = append(batch, q.entries[i])
+			q.entries[i] = nil
+			atomic.StoreUint64(&q.head, pos+1)
+			atomic.StoreUint64(&q.seqs[i], pos+q.mask+1)
+		}
+		if len(batch) > 0 {
+			if atomic.LoadInt32(&q.waiting) > 0 {
+				q.lock.Lock()
+				q.notFull.Broadcast()
+				q.lock.Unlock()
+			}
+			return batch
+		}
+		q.sleep()
+	}
+}
+
+// sleep waits until a producer has put an entry.
+func (q *ringQueue) sleep() {
+	atomic.StoreInt32(&q.sleeping, 1)
+	pos := q.head
+	if atomic.LoadUint64(&q.seqs[pos&q.mask]) == pos+1 {
+		// an entry was put meanwhile
+		if atomic.CompareAndSwapInt32(&q.sleeping, 1, 0) {
+			return
+		}
+		// the producer which reset sleeping is sending the wake-up
+	}
+	<-q.wake
+}
+
+func (q *ringQueue) len() int {
+	n := int64(atomic.LoadUint64(&q.tail) - atomic.LoadUint64(&q.head))
+	if n < 0 {
+		return 0
+	}
+	return int(n)
+}
+
+func (q *ringQueue) cap() int {
+	return int(q.mask + 1)
+}
@@ -0,0 +1,66 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestEntryQueue(t *testing.T) {
+	tests := []struct {
+		channel  bool
+		size     int
+		capacity int
+	}{
+		{false, 5, 8},
+		{false, 0, 2},
+		{true, 5, 5},
+		{true, 0, 0},
+	}
+	for _, test := range tests {
+		logger := log.NewLogger()
+		logger.Close()
+		logger.BufferSize = test.size
+		logger.ChannelQueue = test.channel
+		// the producers wait for room in the queue
+		logger.MaxGoroutines = 0
+		// the dispatcher waits for room in the small queue of the target
+		logger.TargetBuffer = 4
+		target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+		logger.SetTarget(target)
+
+		if capacity := logger.Stats().QueueCapacity; capacity != test.capacity {
+			t.Errorf("QueueCapacity = %v, expected %v for channel %v and BufferSize %v", capacity, test.capacity, test.channel, test.size)
+		}
+		var wg sync.WaitGroup
+		for p := 0; p < 8; p++ {
+			wg.Add(1)
+			go func(l *log.Logger) {
+				defer wg.Done()
+				for i := 0; i < 500; i++ {
+					l.Info(i)
+				}
+			}(logger.GetLogger(fmt.Sprint(p)))
+		}
+		wg.Wait()
+		logger.Close()
+
+		if len(target.entries) != 4000 {
+			t.Errorf("len(entries) = %v, expected 4000 for channel %v", len(target.entries), test.channel)
+		}
+		// the messages of each producer are in order
+		next := map[string]int{}
+		for _, e := range target.entries {
+			if e.Message != fmt.Sprint(next[e.Category]) {
+				t.Fatalf("message = %v, expected %v for channel %v", e.Message, next[e.Category], test.channel)
+			}
+			next[e.Category]++
+		}
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import "fmt"
+
+// Recover logs the panic of the calling goroutine, if any, as an error with its call stack
+// and stops the panic. It must be deferred directly:
+//
+//	defer logger.Recover()
+func (l *Logger) Recover() {
+	if r := recover(); r != nil {
+		l.logPanic(r)
+	}
+}
+
+// Go runs f in a new goroutine. A panic in f is logged like Recover does instead of crashing the program.
+func (l *Logger) Go(f func()) {
+	go func() {
+		defer l.Recover()
+		f()
+	}()
+}
+
+func (l *Logger) logPanic(r interface{}) {
+	if !l.Enabled(LevelError) {
+		return
+	}
+	entry := l.makeEntry(LevelError, fmt.Sprintf("panic: %v", r))
+	stack := &l.current().stack
+	stackDepth := stack.depth
+	if stackDepth < 20 {
+		stackDepth = 20
+	}
+	// skip callFrames, logPanic and Recover, so that the stack starts at the panic
+	entry.CallStack = callFrames(3, stackDepth, stack.keepFrame, false, 0)
+	l.dispatch(entry)
+}
@@ -0,0 +1,70 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+func TestLoggerRecover(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	target := &MemoryTarget{
+		Filter: &log.Filter{MaxLevel: log.LevelDebug},
+		ready:  make(chan bool, 0),
+	}
+	logger.SetTarget(target)
+	defer logger.Close()
+
+	func() {
+		defer logger.Recover()
+		panic("boom")
+	}()
+	if len(target.entries) != 1 {
+		t.Fatalf("len(target.entries) = %v, expected %v", len(target.entries), 1)
+	}
+	e := target.entries[0]
+	if e.Level != log.LevelError || e.Message != "panic: boom" {
+		t.Errorf("entry = %v %q, expected Error %q", e.Level, e.Message, "panic: boom")
+	}
+	if !strings.Contains(e.CallStack.String(), "recover_test.go") {
+		t.Errorf("CallStack = %q, expected the panicking function", e.CallStack)
+	}
+
+	// no panic, nothing logged
+	func() {
+		defer logger.Recover()
+	}()
+	if len(target.entries) != 1 {
+		t.Errorf("len(target.entries) = %v, expected %v", len(target.entries), 1)
+	}
+}
+
+func TestLoggerGo(t *testing.T) {
+	logger := log.NewLogger()
+	target := &GateTarget{
+		Filter:   &log.Filter{MaxLevel: log.LevelDebug},
+		received: make(chan string, 10),
+		ready:    make(chan bool, 0),
+	}
+	logger.SetTarget(target)
+	defer logger.Close()
+
+	logger.Go(func() {
+		panic("boom")
+	})
+	select {
+	case msg := <-target.received:
+		if msg != "panic: boom" {
+			t.Errorf("message = %q, expected %q", msg, "panic: boom")
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("the panic was not logged")
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Built-in patterns of sensitive data. When a pattern has a capturing group,
+// only the text of the first group is masked.
+var (
+	// RedactSecret matches the values of passwords, tokens and keys written as "name=value" or "name: value".
+	RedactSecret = regexp.MustCompile(`(?i)\b(?:password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key)"?\s*[=:]\s*("[^"]*"|[^\s,;&"]+)`)
+	// RedactBearerToken matches the tokens of bearer authorization headers.
+	RedactBearerToken = regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`)
+	// RedactCreditCard matches credit card numbers, possibly grouped with spaces or dashes.
+	RedactCreditCard = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+	// RedactEmail matches email addresses.
+	RedactEmail = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+)
+
+// DefaultRedactPatterns are the patterns masked by the redactor returned by NewRedactor.
+var DefaultRedactPatterns = []*regexp.Regexp{RedactSecret, RedactBearerToken, RedactCreditCard, RedactEmail}
+
+// DefaultRedactFields are the names of the fields masked by the redactor returned by NewRedactor.
+var DefaultRedactFields = []string{"password", "passwd", "secret", "token", "authorization", "api_key", "apikey"}
+
+// Redactor masks sensitive data in log messages and fields. Add its Hook to a logger
+// so that the data is masked before the messages reach the targets:
+//
+//	logger.AddHook(log.NewRedactor().Hook)
+type Redactor struct {
+	Fields   []string         // the names of the fields whose values are masked, case-insensitively
+	Patterns []*regexp.Regexp // the patterns masked in messages and in string field values
+	Mask     string           // the text replacing sensitive data
+}
+
+// NewRedactor creates a Redactor.
+// The new Redactor takes these default options:
+// Fields: DefaultRedactFields, Patterns: DefaultRedactPatterns, Mask: "***".
+func NewRedactor() *Redactor {
+	return &Redactor{
+		Fields:   append([]string{}, DefaultRedactFields...),
+		Patterns: append([]*regexp.Regexp{}, DefaultRedactPatterns...),
+		Mask:     "***",
+	}
+}
+
+// Redact returns s with the matches of the patterns masked.
+func (r *Redactor) Redact(s string) string {
+	for _, pattern := range r.Patterns {
+		s = r.mask(pattern, s)
+	}
+	return s
+}
+
+func (r *Redactor) mask(pattern *regexp.Regexp, s string) string {
+	matches := pattern.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+	var buf strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if len(m) > 3 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+		buf.WriteString(s[last:start])
+		buf.WriteString(r.Mask)
+		last = end
+	}
+	buf.WriteString(s[last:])
+	return buf.String()
+}
+
+// Hook masks the sensitive data of an entry. It can be added to a logger with Logger.AddHook.
+func (r *Redactor) Hook(e *Entry) *Entry {
+	e.Message = r.Redact(e.Message)
+	if len(e.Fields) == 0 {
+		return e
+	}
+	fields := make(Fields, len(e.Fields))
+	for name, value := range e.Fields {
+		if r.sensitive(name) {
+			fields[name] = r.Mask
+		} else if v, ok := value.(string); ok {
+			fields[name] = r.Redact(v)
+		} else {
+			fields[name] = value
+		}
+	}
+	e.Fields = fields
+	return e
+}
+
+func (r *Redactor) sensitive(name string) bool {
+	for _, field := range r.Fields {
+		if strings.EqualFold(field, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestRedactorRedact(t *testing.T) {
+	r := log.NewRedactor()
+	tests := []struct {
+		input, expected string
+	}{
+		{"login password=hunter2 ok", "login password=*** ok"},
+		{`config {"api_key": "abc"}`, `config {"api_key": ***}`},
+		{"Authorization: Bearer eyJhbGciOi.x-y", "Authorization: Bearer ***"},
+		{"card 4111 1111 1111 1111 charged", "card *** charged"},
+		{"mail to alice@example.com", "mail to ***"},
+		{"order 12345 shipped", "order 12345 shipped"},
+	}
+	for _, test := range tests {
+		if result := r.Redact(test.input); result != test.expected {
+			t.Errorf("Redact(%q) = %q, expected %q", test.input, result, test.expected)
+		}
+	}
+
+	r.Patterns = append(r.Patterns, regexp.MustCompile(`ssn (\d{3}-\d{2}-\d{4})`))
+	r.Mask = "[redacted]"
+	if result := r.Redact("ssn 123-45-6789"); result != "ssn [redacted]" {
+		t.Errorf("Redact() = %q, expected %q", result, "ssn [redacted]")
+	}
+}
+
+func TestRedactorHook(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	target := &MemoryTarget{
+		Filter: &log.Filter{MaxLevel: log.LevelDebug},
+		ready:  make(chan bool, 0),
+	}
+	logger.SetTarget(target)
+	defer logger.Close()
+	logger.AddHook(log.NewRedactor().Hook)
+
+	fields := log.Fields{"Password": "hunter2", "user": "bob@example.com", "id": 7}
+	logger.WithFields(fields).Info("token=abc")
+
+	e := target.entries[0]
+	if e.Message != "token=***" {
+		t.Errorf("Message = %q, expected %q", e.Message, "token=***")
+	}
+	if e.Fields["Password"] != "***" || e.Fields["user"] != "***" || e.Fields["id"] != 7 {
+		t.Errorf("Fields = %v, expected the password and the email to be masked", e.Fields)
+	}
+	if strings.Contains(e.FormattedMessage, "hunter2") || strings.Contains(e.FormattedMessage, "abc") {
+		t.Errorf("FormattedMessage = %q, expected no sensitive data", e.FormattedMessage)
+	}
+	if fields["Password"] != "hunter2" {
+		t.Errorf("the fields of the logger were modified")
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// RedisMode specifies how RedisTarget stores log messages.
+type RedisMode int
+
+const (
+	// RedisList pushes messages to the head of a list (LPUSH), optionally capped at MaxLen items.
+	RedisList RedisMode = iota
+	// RedisPubSub publishes messages to a channel (PUBLISH).
+	RedisPubSub
+	// RedisStream appends messages to a stream (XADD), optionally capped at approximately MaxLen items.
+	RedisStream
+)
+
+// RedisTarget sends log messages to a Redis server.
+type RedisTarget struct {
+	*Filter
+	// the network and address of the Redis server.
+	Network string
+	Address string
+	// the password used to authenticate. Leave it empty if authentication is not required.
+	Password string
+	// the database selected after connecting.
+	DB int
+	// how the messages are stored: RedisList, RedisPubSub or RedisStream.
+	Mode RedisMode
+	// the key of the list or stream, or the name of the pub/sub channel.
+	Key string
+	// the maximum length of the list or stream. Zero means no limit.
+	MaxLen int64
+	// the timeout for connecting and for each command.
+	Timeout time.Duration
+	// the size of the message channel.
+	BufferSize int
+
+	entries chan *Entry
+	conn    net.Conn
+	reader  *bufio.Reader
+	close   chan bool
+}
+
+// NewRedisTarget creates a RedisTarget.
+// The new RedisTarget takes these default options:
+// MaxLevel: LevelDebug, Network: tcp, Address: localhost:6379, Mode: RedisList, Key: logs,
+// Timeout: 5s, BufferSize: 1024.
+func NewRedisTarget() *RedisTarget {
+	return &RedisTarget{
+		Filter:     &Filter{MaxLevel: LevelDebug},
+		Network:    "tcp",
+		Address:    "localhost:6379",
+		Mode:       RedisList,
+		Key:        "logs",
+		Timeout:    5 * time.Second,
+		BufferSize: 1024,
+		close:      make(chan bool, 0),
+	}
+}
+
+// Open prepares RedisTarget for processing log messages.
+func (t *RedisTarget) Open(errWriter io.Writer) error {
+	t.Filter.Init()
+	if t.Address == "" {
+		return errors.New("RedisTarget.Address must be specified")
+	}
+	if t.Key == "" {
+		return errors.New("RedisTarget.Key must be specified")
+	}
+	if t.Mode < RedisList || t.Mode > RedisStream {
+		return errors.New("RedisTarget.Mode is invalid")
+	}
+	if t.BufferSize < 0 {
+		return errors.New("RedisTarget.BufferSize must be no less than 0")
+	}
+	if t.MaxLen < 0 {
+		return errors.New("RedisTarget.MaxLen must be no less than 0")
+	}
+	t.entries = make(chan *Entry, t.BufferSize)
+	t.conn = nil
+
+	if err := t.connect(); err != nil {
+		return err
+	}
+
+	go t.sendMessages(errWriter)
+
+	return nil
+}
+
+// Process puts filtered log messages into a channel for sending to Redis.
+func (t *RedisTarget) Process(e *Entry) {
+	if e == nil {
+		t.entries <- nil
+		return
+	}
+	if t.Allow(e) {
+		select {
+		case t.entries <- e:
+		default:
+		}
+	}
+}
+
+// Close closes the Redis target.
+func (t *RedisTarget) Close() {
+	<-t.close
+}
+
+func (t *RedisTarget) connect() error {
+	if t.conn != nil {
+		t.conn.Close()
+		t.conn = nil
+	}
+	conn, err := net.DialTimeout(t.Network, t.Address, t.Timeout)
+	if err != nil {
+		return err
+	}
+	t.conn = conn
+	t.reader = bufio.NewReader(conn)
+
+	var commands [][]string
+	if t.Password != "" {
+		commands = append(commands, []string{"AUTH", t.Password})
+	}
+	if t.DB != 0 {
+		commands = append(commands, []string{"SELECT", strconv.Itoa(t.DB)})
+	}
+	if len(commands) > 0 {
+		if err := t.do(commands...); err != nil {
+			t.conn.Close()
+			t.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *RedisTarget) sendMessages(errWriter io.Writer) {
+	for {
+		entry := <-t.entries
+		if entry == nil {
+			if t.conn != nil {
+				t.conn.Close()
+				t.conn = nil
+			}
+			t.close <- true
+			break
+		}
+		if err := t.write(entry); err != nil {
+			fmt.Fprintf(errWriter, "RedisTarget write error: %v\n", err)
+		}
+	}
+}
+
+// write sends the commands for an entry, reconnecting once if the connection is broken.
+func (t *RedisTarget) write(e *Entry) error {
+	commands := t.commands(e)
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if t.conn == nil {
+			if err = t.connect(); err != nil {
+				continue
+			}
+		}
+		if err = t.do(commands...); err == nil {
+			return nil
+		}
+		if _, ok := err.(redisError); ok {
+			return err
+		}
+		t.conn.Close()
+		t.conn = nil
+	}
+	return err
+}
+
+func (t *RedisTarget) commands(e *Entry) [][]string {
+	switch t.Mode {
+	case RedisPubSub:
+		return [][]string{{"PUBLISH", t.Key, e.String()}}
+	case RedisStream:
+		cmd := []string{"XADD", t.Key}
+		if t.MaxLen > 0 {
+			cmd = append(cmd, "MAXLEN", "~", strconv.FormatInt(t.MaxLen, 10))
+		}
+		return [][]string{append(cmd, "*",
+			"level", e.Level.String(),
+			"category", e.Category,
+			"message", e.String(),
+		)}
+	}
+	commands := [][]string{{"LPUSH", t.Key, e.String()}}
+	if t.MaxLen > 0 {
+		commands = append(commands, []string{"LTRIM", t.Key, "0", strconv.FormatInt(t.MaxLen-1, 10)})
+	}
+	return commands
+}
+
+type redisError string
+
+func (e redisError) Error() string {
+	return "Redis error: " + string(e)
+}
+
+// do sends the commands in a single pipeline and reads their replies.
+func (t *RedisTarget) do(commands ...[]string) error {
+	if t.Timeout > 0 {
+		t.conn.SetDeadline(time.Now().Add(t.Timeout))
+	}
+	w := bufio.NewWriter(t.conn)
+	for _, args := range commands {
+		fmt.Fprintf(w, "*%d\r\n", len(args))
+		for _, arg := range args {
+			fmt.Fprintf(w, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	var replyErr error
+	for range commands {
+		if err := readRedisReply(t.reader); err != nil {
+			if _, ok := err.(redisError); !ok {
+				return err
+			}
+			replyErr = err
+		}
+	}
+	return replyErr
+}
+
+// readRedisReply reads and discards a reply, returning a redisError if the server reported an error.
+func readRedisReply(r *bufio.Reader) error {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if len(line) < 3 {
+		return errors.New("invalid Redis reply")
+	}
+	line = line[:len(line)-2]
+	switch line[0] {
+	case '+', ':':
+		return nil
+	case '-':
+		return redisError(line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return err
+		}
+		_, err = io.CopyN(io.Discard, r, int64(n+2))
+		return err
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			if err := readRedisReply(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid Redis reply: %q", line)
+}
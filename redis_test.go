@@ -0,0 +1,99 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestNewRedisTarget(t *testing.T) {
+	target := log.NewRedisTarget()
+	if target.MaxLevel != log.LevelDebug {
+		t.Errorf("NewRedisTarget.MaxLevel = %v, expected %v", target.MaxLevel, log.LevelDebug)
+	}
+	if target.Mode != log.RedisList {
+		t.Errorf("NewRedisTarget.Mode = %v, expected %v", target.Mode, log.RedisList)
+	}
+}
+
+// RedisServer records the commands received by a fake Redis server.
+type RedisServer struct {
+	commands chan []string
+}
+
+func (s *RedisServer) Start(address string) (string, error) {
+	s.commands = make(chan []string, 100)
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return "", err
+	}
+	go func() {
+		conn, err := listener.Accept()
+		listener.Close()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				close(s.commands)
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			args := make([]string, n)
+			for i := range args {
+				r.ReadString('\n')
+				arg, _ := r.ReadString('\n')
+				args[i] = strings.TrimSuffix(arg, "\r\n")
+			}
+			s.commands <- args
+			conn.Write([]byte(":1\r\n"))
+		}
+	}()
+	return listener.Addr().String(), nil
+}
+
+func TestRedisTarget(t *testing.T) {
+	server := &RedisServer{}
+	address, err := server.Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("server.Start(): %v", err)
+	}
+
+	logger := log.NewLogger()
+	logger.Sync()
+	target := log.NewRedisTarget()
+	target.Address = address
+	target.MaxLen = 10
+	target.Categories = []string{"system.*"}
+	logger.SetTarget(target)
+
+	logger.Infof("t1: %v", 2)
+	logger.GetLogger("system.db").Infof("t2: %v", 3)
+
+	logger.Close()
+
+	var commands []string
+	for args := range server.commands {
+		commands = append(commands, strings.Join(args, " "))
+	}
+	if len(commands) != 2 {
+		t.Fatalf("commands = %q, expected LPUSH and LTRIM", commands)
+	}
+	if !strings.HasPrefix(commands[0], "LPUSH logs ") || !strings.Contains(commands[0], "t2: 3") {
+		t.Errorf("commands[0] = %q, expected LPUSH of t2", commands[0])
+	}
+	if commands[1] != "LTRIM logs 0 9" {
+		t.Errorf("commands[1] = %q, expected %q", commands[1], "LTRIM logs 0 9")
+	}
+}
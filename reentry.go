@@ -0,0 +1,64 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync/atomic"
+)
+
+// the names of the functions calling the Process methods of the targets
+var targetCallers = map[string]bool{
+	runtime.FuncForPC(reflect.ValueOf((*targetWorker).handle).Pointer()).Name():      true,
+	runtime.FuncForPC(reflect.ValueOf((*targetWorker).handleBatch).Pointer()).Name(): true,
+}
+
+// inTarget returns whether the calling goroutine is running the Process method of a target.
+// It walks the call stack, so it is only called when a target may be processing messages.
+func inTarget() bool {
+	pcs := make([]uintptr, 64)
+	for {
+		n := runtime.Callers(2, pcs)
+		if n < len(pcs) {
+			pcs = pcs[:n]
+			break
+		}
+		pcs = make([]uintptr, len(pcs)*2)
+	}
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		if targetCallers[frame.Function] {
+			return true
+		}
+		if !more {
+			return false
+		}
+	}
+}
+
+// reentrant returns whether a message is logged by a target while it processes a message in synchronous mode.
+// Dispatching the message would call the target again before it is done, which could recurse endlessly or
+// deadlock on the locks of the target. In asynchronous mode, such a message is queued like the others.
+func (l *coreLogger) reentrant(c *loggerConfig) bool {
+	return c.syncMode && atomic.LoadInt32(&l.processing) > 0 && inTarget()
+}
+
+// writeReentrant writes a message logged by a target while it processes a message to ErrorWriter.
+func (l *coreLogger) writeReentrant(entry *Entry) {
+	fmt.Fprintf(l.ErrorWriter, "%v (logged by a target while processing a message)\n", entry.FormattedMessage)
+}
+
+// blockedByTarget returns whether an operation waiting for the targets is called by a target while it processes
+// a message, which would wait for itself, and reports it to ErrorWriter.
+func (l *coreLogger) blockedByTarget(operation string) bool {
+	if !inTarget() {
+		return false
+	}
+	fmt.Fprintf(l.ErrorWriter, "%v is not possible from a target processing a message\n", operation)
+	return true
+}
@@ -0,0 +1,137 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+// loggingTarget logs a message with its logger while processing the first message it receives.
+type loggingTarget struct {
+	*log.Filter
+	logger   *log.Logger
+	do       func(*log.Logger)
+	messages []string
+	close    chan bool
+}
+
+func (t *loggingTarget) Open(io.Writer) error {
+	return nil
+}
+
+func (t *loggingTarget) Process(e *log.Entry) {
+	if e == nil {
+		t.close <- true
+		return
+	}
+	t.messages = append(t.messages, e.Message)
+	if len(t.messages) == 1 {
+		t.do(t.logger)
+	}
+}
+
+func (t *loggingTarget) Close() {
+	<-t.close
+}
+
+func TestReentrantSync(t *testing.T) {
+	var errors bytes.Buffer
+	logger := log.NewLogger()
+	logger.ErrorWriter = &errors
+	logger.Sync()
+	target := &loggingTarget{
+		Filter: &log.Filter{MaxLevel: log.LevelDebug},
+		logger: logger,
+		do: func(l *log.Logger) {
+			l.Warn("warning of the target")
+			l.SyncLog(log.LevelWarn, "synchronous warning of the target")
+		},
+		close: make(chan bool),
+	}
+	logger.SetTarget(target)
+	logger.Info("m1")
+	logger.Info("m2")
+	logger.Close()
+
+	if strings.Join(target.messages, ",") != "m1,m2" {
+		t.Errorf("messages = %v, expected the target not to be called again", target.messages)
+	}
+	if !strings.Contains(errors.String(), "warning of the target (logged by a target while processing a message)") {
+		t.Errorf("ErrorWriter = %q, expected the warning of the target", errors.String())
+	}
+}
+
+func TestReentrantAsync(t *testing.T) {
+	var errors bytes.Buffer
+	logger := log.NewLogger()
+	logger.ErrorWriter = &errors
+	target := &loggingTarget{
+		Filter: &log.Filter{MaxLevel: log.LevelDebug},
+		logger: logger,
+		do: func(l *log.Logger) {
+			l.Warn("warning of the target")
+			l.Barrier()
+			l.SetTarget(log.NewConsoleTarget())
+			l.Close()
+		},
+		close: make(chan bool),
+	}
+	logger.SetTarget(target)
+	logger.Info("m1")
+	logger.Barrier()
+	logger.Close()
+
+	// the messages of the target are queued like the others
+	if strings.Join(target.messages, ",") != "m1,warning of the target" {
+		t.Errorf("messages = %v, expected the warning of the target to be processed", target.messages)
+	}
+	for _, operation := range []string{"Waiting for the messages to be processed", "Logger.SetTarget", "Logger.Shutdown"} {
+		if !strings.Contains(errors.String(), operation+" is not possible from a target processing a message") {
+			t.Errorf("ErrorWriter = %q, expected %v to be refused", errors.String(), operation)
+		}
+	}
+}
+
+func TestReentrantQueueFull(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Close()
+	logger.BufferSize = 2
+	logger.TargetBuffer = 0
+	logger.MaxGoroutines = 0
+	target := &loggingTarget{
+		Filter: &log.Filter{MaxLevel: log.LevelDebug},
+		logger: logger,
+		do: func(l *log.Logger) {
+			// the queues are full while the target logs
+			for i := 0; i < 50; i++ {
+				l.Warn("warning of the target")
+			}
+		},
+		close: make(chan bool),
+	}
+	logger.SetTarget(target)
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 10; i++ {
+			logger.Info("m")
+		}
+		logger.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the logger is deadlocked by the messages of the target")
+	}
+	if len(target.messages) != 60 {
+		t.Errorf("len(messages) = %v, expected 60", len(target.messages))
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"strings"
+	"sync"
+)
+
+var registry = struct {
+	sync.Mutex
+	loggers map[string]*Logger
+}{loggers: map[string]*Logger{}}
+
+// GetLoggerByName returns the logger of the given name, creating it on first use, so that
+// different packages can share loggers without passing them around. It is safe for concurrent use.
+//
+// Names are dot-separated categories. A new logger inherits the formatter and fields of its
+// nearest parent, e.g. "app.db.query" inherits from "app.db", and the top-level loggers from
+// the default logger. Levels are inherited through SetCategoryLevel:
+//
+//	log.Default().SetCategoryLevel("app.db", log.LevelWarn)
+//	log.GetLoggerByName("app.db.query").Info("...") // discarded
+//
+// The loggers share the default logger at the time they are created.
+func GetLoggerByName(name string) *Logger {
+	registry.Lock()
+	defer registry.Unlock()
+	return getLoggerByName(name)
+}
+
+// getLoggerByName must be called with the registry locked.
+func getLoggerByName(name string) *Logger {
+	if name == "" {
+		return Default()
+	}
+	if logger, ok := registry.loggers[name]; ok {
+		return logger
+	}
+	parent := Default()
+	if i := strings.LastIndexByte(name, '.'); i > 0 {
+		parent = getLoggerByName(name[:i])
+	}
+	logger := parent.clone()
+	logger.Category = name
+	registry.loggers[name] = logger
+	return logger
+}
+
+// namedCategories returns the names of the registered loggers sharing the given core.
+func namedCategories(core *coreLogger) []string {
+	registry.Lock()
+	defer registry.Unlock()
+	var names []string
+	for name, logger := range registry.loggers {
+		if logger.coreLogger == core {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,101 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestGetLoggerByName(t *testing.T) {
+	var wg sync.WaitGroup
+	loggers := make([]*log.Logger, 10)
+	for i := range loggers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			loggers[i] = log.GetLoggerByName("reg.db.query")
+		}(i)
+	}
+	wg.Wait()
+	for _, l := range loggers {
+		if l != loggers[0] {
+			t.Fatalf("GetLoggerByName() returned different loggers for the same name")
+		}
+	}
+	if loggers[0].Category != "reg.db.query" {
+		t.Errorf("Category = %v, expected %v", loggers[0].Category, "reg.db.query")
+	}
+	if log.GetLoggerByName("") != log.Default() {
+		t.Errorf("GetLoggerByName(\"\") should return the default logger")
+	}
+
+	// a child created after its parent inherits the parent formatter
+	parent := log.GetLoggerByName("reg2")
+	parent.SetFormatter(log.JSONFormatter)
+	child := log.GetLoggerByName("reg2.child")
+	e := &log.Entry{Message: "m"}
+	if child.Formatter(child, e)[0] != '{' {
+		t.Errorf("the formatter of reg2.child was not inherited from reg2")
+	}
+}
+
+func TestCategoryLevel(t *testing.T) {
+	logger := log.NewLogger()
+	logger.SetMaxLevel(log.LevelInfo)
+	logger.SetCategoryLevel("app.db", log.LevelWarn)
+	logger.SetCategoryLevel("app.db.trace", log.LevelDebug)
+	defer logger.Close()
+
+	tests := []struct {
+		category string
+		level    log.Level
+		expected bool
+	}{
+		{"app", log.LevelInfo, true},
+		{"app", log.LevelDebug, false},
+		{"app.db", log.LevelInfo, false},
+		{"app.db.query", log.LevelInfo, false},
+		{"app.db.query", log.LevelWarn, true},
+		{"app.dbx", log.LevelInfo, true},
+		{"app.db.trace.sql", log.LevelDebug, true},
+	}
+	for _, test := range tests {
+		if enabled := logger.GetLogger(test.category).Enabled(test.level); enabled != test.expected {
+			t.Errorf("%v.Enabled(%v) = %v, expected %v", test.category, test.level, enabled, test.expected)
+		}
+	}
+}
+
+func TestLevelTree(t *testing.T) {
+	logger := log.NewLogger()
+	defer logger.Close()
+	logger.SetMaxLevel(log.LevelInfo)
+	logger.SetCategoryLevel("app.db", log.LevelWarn)
+	logger.SetCategoryLevel("app.db.query", log.LevelError)
+	logger.SetCategoryLevel("web", log.LevelDebug)
+	logger.UnsetCategoryLevel("app.db.query")
+
+	if level := logger.EffectiveLevel("app.db.query"); level != log.LevelWarn {
+		t.Errorf("EffectiveLevel() = %v, expected %v", level, log.LevelWarn)
+	}
+	expected := "(root) Info\napp.db Warn\nweb Debug\n"
+	if tree := logger.LevelTree(); tree != expected {
+		t.Errorf("LevelTree() = %q, expected %q", tree, expected)
+	}
+
+	log.GetLoggerByName("tree.a.b")
+	log.Default().SetCategoryLevel("tree.a", log.LevelError)
+	defer log.Default().UnsetCategoryLevel("tree.a")
+	tree := log.Default().LevelTree()
+	for _, line := range []string{"tree Debug (from root)\n", "tree.a Error\n", "tree.a.b Error (from tree.a)\n"} {
+		if !strings.Contains(tree, line) {
+			t.Errorf("LevelTree() = %q, expected the line %q", tree, line)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import "sync/atomic"
+
+// Replay logs an entry recorded earlier, e.g. parsed from a log file by package logread, so that it is handed
+// to the targets again, for example to backfill a store from the files. The entry keeps its time, unless it is
+// zero, as well as its level, category, message, fields, trace, error, call stack and data. It gets a new
+// sequence number, is filtered by the level of its category and the hooks, and is formatted by the logger.
+// A fatal entry does not trigger the fatal action. The entry is not modified.
+func (l *Logger) Replay(e *Entry) {
+	c := l.current()
+	if e == nil || !c.open || e.Level > c.levelOf(e.Category) {
+		return
+	}
+	entry := e.Clone()
+	entry.FormattedMessage = ""
+	entry.control = nil
+	if entry.Time.IsZero() {
+		entry.Time = l.now()
+	}
+	entry.Seq = atomic.AddUint64(&l.seq, 1)
+	l.dispatch(entry)
+}
@@ -0,0 +1,40 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+func TestLoggerReplay(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	logger.SetCategoryLevel("app.db", log.LevelWarn)
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	recorded := time.Date(2015, 10, 22, 8, 39, 28, 0, time.UTC)
+	e := &log.Entry{Level: log.LevelFatal, Category: "batch", Message: "crashed", Time: recorded, Seq: 42, FormattedMessage: "old"}
+	logger.Replay(e)
+	logger.Replay(&log.Entry{Level: log.LevelInfo, Category: "app.db", Message: "filtered"})
+	logger.Replay(&log.Entry{Level: log.LevelInfo, Category: "app", Message: "now"})
+	logger.Close()
+
+	if len(target.entries) != 2 {
+		t.Fatalf("len(target.entries) = %v, expected %v", len(target.entries), 2)
+	}
+	r := target.entries[0]
+	if !r.Time.Equal(recorded) || r.Category != "batch" || r.Level != log.LevelFatal || r.Seq != 1 || r.String() != "2015-10-22 08:39:28|Fatal|batch|crashed" {
+		t.Errorf("entries[0] = %v %v %v %v %q, expected the original time and a new sequence number", r.Time, r.Category, r.Level, r.Seq, r.String())
+	}
+	if e.Seq != 42 || e.FormattedMessage != "old" {
+		t.Errorf("the replayed entry was modified: %v %q", e.Seq, e.FormattedMessage)
+	}
+	if r := target.entries[1]; r.Time.IsZero() || r.Message != "now" {
+		t.Errorf("entries[1] = %v %q, expected the current time", r.Time, r.Message)
+	}
+}
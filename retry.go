@@ -0,0 +1,148 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// EntryWriter is implemented by the targets which can write a message synchronously and report the failure.
+// WriteEntry writes the message whatever the filter of the target.
+type EntryWriter interface {
+	WriteEntry(e *Entry) error
+}
+
+// RetryTarget writes log messages to a target implementing EntryWriter, retrying the failed writes
+// with exponential backoff. A message which still fails is handed to the Fallback target, e.g. a local file,
+// so that it is not lost. Once a message has exhausted its retries, the next messages are tried only once
+// until a write succeeds again, so that a target which is down does not delay the logging.
+type RetryTarget struct {
+	*Filter
+	// the target the messages are written to. It must implement EntryWriter.
+	Target Target
+	// the target the messages are handed to when they cannot be written. Nil means they are reported to the ErrorWriter.
+	Fallback Target
+	// how many times a failed write is retried.
+	MaxRetries int
+	// the wait before the first retry. It doubles after each retry, up to MaxRetryWait.
+	RetryWait    time.Duration
+	MaxRetryWait time.Duration
+	// the size of the message channel. Process waits when it is full, so that no message is dropped.
+	BufferSize int
+
+	writer  EntryWriter
+	down    bool
+	entries chan *Entry
+	close   chan bool
+}
+
+// NewRetryTarget creates a RetryTarget writing to target and falling back to fallback.
+// The new RetryTarget takes these default options:
+// MaxLevel: LevelDebug, MaxRetries: 3, RetryWait: 100ms, MaxRetryWait: 10s, BufferSize: 1024.
+func NewRetryTarget(target Target, fallback Target) *RetryTarget {
+	return &RetryTarget{
+		Filter:       &Filter{MaxLevel: LevelDebug},
+		Target:       target,
+		Fallback:     fallback,
+		MaxRetries:   3,
+		RetryWait:    100 * time.Millisecond,
+		MaxRetryWait: 10 * time.Second,
+		BufferSize:   1024,
+		close:        make(chan bool, 0),
+	}
+}
+
+// Open opens the target and the fallback target.
+func (t *RetryTarget) Open(errWriter io.Writer) error {
+	t.Filter.Init()
+	writer, ok := t.Target.(EntryWriter)
+	if !ok {
+		return errors.New("RetryTarget.Target must implement EntryWriter")
+	}
+	if t.MaxRetries < 0 {
+		return errors.New("RetryTarget.MaxRetries must be no less than 0")
+	}
+	if t.BufferSize < 0 {
+		return errors.New("RetryTarget.BufferSize must be no less than 0")
+	}
+	if err := t.Target.Open(errWriter); err != nil {
+		return err
+	}
+	if t.Fallback != nil {
+		if err := t.Fallback.Open(errWriter); err != nil {
+			go t.Target.Process(nil)
+			t.Target.Close()
+			return err
+		}
+	}
+	t.writer = writer
+	t.down = false
+	t.entries = make(chan *Entry, t.BufferSize)
+
+	go t.writeMessages(errWriter)
+
+	return nil
+}
+
+// Process puts filtered log messages into a channel for writing.
+func (t *RetryTarget) Process(e *Entry) {
+	if e == nil || t.Allow(e) {
+		t.entries <- e
+	}
+}
+
+// Close closes the target and the fallback target once the queued messages are written.
+func (t *RetryTarget) Close() {
+	<-t.close
+	for _, target := range []Target{t.Target, t.Fallback} {
+		if target != nil {
+			// a target may wait in Process(nil) until it is closed, as it does with the logger
+			go target.Process(nil)
+			target.Close()
+		}
+	}
+}
+
+func (t *RetryTarget) writeMessages(errWriter io.Writer) {
+	for {
+		entry := <-t.entries
+		if entry == nil {
+			t.close <- true
+			return
+		}
+		err := t.write(entry)
+		if err == nil {
+			continue
+		}
+		if t.Fallback != nil {
+			t.Fallback.Process(entry)
+		} else {
+			fmt.Fprintf(errWriter, "RetryTarget write error: %v (message dropped: %v)\n", err, entry.String())
+		}
+	}
+}
+
+// write writes a message, retrying unless the target is down.
+func (t *RetryTarget) write(e *Entry) error {
+	wait := t.RetryWait
+	for attempt := 0; ; attempt++ {
+		err := t.writer.WriteEntry(e)
+		if err == nil {
+			t.down = false
+			return nil
+		}
+		if t.down || attempt >= t.MaxRetries {
+			t.down = true
+			return err
+		}
+		time.Sleep(wait)
+		if wait *= 2; t.MaxRetryWait > 0 && wait > t.MaxRetryWait {
+			wait = t.MaxRetryWait
+		}
+	}
+}
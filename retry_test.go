@@ -0,0 +1,103 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+// flakyTarget fails to write the messages until failures reaches 0.
+type flakyTarget struct {
+	*log.Filter
+	failures int
+	attempts int
+	written  []string
+	closed   bool
+}
+
+func (t *flakyTarget) Open(io.Writer) error {
+	return nil
+}
+
+func (t *flakyTarget) Process(e *log.Entry) {
+}
+
+func (t *flakyTarget) Close() {
+	t.closed = true
+}
+
+func (t *flakyTarget) WriteEntry(e *log.Entry) error {
+	t.attempts++
+	if t.failures != 0 {
+		t.failures--
+		return errors.New("unavailable")
+	}
+	t.written = append(t.written, e.Message)
+	return nil
+}
+
+func TestRetryTarget(t *testing.T) {
+	target := &flakyTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, failures: 2}
+	fallback := &bytes.Buffer{}
+	retry := log.NewRetryTarget(target, log.NewWriterTarget(fallback))
+	retry.RetryWait = time.Millisecond
+	retry.MaxRetries = 2
+	if err := retry.Open(&bytes.Buffer{}); err != nil {
+		t.Fatalf("Open(): %v", err)
+	}
+
+	// t1 succeeds at the last retry
+	retry.Process(&log.Entry{Level: log.LevelInfo, Message: "t1", FormattedMessage: "t1"})
+	retry.Process(nil)
+	retry.Close()
+	if target.attempts != 3 || len(target.written) != 1 || !target.closed {
+		t.Errorf("attempts = %v, written = %v, closed = %v, expected 3, [t1], true", target.attempts, target.written, target.closed)
+	}
+
+	// t2 exhausts its retries and goes to the fallback, then t3 is tried once while the target is down
+	target.failures, target.attempts = 4, 0
+	if err := retry.Open(&bytes.Buffer{}); err != nil {
+		t.Fatalf("Open(): %v", err)
+	}
+	retry.Process(&log.Entry{Level: log.LevelInfo, Message: "t2", FormattedMessage: "t2"})
+	retry.Process(&log.Entry{Level: log.LevelInfo, Message: "t3", FormattedMessage: "t3"})
+	retry.Process(&log.Entry{Level: log.LevelInfo, Message: "t4", FormattedMessage: "t4"})
+	retry.Process(nil)
+	retry.Close()
+	if target.attempts != 5 || len(target.written) != 2 || target.written[1] != "t4" {
+		t.Errorf("attempts = %v, written = %v, expected 5, [t1 t4]", target.attempts, target.written)
+	}
+	if s := fallback.String(); s != "t2\nt3\n" {
+		t.Errorf("fallback = %q, expected %q", s, "t2\nt3\n")
+	}
+}
+
+func TestRetryTargetWithoutFallback(t *testing.T) {
+	target := &flakyTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, failures: -1}
+	retry := log.NewRetryTarget(target, nil)
+	retry.MaxRetries = 0
+	errWriter := &bytes.Buffer{}
+	if err := retry.Open(errWriter); err != nil {
+		t.Fatalf("Open(): %v", err)
+	}
+	retry.Process(&log.Entry{Level: log.LevelInfo, Message: "t1", FormattedMessage: "t1"})
+	retry.Process(nil)
+	retry.Close()
+	if s := errWriter.String(); !strings.Contains(s, "RetryTarget write error: unavailable (message dropped: t1)") {
+		t.Errorf("error output = %q, expected the dropped message", s)
+	}
+
+	retry = log.NewRetryTarget(log.NewConsoleTarget(), nil)
+	if err := retry.Open(errWriter); err == nil {
+		t.Errorf("Open() = nil, expected an error for a target which is not an EntryWriter")
+	}
+}
@@ -0,0 +1,436 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Condition tells whether a log message meets some requirements.
+type Condition func(*Entry) bool
+
+// Route sends the messages meeting a condition to some targets of a RouterTarget.
+type Route struct {
+	// the condition of the messages, as parsed by ParseCondition, e.g. `level >= Error AND category matches "payment.*"`.
+	// An empty condition is met by every message.
+	When string `json:"when"`
+	// the condition of the messages given in code, which is used instead of When if it is not nil.
+	Match Condition `json:"-"`
+	// the names of the targets the messages are sent to.
+	Targets []string `json:"targets"`
+}
+
+// RouterTarget sends each log message to the named child targets chosen by its routes: a message is sent
+// to the targets of every route it matches, or to the Default targets if it matches none. It replaces the
+// filters of the children for complex topologies:
+//
+//	router := log.NewRouterTarget()
+//	router.Targets = map[string]log.Target{"sentry": sentryTarget, "file": fileTarget}
+//	router.Routes = []log.Route{
+//		{When: `level >= Error AND category matches "payment.*"`, Targets: []string{"sentry", "file"}},
+//	}
+//	router.Default = []string{"file"}
+//
+// Like TeeTarget, each child gets its own queue and goroutine.
+type RouterTarget struct {
+	*Filter
+	Targets map[string]Target // the child targets by name
+	Routes  []Route           // the routes, which are all evaluated
+	Default []string          // the names of the targets of the messages matching no route
+	// the size of the queue of each child.
+	BufferSize int
+	// whether to wait when the queue of a child is full. If false, the message is dropped for that child.
+	Block bool
+
+	routes   []route
+	defaults []int
+	tee      *TeeTarget
+}
+
+// route is a Route whose targets are replaced by their indexes among the open children.
+type route struct {
+	match   Condition
+	targets []int
+}
+
+// NewRouterTarget creates a RouterTarget.
+// The new RouterTarget takes these default options:
+// MaxLevel: LevelDebug, BufferSize: 1024, Block: false
+// You must specify the Targets and the Routes fields.
+func NewRouterTarget() *RouterTarget {
+	return &RouterTarget{
+		Filter:     &Filter{MaxLevel: LevelDebug},
+		BufferSize: 1024,
+	}
+}
+
+// Open parses the routes and opens the child targets.
+// A child which fails to open is removed from the routes.
+func (t *RouterTarget) Open(errWriter io.Writer) error {
+	t.Filter.Init()
+	names := make([]string, 0, len(t.Targets))
+	for name := range t.Targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	check := func(targets []string) error {
+		for _, name := range targets {
+			if t.Targets[name] == nil {
+				return fmt.Errorf("RouterTarget has no target named %q", name)
+			}
+		}
+		return nil
+	}
+	matches := make([]Condition, len(t.Routes))
+	for i, r := range t.Routes {
+		if err := check(r.Targets); err != nil {
+			return err
+		}
+		if matches[i] = r.Match; matches[i] == nil {
+			var err error
+			if matches[i], err = ParseCondition(r.When); err != nil {
+				return fmt.Errorf("RouterTarget.Routes[%v]: %v", i, err)
+			}
+		}
+	}
+	if err := check(t.Default); err != nil {
+		return err
+	}
+
+	children := make([]Target, len(names))
+	for i, name := range names {
+		children[i] = t.Targets[name]
+	}
+	t.tee = &TeeTarget{
+		Filter:     t.Filter,
+		Targets:    children,
+		BufferSize: t.BufferSize,
+		Block:      t.Block,
+		close:      make(chan bool, 0),
+	}
+	if err := t.tee.Open(errWriter); err != nil {
+		return err
+	}
+	// the children which failed to open are no longer among those of the tee
+	indexes := map[string]int{}
+	for i, name := range names {
+		for j, child := range t.tee.Targets {
+			if child == children[i] {
+				indexes[name] = j
+			}
+		}
+	}
+	lookup := func(targets []string) []int {
+		var found []int
+		for _, name := range targets {
+			if i, ok := indexes[name]; ok {
+				found = append(found, i)
+			}
+		}
+		return found
+	}
+	t.routes = make([]route, len(t.Routes))
+	for i, r := range t.Routes {
+		t.routes[i] = route{match: matches[i], targets: lookup(r.Targets)}
+	}
+	t.defaults = lookup(t.Default)
+	return nil
+}
+
+// Process sends a filtered log message to the targets of the routes it matches.
+func (t *RouterTarget) Process(e *Entry) {
+	if e == nil {
+		t.tee.Process(nil)
+		return
+	}
+	if !t.Allow(e) {
+		return
+	}
+	to := make([]bool, len(t.tee.queues))
+	matched, selected := false, false
+	for _, r := range t.routes {
+		if r.match(e) {
+			matched = true
+			for _, i := range r.targets {
+				to[i], selected = true, true
+			}
+		}
+	}
+	if !matched {
+		for _, i := range t.defaults {
+			to[i], selected = true, true
+		}
+	}
+	if selected {
+		t.tee.send(e, to)
+	}
+}
+
+// Close closes the child targets once they have processed their queued messages.
+func (t *RouterTarget) Close() {
+	t.tee.Close()
+}
+
+// ParseCondition parses a condition on log messages made of comparisons combined with AND, OR, NOT and
+// parentheses, e.g. `level >= Error AND (category matches "payment.*" OR fields.amount > 1000)`.
+// The comparisons compare these operands to a value, which is quoted if it contains spaces or symbols:
+//
+//	level      with =, !=, >, >=, < or <=. A level is greater than another when it is more severe.
+//	category   with = or !=, matches for a pattern as used by path.Match, contains for a substring,
+//	message    or =~ for a regular expression
+//	fields.x   the value of the field x formatted by fmt.Sprint, with the operators of category
+//	           and with >, >=, < or <= when both the value and the field are numbers.
+//
+// The keywords and the operands are case-insensitive. &&, || and ! may be used instead of AND, OR and NOT.
+// An empty condition is met by every message.
+func ParseCondition(expr string) (Condition, error) {
+	tokens, err := tokenizeCondition(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return func(*Entry) bool { return true }, nil
+	}
+	p := &conditionParser{tokens: tokens}
+	c, err := p.or()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition %q: %v", expr, err)
+	}
+	return c, nil
+}
+
+// conditionToken is a word, a quoted string or a symbol of a condition.
+type conditionToken struct {
+	text   string
+	quoted bool
+}
+
+func tokenizeCondition(expr string) ([]conditionToken, error) {
+	var tokens []conditionToken
+	for s := strings.TrimSpace(expr); s != ""; s = strings.TrimSpace(s) {
+		switch {
+		case s[0] == '"' || s[0] == '`':
+			quoted, err := strconv.QuotedPrefix(s)
+			if err != nil {
+				return nil, errors.New("unterminated string " + s)
+			}
+			text, _ := strconv.Unquote(quoted)
+			tokens = append(tokens, conditionToken{text: text, quoted: true})
+			s = s[len(quoted):]
+		case strings.IndexByte("()", s[0]) >= 0:
+			tokens = append(tokens, conditionToken{text: s[:1]})
+			s = s[1:]
+		case strings.IndexByte("=!<>&|", s[0]) >= 0:
+			n := 1
+			if len(s) > 1 && strings.IndexByte("=~&|", s[1]) >= 0 {
+				n = 2
+			}
+			tokens = append(tokens, conditionToken{text: s[:n]})
+			s = s[n:]
+		default:
+			n := strings.IndexAny(s, " \t\r\n()=!<>&|\"`")
+			if n < 0 {
+				n = len(s)
+			}
+			tokens = append(tokens, conditionToken{text: s[:n]})
+			s = s[n:]
+		}
+	}
+	return tokens, nil
+}
+
+// conditionParser parses the tokens of a condition by recursive descent.
+type conditionParser struct {
+	tokens []conditionToken
+	pos    int
+}
+
+// accept consumes the next token if it is one of the given keywords or symbols.
+func (p *conditionParser) accept(words ...string) bool {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].quoted {
+		return false
+	}
+	for _, word := range words {
+		if strings.EqualFold(p.tokens[p.pos].text, word) {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *conditionParser) next() (conditionToken, error) {
+	if p.pos >= len(p.tokens) {
+		return conditionToken{}, errors.New("unexpected end")
+	}
+	p.pos++
+	return p.tokens[p.pos-1], nil
+}
+
+func (p *conditionParser) or() (Condition, error) {
+	c, err := p.and()
+	for err == nil && p.accept("OR", "||") {
+		var right Condition
+		if right, err = p.and(); err == nil {
+			left := c
+			c = func(e *Entry) bool { return left(e) || right(e) }
+		}
+	}
+	return c, err
+}
+
+func (p *conditionParser) and() (Condition, error) {
+	c, err := p.not()
+	for err == nil && p.accept("AND", "&&") {
+		var right Condition
+		if right, err = p.not(); err == nil {
+			left := c
+			c = func(e *Entry) bool { return left(e) && right(e) }
+		}
+	}
+	return c, err
+}
+
+func (p *conditionParser) not() (Condition, error) {
+	if p.accept("NOT", "!") {
+		c, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return func(e *Entry) bool { return !c(e) }, nil
+	}
+	if p.accept("(") {
+		c, err := p.or()
+		if err == nil && !p.accept(")") {
+			err = errors.New("missing )")
+		}
+		return c, err
+	}
+	return p.comparison()
+}
+
+func (p *conditionParser) comparison() (Condition, error) {
+	operand, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	op, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	value, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if operand.quoted || op.quoted {
+		return nil, fmt.Errorf("unexpected %q", operand.text)
+	}
+	name := strings.ToLower(operand.text)
+	if name == "level" {
+		return levelCondition(strings.ToLower(op.text), value.text)
+	}
+	var get func(e *Entry) (string, bool)
+	switch {
+	case name == "category":
+		get = func(e *Entry) (string, bool) { return e.Category, true }
+	case name == "message":
+		get = func(e *Entry) (string, bool) { return e.Message, true }
+	case strings.HasPrefix(name, "fields.") && len(name) > len("fields."):
+		field := operand.text[len("fields."):]
+		get = func(e *Entry) (string, bool) {
+			if v, ok := e.Fields[field]; ok {
+				return fmt.Sprint(v), true
+			}
+			return "", false
+		}
+	default:
+		return nil, fmt.Errorf("unknown operand %q", operand.text)
+	}
+	return stringCondition(get, strings.ToLower(op.text), value.text)
+}
+
+func levelCondition(op, value string) (Condition, error) {
+	var level Level
+	if err := level.Set(value); err != nil {
+		return nil, err
+	}
+	// a smaller level is more severe
+	switch op {
+	case "=", "==":
+		return func(e *Entry) bool { return e.Level == level }, nil
+	case "!=":
+		return func(e *Entry) bool { return e.Level != level }, nil
+	case ">":
+		return func(e *Entry) bool { return e.Level < level }, nil
+	case ">=":
+		return func(e *Entry) bool { return e.Level <= level }, nil
+	case "<":
+		return func(e *Entry) bool { return e.Level > level }, nil
+	case "<=":
+		return func(e *Entry) bool { return e.Level >= level }, nil
+	}
+	return nil, fmt.Errorf("unknown operator %q for level", op)
+}
+
+func stringCondition(get func(e *Entry) (string, bool), op, value string) (Condition, error) {
+	switch op {
+	case "=", "==":
+		return func(e *Entry) bool { s, ok := get(e); return ok && s == value }, nil
+	case "!=":
+		return func(e *Entry) bool { s, ok := get(e); return !ok || s != value }, nil
+	case "contains":
+		return func(e *Entry) bool { s, ok := get(e); return ok && strings.Contains(s, value) }, nil
+	case "matches":
+		if _, err := path.Match(value, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q", value)
+		}
+		return func(e *Entry) bool {
+			s, ok := get(e)
+			matched, _ := path.Match(value, s)
+			return ok && matched
+		}, nil
+	case "=~":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, err
+		}
+		return func(e *Entry) bool { s, ok := get(e); return ok && re.MatchString(s) }, nil
+	case ">", ">=", "<", "<=":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", value)
+		}
+		return func(e *Entry) bool {
+			s, ok := get(e)
+			if !ok {
+				return false
+			}
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return false
+			}
+			switch op {
+			case ">":
+				return v > n
+			case ">=":
+				return v >= n
+			case "<":
+				return v < n
+			}
+			return v <= n
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown operator %q", op)
+}
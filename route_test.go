@@ -0,0 +1,100 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestParseCondition(t *testing.T) {
+	e := &log.Entry{
+		Level:    log.LevelError,
+		Category: "payment.card",
+		Message:  "card declined: insufficient funds",
+		Fields:   log.Fields{"amount": 1500, "user": "alice"},
+	}
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{``, true},
+		{`level >= Error`, true},
+		{`level > error`, false},
+		{`level <= warn`, false},
+		{`level != Info && level = Error`, true},
+		{`category matches "payment.*"`, true},
+		{`category = payment`, false},
+		{`level >= Error AND category matches "payment.*"`, true},
+		{`level >= Fatal OR category contains card`, true},
+		{`NOT (level >= Fatal OR category contains card)`, false},
+		{`!(category = "app") and message =~ "^card .*funds$"`, true},
+		{`fields.amount > 1000 AND fields.user = alice`, true},
+		{`fields.amount < 1000`, false},
+		{`fields.user > 1000`, false},
+		{`fields.missing != x`, true},
+		{`fields.missing = x or level = 1`, true},
+	}
+	for _, test := range tests {
+		c, err := log.ParseCondition(test.expr)
+		if err != nil {
+			t.Errorf("ParseCondition(%q) = %v, expected no error", test.expr, err)
+			continue
+		}
+		if c(e) != test.expected {
+			t.Errorf("ParseCondition(%q)(e) = %v, expected %v", test.expr, !test.expected, test.expected)
+		}
+	}
+
+	for _, expr := range []string{`level >=`, `level >= loud`, `level matches x`, `size > 1`, `(level = Error`,
+		`level = Error category = x`, `message =~ "("`, `fields.amount > lots`, `message = "unterminated`} {
+		if _, err := log.ParseCondition(expr); err == nil {
+			t.Errorf("ParseCondition(%q) = nil error, expected an error", expr)
+		}
+	}
+}
+
+func TestRouterTarget(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	alerts := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	file := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	audit := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	router := log.NewRouterTarget()
+	router.Targets = map[string]log.Target{"alerts": alerts, "file": file, "audit": audit}
+	router.Routes = []log.Route{
+		{When: `level >= Error AND category matches "payment.*"`, Targets: []string{"alerts", "file"}},
+		{Match: func(e *log.Entry) bool { return e.Category == "audit" }, Targets: []string{"audit"}},
+	}
+	router.Default = []string{"file"}
+	logger.SetTarget(router)
+
+	logger.GetLogger("payment.card").Error("declined")
+	logger.GetLogger("payment.card").Info("charged")
+	logger.GetLogger("audit").Info("login")
+	logger.Close()
+
+	if len(alerts.entries) != 1 || alerts.entries[0].Message != "declined" {
+		t.Errorf("len(alerts.entries) = %v, expected only declined", len(alerts.entries))
+	}
+	if len(file.entries) != 2 || file.entries[0].Message != "declined" || file.entries[1].Message != "charged" {
+		t.Errorf("len(file.entries) = %v, expected declined and charged", len(file.entries))
+	}
+	if len(audit.entries) != 1 || audit.entries[0].Message != "login" {
+		t.Errorf("len(audit.entries) = %v, expected only login", len(audit.entries))
+	}
+	if alerts.entries[0] == file.entries[0] {
+		t.Errorf("the targets share an entry, expected each its own copy")
+	}
+
+	router = log.NewRouterTarget()
+	router.Targets = map[string]log.Target{"file": file}
+	router.Routes = []log.Route{{When: "level >= Error", Targets: []string{"sentry"}}}
+	if err := router.Open(&bytes.Buffer{}); err == nil {
+		t.Errorf("Open() = nil for a route to an unknown target, expected an error")
+	}
+}
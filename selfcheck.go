@@ -0,0 +1,161 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// checkTimeout bounds the time a Checker may take to reach a server.
+const checkTimeout = 5 * time.Second
+
+// openFailure records a target which failed to open, as it is left out of the targets of the logger.
+type openFailure struct {
+	target Target
+	err    error
+}
+
+// Checker is implemented by the targets which can check that they are able to deliver messages,
+// e.g. that their file can be written or their server reached. It is called by Logger.SelfCheck.
+type Checker interface {
+	Check() error
+}
+
+// CheckResult is the outcome of a check made by SelfCheck.
+type CheckResult struct {
+	Component string // "logger", "formatter", or the type of a target, e.g. "*log.FileTarget"
+	Check     string // what was checked
+	Err       error  // the problem found, or nil
+}
+
+// MarshalJSON encodes the result with the message of its error.
+func (r CheckResult) MarshalJSON() ([]byte, error) {
+	doc := struct {
+		Component string `json:"component"`
+		Check     string `json:"check"`
+		OK        bool   `json:"ok"`
+		Error     string `json:"error,omitempty"`
+	}{r.Component, r.Check, r.Err == nil, ""}
+	if r.Err != nil {
+		doc.Error = r.Err.Error()
+	}
+	return json.Marshal(&doc)
+}
+
+// SelfCheckReport lists the results of the checks made by SelfCheck.
+type SelfCheckReport struct {
+	Results []CheckResult `json:"results"`
+}
+
+func (r *SelfCheckReport) add(component, check string, err error) {
+	r.Results = append(r.Results, CheckResult{Component: component, Check: check, Err: err})
+}
+
+// OK returns whether all the checks passed.
+func (r *SelfCheckReport) OK() bool {
+	return r.Err() == nil
+}
+
+// Err returns an error listing the failed checks, or nil if all the checks passed.
+func (r *SelfCheckReport) Err() error {
+	var problems []string
+	for _, result := range r.Results {
+		if result.Err != nil {
+			problems = append(problems, fmt.Sprintf("%v %v: %v", result.Component, result.Check, result.Err))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New("log self check failed: " + strings.Join(problems, "; "))
+}
+
+// String returns the results one per line.
+func (r *SelfCheckReport) String() string {
+	var b strings.Builder
+	for _, result := range r.Results {
+		if result.Err != nil {
+			fmt.Fprintf(&b, "FAIL %v %v: %v\n", result.Component, result.Check, result.Err)
+		} else {
+			fmt.Fprintf(&b, "ok   %v %v\n", result.Component, result.Check)
+		}
+	}
+	return b.String()
+}
+
+// SelfCheck checks that the messages of the logger can be delivered, so that a program can fail fast at startup
+// rather than find out later that its logs went nowhere. It checks that the logger is open and has targets, that
+// the formatter formats a message without panicking, that the targets were opened and are healthy, and calls the
+// targets implementing Checker, e.g. to write their files or reach their servers:
+//
+//	if report := logger.SelfCheck(); !report.OK() {
+//		fmt.Fprint(os.Stderr, report)
+//		os.Exit(1)
+//	}
+func (l *Logger) SelfCheck() *SelfCheckReport {
+	report := &SelfCheckReport{}
+	c := l.current()
+	var err error
+	if !c.open {
+		err = errors.New("the logger is not open")
+	} else if len(c.targets) == 0 {
+		err = errors.New("the logger has no target")
+	}
+	report.add("logger", "is open with targets", err)
+	report.add("formatter", "formats a message", l.checkFormatter())
+
+	l.lock.Lock()
+	failures := l.openErrors
+	l.lock.Unlock()
+	for _, failure := range failures {
+		report.add(fmt.Sprintf("%T", failure.target), "opens", failure.err)
+	}
+	for _, status := range l.TargetStatus() {
+		name := fmt.Sprintf("%T", status.Target)
+		err = nil
+		if !status.Open {
+			err = errors.New("the target is closed")
+		} else if status.Disabled {
+			err = fmt.Errorf("the target was disabled after panicking: %v", status.LastError)
+		} else if !status.Healthy && status.LastError != nil {
+			err = fmt.Errorf("the target is failing: %v", status.LastError)
+		} else if !status.Healthy {
+			err = errors.New("the target drops messages")
+		}
+		report.add(name, "is open and healthy", err)
+		if checker, ok := status.Target.(Checker); ok {
+			report.add(name, "can deliver messages", checker.Check())
+		}
+	}
+	return report
+}
+
+// checkFormatter formats a sample message with the formatter of the logger.
+func (l *Logger) checkFormatter() (err error) {
+	if atomic.LoadInt32(&l.fmtDisabled) == 1 {
+		return errors.New("the formatter was disabled after panicking")
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("the formatter panics: %v", r)
+		}
+	}()
+	entry := &Entry{
+		Category: l.Category,
+		Level:    LevelInfo,
+		Message:  "self check",
+		Time:     l.now(),
+		Fields:   Fields{"check": true},
+	}
+	if l.currentFormatter()(l, entry) == "" {
+		return errors.New("the formatter returns an empty message")
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestSelfCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "selfcheck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	logger := log.NewLogger()
+	logger.ErrorWriter = ioutil.Discard
+	file := log.NewFileTarget()
+	file.FileName = filepath.Join(dir, "logs", "app.log")
+	network := log.NewNetworkTarget()
+	network.Network = "tcp"
+	network.Address = listener.Addr().String()
+	network.Persistent = false
+	logger.SetTarget(file, network)
+	defer logger.Close()
+
+	report := logger.SelfCheck()
+	if !report.OK() {
+		t.Errorf("SelfCheck() = %v, expected the checks to pass", report)
+	}
+	if len(report.Results) != 6 {
+		t.Errorf("len(Results) = %v, expected 6", len(report.Results))
+	}
+
+	// a closed server, a file which cannot be created and a panicking formatter
+	listener.Close()
+	broken := log.NewFileTarget()
+	broken.FileName = filepath.Join(file.FileName, "app.log")
+	logger.SetFormatter(func(*log.Logger, *log.Entry) string {
+		panic("broken formatter")
+	})
+	logger.SetTarget(file, network, broken)
+
+	report = logger.SelfCheck()
+	if report.OK() {
+		t.Errorf("OK() = true, expected the checks to fail")
+	}
+	failures := map[string]bool{}
+	for _, result := range report.Results {
+		if result.Err != nil {
+			failures[result.Component+" "+result.Check] = true
+		}
+	}
+	for _, expected := range []string{
+		"formatter formats a message",
+		"*log.FileTarget opens",
+		"*log.NetworkTarget can deliver messages",
+	} {
+		if !failures[expected] {
+			t.Errorf("failures = %v, expected %q to fail", failures, expected)
+		}
+	}
+	if len(failures) != 3 {
+		t.Errorf("len(failures) = %v, expected 3", len(failures))
+	}
+	if err := report.Err(); err == nil || !strings.Contains(err.Error(), "the formatter panics: broken formatter") {
+		t.Errorf("Err() = %v, expected the panic of the formatter", err)
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `{"component":"formatter","check":"formats a message","ok":false,"error":"the formatter panics: broken formatter"}`) {
+		t.Errorf("json.Marshal(report) = %s, expected the failure of the formatter", data)
+	}
+}
+
+func TestSelfCheckClosed(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Close()
+	report := logger.SelfCheck()
+	if report.OK() || !strings.Contains(report.String(), "FAIL logger is open with targets: the logger is not open") {
+		t.Errorf("SelfCheck() = %v, expected the logger to be reported closed", report)
+	}
+}
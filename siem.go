@@ -0,0 +1,216 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"strconv"
+	"strings"
+)
+
+// siemSeverities maps log levels to the severities of CEF and LEEF, from 0 to 10.
+var siemSeverities = map[Level]int{
+	LevelEmergency: 10,
+	LevelAlert:     10,
+	LevelCritical:  9,
+	LevelFatal:     9,
+	LevelError:     7,
+	LevelWarn:      5,
+	LevelInfo:      3,
+	LevelDebug:     1,
+	LevelTrace:     0,
+}
+
+// siemSeverity returns the CEF and LEEF severity of a level. The custom levels are mapped like the closest built-in level.
+func siemSeverity(level Level) int {
+	if severity, ok := siemSeverities[level]; ok {
+		return severity
+	}
+	if level < LevelEmergency {
+		return 10
+	}
+	return 0
+}
+
+// CEFFormat formats log messages as ArcSight Common Event Format (CEF) events, so that SIEM platforms can ingest them.
+// The signature ID of an event is the category of the message, the name is the first line of the message, and
+// the severity is mapped from the level, from 10 for Emergency to 0 for Trace. The time, the category, the message
+// and the error are written as the rt, cat, msg and reason extensions, followed by the fields, whose keys are
+// stripped of the characters other than letters and digits, as CEF requires.
+type CEFFormat struct {
+	// the vendor, the product and the version of the device, i.e. of the application.
+	Vendor  string
+	Product string
+	Version string
+}
+
+// NewCEFFormatter returns a Formatter writing CEF events with the given device vendor, product and version.
+func NewCEFFormatter(vendor, product, version string) Formatter {
+	return NewFormatter((&CEFFormat{Vendor: vendor, Product: product, Version: version}).Append)
+}
+
+// Append appends a message formatted as a CEF event. It can be used as an AppendFormatter.
+func (f *CEFFormat) Append(buf []byte, l *Logger, e *Entry) []byte {
+	buf = append(buf, "CEF:0|"...)
+	buf = appendSIEMHeader(buf, f.Vendor)
+	buf = appendSIEMHeader(buf, f.Product)
+	buf = appendSIEMHeader(buf, f.Version)
+	buf = appendSIEMHeader(buf, eventID(e))
+	buf = appendSIEMHeader(buf, eventName(e))
+	buf = strconv.AppendInt(buf, int64(siemSeverity(e.Level)), 10)
+	buf = append(buf, "|rt="...)
+	buf = strconv.AppendInt(buf, e.Time.UnixNano()/1e6, 10)
+	buf = append(buf, " cat="...)
+	buf = appendCEFValue(buf, e.Category)
+	buf = append(buf, " msg="...)
+	buf = appendCEFValue(buf, e.Message)
+	if e.Error != nil {
+		buf = append(buf, " reason="...)
+		buf = appendCEFValue(buf, e.Error.Error())
+	}
+	var array [16]string
+	for _, k := range e.Fields.sortedKeys(&array) {
+		key := siemKey(k)
+		if key == "" {
+			continue
+		}
+		buf = append(buf, ' ')
+		buf = append(buf, key...)
+		buf = append(buf, '=')
+		buf = appendCEFValue(buf, string(appendValue(nil, e.Fields[k])))
+	}
+	return buf
+}
+
+// LEEFFormat formats log messages as IBM QRadar Log Event Extended Format (LEEF) 1.0 events, so that SIEM platforms
+// can ingest them. The event ID is the category of the message. The attributes, separated by tabs, are the time as
+// devTime in milliseconds since the epoch, the severity as sev, mapped from the level like with CEFFormat, the category
+// as cat, the message as msg and the error as reason, followed by the fields, whose keys are stripped of the
+// characters other than letters and digits.
+type LEEFFormat struct {
+	// the vendor, the product and the version of the device, i.e. of the application.
+	Vendor  string
+	Product string
+	Version string
+}
+
+// NewLEEFFormatter returns a Formatter writing LEEF events with the given device vendor, product and version.
+func NewLEEFFormatter(vendor, product, version string) Formatter {
+	return NewFormatter((&LEEFFormat{Vendor: vendor, Product: product, Version: version}).Append)
+}
+
+// Append appends a message formatted as a LEEF event. It can be used as an AppendFormatter.
+func (f *LEEFFormat) Append(buf []byte, l *Logger, e *Entry) []byte {
+	buf = append(buf, "LEEF:1.0|"...)
+	buf = appendSIEMHeader(buf, f.Vendor)
+	buf = appendSIEMHeader(buf, f.Product)
+	buf = appendSIEMHeader(buf, f.Version)
+	buf = appendSIEMHeader(buf, eventID(e))
+	buf = append(buf, "devTime="...)
+	buf = strconv.AppendInt(buf, e.Time.UnixNano()/1e6, 10)
+	buf = append(buf, "\tsev="...)
+	buf = strconv.AppendInt(buf, int64(siemSeverity(e.Level)), 10)
+	buf = append(buf, "\tcat="...)
+	buf = appendLEEFValue(buf, e.Category)
+	buf = append(buf, "\tmsg="...)
+	buf = appendLEEFValue(buf, e.Message)
+	if e.Error != nil {
+		buf = append(buf, "\treason="...)
+		buf = appendLEEFValue(buf, e.Error.Error())
+	}
+	var array [16]string
+	for _, k := range e.Fields.sortedKeys(&array) {
+		key := siemKey(k)
+		if key == "" {
+			continue
+		}
+		buf = append(buf, '\t')
+		buf = append(buf, key...)
+		buf = append(buf, '=')
+		buf = appendLEEFValue(buf, string(appendValue(nil, e.Fields[k])))
+	}
+	return buf
+}
+
+// eventID returns the ID of the event type of a message, which is its category, or its level if it has none.
+func eventID(e *Entry) string {
+	if e.Category != "" {
+		return e.Category
+	}
+	return e.Level.String()
+}
+
+// eventName returns the first line of a message, at most 512 bytes long.
+func eventName(e *Entry) string {
+	name := e.Message
+	if i := strings.IndexAny(name, "\r\n"); i >= 0 {
+		name = name[:i]
+	}
+	if len(name) > 512 {
+		name = name[:512]
+	}
+	return name
+}
+
+// siemKey returns a field key without the characters other than ASCII letters and digits.
+func siemKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, key)
+}
+
+// appendSIEMHeader appends a field of a CEF or LEEF header followed by the separator, escaping the backslashes
+// and the separators, and replacing the line breaks with spaces.
+func appendSIEMHeader(buf []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\', '|':
+			buf = append(buf, '\\', c)
+		case '\r', '\n':
+			buf = append(buf, ' ')
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return append(buf, '|')
+}
+
+// appendCEFValue appends the value of a CEF extension, escaping the backslashes, the equal signs and the line breaks.
+func appendCEFValue(buf []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\', '=':
+			buf = append(buf, '\\', c)
+		case '\r':
+			buf = append(buf, `\r`...)
+		case '\n':
+			buf = append(buf, `\n`...)
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return buf
+}
+
+// appendLEEFValue appends the value of a LEEF attribute, escaping the backslashes, the tabs and the line breaks.
+func appendLEEFValue(buf []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			buf = append(buf, `\\`...)
+		case '\t':
+			buf = append(buf, `\t`...)
+		case '\r':
+			buf = append(buf, `\r`...)
+		case '\n':
+			buf = append(buf, `\n`...)
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return buf
+}
@@ -0,0 +1,50 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+func TestCEFFormatter(t *testing.T) {
+	e := &log.Entry{
+		Level:    log.LevelError,
+		Category: "auth",
+		Message:  "login failed\nfor a=b",
+		Time:     time.Unix(1420167845, 0),
+		Fields:   log.Fields{"user.name": "alice", "src": `10.0.0.1\x`},
+		Error:    errors.New("bad password"),
+	}
+	result := log.NewCEFFormatter("Acme", "Shop|Web", "1.0")(log.NewLogger(), e)
+	expected := `CEF:0|Acme|Shop\|Web|1.0|auth|login failed|7|rt=1420167845000 cat=auth msg=login failed\nfor a\=b reason=bad password src=10.0.0.1\\x username=alice`
+	if result != expected {
+		t.Errorf("NewCEFFormatter() = %q, expected %q", result, expected)
+	}
+
+	e = &log.Entry{Level: log.LevelEmergency, Message: "down", Time: time.Unix(1, 0)}
+	result = log.NewCEFFormatter("Acme", "Shop", "1.0")(log.NewLogger(), e)
+	if expected := "CEF:0|Acme|Shop|1.0|Emergency|down|10|rt=1000 cat= msg=down"; result != expected {
+		t.Errorf("NewCEFFormatter() = %q, expected %q", result, expected)
+	}
+}
+
+func TestLEEFFormatter(t *testing.T) {
+	e := &log.Entry{
+		Level:    log.LevelWarn,
+		Category: "auth",
+		Message:  "too many\tattempts",
+		Time:     time.Unix(1420167845, 0),
+		Fields:   log.Fields{"usrName": "alice", "count": 5},
+	}
+	result := log.NewLEEFFormatter("Acme", "Shop", "1.0")(log.NewLogger(), e)
+	expected := "LEEF:1.0|Acme|Shop|1.0|auth|devTime=1420167845000\tsev=5\tcat=auth\tmsg=too many\\tattempts\tcount=5\tusrName=alice"
+	if result != expected {
+		t.Errorf("NewLEEFFormatter() = %q, expected %q", result, expected)
+	}
+}
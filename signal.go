@@ -0,0 +1,115 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// closedChan is the channel returned by Closed for a logger which has never been opened.
+var closedChan = make(chan struct{})
+
+func init() {
+	close(closedChan)
+}
+
+// Closed returns a channel which is closed once the logger has been closed, i.e. once its queued messages
+// have been processed and its targets closed, so that the application can sequence its shutdown:
+//
+//	logger.CatchSignal(os.Interrupt, syscall.SIGTERM)
+//	...
+//	<-logger.Closed()
+//	db.Close()
+//
+// If the logger is opened again, Closed returns a new channel.
+func (l *coreLogger) Closed() <-chan struct{} {
+	c := l.current()
+	if c.pipeline == nil {
+		return closedChan
+	}
+	return c.pipeline.done
+}
+
+// CloseOnDone closes the logger when ctx is done, e.g. when the context of the application is canceled.
+// It returns a function which cancels the close. It has no effect once the logger is closed otherwise.
+func (l *coreLogger) CloseOnDone(ctx context.Context) (stop func()) {
+	closed, stopped := l.Closed(), make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			l.closeUnless(stopped)
+		case <-closed:
+		case <-stopped:
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(stopped) })
+	}
+}
+
+// CloseOnSignal closes the logger when the program receives one of the given signals, e.g. os.Interrupt
+// and syscall.SIGTERM, so that the queued messages are written before the program exits. The signal is then
+// sent again to the program, which is terminated as it would have been without the logger, unless it handles
+// the signal too, e.g. with signal.NotifyContext. Where the signal cannot be sent again, e.g. on Windows, the
+// program exits with status 1. It returns a function which stops listening to the signals.
+func (l *coreLogger) CloseOnSignal(signals ...os.Signal) (stop func()) {
+	return l.closeOnSignal(signals, true)
+}
+
+// CatchSignal closes the logger when the program receives one of the given signals like CloseOnSignal, but
+// the signals no longer terminate the program, as with signal.Notify: the program must handle them too and
+// exit once Closed is closed. It returns a function which stops listening to the signals.
+func (l *coreLogger) CatchSignal(signals ...os.Signal) (stop func()) {
+	return l.closeOnSignal(signals, false)
+}
+
+func (l *coreLogger) closeOnSignal(signals []os.Signal, raise bool) (stop func()) {
+	closed, stopped := l.Closed(), make(chan struct{})
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+	go func() {
+		defer signal.Stop(ch)
+		select {
+		case sig := <-ch:
+			if l.closeUnless(stopped) && raise {
+				signal.Stop(ch)
+				raiseSignal(sig)
+			}
+		case <-closed:
+		case <-stopped:
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(stopped) })
+	}
+}
+
+// raiseSignal sends a signal to the program, or exits the program if it is unable to.
+func raiseSignal(sig os.Signal) {
+	p, err := os.FindProcess(os.Getpid())
+	if err == nil {
+		err = p.Signal(sig)
+	}
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// closeUnless closes the logger unless stopped is closed, which takes precedence over the event to close it.
+// It returns whether the logger was closed.
+func (l *coreLogger) closeUnless(stopped chan struct{}) bool {
+	select {
+	case <-stopped:
+		return false
+	default:
+		l.Close()
+		return true
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+func TestCloseOnDone(t *testing.T) {
+	logger := log.NewLogger()
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	ctx, cancel := context.WithCancel(context.Background())
+	logger.CloseOnDone(ctx)
+	logger.Info("queued")
+
+	select {
+	case <-logger.Closed():
+		t.Fatalf("Closed() is closed before ctx is done")
+	default:
+	}
+	cancel()
+	select {
+	case <-logger.Closed():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the logger was not closed when ctx was done")
+	}
+	if len(target.entries) != 1 {
+		t.Errorf("len(entries) = %v, expected the queued message to be written", len(target.entries))
+	}
+
+	// a stopped close has no effect
+	logger.Open()
+	ctx, cancel = context.WithCancel(context.Background())
+	stop := logger.CloseOnDone(ctx)
+	stop()
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case <-logger.Closed():
+		t.Errorf("the logger was closed after the close was stopped")
+	default:
+	}
+	logger.Close()
+}
+
+func TestCloseOnSignal(t *testing.T) {
+	// the signal sent again by the logger is received here instead of terminating the test
+	received := make(chan os.Signal, 2)
+	signal.Notify(received, os.Interrupt)
+	defer signal.Stop(received)
+
+	logger := log.NewLogger()
+	logger.SetTarget(&MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)})
+	defer logger.CloseOnSignal(os.Interrupt)()
+
+	p, _ := os.FindProcess(os.Getpid())
+	if err := p.Signal(os.Interrupt); err != nil {
+		t.Skipf("unable to send os.Interrupt: %v", err)
+	}
+	select {
+	case <-logger.Closed():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the logger was not closed on os.Interrupt")
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received os.Interrupt %v times, expected it to be sent again once the logger was closed", i)
+		}
+	}
+}
+
+func TestCatchSignal(t *testing.T) {
+	received := make(chan os.Signal, 2)
+	signal.Notify(received, os.Interrupt)
+	defer signal.Stop(received)
+
+	logger := log.NewLogger()
+	logger.SetTarget(&MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)})
+	defer logger.CatchSignal(os.Interrupt)()
+
+	p, _ := os.FindProcess(os.Getpid())
+	if err := p.Signal(os.Interrupt); err != nil {
+		t.Skipf("unable to send os.Interrupt: %v", err)
+	}
+	select {
+	case <-logger.Closed():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the logger was not closed on os.Interrupt")
+	}
+	<-received
+	select {
+	case <-received:
+		t.Errorf("the caught signal was sent again")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
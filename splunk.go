@@ -0,0 +1,161 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SplunkTarget sends log messages to a Splunk HTTP Event Collector (HEC).
+type SplunkTarget struct {
+	*Filter
+	// the base URL of the collector, e.g. "https://splunk.example.com:8088".
+	URL string
+	// the HEC token.
+	Token string
+	// the source, sourcetype, index and host metadata of the events. Empty values are left to the token defaults.
+	Source     string
+	SourceType string
+	Index      string
+	Host       string
+	// whether to gzip the request bodies.
+	Gzip bool
+	// the maximum number of events sent in one request.
+	BatchSize int
+	// the maximum time a message is kept in memory before being sent.
+	FlushInterval time.Duration
+	// the size of the message channel.
+	BufferSize int
+	// the HTTP client used to call the collector.
+	Client *http.Client
+	// the TLS options of the HTTP client created when Client is nil, e.g. for mutual TLS.
+	TLS *TLSOptions
+
+	batcher *BatchingTarget
+}
+
+// NewSplunkTarget creates a SplunkTarget.
+// The new SplunkTarget takes these default options:
+// MaxLevel: LevelDebug, Gzip: true, BatchSize: 100, FlushInterval: 2s, BufferSize: 1024.
+// You must specify the URL and Token fields.
+func NewSplunkTarget() *SplunkTarget {
+	return &SplunkTarget{
+		Filter:        &Filter{MaxLevel: LevelDebug},
+		Gzip:          true,
+		BatchSize:     100,
+		FlushInterval: 2 * time.Second,
+		BufferSize:    1024,
+	}
+}
+
+// Open prepares SplunkTarget for processing log messages.
+func (t *SplunkTarget) Open(errWriter io.Writer) error {
+	t.Filter.Init()
+	if t.URL == "" {
+		return errors.New("SplunkTarget.URL must be specified")
+	}
+	if t.Token == "" {
+		return errors.New("SplunkTarget.Token must be specified")
+	}
+	if t.Client == nil {
+		client, err := newHTTPClient(t.TLS)
+		if err != nil {
+			return fmt.Errorf("SplunkTarget.TLS is invalid: %v", err)
+		}
+		t.Client = client
+	}
+	t.batcher = &BatchingTarget{
+		Filter:        t.Filter,
+		Writer:        BatchWriterFunc(t.write),
+		Name:          "SplunkTarget",
+		BatchSize:     t.BatchSize,
+		FlushInterval: t.FlushInterval,
+		BufferSize:    t.BufferSize,
+		close:         make(chan bool, 0),
+	}
+	return t.batcher.Open(errWriter)
+}
+
+// Process puts filtered log messages into a batch for sending to Splunk.
+func (t *SplunkTarget) Process(e *Entry) {
+	t.batcher.Process(e)
+}
+
+// Close sends the last batch and closes the Splunk target.
+func (t *SplunkTarget) Close() {
+	t.batcher.Close()
+}
+
+type splunkEvent struct {
+	Time       float64           `json:"time"`
+	Host       string            `json:"host,omitempty"`
+	Source     string            `json:"source,omitempty"`
+	SourceType string            `json:"sourcetype,omitempty"`
+	Index      string            `json:"index,omitempty"`
+	Event      string            `json:"event"`
+	Fields     map[string]string `json:"fields"`
+}
+
+func (t *SplunkTarget) write(entries []*Entry) error {
+	body := new(bytes.Buffer)
+	var w io.Writer = body
+	var zw *gzip.Writer
+	if t.Gzip {
+		zw = gzip.NewWriter(body)
+		w = zw
+	}
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		err := enc.Encode(&splunkEvent{
+			Time:       float64(e.Time.UnixNano()) / float64(time.Second),
+			Host:       t.Host,
+			Source:     t.Source,
+			SourceType: t.SourceType,
+			Index:      t.Index,
+			Event:      e.String(),
+			Fields: map[string]string{
+				"level":    e.Level.String(),
+				"category": e.Category,
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimRight(t.URL, "/")+"/services/collector/event", body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Splunk "+t.Token)
+	req.Header.Set("Content-Type", "application/json")
+	if t.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%v: %s", resp.Status, bytes.TrimSpace(data))
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestNewSplunkTarget(t *testing.T) {
+	target := log.NewSplunkTarget()
+	if target.MaxLevel != log.LevelDebug {
+		t.Errorf("NewSplunkTarget.MaxLevel = %v, expected %v", target.MaxLevel, log.LevelDebug)
+	}
+	if target.BatchSize != 100 {
+		t.Errorf("NewSplunkTarget.BatchSize = %v, expected %v", target.BatchSize, 100)
+	}
+}
+
+func TestSplunkTarget(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/services/collector/event" {
+			t.Errorf("Unexpected path %q", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Splunk token" {
+			t.Errorf("Unexpected Authorization header %q", r.Header.Get("Authorization"))
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("gzip.NewReader(): %v", err)
+			return
+		}
+		data, _ := ioutil.ReadAll(zr)
+		body += string(data)
+	}))
+	defer server.Close()
+
+	logger := log.NewLogger()
+	logger.Sync()
+	target := log.NewSplunkTarget()
+	target.URL = server.URL
+	target.Token = "token"
+	target.Index = "main"
+	target.Categories = []string{"system.*"}
+	logger.SetTarget(target)
+
+	logger.Infof("t1: %v", 2)
+	logger.GetLogger("system.db").Infof("t2: %v", 3)
+
+	logger.Close()
+
+	if strings.Contains(body, "t1: 2") {
+		t.Errorf("Found unexpected %q", "t1: 2")
+	}
+	if !strings.Contains(body, "t2: 3") || !strings.Contains(body, `"index":"main"`) {
+		t.Errorf("Expected event not found in %q", body)
+	}
+}
@@ -0,0 +1,316 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// SpoolTarget writes log messages to a target implementing EntryWriter, spooling them to a local file
+// when its queue is full or the target fails. The spooled messages are replayed in order once the target
+// accepts messages again, and the messages logged meanwhile are spooled after them, so that the memory
+// used is bounded and no message is lost during a short outage. The spool file is kept when the target
+// is closed, and replayed when it is opened again.
+type SpoolTarget struct {
+	*Filter
+	// the target the messages are written to. It must implement EntryWriter.
+	Target Target
+	// the spool file.
+	FileName string
+	// the size of the message channel.
+	BufferSize int
+	// the maximum size of the spool file in bytes. New messages are dropped when it is full. 0 means no limit.
+	MaxSpoolSize int64
+	// the interval of the attempts to replay the spooled messages.
+	RetryInterval time.Duration
+
+	writer    EntryWriter
+	errWriter io.Writer
+	lock      sync.Mutex // guards the fields below
+	spooling  bool       // whether the new messages are spooled
+	file      *os.File
+	size      int64 // the size of the spool file
+	offset    int64 // the size of the replayed messages at the beginning of the spool file
+	dropped   int   // the number of messages dropped because the spool file was full
+	entries   chan *Entry
+	close     chan bool
+}
+
+// spoolRecord is the JSON representation of a message in a spool file or a WAL.
+type spoolRecord struct {
+	Level            Level     `json:"level"`
+	Category         string    `json:"category"`
+	Message          string    `json:"message"`
+	Time             time.Time `json:"time"`
+	Seq              uint64    `json:"seq,omitempty"`
+	CallStack        CallStack `json:"callstack,omitempty"`
+	Fields           Fields    `json:"fields,omitempty"`
+	TraceID          string    `json:"trace_id,omitempty"`
+	SpanID           string    `json:"span_id,omitempty"`
+	Error            string    `json:"error,omitempty"`
+	FormattedMessage string    `json:"formatted"`
+}
+
+// NewSpoolTarget creates a SpoolTarget writing to target and spooling to the file fileName.
+// The new SpoolTarget takes these default options:
+// MaxLevel: LevelDebug, BufferSize: 1024, RetryInterval: 5s.
+func NewSpoolTarget(target Target, fileName string) *SpoolTarget {
+	return &SpoolTarget{
+		Filter:        &Filter{MaxLevel: LevelDebug},
+		Target:        target,
+		FileName:      fileName,
+		BufferSize:    1024,
+		RetryInterval: 5 * time.Second,
+		close:         make(chan bool, 0),
+	}
+}
+
+// Open opens the target and the spool file.
+func (t *SpoolTarget) Open(errWriter io.Writer) error {
+	t.Filter.Init()
+	writer, ok := t.Target.(EntryWriter)
+	if !ok {
+		return errors.New("SpoolTarget.Target must implement EntryWriter")
+	}
+	if t.FileName == "" {
+		return errors.New("SpoolTarget.FileName must be specified")
+	}
+	if t.BufferSize < 0 {
+		return errors.New("SpoolTarget.BufferSize must be no less than 0")
+	}
+	if t.RetryInterval <= 0 {
+		return errors.New("SpoolTarget.RetryInterval must be greater than 0")
+	}
+	file, err := os.OpenFile(t.FileName, os.O_RDWR|os.O_CREATE, 0660)
+	if err != nil {
+		return fmt.Errorf("SpoolTarget was unable to open the spool file: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("SpoolTarget was unable to open the spool file: %v", err)
+	}
+	if err := t.Target.Open(errWriter); err != nil {
+		file.Close()
+		return err
+	}
+	t.writer = writer
+	t.errWriter = errWriter
+	t.file = file
+	t.size = info.Size()
+	t.offset = 0
+	t.dropped = 0
+	// the messages left by the previous run are replayed first
+	t.spooling = t.size > 0
+	t.entries = make(chan *Entry, t.BufferSize)
+
+	go t.writeMessages()
+
+	return nil
+}
+
+// Process puts filtered log messages into a channel for writing, or appends them to the spool file
+// if the channel is full or the target is failing.
+func (t *SpoolTarget) Process(e *Entry) {
+	if e == nil {
+		t.entries <- nil
+		return
+	}
+	if !t.Allow(e) {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !t.spooling {
+		select {
+		case t.entries <- e:
+			return
+		default:
+			t.spooling = true
+		}
+	}
+	t.spool(e)
+}
+
+// Close closes the target and the spool file once the queued messages are written or spooled.
+func (t *SpoolTarget) Close() {
+	<-t.close
+	// a target may wait in Process(nil) until it is closed, as it does with the logger
+	go t.Target.Process(nil)
+	t.Target.Close()
+}
+
+func (t *SpoolTarget) writeMessages() {
+	ticker := time.NewTicker(t.RetryInterval)
+	defer ticker.Stop()
+	if t.spooling {
+		t.replay()
+	}
+	for {
+		select {
+		case entry := <-t.entries:
+			if entry == nil {
+				t.finish()
+				return
+			}
+			err := t.writer.WriteEntry(entry)
+			if err == nil {
+				continue
+			}
+			t.lock.Lock()
+			if !t.spooling {
+				fmt.Fprintf(t.errWriter, "SpoolTarget write error: %v (spooling the messages to %v)\n", err, t.FileName)
+				t.spooling = true
+			}
+			t.spool(entry)
+			// the queued messages are older than those which will be spooled from now on
+			closing := t.drain()
+			t.lock.Unlock()
+			if closing {
+				t.finish()
+				return
+			}
+		case <-ticker.C:
+			t.replay()
+		}
+	}
+}
+
+// finish attempts a last replay and closes the spool file.
+func (t *SpoolTarget) finish() {
+	t.replay()
+	t.lock.Lock()
+	if t.dropped > 0 {
+		fmt.Fprintf(t.errWriter, "%v messages were dropped because the spool file %v was full\n", t.dropped, t.FileName)
+		t.dropped = 0
+	}
+	t.file.Close()
+	t.lock.Unlock()
+	t.close <- true
+}
+
+// drain spools the queued messages. It returns true if the closing signal was among them.
+// It must be called with t.lock held.
+func (t *SpoolTarget) drain() bool {
+	for {
+		select {
+		case entry := <-t.entries:
+			if entry == nil {
+				return true
+			}
+			t.spool(entry)
+		default:
+			return false
+		}
+	}
+}
+
+// spool appends a message to the spool file. It must be called with t.lock held.
+func (t *SpoolTarget) spool(e *Entry) {
+	data, err := json.Marshal(newSpoolRecord(e))
+	if err != nil {
+		fmt.Fprintf(t.errWriter, "SpoolTarget was unable to spool a message: %v (message dropped: %v)\n", err, e.String())
+		return
+	}
+	data = append(data, '\n')
+	if t.MaxSpoolSize > 0 && t.size+int64(len(data)) > t.MaxSpoolSize {
+		t.dropped++
+		return
+	}
+	if _, err := t.file.WriteAt(data, t.size); err != nil {
+		fmt.Fprintf(t.errWriter, "SpoolTarget was unable to spool a message: %v (message dropped: %v)\n", err, e.String())
+		return
+	}
+	t.size += int64(len(data))
+}
+
+// replay writes the spooled messages to the target in order until the spool file is empty or the target fails.
+// The file is emptied and the new messages are queued again once all spooled messages have been written.
+func (t *SpoolTarget) replay() {
+	for {
+		t.lock.Lock()
+		if !t.spooling {
+			t.lock.Unlock()
+			return
+		}
+		if t.offset >= t.size {
+			if err := t.file.Truncate(0); err != nil {
+				fmt.Fprintf(t.errWriter, "SpoolTarget was unable to empty the spool file: %v\n", err)
+			}
+			t.size, t.offset = 0, 0
+			t.spooling = false
+			if t.dropped > 0 {
+				fmt.Fprintf(t.errWriter, "%v messages were dropped because the spool file %v was full\n", t.dropped, t.FileName)
+				t.dropped = 0
+			}
+			t.lock.Unlock()
+			return
+		}
+		// the records up to the current size are complete as they are written with t.lock held
+		reader := bufio.NewReader(io.NewSectionReader(t.file, t.offset, t.size-t.offset))
+		t.lock.Unlock()
+
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(line) == 0 && err != nil {
+				break
+			}
+			var r spoolRecord
+			if err := json.Unmarshal(line, &r); err != nil {
+				fmt.Fprintf(t.errWriter, "SpoolTarget skipped a corrupted message in %v: %v\n", t.FileName, err)
+			} else if err := t.writer.WriteEntry(r.entry()); err != nil {
+				return
+			}
+			t.lock.Lock()
+			t.offset += int64(len(line))
+			t.lock.Unlock()
+		}
+	}
+}
+
+func newSpoolRecord(e *Entry) *spoolRecord {
+	r := &spoolRecord{
+		Level:            e.Level,
+		Category:         e.Category,
+		Message:          e.Message,
+		Time:             e.Time,
+		Seq:              e.Seq,
+		CallStack:        e.CallStack,
+		Fields:           e.Fields,
+		TraceID:          e.TraceID,
+		SpanID:           e.SpanID,
+		FormattedMessage: e.FormattedMessage,
+	}
+	if e.Error != nil {
+		r.Error = e.Error.Error()
+	}
+	return r
+}
+
+func (r *spoolRecord) entry() *Entry {
+	e := &Entry{
+		Level:            r.Level,
+		Category:         r.Category,
+		Message:          r.Message,
+		Time:             r.Time,
+		Seq:              r.Seq,
+		CallStack:        r.CallStack,
+		Fields:           r.Fields,
+		TraceID:          r.TraceID,
+		SpanID:           r.SpanID,
+		FormattedMessage: r.FormattedMessage,
+	}
+	if r.Error != "" {
+		e.Error = errors.New(r.Error)
+	}
+	return e
+}
@@ -0,0 +1,138 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+// switchTarget fails to write the messages while it is down.
+type switchTarget struct {
+	*log.Filter
+	lock    sync.Mutex
+	down    bool
+	written []string
+}
+
+func (t *switchTarget) Open(io.Writer) error {
+	return nil
+}
+
+func (t *switchTarget) Process(e *log.Entry) {
+}
+
+func (t *switchTarget) Close() {
+}
+
+func (t *switchTarget) WriteEntry(e *log.Entry) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.down {
+		return errors.New("unavailable")
+	}
+	t.written = append(t.written, e.Message)
+	return nil
+}
+
+func (t *switchTarget) setDown(down bool) {
+	t.lock.Lock()
+	t.down = down
+	t.lock.Unlock()
+}
+
+func (t *switchTarget) messages() string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return strings.Join(t.written, ",")
+}
+
+func spoolEntry(message string) *log.Entry {
+	return &log.Entry{Level: log.LevelInfo, Message: message, FormattedMessage: message, Fields: log.Fields{"n": 1}}
+}
+
+func TestSpoolTarget(t *testing.T) {
+	spoolFile := "spool.log"
+	os.Remove(spoolFile)
+	defer os.Remove(spoolFile)
+
+	target := &switchTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, down: true}
+	spool := log.NewSpoolTarget(target, spoolFile)
+	spool.RetryInterval = 10 * time.Millisecond
+	if err := spool.Open(&lockedBuffer{}); err != nil {
+		t.Fatalf("Open(): %v", err)
+	}
+	spool.Process(spoolEntry("t1"))
+	spool.Process(spoolEntry("t2"))
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if data, _ := ioutil.ReadFile(spoolFile); strings.Count(string(data), "\n") == 2 {
+			break
+		}
+	}
+	if data, _ := ioutil.ReadFile(spoolFile); !strings.Contains(string(data), `"message":"t2"`) {
+		t.Fatalf("spool file = %q, expected the failed messages", data)
+	}
+
+	// the spooled messages are replayed before the new ones
+	spool.Process(spoolEntry("t3"))
+	target.setDown(false)
+	spool.Process(spoolEntry("t4"))
+	expected := "t1,t2,t3,t4"
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if target.messages() == expected {
+			break
+		}
+	}
+	spool.Process(spoolEntry("t5"))
+	spool.Process(nil)
+	spool.Close()
+	if s := target.messages(); s != expected+",t5" {
+		t.Errorf("written messages = %v, expected %v", s, expected+",t5")
+	}
+	if info, err := os.Stat(spoolFile); err != nil || info.Size() != 0 {
+		t.Errorf("the spool file was not emptied")
+	}
+}
+
+func TestSpoolTargetReopen(t *testing.T) {
+	spoolFile := "spool.log"
+	os.Remove(spoolFile)
+	defer os.Remove(spoolFile)
+
+	target := &switchTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, down: true}
+	spool := log.NewSpoolTarget(target, spoolFile)
+	spool.RetryInterval = time.Hour
+	errWriter := &lockedBuffer{}
+	if err := spool.Open(errWriter); err != nil {
+		t.Fatalf("Open(): %v", err)
+	}
+	spool.Process(spoolEntry("t1"))
+	spool.Process(nil)
+	spool.Close()
+	if s := errWriter.String(); !strings.Contains(s, "SpoolTarget write error: unavailable") {
+		t.Errorf("error output = %q, expected the write error", s)
+	}
+
+	// the messages spooled before the target was closed are replayed when it is opened again
+	target.setDown(false)
+	if err := spool.Open(&bytes.Buffer{}); err != nil {
+		t.Fatalf("Open(): %v", err)
+	}
+	spool.Process(spoolEntry("t2"))
+	spool.Process(nil)
+	spool.Close()
+	if s := target.messages(); s != "t1,t2" {
+		t.Errorf("written messages = %v, expected %v", s, "t1,t2")
+	}
+}
@@ -0,0 +1,258 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"encoding/json"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Frame is a frame of a call stack.
+type Frame struct {
+	File string `bson:"file" json:"file"`
+	Line int    `bson:"line" json:"line"`
+	Func string `bson:"func,omitempty" json:"func,omitempty"` // the package path-qualified function name
+}
+
+// CallStack is the call stack of a log message, from the innermost frame.
+// It is encoded in JSON as an array of frames, so that the targets can send it as a structure.
+type CallStack []Frame
+
+// String returns the call stack as a string holding each frame as file:line on a new line,
+// which is how the text formatters write it.
+func (s CallStack) String() string {
+	return string(s.appendTo(nil))
+}
+
+func (s CallStack) appendTo(buf []byte) []byte {
+	for _, f := range s {
+		buf = append(buf, '\n')
+		buf = append(buf, f.File...)
+		buf = append(buf, ':')
+		buf = strconv.AppendInt(buf, int64(f.Line), 10)
+	}
+	return buf
+}
+
+// UnmarshalJSON decodes a call stack from an array of frames, or from a string in the format
+// of String, which is how the call stacks were encoded by previous versions.
+func (s *CallStack) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+		*s = ParseCallStack(text)
+		return nil
+	}
+	var frames []Frame
+	if err := json.Unmarshal(data, &frames); err != nil {
+		return err
+	}
+	*s = frames
+	return nil
+}
+
+// ParseCallStack parses a call stack in the format of CallStack.String.
+// The frames it returns have no function name.
+func ParseCallStack(text string) CallStack {
+	var s CallStack
+	for _, line := range strings.Split(text, "\n") {
+		if line == "" {
+			continue
+		}
+		f := Frame{File: line}
+		if i := strings.LastIndexByte(line, ':'); i >= 0 {
+			if n, err := strconv.Atoi(line[i+1:]); err == nil {
+				f.File, f.Line = line[:i], n
+			}
+		}
+		s = append(s, f)
+	}
+	return s
+}
+
+// GetCallFrames returns the current call stack like GetCallStack, as frames.
+func GetCallFrames(skip int, frames int, filter string) CallStack {
+	return callFrames(skip+1, frames, func(file string) bool {
+		return filter == "" || strings.Contains(file, filter)
+	}, false, 0)
+}
+
+// stackOptions are the call stack options of an open logger, copied from the exported fields.
+type stackOptions struct {
+	depth    int
+	filter   string
+	includes []string
+	excludes []string
+}
+
+// stackOptions returns the call stack options set by the exported fields.
+func (l *coreLogger) stackOptions() stackOptions {
+	return stackOptions{
+		depth:    l.CallStackDepth,
+		filter:   l.CallStackFilter,
+		includes: append([]string(nil), l.CallStackIncludes...),
+		excludes: append([]string(nil), l.CallStackExcludes...),
+	}
+}
+
+// keepFrame returns whether a call stack frame of the file is counted according to
+// CallStackFilter, CallStackIncludes and CallStackExcludes.
+func (o *stackOptions) keepFrame(file string) bool {
+	for _, exclude := range o.excludes {
+		if strings.Contains(file, exclude) {
+			return false
+		}
+	}
+	if o.filter == "" && len(o.includes) == 0 {
+		return true
+	}
+	if o.filter != "" && strings.Contains(file, o.filter) {
+		return true
+	}
+	for _, include := range o.includes {
+		if strings.Contains(file, include) {
+			return true
+		}
+	}
+	return false
+}
+
+// packagePrefix prefixes the names of the functions of this package.
+var packagePrefix = reflect.TypeOf(Entry{}).PkgPath() + "."
+
+// callFrames returns up to the given number of frames of the current call stack whose file is kept.
+// The first skip frames are skipped like with runtime.Caller, then, if caller is true, the frames of
+// this package, so that the stack starts at the caller of the logger whatever the path of the call
+// through the logger, and then callerSkip more frames.
+func callFrames(skip int, frames int, keep func(file string) bool, caller bool, callerSkip int) CallStack {
+	if frames <= 0 {
+		return nil
+	}
+	pcs := make([]uintptr, 64)
+	for {
+		// runtime.Callers counts itself, unlike runtime.Caller
+		n := runtime.Callers(skip+1, pcs)
+		if n < len(pcs) {
+			pcs = pcs[:n]
+			break
+		}
+		pcs = make([]uintptr, len(pcs)*2)
+	}
+	if len(pcs) == 0 {
+		return nil
+	}
+	var stack CallStack
+	iter := runtime.CallersFrames(pcs)
+	for more := true; more && len(stack) < frames; {
+		var frame runtime.Frame
+		frame, more = iter.Next()
+		if caller && strings.HasPrefix(frame.Function, packagePrefix) {
+			continue
+		}
+		caller = false
+		if callerSkip > 0 {
+			callerSkip--
+		} else if keep(frame.File) {
+			stack = append(stack, Frame{File: frame.File, Line: frame.Line, Func: frame.Function})
+		}
+	}
+	return stack
+}
+
+// StackFormat configures how the text formatters write the frames of call stacks.
+// The zero value writes each frame as the full path of the file and the line.
+type StackFormat struct {
+	// whether to trim the file paths up to the module cache, i.e. up to "/pkg/mod/", or else up to the
+	// first "/src/" directory, which is the source directory of GOPATH or GOROOT, so that "/go/src/runtime/proc.go"
+	// is written as "runtime/proc.go".
+	TrimGoPaths bool
+	// the prefixes trimmed from the file paths, e.g. the root directory of the module.
+	TrimPrefixes []string
+	// whether to write only the name of the file and of its directory, e.g. "log/logger.go".
+	ShortPaths bool
+	// whether to write the function of each frame after the line, e.g. "log.(*Logger).Info".
+	Functions bool
+	// the maximum width of a frame. The beginning of a longer frame is replaced with "...". 0 means no limit.
+	MaxWidth int
+}
+
+// SetStackFormat changes how the built-in text formatters write the call stacks of the messages for
+// the logger and the loggers sharing its targets. It can be called while messages are being logged.
+func (l *Logger) SetStackFormat(format StackFormat) *Logger {
+	format.TrimPrefixes = append([]string{}, format.TrimPrefixes...)
+	l.stackFormat.Store(&format)
+	return l
+}
+
+// appendCallStack appends a call stack in the format set for the logger.
+func appendCallStack(buf []byte, l *Logger, s CallStack) []byte {
+	var f *StackFormat
+	if l != nil && l.coreLogger != nil {
+		f, _ = l.stackFormat.Load().(*StackFormat)
+	}
+	if f == nil {
+		return s.appendTo(buf)
+	}
+	for _, frame := range s {
+		buf = append(buf, '\n')
+		start := len(buf)
+		buf = append(buf, f.path(frame.File)...)
+		buf = append(buf, ':')
+		buf = strconv.AppendInt(buf, int64(frame.Line), 10)
+		if f.Functions && frame.Func != "" {
+			buf = append(buf, ' ')
+			buf = append(buf, shortFunc(frame.Func)...)
+		}
+		if f.MaxWidth > 3 && utf8.RuneCount(buf[start:]) > f.MaxWidth {
+			// keep the end of the frame, which holds the file name and the line
+			cut := start
+			for n := utf8.RuneCount(buf[start:]) - f.MaxWidth + 3; n > 0; n-- {
+				_, size := utf8.DecodeRune(buf[cut:])
+				cut += size
+			}
+			buf = append(append(buf[:start], "..."...), buf[cut:]...)
+		}
+	}
+	return buf
+}
+
+// path returns the path of a file as written in a call stack.
+func (f *StackFormat) path(file string) string {
+	if f.TrimGoPaths {
+		if i := strings.Index(file, "/pkg/mod/"); i >= 0 {
+			file = file[i+len("/pkg/mod/"):]
+		} else if i := strings.Index(file, "/src/"); i >= 0 {
+			file = file[i+len("/src/"):]
+		}
+	}
+	for _, prefix := range f.TrimPrefixes {
+		if strings.HasPrefix(file, prefix) {
+			file = file[len(prefix):]
+			break
+		}
+	}
+	if f.ShortPaths {
+		if i := strings.LastIndexByte(file, '/'); i > 0 {
+			if j := strings.LastIndexByte(file[:i], '/'); j >= 0 {
+				file = file[j+1:]
+			}
+		}
+	}
+	return file
+}
+
+// shortFunc returns the name of a function without the path of its package, e.g. "log.(*Logger).Info".
+func shortFunc(name string) string {
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
@@ -0,0 +1,138 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestGetCallFrames(t *testing.T) {
+	frames := log.GetCallFrames(1, 2, "")
+	if len(frames) != 2 {
+		t.Fatalf("len(GetCallFrames()) = %v, expected 2", len(frames))
+	}
+	if f := frames[0]; !strings.HasSuffix(f.File, "stack_test.go") || f.Line == 0 || !strings.HasSuffix(f.Func, ".TestGetCallFrames") {
+		t.Errorf("frames[0] = %+v, expected TestGetCallFrames in stack_test.go", f)
+	}
+	if frames := log.GetCallFrames(1, 5, "stack_test.go"); len(frames) != 1 {
+		t.Errorf("len(GetCallFrames()) = %v with a filter, expected 1", len(frames))
+	}
+	if stack := log.GetCallStack(1, 1, ""); !strings.HasPrefix(stack, "\n") || !strings.Contains(stack, "stack_test.go:") {
+		t.Errorf("GetCallStack() = %q, expected the frame of the test", stack)
+	}
+}
+
+func TestCallStack(t *testing.T) {
+	s := log.CallStack{{File: "/app/main.go", Line: 12, Func: "main.main"}, {File: "/app/run.go", Line: 3}}
+	if result := s.String(); result != "\n/app/main.go:12\n/app/run.go:3" {
+		t.Errorf("String() = %q, expected the frames as file:line", result)
+	}
+	expected := log.CallStack{{File: "/app/main.go", Line: 12}, {File: "/app/run.go", Line: 3}}
+	if result := log.ParseCallStack(s.String()); !reflect.DeepEqual(result, expected) {
+		t.Errorf("ParseCallStack() = %v, expected %v", result, expected)
+	}
+
+	data, _ := json.Marshal(s)
+	if string(data) != `[{"file":"/app/main.go","line":12,"func":"main.main"},{"file":"/app/run.go","line":3}]` {
+		t.Errorf("json.Marshal() = %s, expected an array of frames", data)
+	}
+	tests := []struct {
+		data     string
+		expected log.CallStack
+	}{
+		{string(data), s},
+		{`"\n/app/main.go:12\n/app/run.go:3"`, expected},
+	}
+	for _, test := range tests {
+		var result log.CallStack
+		if err := json.Unmarshal([]byte(test.data), &result); err != nil || !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("json.Unmarshal(%s) = %v, %v, expected %v", test.data, result, err, test.expected)
+		}
+	}
+}
+
+func TestJSONFormatterCallStack(t *testing.T) {
+	logger := log.NewLogger()
+	e := &log.Entry{Message: "t1", CallStack: log.CallStack{{File: "/app/main.go", Line: 12, Func: "main.main"}}}
+	var result log.JSONL
+	if err := json.Unmarshal([]byte(log.JSONFormatter(logger, e)), &result); err != nil {
+		t.Fatalf("json.Unmarshal() = %v", err)
+	}
+	if !reflect.DeepEqual(result.CallStack, e.CallStack) {
+		t.Errorf("CallStack = %v, expected %v", result.CallStack, e.CallStack)
+	}
+}
+
+// logThroughHelper logs a message like a logging helper of an application would.
+func logThroughHelper(logger *log.Logger, message string) {
+	logger.Info(message)
+}
+
+func TestLoggerAddCallerSkip(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	logger.CallStackDepth = 1
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	logThroughHelper(logger, "t1")
+	logThroughHelper(logger.AddCallerSkip(1), "t2")
+	logger.Close()
+
+	if len(target.entries) != 2 {
+		t.Fatalf("len(entries) = %v, expected 2", len(target.entries))
+	}
+	if f := target.entries[0].CallStack[0].Func; !strings.HasSuffix(f, ".logThroughHelper") {
+		t.Errorf("the call stack starts at %v, expected the helper", f)
+	}
+	if f := target.entries[1].CallStack[0].Func; !strings.HasSuffix(f, ".TestLoggerAddCallerSkip") {
+		t.Errorf("the call stack starts at %v with AddCallerSkip(1), expected the caller of the helper", f)
+	}
+}
+
+func TestLoggerCallStackFilters(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	logger.CallStackDepth = 10
+	logger.CallStackIncludes = []string{"stack_test.go", "testing.go"}
+	logger.CallStackExcludes = []string{"testing.go"}
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	logger.Info("t1")
+	logger.Close()
+
+	stack := target.entries[0].CallStack
+	if len(stack) != 1 || !strings.HasSuffix(stack[0].File, "stack_test.go") {
+		t.Errorf("CallStack = %v, expected only the frame of stack_test.go", stack)
+	}
+}
+
+func TestLoggerSetStackFormat(t *testing.T) {
+	e := &log.Entry{Message: "t1", CallStack: log.CallStack{
+		{File: "/home/me/go/pkg/mod/github.com/admpub/log@v1.0.0/logger.go", Line: 12, Func: "github.com/admpub/log.(*Logger).Info"},
+		{File: "/home/me/myapp/cmd/main.go", Line: 3, Func: "main.main"},
+	}}
+	tests := []struct {
+		format   log.StackFormat
+		expected string
+	}{
+		{log.StackFormat{}, "\n/home/me/go/pkg/mod/github.com/admpub/log@v1.0.0/logger.go:12\n/home/me/myapp/cmd/main.go:3"},
+		{log.StackFormat{TrimGoPaths: true, TrimPrefixes: []string{"/home/me/myapp/"}}, "\ngithub.com/admpub/log@v1.0.0/logger.go:12\ncmd/main.go:3"},
+		{log.StackFormat{ShortPaths: true, Functions: true}, "\nlog@v1.0.0/logger.go:12 log.(*Logger).Info\ncmd/main.go:3 main.main"},
+		{log.StackFormat{TrimGoPaths: true, MaxWidth: 20}, "\n....0.0/logger.go:12\n...app/cmd/main.go:3"},
+	}
+	for _, test := range tests {
+		logger := log.NewLogger()
+		logger.SetStackFormat(test.format)
+		result := log.NormalFormatter(logger, e)
+		if i := strings.IndexByte(result, '\n'); i < 0 || result[i:] != test.expected {
+			t.Errorf("%+v: formatted %q, expected the call stack %q", test.format, result, test.expected)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats describes the state of the logging pipeline of a logger, so that the pipeline itself can be observed.
+type Stats struct {
+	// whether the logger is open, and whether it hands the messages to the targets synchronously.
+	Open     bool `json:"open"`
+	SyncMode bool `json:"syncMode"`
+	// the number of messages waiting in the queue of the logger, and its size, BufferSize.
+	QueueLength   int `json:"queueLength"`
+	QueueCapacity int `json:"queueCapacity"`
+	// the number of messages on their way to the targets, including those waiting for room in the queue.
+	InFlight int32 `json:"inFlight"`
+	// the number of messages dropped because the queue of a target was full with DropOnFull, for all the targets.
+	Dropped int64 `json:"dropped"`
+	// the number of messages logged by level since the logger was created, except the heartbeats.
+	Levels map[Level]uint64 `json:"levels"`
+	// the status of the targets, in the order of Targets.
+	Targets []TargetStatus `json:"targets"`
+}
+
+// levelCounts counts the messages logged by level.
+type levelCounts struct {
+	counts sync.Map // the *uint64 counter of each level
+}
+
+func (c *levelCounts) add(level Level) {
+	counter, ok := c.counts.Load(level)
+	if !ok {
+		counter, _ = c.counts.LoadOrStore(level, new(uint64))
+	}
+	atomic.AddUint64(counter.(*uint64), 1)
+}
+
+func (c *levelCounts) get() map[Level]uint64 {
+	counts := map[Level]uint64{}
+	c.counts.Range(func(level, counter interface{}) bool {
+		counts[level.(Level)] = atomic.LoadUint64(counter.(*uint64))
+		return true
+	})
+	return counts
+}
+
+// reset sets the counters to zero.
+func (c *levelCounts) reset() {
+	c.counts.Range(func(_, counter interface{}) bool {
+		atomic.StoreUint64(counter.(*uint64), 0)
+		return true
+	})
+}
+
+// Stats returns the state of the logging pipeline.
+func (l *coreLogger) Stats() Stats {
+	c := l.current()
+	stats := Stats{
+		Open:     c.open,
+		SyncMode: c.syncMode,
+		InFlight: atomic.LoadInt32(&l.goroutines),
+		Levels:   l.levelCounts.get(),
+		Targets:  l.TargetStatus(),
+	}
+	if c.pipeline != nil {
+		stats.QueueLength = c.pipeline.entries.len()
+		stats.QueueCapacity = c.pipeline.entries.cap()
+	}
+	for _, status := range stats.Targets {
+		stats.Dropped += status.Dropped
+	}
+	return stats
+}
+
+// Publish publishes the Stats of the logger as an expvar variable, which is served with the other
+// variables at /debug/vars. Like expvar.Publish, it panics if the name is already in use.
+func (l *coreLogger) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return l.Stats()
+	}))
+}
+
+// DebugHandler returns an HTTP handler serving the Stats of the logger in JSON.
+// It should be mounted behind the application's authentication.
+func (l *coreLogger) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(l.Stats())
+	})
+}
+
+// MarshalJSON encodes the status with the type of the target and the message of the last error.
+func (s TargetStatus) MarshalJSON() ([]byte, error) {
+	doc := struct {
+		Target        string     `json:"target"`
+		Open          bool       `json:"open"`
+		Healthy       bool       `json:"healthy"`
+		Processed     int64      `json:"processed"`
+		Failed        int64      `json:"failed"`
+		Dropped       int64      `json:"dropped"`
+		QueueDepth    int        `json:"queueDepth"`
+		QueueCapacity int        `json:"queueCapacity"`
+		LastError     string     `json:"lastError,omitempty"`
+		LastErrorTime *time.Time `json:"lastErrorTime,omitempty"`
+	}{
+		Target:        fmt.Sprintf("%T", s.Target),
+		Open:          s.Open,
+		Healthy:       s.Healthy,
+		Processed:     s.Processed,
+		Failed:        s.Failed,
+		Dropped:       s.Dropped,
+		QueueDepth:    s.QueueDepth,
+		QueueCapacity: s.QueueCapacity,
+	}
+	if s.LastError != nil {
+		doc.LastError = s.LastError.Error()
+		doc.LastErrorTime = &s.LastErrorTime
+	}
+	return json.Marshal(&doc)
+}
@@ -0,0 +1,60 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+func TestStats(t *testing.T) {
+	logger := log.NewLogger()
+	logger.TargetBuffer = 8
+	logger.Sync()
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	logger.Info("a")
+	logger.Info("b")
+	logger.Warn("c")
+
+	stats := logger.Stats()
+	if !stats.Open || !stats.SyncMode || stats.QueueCapacity != 1024 {
+		t.Errorf("Stats() = %v %v %v, expected an open sync logger with the default queue", stats.Open, stats.SyncMode, stats.QueueCapacity)
+	}
+	if stats.Levels[log.LevelInfo] != 2 || stats.Levels[log.LevelWarn] != 1 || stats.Levels[log.LevelError] != 0 {
+		t.Errorf("Stats().Levels = %v, expected 2 Info and 1 Warn", stats.Levels)
+	}
+	if len(stats.Targets) != 1 || stats.Targets[0].Processed != 3 || stats.Targets[0].QueueCapacity != 8 {
+		t.Errorf("Stats().Targets = %+v, expected the target with 3 messages", stats.Targets)
+	}
+
+	recorder := httptest.NewRecorder()
+	logger.DebugHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/debug/log", nil))
+	var doc struct {
+		Levels  map[string]uint64
+		Targets []map[string]interface{}
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("DebugHandler() = %v, expected JSON", err)
+	}
+	if doc.Levels["Info"] != 2 || len(doc.Targets) != 1 || doc.Targets[0]["target"] != "*log_test.MemoryTarget" {
+		t.Errorf("DebugHandler() = %s, expected the levels and the target", recorder.Body.String())
+	}
+
+	// expvar names cannot be reused, so each run of the test publishes its own
+	name := fmt.Sprintf("test_logger_%v", time.Now().UnixNano())
+	logger.Publish(name)
+	if v := expvar.Get(name); v == nil || !strings.Contains(v.String(), `"Warn":1`) {
+		t.Errorf("expvar %v = %v, expected the stats", name, v)
+	}
+	logger.Close()
+}
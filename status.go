@@ -0,0 +1,166 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TargetStatus describes the health of a target of a logger.
+type TargetStatus struct {
+	Target Target
+	// whether the target is receiving messages, i.e. the logger is open and the target was not removed.
+	Open bool
+	// whether the last message was processed, and no message was dropped since then.
+	Healthy bool
+	// the number of messages handed to the target.
+	Processed int64
+	// the number of messages the target failed to process. Only the targets run by V2Target report their failures,
+	// besides the panics of the targets and the messages not handed to a disabled target.
+	Failed int64
+	// the number of messages dropped because the queue of the target was full, with DropOnFull.
+	Dropped int64
+	// the number of messages waiting in the queue of the target, and its size, TargetBuffer,
+	// or their sums if the target is processed by several goroutines.
+	QueueDepth    int
+	QueueCapacity int
+	// the last error reported by the target, and when it was reported.
+	LastError     error
+	LastErrorTime time.Time
+	// whether the target was disabled after panicking MaxPanics times.
+	Disabled bool
+}
+
+// targetStats holds the counters of a target worker. The counters are first to be aligned for atomic operations.
+type targetStats struct {
+	processed int64
+	failed    int64
+	dropped   int64
+	stopped   int32 // set when the worker has handed the closing signal to the target
+	panics    int32 // the number of panics of the target
+	disabled  int32 // set when the target is disabled after panicking MaxPanics times
+
+	lock          sync.Mutex // guards the fields below
+	unhealthy     bool
+	lastError     error
+	lastErrorTime time.Time
+}
+
+// TargetStatus returns the status of the targets of the logger, in the order of Targets.
+// It may be polled by a health check to find out the log destinations which stopped accepting messages.
+func (l *coreLogger) TargetStatus() []TargetStatus {
+	c := l.current()
+	statuses := make([]TargetStatus, 0, len(c.workers))
+	for _, w := range c.workers {
+		statuses = append(statuses, w.status(c.open))
+	}
+	return statuses
+}
+
+// OnUnhealthy registers functions to be called when a target becomes unhealthy: it fails to process
+// a message, or a message is dropped because its queue is full with DropOnFull. They are called again
+// only after the target has processed a message successfully. The functions are called in their own
+// goroutine, so they may log messages with the logger itself.
+func (l *coreLogger) OnUnhealthy(callbacks ...func(TargetStatus)) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	current, _ := l.unhealthy.Load().([]func(TargetStatus))
+	l.unhealthy.Store(append(append([]func(TargetStatus){}, current...), callbacks...))
+}
+
+// handle hands a message to the target of the worker and records the outcome.
+func (w *targetWorker) handle(entry *Entry) {
+	if atomic.LoadInt32(&w.stats.disabled) == 1 {
+		atomic.AddInt64(&w.stats.failed, 1)
+		return
+	}
+	if f, ok := w.target.(folder); ok {
+		f.fold(entry)
+	}
+	atomic.AddInt32(&w.logger.processing, 1)
+	err := w.process(entry)
+	atomic.AddInt32(&w.logger.processing, -1)
+	atomic.AddInt64(&w.stats.processed, 1)
+	if err != nil {
+		atomic.AddInt64(&w.stats.failed, 1)
+	}
+	w.stats.lock.Lock()
+	if err != nil {
+		w.stats.lastError, w.stats.lastErrorTime = err, time.Now()
+	}
+	w.setHealthy(err == nil)
+}
+
+// handleBatch hands several messages at once to the BatchTarget of the worker.
+func (w *targetWorker) handleBatch(entries []*Entry) {
+	if atomic.LoadInt32(&w.stats.disabled) == 1 {
+		atomic.AddInt64(&w.stats.failed, int64(len(entries)))
+		return
+	}
+	if f, ok := w.target.(folder); ok {
+		for _, entry := range entries {
+			f.fold(entry)
+		}
+	}
+	atomic.AddInt32(&w.logger.processing, 1)
+	err := w.processBatch(entries)
+	atomic.AddInt32(&w.logger.processing, -1)
+	atomic.AddInt64(&w.stats.processed, int64(len(entries)))
+	if err != nil {
+		atomic.AddInt64(&w.stats.failed, int64(len(entries)))
+	}
+	w.stats.lock.Lock()
+	if err != nil {
+		w.stats.lastError, w.stats.lastErrorTime = err, time.Now()
+	}
+	w.setHealthy(err == nil)
+}
+
+// drop records a message dropped because the queue of the worker is full.
+func (w *targetWorker) drop() {
+	w.dropped++
+	atomic.AddInt64(&w.stats.dropped, 1)
+	w.stats.lock.Lock()
+	w.setHealthy(false)
+}
+
+// setHealthy updates the health of the target and calls the OnUnhealthy callbacks if the target has
+// just become unhealthy. It must be called with w.stats.lock held, and releases it.
+func (w *targetWorker) setHealthy(healthy bool) {
+	becameUnhealthy := !healthy && !w.stats.unhealthy
+	w.stats.unhealthy = !healthy
+	w.stats.lock.Unlock()
+	if !becameUnhealthy {
+		return
+	}
+	callbacks, _ := w.logger.unhealthy.Load().([]func(TargetStatus))
+	if len(callbacks) == 0 {
+		return
+	}
+	status := w.status(true)
+	for _, callback := range callbacks {
+		go callback(status)
+	}
+}
+
+func (w *targetWorker) status(open bool) TargetStatus {
+	w.stats.lock.Lock()
+	defer w.stats.lock.Unlock()
+	return TargetStatus{
+		Target:        w.target,
+		Open:          open && atomic.LoadInt32(&w.stats.stopped) == 0,
+		Healthy:       !w.stats.unhealthy,
+		Processed:     atomic.LoadInt64(&w.stats.processed),
+		Failed:        atomic.LoadInt64(&w.stats.failed),
+		Dropped:       atomic.LoadInt64(&w.stats.dropped),
+		QueueDepth:    w.queueDepth(),
+		QueueCapacity: w.queueCapacity(),
+		LastError:     w.stats.lastError,
+		LastErrorTime: w.stats.lastErrorTime,
+		Disabled:      atomic.LoadInt32(&w.stats.disabled) == 1,
+	}
+}
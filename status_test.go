@@ -0,0 +1,104 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+func TestTargetStatus(t *testing.T) {
+	logger := log.NewLogger()
+	logger.ErrorWriter = &bytes.Buffer{}
+	logger.Sync()
+	unhealthy := make(chan log.TargetStatus, 10)
+	logger.OnUnhealthy(func(status log.TargetStatus) {
+		unhealthy <- status
+	})
+	v2 := log.NewV2Target(newV2Target())
+	v2.MaxRetries = 0
+	logger.SetTarget(v2)
+
+	logger.Info("t1")
+	logger.Info("t2 fail")
+	logger.Info("t3 fail")
+
+	statuses := logger.TargetStatus()
+	if len(statuses) != 1 {
+		t.Fatalf("len(TargetStatus()) = %v, expected %v", len(statuses), 1)
+	}
+	status := statuses[0]
+	if status.Target != v2 || !status.Open || status.Healthy {
+		t.Errorf("TargetStatus() = %+v, expected the open and unhealthy target", status)
+	}
+	if status.Processed != 3 || status.Failed != 2 {
+		t.Errorf("TargetStatus() = %v processed, %v failed, expected %v, %v", status.Processed, status.Failed, 3, 2)
+	}
+	if status.LastError == nil || status.LastError.Error() != "rejected" || status.LastErrorTime.IsZero() {
+		t.Errorf("TargetStatus().LastError = %v, expected %v", status.LastError, "rejected")
+	}
+
+	// the callback is called once until the target recovers
+	select {
+	case status := <-unhealthy:
+		if status.Failed != 1 {
+			t.Errorf("OnUnhealthy status.Failed = %v, expected %v", status.Failed, 1)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the OnUnhealthy callback was not called")
+	}
+	logger.Info("t4")
+	if status := logger.TargetStatus()[0]; !status.Healthy {
+		t.Errorf("TargetStatus().Healthy = false, expected true after a message was processed")
+	}
+	logger.Info("t5 fail")
+	select {
+	case <-unhealthy:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the OnUnhealthy callback was not called after the target recovered")
+	}
+	if len(unhealthy) != 0 {
+		t.Errorf("the OnUnhealthy callback was called %v more times, expected once per failure streak", len(unhealthy))
+	}
+
+	logger.Close()
+	if status := logger.TargetStatus()[0]; status.Open || status.Processed != 5 {
+		t.Errorf("TargetStatus() = %+v after Close, expected a closed target which processed 5 messages", status)
+	}
+}
+
+func TestTargetStatusDropped(t *testing.T) {
+	logger := log.NewLogger()
+	logger.ErrorWriter = &lockedBuffer{}
+	logger.TargetBuffer = 0
+	logger.MaxGoroutines = 0
+	logger.DropOnFull = true
+	unhealthy := make(chan log.TargetStatus, 10)
+	logger.OnUnhealthy(func(status log.TargetStatus) {
+		unhealthy <- status
+	})
+	target := newV2Target()
+	logger.SetTarget(log.NewV2Target(target))
+
+	// the target is blocked by the first message it receives, so that the next ones are dropped
+	for i := 0; i < 10; i++ {
+		logger.Info("t block")
+	}
+	select {
+	case status := <-unhealthy:
+		if status.Dropped == 0 {
+			t.Errorf("OnUnhealthy status.Dropped = %v, expected dropped messages", status.Dropped)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the OnUnhealthy callback was not called")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	logger.Shutdown(ctx)
+}
@@ -0,0 +1,336 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// webSocketGUID is the value a WebSocket handshake key is combined with (RFC 6455).
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// StreamTarget streams the live log messages to HTTP clients. It is an http.Handler serving
+// Server-Sent Events, or a WebSocket when the request asks for an upgrade, which lets a lightweight
+// admin dashboard show the logs. The clients choose the messages with these query parameters:
+//
+//	level     the maximum level of the messages, e.g. "warn"
+//	category  the categories of the messages, separated by commas, which can use "*" as a suffix
+//	n         the number of the last messages sent first, no more than TailSize
+//	format    "json" to send the messages formatted by AppendJSON instead of the logger's formatter
+//
+// The handler exposes the logs, so it should be mounted behind the application's authentication.
+type StreamTarget struct {
+	*Filter
+	// the number of the last messages kept for the clients asking for them.
+	TailSize int
+	// the interval of the comments or pings keeping the idle connections open. Zero disables them.
+	KeepAlive time.Duration
+
+	tailLock sync.Mutex // guards tail
+	tail     *tailer
+	close    chan bool
+}
+
+// NewStreamTarget creates a StreamTarget.
+// The new StreamTarget takes these default options:
+// MaxLevel: LevelDebug, TailSize: 100, KeepAlive: 30s
+func NewStreamTarget() *StreamTarget {
+	return &StreamTarget{
+		Filter:    &Filter{MaxLevel: LevelDebug},
+		TailSize:  100,
+		KeepAlive: 30 * time.Second,
+		close:     make(chan bool, 0),
+	}
+}
+
+// Open prepares StreamTarget for streaming log messages.
+func (t *StreamTarget) Open(io.Writer) error {
+	t.Filter.Init()
+	t.tailLock.Lock()
+	t.tail = newTailer(t.TailSize)
+	t.tailLock.Unlock()
+	return nil
+}
+
+// Process sends a log message to the connected clients.
+func (t *StreamTarget) Process(e *Entry) {
+	if e == nil {
+		t.tailLock.Lock()
+		t.tail.close()
+		t.tail = nil
+		t.tailLock.Unlock()
+		t.close <- true
+		return
+	}
+	if t.Allow(e) {
+		t.tail.add(e)
+	}
+}
+
+// Close closes the stream target, which ends the streams of the connected clients.
+func (t *StreamTarget) Close() {
+	<-t.close
+}
+
+// stream is the view of the messages requested by a client.
+type stream struct {
+	filter *Filter
+	n      int
+	json   bool
+}
+
+// parseStream reads the query parameters of a request.
+func parseStream(query url.Values) (*stream, error) {
+	s := &stream{filter: &Filter{MaxLevel: math.MaxInt32}}
+	if level := query.Get("level"); level != "" {
+		if err := s.filter.MaxLevel.Set(level); err != nil {
+			return nil, err
+		}
+	}
+	for _, categories := range query["category"] {
+		for _, category := range strings.Split(categories, ",") {
+			if category = strings.TrimSpace(category); category != "" {
+				s.filter.Categories = append(s.filter.Categories, category)
+			}
+		}
+	}
+	s.filter.Init()
+	if n := query.Get("n"); n != "" {
+		var err error
+		if s.n, err = strconv.Atoi(n); err != nil || s.n < 0 {
+			return nil, errors.New("invalid number of messages " + strconv.Quote(n))
+		}
+	}
+	switch format := query.Get("format"); format {
+	case "", "text":
+	case "json":
+		s.json = true
+	default:
+		return nil, errors.New("unknown format " + strconv.Quote(format))
+	}
+	return s, nil
+}
+
+// message returns the message of an entry as it is sent to the client.
+func (s *stream) message(e *Entry) string {
+	if s.json {
+		return formatWith(AppendJSON, nil, e)
+	}
+	return e.String()
+}
+
+// ServeHTTP streams the log messages to a client until it disconnects or the target is closed.
+func (t *StreamTarget) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s, err := parseStream(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	t.tailLock.Lock()
+	tail := t.tail
+	t.tailLock.Unlock()
+	if tail == nil {
+		http.Error(w, "StreamTarget is not open", http.StatusServiceUnavailable)
+		return
+	}
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		t.serveWebSocket(w, r, tail, s)
+	} else {
+		t.serveEvents(w, r, tail, s)
+	}
+}
+
+// serveEvents streams the messages as Server-Sent Events, whose ids are the sequence numbers of the messages.
+func (t *StreamTarget) serveEvents(w http.ResponseWriter, r *http.Request, tail *tailer, s *stream) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	entries, err := tail.subscribe(r.Context(), s.n)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive, stop := t.keepAlive()
+	defer stop()
+	var buf []byte
+	for {
+		select {
+		case e, ok := <-entries:
+			if !ok {
+				return
+			}
+			if !s.filter.Allow(e) {
+				continue
+			}
+			buf = append(buf[:0], "id: "...)
+			buf = strconv.AppendUint(buf, e.Seq, 10)
+			buf = append(buf, '\n')
+			for _, line := range strings.Split(s.message(e), "\n") {
+				buf = append(buf, "data: "...)
+				buf = append(buf, strings.TrimSuffix(line, "\r")...)
+				buf = append(buf, '\n')
+			}
+			buf = append(buf, '\n')
+		case <-keepAlive:
+			buf = append(buf[:0], ":\n\n"...)
+		}
+		if _, err := w.Write(buf); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// serveWebSocket streams the messages over a WebSocket, one text message each.
+// The messages sent by the client are discarded.
+func (t *StreamTarget) serveWebSocket(w http.ResponseWriter, r *http.Request, tail *tailer, s *stream) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != "GET" || key == "" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+		http.Error(w, "invalid WebSocket handshake", http.StatusBadRequest)
+		return
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		// a page of another site must not read the logs with the credentials of the user
+		if u, err := url.Parse(origin); err != nil || !strings.EqualFold(u.Host, r.Host) {
+			http.Error(w, "cross-origin WebSocket requests are not allowed", http.StatusForbidden)
+			return
+		}
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket is not supported", http.StatusInternalServerError)
+		return
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	entries, err := tail.subscribe(ctx, s.n)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: ")
+	rw.WriteString(base64.StdEncoding.EncodeToString(sum[:]))
+	rw.WriteString("\r\n\r\n")
+	if rw.Flush() != nil {
+		return
+	}
+	go func() {
+		// the stream ends when the client closes the connection
+		defer cancel()
+		for {
+			if opcode, err := readWebSocketFrame(rw.Reader); err != nil || opcode == 8 {
+				return
+			}
+		}
+	}()
+
+	keepAlive, stop := t.keepAlive()
+	defer stop()
+	var buf []byte
+	for {
+		select {
+		case e, ok := <-entries:
+			if !ok {
+				// the target is closed, or the client has closed the connection
+				rw.Write(appendWebSocketFrame(buf[:0], 8, nil))
+				rw.Flush()
+				return
+			}
+			if !s.filter.Allow(e) {
+				continue
+			}
+			buf = appendWebSocketFrame(buf[:0], 1, []byte(s.message(e)))
+		case <-keepAlive:
+			buf = appendWebSocketFrame(buf[:0], 9, nil)
+		}
+		if _, err := rw.Write(buf); err != nil || rw.Flush() != nil {
+			return
+		}
+	}
+}
+
+// keepAlive returns a channel ticking every KeepAlive, or never if it is zero, and a function stopping it.
+func (t *StreamTarget) keepAlive() (<-chan time.Time, func()) {
+	if t.KeepAlive <= 0 {
+		return nil, func() {}
+	}
+	ticker := time.NewTicker(t.KeepAlive)
+	return ticker.C, ticker.Stop
+}
+
+// appendWebSocketFrame appends an unmasked frame, as sent by a server, holding a whole message.
+func appendWebSocketFrame(buf []byte, opcode byte, payload []byte) []byte {
+	buf = append(buf, 0x80|opcode)
+	switch n := len(payload); {
+	case n < 126:
+		buf = append(buf, byte(n))
+	case n <= math.MaxUint16:
+		buf = append(buf, 126, byte(n>>8), byte(n))
+	default:
+		buf = append(buf, 127)
+		for shift := 56; shift >= 0; shift -= 8 {
+			buf = append(buf, byte(uint64(n)>>uint(shift)))
+		}
+	}
+	return append(buf, payload...)
+}
+
+// readWebSocketFrame reads a frame sent by a client and returns its opcode, discarding its payload.
+func readWebSocketFrame(r *bufio.Reader) (byte, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:2]); err != nil {
+		return 0, err
+	}
+	opcode := header[0] & 0x0f
+	masked := header[1]&0x80 != 0
+	size := uint64(header[1] & 0x7f)
+	switch size {
+	case 126:
+		if _, err := io.ReadFull(r, header[:2]); err != nil {
+			return 0, err
+		}
+		size = uint64(header[0])<<8 | uint64(header[1])
+	case 127:
+		if _, err := io.ReadFull(r, header[:8]); err != nil {
+			return 0, err
+		}
+		size = 0
+		for _, b := range header {
+			size = size<<8 | uint64(b)
+		}
+	}
+	if masked {
+		size += 4
+	}
+	_, err := io.CopyN(ioutil.Discard, r, int64(size))
+	return opcode, err
+}
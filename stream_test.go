@@ -0,0 +1,128 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+// readEvent returns the lines of the next Server-Sent Event.
+func readEvent(r *bufio.Reader) ([]string, error) {
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return lines, err
+		}
+		if line == "\n" {
+			return lines, nil
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+}
+
+func TestStreamTargetEvents(t *testing.T) {
+	target := log.NewStreamTarget()
+	recorder := httptest.NewRecorder()
+	target.ServeHTTP(recorder, httptest.NewRequest("GET", "/debug/logs", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("ServeHTTP() = %v before Open, expected %v", recorder.Code, http.StatusServiceUnavailable)
+	}
+
+	logger := log.NewLogger()
+	logger.Sync()
+	logger.SetTarget(target)
+	server := httptest.NewServer(target)
+	defer server.Close()
+
+	recorder = httptest.NewRecorder()
+	target.ServeHTTP(recorder, httptest.NewRequest("GET", "/debug/logs?level=loud", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("ServeHTTP() = %v for an unknown level, expected %v", recorder.Code, http.StatusBadRequest)
+	}
+
+	logger.GetLogger("app.db").Info("a")
+	logger.GetLogger("app.db").Warn("b")
+	logger.GetLogger("other").Error("c")
+	res, err := http.Get(server.URL + "?n=10&level=warn&category=app.*")
+	if err != nil {
+		t.Fatalf("GET = %v, expected no error", err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, expected text/event-stream", ct)
+	}
+	logger.GetLogger("app.web").Warn("d\ne")
+
+	r := bufio.NewReader(res.Body)
+	event, err := readEvent(r)
+	if err != nil || len(event) != 2 || event[0] != "id: 2" || !strings.HasSuffix(event[1], "|Warn|app.db|b") {
+		t.Errorf("event = %q, %v, expected the message b", event, err)
+	}
+	event, err = readEvent(r)
+	if err != nil || len(event) != 3 || event[0] != "id: 4" || !strings.HasSuffix(event[1], "|app.web|d") || event[2] != "data: e" {
+		t.Errorf("event = %q, %v, expected the message d on two lines", event, err)
+	}
+
+	logger.Close()
+	if _, err := readEvent(r); err != io.EOF {
+		t.Errorf("readEvent() = %v once the target is closed, expected %v", err, io.EOF)
+	}
+}
+
+func TestStreamTargetWebSocket(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	target := log.NewStreamTarget()
+	logger.SetTarget(target)
+	defer logger.Close()
+	server := httptest.NewServer(target)
+	defer server.Close()
+
+	logger.Info("hello")
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial() = %v, expected no error", err)
+	}
+	defer conn.Close()
+	req, _ := http.NewRequest("GET", server.URL+"?n=1&format=json", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Write(conn)
+
+	r := bufio.NewReader(conn)
+	res, err := http.ReadResponse(r, req)
+	if err != nil {
+		t.Fatalf("ReadResponse() = %v, expected no error", err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols || res.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake = %v %q, expected the WebSocket to be accepted", res.StatusCode, res.Header.Get("Sec-WebSocket-Accept"))
+	}
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil || header[0] != 0x81 {
+		t.Fatalf("frame header = %x, %v, expected a text frame", header, err)
+	}
+	payload := make([]byte, header[1])
+	io.ReadFull(r, payload)
+	if !strings.Contains(string(payload), `"message":"hello"`) {
+		t.Errorf("frame = %s, expected the message in JSON", payload)
+	}
+
+	// a masked close frame with no payload
+	conn.Write([]byte{0x88, 0x80, 1, 2, 3, 4})
+	if _, err := io.ReadFull(r, header); err != nil || header[0] != 0x88 {
+		t.Errorf("frame header = %x, %v, expected a close frame", header, err)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// tailBuffer is the number of entries buffered for a subscriber beyond the last entries sent first.
+// The entries are dropped for a subscriber whose buffer is full, so that a slow reader does not block the target.
+const tailBuffer = 256
+
+// errTailClosed is returned when following a target which is not open.
+var errTailClosed = errors.New("the target is not open")
+
+// tailer keeps the last entries processed by a target and streams the new ones to the subscribers.
+type tailer struct {
+	lock        sync.Mutex
+	recent      []*Entry // a ring of the last entries
+	next        int      // the index of the oldest entry once the ring is full
+	subscribers map[chan *Entry]bool
+	closed      bool
+}
+
+func newTailer(size int) *tailer {
+	if size < 0 {
+		size = 0
+	}
+	return &tailer{
+		recent:      make([]*Entry, 0, size),
+		subscribers: map[chan *Entry]bool{},
+	}
+}
+
+// add records an entry and sends it to the subscribers.
+func (t *tailer) add(e *Entry) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if cap(t.recent) > 0 {
+		if len(t.recent) < cap(t.recent) {
+			t.recent = append(t.recent, e)
+		} else {
+			t.recent[t.next] = e
+			t.next = (t.next + 1) % len(t.recent)
+		}
+	}
+	for ch := range t.subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// last returns up to n of the last entries, from the oldest.
+func (t *tailer) last(n int) []*Entry {
+	entries := append(append([]*Entry{}, t.recent[t.next:]...), t.recent[:t.next]...)
+	if n < len(entries) {
+		entries = entries[len(entries)-n:]
+	}
+	return entries
+}
+
+// subscribe returns a channel receiving up to n of the last entries, then the new ones until
+// the context is done or the tailer is closed, when the channel is closed.
+func (t *tailer) subscribe(ctx context.Context, n int) (<-chan *Entry, error) {
+	if n < 0 {
+		n = 0
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.closed {
+		return nil, errTailClosed
+	}
+	entries := t.last(n)
+	ch := make(chan *Entry, len(entries)+tailBuffer)
+	for _, e := range entries {
+		ch <- e
+	}
+	t.subscribers[ch] = true
+	go func() {
+		<-ctx.Done()
+		t.unsubscribe(ch)
+	}()
+	return ch, nil
+}
+
+func (t *tailer) unsubscribe(ch chan *Entry) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.subscribers[ch] {
+		delete(t.subscribers, ch)
+		close(ch)
+	}
+}
+
+// close closes the channels of the subscribers.
+func (t *tailer) close() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.closed = true
+	for ch := range t.subscribers {
+		delete(t.subscribers, ch)
+		close(ch)
+	}
+}
+
+// Tail returns a channel receiving up to the last n entries written by the target, which keeps the last
+// TailSize entries, then the entries written next, so that an admin endpoint such as /debug/logs can show
+// the logs without reading the file. The channel is closed when the context is done or the target is closed.
+// The entries are dropped for a reader which falls behind. They must not be modified.
+func (t *FileTarget) Tail(ctx context.Context, n int) (<-chan *Entry, error) {
+	t.tailLock.Lock()
+	tail := t.tail
+	t.tailLock.Unlock()
+	if tail == nil {
+		return nil, errors.New("FileTarget is not open")
+	}
+	return tail.subscribe(ctx, n)
+}
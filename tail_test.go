@@ -0,0 +1,61 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestFileTargetTail(t *testing.T) {
+	logFile := "tail.log"
+	os.Remove(logFile)
+	defer os.Remove(logFile)
+
+	logger := log.NewLogger()
+	logger.Sync()
+	target := log.NewFileTarget()
+	target.FileName = logFile
+	target.TailSize = 3
+	if _, err := target.Tail(context.Background(), 1); err == nil {
+		t.Errorf("Tail() = nil error before Open, expected an error")
+	}
+	logger.SetTarget(target)
+	for _, message := range []string{"t1", "t2", "t3", "t4"} {
+		logger.Info(message)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	entries, err := target.Tail(ctx, 2)
+	if err != nil {
+		t.Fatalf("Tail() = %v, expected no error", err)
+	}
+	all, err := target.Tail(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("Tail() = %v, expected no error", err)
+	}
+	logger.Info("t5")
+	for _, expected := range []string{"t3", "t4", "t5"} {
+		if e := <-entries; e == nil || e.Message != expected {
+			t.Errorf("Tail() received %v, expected %v", e, expected)
+		}
+	}
+	cancel()
+	if _, ok := <-entries; ok {
+		t.Errorf("the channel of Tail() is open once the context is done")
+	}
+
+	logger.Close()
+	var messages []string
+	for e := range all {
+		messages = append(messages, e.Message)
+	}
+	if len(messages) != 4 || messages[0] != "t2" || messages[3] != "t5" {
+		t.Errorf("Tail() received %v, expected the last 3 entries and t5 until the target is closed", messages)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// TargetV2 is a target which reports its errors and honors cancellation.
+// A TargetV2 is added to a logger through NewV2Target.
+type TargetV2 interface {
+	// Open prepares the target for processing log messages.
+	Open(errWriter io.Writer) error
+	// Process writes a log message and returns the error preventing it, if any.
+	// The messages not allowed by the filter of the target are ignored without error.
+	// ctx is canceled when the logger gives up waiting for the target.
+	Process(ctx context.Context, e *Entry) error
+	// Close flushes the pending messages and closes the target, giving up when ctx is done.
+	Close(ctx context.Context) error
+	SetLevel(interface{})
+	SetLevels(...Level)
+}
+
+// V2Target runs a TargetV2 as a target of a logger. A message which fails to be processed is retried
+// with exponential backoff, and the last error is written to the ErrorWriter of the logger.
+// When the logger is closed by Shutdown, the context of Shutdown is passed to TargetV2.Close
+// and the message being processed is canceled if the context is done.
+type V2Target struct {
+	// the target the messages are handed to.
+	Target TargetV2
+	// how many times a message which failed to be processed is retried.
+	MaxRetries int
+	// the wait before the first retry. It doubles after each retry.
+	RetryWait time.Duration
+
+	errWriter io.Writer
+	ctx       context.Context
+	cancel    context.CancelFunc
+	done      chan bool
+	canceled  int // the number of messages not processed because ctx was canceled
+}
+
+// NewV2Target creates a V2Target running the given TargetV2.
+// The new V2Target takes these default options:
+// MaxRetries: 2, RetryWait: 100ms.
+func NewV2Target(target TargetV2) *V2Target {
+	return &V2Target{
+		Target:     target,
+		MaxRetries: 2,
+		RetryWait:  100 * time.Millisecond,
+	}
+}
+
+// Open opens the TargetV2.
+func (t *V2Target) Open(errWriter io.Writer) error {
+	if err := t.Target.Open(errWriter); err != nil {
+		return err
+	}
+	t.errWriter = errWriter
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+	t.done = make(chan bool)
+	t.canceled = 0
+	return nil
+}
+
+// Process hands a log message to the TargetV2, retrying it if it fails.
+func (t *V2Target) Process(e *Entry) {
+	if e == nil {
+		close(t.done)
+		return
+	}
+	t.process(e)
+}
+
+// process hands a log message to the TargetV2, retrying it if it fails, and returns the last error.
+func (t *V2Target) process(e *Entry) error {
+	if err := t.ctx.Err(); err != nil {
+		t.canceled++
+		return err
+	}
+	wait := t.RetryWait
+	for attempt := 0; ; attempt++ {
+		err := t.Target.Process(t.ctx, e)
+		if err == nil {
+			return nil
+		}
+		if attempt >= t.MaxRetries || t.ctx.Err() != nil {
+			fmt.Fprintf(t.errWriter, "%T write error: %v\n", t.Target, err)
+			return err
+		}
+		select {
+		case <-time.After(wait):
+		case <-t.ctx.Done():
+		}
+		wait *= 2
+	}
+}
+
+// Close closes the TargetV2 once the messages have been processed.
+func (t *V2Target) Close() {
+	t.closeContext(context.Background())
+}
+
+// closeContext closes the TargetV2 once the messages have been processed, canceling
+// the message being processed when ctx is done.
+func (t *V2Target) closeContext(ctx context.Context) {
+	defer t.cancel()
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		t.cancel()
+		<-t.done
+	}
+	if t.canceled > 0 {
+		fmt.Fprintf(t.errWriter, "%v messages were not processed by target %T because the shutdown was canceled\n", t.canceled, t.Target)
+	}
+	if err := t.Target.Close(ctx); err != nil {
+		fmt.Fprintf(t.errWriter, "Failed to close target %T: %v\n", t.Target, err)
+	}
+}
+
+func (t *V2Target) SetLevel(level interface{}) {
+	t.Target.SetLevel(level)
+}
+
+func (t *V2Target) SetLevels(levels ...Level) {
+	t.Target.SetLevels(levels...)
+}
+
+// legacyTarget adapts a Target to TargetV2.
+type legacyTarget struct {
+	Target
+}
+
+// AdaptTarget adapts a Target to TargetV2. Process reports the errors of the targets implementing
+// EntryWriter, and Close gives up waiting for the target when the context is done.
+func AdaptTarget(target Target) TargetV2 {
+	if t, ok := target.(*V2Target); ok {
+		return t.Target
+	}
+	return legacyTarget{target}
+}
+
+func (t legacyTarget) Process(ctx context.Context, e *Entry) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	w, ok := t.Target.(EntryWriter)
+	if !ok {
+		t.Target.Process(e)
+		return nil
+	}
+	if f, ok := t.Target.(interface{ Allow(*Entry) bool }); ok && !f.Allow(e) {
+		return nil
+	}
+	return w.WriteEntry(e)
+}
+
+func (t legacyTarget) Close(ctx context.Context) error {
+	closed := make(chan bool)
+	go func() {
+		// a target may wait in Process(nil) until it is closed
+		go t.Target.Process(nil)
+		t.Target.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+// v2Target fails to process the messages containing "fail" and blocks on those containing "block".
+type v2Target struct {
+	*log.Filter
+	lock     sync.Mutex
+	attempts map[string]int
+	closed   chan bool
+}
+
+func newV2Target() *v2Target {
+	return &v2Target{Filter: &log.Filter{MaxLevel: log.LevelDebug}, attempts: map[string]int{}, closed: make(chan bool)}
+}
+
+func (t *v2Target) Open(io.Writer) error {
+	return nil
+}
+
+func (t *v2Target) Process(ctx context.Context, e *log.Entry) error {
+	t.lock.Lock()
+	t.attempts[e.Message]++
+	t.lock.Unlock()
+	switch {
+	case strings.Contains(e.Message, "fail"):
+		return errors.New("rejected")
+	case strings.Contains(e.Message, "block"):
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (t *v2Target) Close(ctx context.Context) error {
+	close(t.closed)
+	return nil
+}
+
+func (t *v2Target) attemptsOf(message string) int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.attempts[message]
+}
+
+// lockedBuffer is a bytes.Buffer which can be written and read concurrently.
+type lockedBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
+
+func TestV2Target(t *testing.T) {
+	logger := log.NewLogger()
+	errWriter := &lockedBuffer{}
+	logger.ErrorWriter = errWriter
+	target := newV2Target()
+	v2 := log.NewV2Target(target)
+	v2.RetryWait = time.Millisecond
+	logger.SetTarget(v2)
+
+	logger.Info("t1")
+	logger.Info("t2 fail")
+	logger.Close()
+
+	select {
+	case <-target.closed:
+	default:
+		t.Errorf("the TargetV2 was not closed")
+	}
+	if n := target.attemptsOf("t1"); n != 1 {
+		t.Errorf("attempts of t1 = %v, expected %v", n, 1)
+	}
+	if n := target.attemptsOf("t2 fail"); n != 3 {
+		t.Errorf("attempts of t2 = %v, expected %v", n, 3)
+	}
+	if s := errWriter.String(); !strings.Contains(s, "*log_test.v2Target write error: rejected") {
+		t.Errorf("error output = %q, expected the write error", s)
+	}
+}
+
+func TestV2TargetShutdown(t *testing.T) {
+	logger := log.NewLogger()
+	errWriter := &lockedBuffer{}
+	logger.ErrorWriter = errWriter
+	// the messages are sent to the pipeline in order
+	logger.MaxGoroutines = 0
+	target := newV2Target()
+	logger.SetTarget(log.NewV2Target(target))
+
+	logger.Info("t1 block")
+	logger.Info("t2")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := logger.Shutdown(ctx); err == nil {
+		t.Errorf("Shutdown() = nil, expected an error as the target is blocked")
+	}
+
+	// the blocked message is canceled, and the target is closed in the background
+	select {
+	case <-target.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the TargetV2 was not closed")
+	}
+	if n := target.attemptsOf("t2"); n != 0 {
+		t.Errorf("attempts of t2 = %v, expected %v", n, 0)
+	}
+	if s := errWriter.String(); !strings.Contains(s, "1 messages were not processed by target *log_test.v2Target") {
+		t.Errorf("error output = %q, expected the canceled message", s)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestAdaptTarget(t *testing.T) {
+	target := log.NewWriterTarget(failingWriter{})
+	target.MaxLevel = log.LevelInfo
+	v2 := log.AdaptTarget(target)
+	if err := v2.Open(&bytes.Buffer{}); err != nil {
+		t.Fatalf("Open(): %v", err)
+	}
+	if err := v2.Process(context.Background(), &log.Entry{Level: log.LevelInfo}); err == nil || err.Error() != "disk full" {
+		t.Errorf("Process() = %v, expected the write error", err)
+	}
+	if err := v2.Process(context.Background(), &log.Entry{Level: log.LevelDebug}); err != nil {
+		t.Errorf("Process() = %v, expected nil for a filtered message", err)
+	}
+	if err := v2.Close(context.Background()); err != nil {
+		t.Errorf("Close() = %v, expected nil", err)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// TeeTarget fans log messages out to several child targets. Each child gets its own bounded
+// queue and goroutine, so that a slow child (e.g. a network target) cannot stall the others.
+type TeeTarget struct {
+	*Filter
+	Targets []Target // the child targets
+	// the size of the queue of each child.
+	BufferSize int
+	// whether to wait when the queue of a child is full. If false, the message is dropped for that child.
+	Block bool
+
+	queues    []chan *Entry
+	dropped   []int64
+	wg        sync.WaitGroup
+	errWriter io.Writer
+	close     chan bool
+}
+
+// NewTeeTarget creates a TeeTarget with the given child targets.
+// The new TeeTarget takes these default options:
+// MaxLevel: LevelDebug, BufferSize: 1024, Block: false
+func NewTeeTarget(targets ...Target) *TeeTarget {
+	return &TeeTarget{
+		Filter:     &Filter{MaxLevel: LevelDebug},
+		Targets:    targets,
+		BufferSize: 1024,
+		close:      make(chan bool, 0),
+	}
+}
+
+// Open opens the child targets and starts their goroutines.
+// A child which fails to open is removed, as the logger does with its own targets.
+func (t *TeeTarget) Open(errWriter io.Writer) error {
+	t.Filter.Init()
+	if t.BufferSize < 0 {
+		return errors.New("TeeTarget.BufferSize must be no less than 0")
+	}
+	var targets []Target
+	for _, target := range t.Targets {
+		if err := target.Open(errWriter); err != nil {
+			fmt.Fprintf(errWriter, "Failed to open target: %v\n", err)
+		} else {
+			targets = append(targets, target)
+		}
+	}
+	t.Targets = targets
+	t.errWriter = errWriter
+	t.queues = make([]chan *Entry, len(targets))
+	t.dropped = make([]int64, len(targets))
+	for i, target := range targets {
+		t.queues[i] = make(chan *Entry, t.BufferSize)
+		t.wg.Add(1)
+		go t.process(target, t.queues[i])
+	}
+	return nil
+}
+
+func (t *TeeTarget) process(target Target, queue chan *Entry) {
+	defer t.wg.Done()
+	for {
+		entry := <-queue
+		target.Process(entry)
+		if entry == nil {
+			break
+		}
+	}
+}
+
+// Process puts a filtered log message into the queue of every child.
+func (t *TeeTarget) Process(e *Entry) {
+	if e == nil {
+		for _, queue := range t.queues {
+			queue <- nil
+		}
+		t.close <- true
+		return
+	}
+	if t.Allow(e) {
+		t.send(e, nil)
+	}
+}
+
+// send puts a message into the queues of the children selected by to, or of every child if to is nil.
+func (t *TeeTarget) send(e *Entry, to []bool) {
+	last := len(t.queues) - 1
+	for last >= 0 && to != nil && !to[last] {
+		last--
+	}
+	for i, queue := range t.queues {
+		if to != nil && !to[i] {
+			continue
+		}
+		// the entry is the tee's own copy: the last child gets it and the others a clone
+		entry := e
+		if i < last {
+			entry = e.Clone()
+		}
+		if t.Block {
+			queue <- entry
+			continue
+		}
+		select {
+		case queue <- entry:
+		default:
+			atomic.AddInt64(&t.dropped[i], 1)
+		}
+	}
+}
+
+// Close closes the child targets once they have processed their queued messages.
+func (t *TeeTarget) Close() {
+	<-t.close
+	for i, target := range t.Targets {
+		target.Close()
+		if n := atomic.SwapInt64(&t.dropped[i], 0); n > 0 {
+			fmt.Fprintf(t.errWriter, "TeeTarget dropped %v messages for target %T because its queue was full\n", n, target)
+		}
+	}
+	t.wg.Wait()
+}
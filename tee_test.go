@@ -0,0 +1,53 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestNewTeeTarget(t *testing.T) {
+	target := log.NewTeeTarget()
+	if target.MaxLevel != log.LevelDebug {
+		t.Errorf("NewTeeTarget.MaxLevel = %v, expected %v", target.MaxLevel, log.LevelDebug)
+	}
+	if target.BufferSize != 1024 {
+		t.Errorf("NewTeeTarget.BufferSize = %v, expected %v", target.BufferSize, 1024)
+	}
+}
+
+func TestTeeTarget(t *testing.T) {
+	buf1, buf2 := &bytes.Buffer{}, &bytes.Buffer{}
+	t1 := log.NewWriterTarget(buf1)
+	t2 := log.NewWriterTarget(buf2)
+	t2.Categories = []string{"system.*"}
+	memory := &MemoryTarget{
+		Filter: &log.Filter{MaxLevel: log.LevelDebug},
+		ready:  make(chan bool, 0),
+	}
+
+	logger := log.NewLogger()
+	logger.Sync()
+	logger.SetTarget(log.NewTeeTarget(t1, t2, memory))
+
+	logger.Info("t1")
+	logger.GetLogger("system.db").Info("t2")
+
+	logger.Close()
+
+	if !strings.Contains(buf1.String(), "t1") || !strings.Contains(buf1.String(), "t2") {
+		t.Errorf("buf1 = %q, expected t1 and t2", buf1.String())
+	}
+	if strings.Contains(buf2.String(), "t1") || !strings.Contains(buf2.String(), "t2") {
+		t.Errorf("buf2 = %q, expected only t2", buf2.String())
+	}
+	if len(memory.entries) != 2 {
+		t.Errorf("len(memory.entries) = %v, expected %v", len(memory.entries), 2)
+	}
+}
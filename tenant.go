@@ -0,0 +1,302 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TenantKey is the field holding the ID of the tenant a message is logged for.
+const TenantKey = "tenant_id"
+
+// ForTenant returns a logger attaching the ID of a tenant to the messages, so that the messages
+// of each tenant of a multi-tenant service can be limited and stored separately.
+func (l *Logger) ForTenant(id string) *Logger {
+	return l.With(TenantKey, id)
+}
+
+// Tenant returns the ID of the tenant the message is logged for, or "" if there is none.
+func (e *Entry) Tenant() string {
+	if id, ok := e.Fields[TenantKey]; ok {
+		return fmt.Sprint(id)
+	}
+	return ""
+}
+
+// TenantLimiter limits the number of messages each tenant may log per interval, so that a noisy tenant
+// cannot flood the logs of the others. Add its Hook to a logger:
+//
+//	limiter := log.NewTenantLimiter(1000, time.Minute)
+//	limiter.Limits["big-customer"] = 10000
+//	logger.AddHook(limiter.Hook)
+//
+// The messages without a tenant and the fatal messages are not limited. The intervals are counted by the time
+// of the messages. The counts of the tenants which have not logged in the current interval are forgotten.
+type TenantLimiter struct {
+	Limit int // the number of messages a tenant may log per interval
+	// the limits of the tenants which differ from Limit. It must not be modified once the hook is in use:
+	// call SetLimit instead.
+	Limits   map[string]int
+	Interval time.Duration // the length of the intervals. Zero means a single interval which never ends.
+
+	lock    sync.Mutex
+	windows map[string]*tenantWindow
+	dropped map[string]uint64 // the number of messages dropped for the tenants whose window was evicted
+	swept   time.Time         // the start of the interval the windows were last evicted in
+}
+
+// tenantWindow counts the messages of a tenant in the current interval.
+type tenantWindow struct {
+	start   time.Time
+	count   int
+	dropped uint64 // the number of messages dropped in all the intervals
+}
+
+// NewTenantLimiter creates a TenantLimiter letting each tenant log limit messages per interval.
+func NewTenantLimiter(limit int, interval time.Duration) *TenantLimiter {
+	return &TenantLimiter{
+		Limit:    limit,
+		Limits:   map[string]int{},
+		Interval: interval,
+		windows:  map[string]*tenantWindow{},
+		dropped:  map[string]uint64{},
+	}
+}
+
+// SetLimit changes the limit of a tenant. It can be called while messages are being logged.
+func (r *TenantLimiter) SetLimit(tenant string, limit int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	limits := make(map[string]int, len(r.Limits)+1)
+	for k, v := range r.Limits {
+		limits[k] = v
+	}
+	limits[tenant] = limit
+	r.Limits = limits
+}
+
+// Hook drops the entry if its tenant has reached its limit in the current interval.
+func (r *TenantLimiter) Hook(e *Entry) *Entry {
+	tenant := e.Tenant()
+	if tenant == "" || e.Level == LevelFatal {
+		return e
+	}
+	var start time.Time
+	if r.Interval > 0 {
+		start = e.Time.Truncate(r.Interval)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	limit, ok := r.Limits[tenant]
+	if !ok {
+		limit = r.Limit
+	}
+	if start.After(r.swept) {
+		r.evict(start)
+	}
+	w := r.windows[tenant]
+	if w == nil {
+		w = &tenantWindow{start: start, dropped: r.dropped[tenant]}
+		delete(r.dropped, tenant)
+		r.windows[tenant] = w
+	} else if !w.start.Equal(start) {
+		w.start, w.count = start, 0
+	}
+	if w.count >= limit {
+		w.dropped++
+		return nil
+	}
+	w.count++
+	return e
+}
+
+// evict forgets the windows of the intervals before the one starting at start, keeping their dropped messages.
+// It must be called with r.lock held.
+func (r *TenantLimiter) evict(start time.Time) {
+	if r.dropped == nil {
+		r.dropped = map[string]uint64{}
+	}
+	for tenant, w := range r.windows {
+		if w.start.Before(start) {
+			if w.dropped > 0 {
+				r.dropped[tenant] = w.dropped
+			}
+			delete(r.windows, tenant)
+		}
+	}
+	r.swept = start
+}
+
+// Dropped returns the number of messages dropped for each tenant which has reached its limit.
+func (r *TenantLimiter) Dropped() map[string]uint64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	dropped := map[string]uint64{}
+	for tenant, n := range r.dropped {
+		dropped[tenant] = n
+	}
+	for tenant, w := range r.windows {
+		if w.dropped > 0 {
+			dropped[tenant] = w.dropped
+		}
+	}
+	return dropped
+}
+
+// TenantTarget shards the log messages by tenant: each tenant gets its own target, created by New when
+// its first message arrives, e.g. a FileTarget writing to a file of the tenant, or an HTTPTarget posting
+// to an index of the tenant. The messages without a tenant go to the target of the tenant "".
+// The target of a tenant which has logged nothing for IdleTimeout is closed, and created again by New
+// when the next message of the tenant arrives.
+type TenantTarget struct {
+	*Filter
+	// creates the target of a tenant. The target is opened by the TenantTarget.
+	New func(tenant string) (Target, error)
+	// the time, measured by the time of the messages, after which the target of a tenant which has received
+	// no message is closed. Zero means never.
+	IdleTimeout time.Duration
+
+	targets   map[string]*tenantTarget
+	swept     time.Time // the time the idle targets were last closed
+	errWriter io.Writer
+	close     chan bool
+}
+
+// tenantTarget is the target of a tenant, or nil if it could not be opened.
+type tenantTarget struct {
+	target   Target
+	lastUsed time.Time
+}
+
+// NewTenantTarget creates a TenantTarget creating the targets of the tenants with newTarget.
+// The new TenantTarget takes these default options:
+// MaxLevel: LevelDebug, IdleTimeout: 10 minutes
+func NewTenantTarget(newTarget func(tenant string) (Target, error)) *TenantTarget {
+	return &TenantTarget{
+		Filter:      &Filter{MaxLevel: LevelDebug},
+		New:         newTarget,
+		IdleTimeout: 10 * time.Minute,
+		close:       make(chan bool, 0),
+	}
+}
+
+// NewTenantFileTarget creates a TenantTarget writing the messages of each tenant to its own file.
+// The file name contains "{tenant}", which is replaced by the ID of the tenant, or by "default" for the
+// messages without a tenant. The characters of the IDs other than letters, digits, '-', '_' and '.' are
+// replaced by '_', so that an ID cannot point to another directory. configure, if not nil, sets the other
+// options of the file targets.
+func NewTenantFileTarget(fileName string, configure func(*FileTarget)) *TenantTarget {
+	return NewTenantTarget(func(tenant string) (Target, error) {
+		if !strings.Contains(fileName, "{tenant}") {
+			return nil, errors.New("the file name of a TenantTarget must contain {tenant}")
+		}
+		if tenant == "" {
+			tenant = "default"
+		}
+		target := NewFileTarget()
+		target.FileName = strings.Replace(fileName, "{tenant}", safeFileName(tenant), -1)
+		if configure != nil {
+			configure(target)
+		}
+		return target, nil
+	})
+}
+
+// safeFileName replaces the characters which are not safe in a file name by '_'.
+func safeFileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		if r == '.' && s != "." && s != ".." {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// Open prepares TenantTarget for processing log messages.
+func (t *TenantTarget) Open(errWriter io.Writer) error {
+	t.Filter.Init()
+	if t.New == nil {
+		return errors.New("TenantTarget.New must be specified")
+	}
+	t.targets = map[string]*tenantTarget{}
+	t.swept = time.Time{}
+	t.errWriter = errWriter
+	return nil
+}
+
+// Process hands a filtered log message to the target of its tenant.
+func (t *TenantTarget) Process(e *Entry) {
+	if e == nil {
+		for _, child := range t.targets {
+			if child.target != nil {
+				// the targets signal Close from Process, as they do with the logger
+				go child.target.Process(nil)
+			}
+		}
+		t.close <- true
+		return
+	}
+	if !t.Allow(e) {
+		return
+	}
+	tenant := e.Tenant()
+	child, ok := t.targets[tenant]
+	if !ok {
+		child = &tenantTarget{}
+		target, err := t.New(tenant)
+		if err == nil {
+			err = target.Open(t.errWriter)
+		}
+		if err != nil {
+			// the messages of the tenant are dropped until the target is closed for being idle,
+			// rather than retrying on every message
+			fmt.Fprintf(t.errWriter, "TenantTarget was unable to open the target of tenant %q: %v\n", tenant, err)
+		} else {
+			child.target = target
+		}
+		t.targets[tenant] = child
+	}
+	child.lastUsed = e.Time
+	if child.target != nil {
+		child.target.Process(e)
+	}
+	if t.IdleTimeout > 0 && e.Time.Sub(t.swept) >= t.IdleTimeout {
+		t.closeIdle(e.Time)
+	}
+}
+
+// closeIdle closes the targets of the tenants which have received no message for IdleTimeout.
+func (t *TenantTarget) closeIdle(now time.Time) {
+	for tenant, child := range t.targets {
+		if now.Sub(child.lastUsed) < t.IdleTimeout {
+			continue
+		}
+		if child.target != nil {
+			go child.target.Process(nil)
+			child.target.Close()
+		}
+		delete(t.targets, tenant)
+	}
+	t.swept = now
+}
+
+// Close closes the targets of the tenants.
+func (t *TenantTarget) Close() {
+	<-t.close
+	for _, child := range t.targets {
+		if child.target != nil {
+			child.target.Close()
+		}
+	}
+}
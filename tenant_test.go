@@ -0,0 +1,154 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+func TestTenantLimiter(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	logger.SetClock(log.NewManualClock(time.Date(2015, 10, 22, 8, 39, 0, 0, time.UTC), 10*time.Second))
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	limiter := log.NewTenantLimiter(2, time.Minute)
+	limiter.Limits["big"] = 3
+	logger.AddHook(limiter.Hook)
+
+	small, big := logger.ForTenant("small"), logger.ForTenant("big")
+	// the first 6 messages are in the same minute
+	for i := 0; i < 3; i++ {
+		small.Info("s")
+		big.Info("b")
+	}
+	logger.Info("no tenant")
+	small.Info("next minute")
+	logger.Close()
+
+	var messages []string
+	for _, e := range target.entries {
+		messages = append(messages, e.Message+":"+e.Tenant())
+	}
+	expected := "s:small b:big s:small b:big b:big no tenant: next minute:small"
+	if strings.Join(messages, " ") != expected {
+		t.Errorf("messages = %q, expected %q", strings.Join(messages, " "), expected)
+	}
+	if dropped := limiter.Dropped(); len(dropped) != 1 || dropped["small"] != 1 {
+		t.Errorf("Dropped() = %v, expected 1 message of small", dropped)
+	}
+}
+
+func TestTenantFileTarget(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tenants")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	logger := log.NewLogger()
+	logger.Sync()
+	logger.SetTarget(log.NewTenantFileTarget(filepath.Join(dir, "{tenant}.log"), func(target *log.FileTarget) {
+		target.Rotate = false
+	}))
+	logger.ForTenant("acme").Info("a1")
+	logger.ForTenant("../globex").Info("g1")
+	logger.Info("d1")
+	logger.ForTenant("acme").Info("a2")
+	logger.Close()
+
+	for name, expected := range map[string][]string{"acme.log": {"a1", "a2"}, ".._globex.log": {"g1"}, "default.log": {"d1"}} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("ReadFile(%v) = %v, expected no error", name, err)
+			continue
+		}
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		if len(lines) != len(expected) {
+			t.Errorf("%v = %q, expected %v lines", name, data, len(expected))
+			continue
+		}
+		for i, message := range expected {
+			if !strings.Contains(lines[i], "|"+message) {
+				t.Errorf("%v line %v = %q, expected %v", name, i, lines[i], message)
+			}
+		}
+	}
+}
+
+func TestTenantLimiterEviction(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	logger.SetClock(log.NewManualClock(time.Date(2015, 10, 22, 8, 39, 0, 0, time.UTC), 10*time.Second))
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	limiter := log.NewTenantLimiter(1, time.Minute)
+	logger.AddHook(limiter.Hook)
+
+	// the windows of the first minute are evicted when the second minute starts
+	small, big := logger.ForTenant("small"), logger.ForTenant("big")
+	small.Info("s1")
+	small.Info("s2")
+	limiter.SetLimit("big", 2)
+	big.Info("b1")
+	big.Info("b2")
+	big.Info("b3")
+	logger.Info("no tenant")
+	small.Info("s3")
+	logger.Close()
+
+	var messages []string
+	for _, e := range target.entries {
+		messages = append(messages, e.Message)
+	}
+	if s := strings.Join(messages, " "); s != "s1 b1 b2 no tenant s3" {
+		t.Errorf("messages = %q, expected %q", s, "s1 b1 b2 no tenant s3")
+	}
+	if dropped := limiter.Dropped(); len(dropped) != 2 || dropped["small"] != 1 || dropped["big"] != 1 {
+		t.Errorf("Dropped() = %v, expected 1 message of small and of big", dropped)
+	}
+}
+
+func TestTenantTargetIdleTimeout(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	logger.SetClock(log.NewManualClock(time.Date(2015, 10, 22, 8, 39, 0, 0, time.UTC), 10*time.Second))
+	created := map[string][]*MemoryTarget{}
+	tenants := log.NewTenantTarget(func(tenant string) (log.Target, error) {
+		target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+		created[tenant] = append(created[tenant], target)
+		return target, nil
+	})
+	tenants.IdleTimeout = 30 * time.Second
+	logger.SetTarget(tenants)
+
+	a, b := logger.ForTenant("a"), logger.ForTenant("b")
+	a.Info("a1")
+	for i := 0; i < 4; i++ {
+		b.Info("b")
+	}
+	// the target of a was closed for being idle
+	a.Info("a2")
+	logger.Close()
+
+	if len(created["a"]) != 2 || len(created["b"]) != 1 {
+		t.Fatalf("created %v targets for a and %v for b, expected 2 and 1", len(created["a"]), len(created["b"]))
+	}
+	for i, message := range []string{"a1", "a2"} {
+		if entries := created["a"][i].entries; len(entries) != 1 || entries[0].Message != message {
+			t.Errorf("target %v of a received %v messages, expected %v", i, len(entries), message)
+		}
+	}
+	if n := len(created["b"][0].entries); n != 4 {
+		t.Errorf("the target of b received %v messages, expected 4", n)
+	}
+}
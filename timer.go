@@ -0,0 +1,26 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+// Timer starts timing an operation and returns a function which logs the message at the Info level
+// with the time elapsed since Timer was called in the "elapsed" field, a time.Duration:
+//
+//	defer logger.Timer("load config")()
+//
+// The time is given by the clock of the logger, and the message is only formatted when it is logged.
+func (l *Logger) Timer(format string, a ...interface{}) func() {
+	return l.TimerLevel(LevelInfo, format, a...)
+}
+
+// TimerLevel is like Timer, but logs the message at the given level.
+func (l *Logger) TimerLevel(level Level, format string, a ...interface{}) func() {
+	start := l.now()
+	return func() {
+		if !l.Enabled(level) {
+			return
+		}
+		l.WithFields(Fields{"elapsed": l.now().Sub(start)}).Logf(level, format, a...)
+	}
+}
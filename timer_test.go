@@ -0,0 +1,37 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+func TestTimer(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+	defer logger.Close()
+	logger.SetClock(log.NewManualClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), 250*time.Millisecond))
+
+	done := logger.Timer("load %v", "config")
+	done()
+	logger.SetMaxLevel(log.LevelInfo)
+	logger.TimerLevel(log.LevelDebug, "skipped")()
+
+	if len(target.entries) != 1 {
+		t.Fatalf("%v messages were logged, expected %v", len(target.entries), 1)
+	}
+	e := target.entries[0]
+	if e.Message != "load config" || e.Level != log.LevelInfo {
+		t.Errorf("message = %v %q, expected Info %q", e.Level, e.Message, "load config")
+	}
+	if e.Fields["elapsed"] != 250*time.Millisecond {
+		t.Errorf("elapsed = %v, expected %v", e.Fields["elapsed"], 250*time.Millisecond)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// TLSOptions configures the TLS connections of the targets sending log messages to remote collectors:
+// NetworkTarget, HTTPTarget, SplunkTarget, OTLPTarget and rpclog.GRPCTarget.
+// The zero value verifies the server with the system CAs and sends no client certificate.
+type TLSOptions struct {
+	// the file of the PEM-encoded certificates of the CAs verifying the server. The system CAs are used if it is empty.
+	CAFile string
+	// the files of the PEM-encoded client certificate and its key, presented to the server for mutual TLS.
+	CertFile string
+	KeyFile  string
+	// the name of the server, which is verified and sent with SNI. It defaults to the host of the address.
+	ServerName string
+	// whether to accept any server certificate. It makes the connection vulnerable to interception,
+	// so it should only be used for testing.
+	InsecureSkipVerify bool
+}
+
+// Config builds the tls.Config described by the options.
+func (o *TLSOptions) Config() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         o.ServerName,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+	if o.CAFile != "" {
+		data, err := ioutil.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(data) {
+			return nil, errors.New("no certificate found in " + o.CAFile)
+		}
+	}
+	if o.CertFile != "" || o.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// newHTTPClient creates the HTTP client of a target, using the TLS options if they are not nil.
+func newHTTPClient(options *TLSOptions) (*http.Client, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	if options != nil {
+		config, err := options.Config()
+		if err != nil {
+			return nil, err
+		}
+		client.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: config,
+		}
+	}
+	return client, nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+// writeCertificate creates a certificate signed by parent, or self-signed if parent is nil,
+// and writes it and its key as PEM files in dir.
+func writeCertificate(t *testing.T, dir, name string, template *x509.Certificate, parent *tls.Certificate) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey(): %v", err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	signer, signerKey := template, interface{}(key)
+	if parent != nil {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate(): %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey(): %v", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	ioutil.WriteFile(filepath.Join(dir, name+".crt"), certPEM, 0600)
+	ioutil.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0600)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("X509KeyPair(): %v", err)
+	}
+	cert.Leaf, _ = x509.ParseCertificate(der)
+	return cert
+}
+
+func TestNetworkTargetTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtls")
+	if err != nil {
+		t.Fatalf("TempDir(): %v", err)
+	}
+	defer os.RemoveAll(dir)
+	ca := writeCertificate(t, dir, "ca", &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "log test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	server := writeCertificate(t, dir, "server", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "collector"},
+		DNSNames:    []string{"collector.test"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, &ca)
+	writeCertificate(t, dir, "client", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "app"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, &ca)
+
+	// the collector requires a client certificate signed by the CA
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.Leaf)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{server},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	})
+	if err != nil {
+		t.Fatalf("tls.Listen(): %v", err)
+	}
+	defer listener.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- err.Error()
+			return
+		}
+		defer conn.Close()
+		data, err := ioutil.ReadAll(conn)
+		if err != nil {
+			received <- err.Error()
+			return
+		}
+		received <- string(data)
+	}()
+
+	logger := log.NewLogger()
+	logger.Sync()
+	target := log.NewNetworkTarget()
+	target.Network = "tcp"
+	target.Address = listener.Addr().String()
+	target.TLS = &log.TLSOptions{
+		CAFile:     filepath.Join(dir, "ca.crt"),
+		CertFile:   filepath.Join(dir, "client.crt"),
+		KeyFile:    filepath.Join(dir, "client.key"),
+		ServerName: "collector.test",
+	}
+	logger.SetTarget(target)
+	if len(logger.Targets) != 1 {
+		t.Fatalf("the TLS network target was not opened")
+	}
+	logger.Info("t1")
+	logger.Close()
+
+	if result := <-received; !strings.Contains(result, "t1") {
+		t.Errorf("received %q, expected t1", result)
+	}
+}
+
+func TestHTTPTargetTLS(t *testing.T) {
+	var messages []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		messages = append(messages, string(data))
+	}))
+	defer server.Close()
+
+	logger := log.NewLogger()
+	logger.Sync()
+	target := log.NewHTTPTarget()
+	target.URL = server.URL
+	target.TLS = &log.TLSOptions{InsecureSkipVerify: true}
+	logger.SetTarget(target)
+	logger.Info("t1")
+	logger.Close()
+
+	if len(messages) != 1 || !strings.Contains(messages[0], "t1") {
+		t.Errorf("messages = %v, expected t1", messages)
+	}
+}
+
+func TestTLSOptions(t *testing.T) {
+	if _, err := (&log.TLSOptions{CAFile: "missing.crt"}).Config(); err == nil {
+		t.Errorf("Config() = nil error for a missing CA file, expected an error")
+	}
+	config, err := (&log.TLSOptions{ServerName: "collector", InsecureSkipVerify: true}).Config()
+	if err != nil || config.ServerName != "collector" || !config.InsecureSkipVerify || config.RootCAs != nil {
+		t.Errorf("Config() = %+v, %v, expected the server name and no CA", config, err)
+	}
+	target := log.NewNetworkTarget()
+	target.Network = "udp"
+	target.Address = "127.0.0.1:1"
+	target.TLS = &log.TLSOptions{}
+	if err := target.Open(ioutil.Discard); err == nil {
+		t.Errorf("Open() = nil, expected an error for TLS over a datagram network")
+	}
+}
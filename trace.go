@@ -0,0 +1,44 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import "context"
+
+// SpanContext extracts the trace and span IDs from the context passed to Logger.WithContext.
+// It defaults to TraceFromContext and can be replaced to read the IDs set by a tracing library,
+// for example with OpenTelemetry:
+//
+//	log.SpanContext = func(ctx context.Context) (string, string) {
+//		sc := trace.SpanContextFromContext(ctx)
+//		if !sc.IsValid() {
+//			return "", ""
+//		}
+//		return sc.TraceID().String(), sc.SpanID().String()
+//	}
+var SpanContext = TraceFromContext
+
+type traceKey struct{}
+
+type traceIDs struct {
+	traceID, spanID string
+}
+
+// ContextWithTrace returns a copy of ctx carrying the given trace and span IDs.
+func ContextWithTrace(ctx context.Context, traceID, spanID string) context.Context {
+	return context.WithValue(ctx, traceKey{}, traceIDs{traceID, spanID})
+}
+
+// TraceFromContext returns the trace and span IDs set by ContextWithTrace.
+func TraceFromContext(ctx context.Context) (traceID string, spanID string) {
+	ids, _ := ctx.Value(traceKey{}).(traceIDs)
+	return ids.traceID, ids.spanID
+}
+
+// WithTrace returns a logger whose messages carry the given trace and span IDs.
+func (l *Logger) WithTrace(traceID, spanID string) *Logger {
+	logger := l.clone()
+	logger.traceID, logger.spanID = traceID, spanID
+	return logger
+}
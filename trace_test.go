@@ -0,0 +1,42 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestLoggerTrace(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	target := &MemoryTarget{
+		Filter: &log.Filter{MaxLevel: log.LevelDebug},
+		ready:  make(chan bool, 0),
+	}
+	logger.SetTarget(target)
+	defer logger.Close()
+
+	ctx := log.ContextWithTrace(context.Background(), "t1", "s1")
+	logger.WithContext(ctx).GetLogger("sub").Info("m1")
+	logger.WithTrace("t2", "s2").WithContext(context.Background()).Info("m2")
+	logger.Info("m3")
+
+	expected := [][2]string{{"t1", "s1"}, {"t2", "s2"}, {"", ""}}
+	for i, e := range target.entries {
+		if e.TraceID != expected[i][0] || e.SpanID != expected[i][1] {
+			t.Errorf("entries[%v] trace = %v/%v, expected %v/%v", i, e.TraceID, e.SpanID, expected[i][0], expected[i][1])
+		}
+	}
+	if !strings.Contains(target.entries[0].FormattedMessage, "|m1 trace_id=t1 span_id=s1") {
+		t.Errorf("FormattedMessage = %q, expected the trace and span IDs", target.entries[0].FormattedMessage)
+	}
+	if strings.Contains(target.entries[2].FormattedMessage, "trace_id") {
+		t.Errorf("FormattedMessage = %q, expected no trace ID", target.entries[2].FormattedMessage)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+type debugKey struct{}
+
+// ContextWithDebug returns a copy of ctx marked so that the loggers created from it by Logger.WithContext
+// log the debug messages, even if the maximum level is lower, e.g. to trace a single request in production.
+func ContextWithDebug(ctx context.Context) context.Context {
+	return context.WithValue(ctx, debugKey{}, true)
+}
+
+// DebugFromContext returns whether the context is marked by ContextWithDebug.
+func DebugFromContext(ctx context.Context) bool {
+	debug, _ := ctx.Value(debugKey{}).(bool)
+	return debug
+}
+
+// DebugTrigger tells whether the messages of a request are to be logged at the Debug level.
+type DebugTrigger func(r *http.Request) bool
+
+// DebugHeader returns a trigger firing when the request has the given header set to a true value, e.g. "1".
+func DebugHeader(name string) DebugTrigger {
+	return func(r *http.Request) bool {
+		debug, _ := strconv.ParseBool(r.Header.Get(name))
+		return debug
+	}
+}
+
+// DebugUsers returns a trigger firing for the requests of the given users, whose IDs are found by user.
+func DebugUsers(user func(r *http.Request) string, ids ...string) DebugTrigger {
+	users := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		users[id] = true
+	}
+	return func(r *http.Request) bool {
+		return users[user(r)]
+	}
+}
+
+// DebugMiddleware returns a middleware marking the context of the requests firing one of the triggers with
+// ContextWithDebug, so that the loggers created with Logger.WithContext(r.Context()) log their debug messages:
+//
+//	handler = log.DebugMiddleware(log.DebugHeader("X-Debug"))(handler)
+//
+// As the header can be sent by anyone, the trigger should check that the request is authorized.
+func DebugMiddleware(triggers ...DebugTrigger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, trigger := range triggers {
+				if trigger(r) {
+					r = r.WithContext(ContextWithDebug(r.Context()))
+					break
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestDebugMiddleware(t *testing.T) {
+	logger := log.NewLogger()
+	logger.Sync()
+	logger.SetMaxLevel(log.LevelInfo)
+	target := &MemoryTarget{Filter: &log.Filter{MaxLevel: log.LevelDebug}, ready: make(chan bool)}
+	logger.SetTarget(target)
+
+	handler := log.DebugMiddleware(
+		log.DebugHeader("X-Debug"),
+		log.DebugUsers(func(r *http.Request) string { return r.URL.Query().Get("user") }, "alice"),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.WithContext(r.Context()).GetLogger("app.api").Debug(r.URL.String())
+	}))
+	for _, test := range []struct {
+		url    string
+		header string
+	}{
+		{"/plain", ""},
+		{"/header", "1"},
+		{"/header-off", "false"},
+		{"/user?user=alice", ""},
+		{"/user?user=bob", ""},
+	} {
+		req := httptest.NewRequest("GET", test.url, nil)
+		if test.header != "" {
+			req.Header.Set("X-Debug", test.header)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	logger.WithContext(log.ContextWithDebug(context.Background())).Trace("trace")
+	logger.Close()
+
+	if len(target.entries) != 2 || target.entries[0].Message != "/header" || target.entries[1].Message != "/user?user=alice" {
+		t.Errorf("len(entries) = %v, expected the debug messages of /header and /user?user=alice", len(target.entries))
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// UnixTarget writes log messages to a unix domain socket, typically to hand them to a local
+// collector such as vector or fluent-bit. The connection is re-established automatically
+// when it breaks or when the collector is not listening yet.
+type UnixTarget struct {
+	*Filter
+	// the path of the socket.
+	Path string
+	// whether to use a datagram socket (unixgram) instead of a stream socket (unix).
+	// With a datagram socket each message is sent as one datagram without a trailing newline.
+	Datagram bool
+	// the minimum time between two connection attempts. Messages are dropped while the socket is unavailable.
+	ReconnectWait time.Duration
+	// the timeout for writing a message.
+	WriteTimeout time.Duration
+	// the size of the message channel.
+	BufferSize int
+
+	conn        net.Conn
+	lastAttempt time.Time
+	dropped     int
+	entries     chan *Entry
+	close       chan bool
+}
+
+// NewUnixTarget creates a UnixTarget.
+// The new UnixTarget takes these default options:
+// MaxLevel: LevelDebug, ReconnectWait: 1s, WriteTimeout: 5s, BufferSize: 1024.
+// You must specify the Path field.
+func NewUnixTarget() *UnixTarget {
+	return &UnixTarget{
+		Filter:        &Filter{MaxLevel: LevelDebug},
+		ReconnectWait: time.Second,
+		WriteTimeout:  5 * time.Second,
+		BufferSize:    1024,
+		close:         make(chan bool, 0),
+	}
+}
+
+// Open prepares UnixTarget for processing log messages.
+// It does not fail if the socket cannot be connected yet.
+func (t *UnixTarget) Open(errWriter io.Writer) error {
+	t.Filter.Init()
+	if t.Path == "" {
+		return errors.New("UnixTarget.Path must be specified")
+	}
+	if t.BufferSize < 0 {
+		return errors.New("UnixTarget.BufferSize must be no less than 0")
+	}
+	t.entries = make(chan *Entry, t.BufferSize)
+	t.conn = nil
+	t.dropped = 0
+	t.lastAttempt = time.Time{}
+	if err := t.connect(); err != nil {
+		fmt.Fprintf(errWriter, "UnixTarget connect error: %v\n", err)
+	}
+
+	go t.sendMessages(errWriter)
+
+	return nil
+}
+
+// Check checks that the socket can be connected, see Checker.
+func (t *UnixTarget) Check() error {
+	network := "unix"
+	if t.Datagram {
+		network = "unixgram"
+	}
+	conn, err := net.DialTimeout(network, t.Path, checkTimeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// Process puts filtered log messages into a channel for sending to the socket.
+func (t *UnixTarget) Process(e *Entry) {
+	if e == nil {
+		t.entries <- nil
+		return
+	}
+	if t.Allow(e) {
+		select {
+		case t.entries <- e:
+		default:
+		}
+	}
+}
+
+// Close closes the unix socket target.
+func (t *UnixTarget) Close() {
+	<-t.close
+}
+
+func (t *UnixTarget) connect() error {
+	if t.conn != nil {
+		t.conn.Close()
+		t.conn = nil
+	}
+	t.lastAttempt = time.Now()
+	network := "unix"
+	if t.Datagram {
+		network = "unixgram"
+	}
+	conn, err := net.Dial(network, t.Path)
+	if err != nil {
+		return err
+	}
+	t.conn = conn
+	return nil
+}
+
+func (t *UnixTarget) sendMessages(errWriter io.Writer) {
+	for {
+		entry := <-t.entries
+		if entry == nil {
+			if t.conn != nil {
+				t.conn.Close()
+				t.conn = nil
+			}
+			t.close <- true
+			break
+		}
+		message := entry.String()
+		if !t.Datagram {
+			message += "\n"
+		}
+		if err := t.write(message); err != nil {
+			fmt.Fprintf(errWriter, "UnixTarget write error: %v\n", err)
+		}
+	}
+}
+
+// write sends a message, reconnecting once if the connection is broken.
+func (t *UnixTarget) write(message string) error {
+	for attempt := 0; attempt < 2; attempt++ {
+		if t.conn == nil {
+			if time.Since(t.lastAttempt) < t.ReconnectWait {
+				t.dropped++
+				return nil
+			}
+			if err := t.connect(); err != nil {
+				t.dropped++
+				return err
+			}
+		}
+		if t.WriteTimeout > 0 {
+			t.conn.SetWriteDeadline(time.Now().Add(t.WriteTimeout))
+		}
+		if _, err := t.conn.Write([]byte(message)); err != nil {
+			t.conn.Close()
+			t.conn = nil
+			t.lastAttempt = time.Time{}
+			continue
+		}
+		if t.dropped > 0 {
+			dropped := t.dropped
+			t.dropped = 0
+			return fmt.Errorf("%v messages were dropped while the socket was unavailable", dropped)
+		}
+		return nil
+	}
+	t.dropped++
+	return errors.New("the socket is unavailable")
+}
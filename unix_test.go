@@ -0,0 +1,85 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/admpub/log"
+)
+
+func TestNewUnixTarget(t *testing.T) {
+	target := log.NewUnixTarget()
+	if target.MaxLevel != log.LevelDebug {
+		t.Errorf("NewUnixTarget.MaxLevel = %v, expected %v", target.MaxLevel, log.LevelDebug)
+	}
+	if target.Datagram {
+		t.Errorf("NewUnixTarget.Datagram should be false, got true")
+	}
+}
+
+func TestUnixTarget(t *testing.T) {
+	dir, err := os.MkdirTemp("", "log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("net.Listen(): %v", err)
+	}
+	defer listener.Close()
+
+	lines := make(chan string, 10)
+	go func() {
+		// the first connection is closed after one line to force a reconnection
+		for i := 0; i < 2; i++ {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					break
+				}
+				lines <- line
+				if i == 0 {
+					break
+				}
+			}
+			conn.Close()
+		}
+		close(lines)
+	}()
+
+	logger := log.NewLogger()
+	logger.Sync()
+	target := log.NewUnixTarget()
+	target.Path = path
+	logger.SetTarget(target)
+
+	logger.Info("t1")
+	<-time.After(50 * time.Millisecond)
+	logger.Info("t2")
+
+	logger.Close()
+
+	var received []string
+	for line := range lines {
+		received = append(received, line)
+	}
+	if len(received) != 2 || !strings.HasSuffix(received[0], "t1\n") || !strings.HasSuffix(received[1], "t2\n") {
+		t.Errorf("received = %q, expected t1 and t2", received)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// WAL is a write-ahead log which synchronously writes the severe messages, and syncs them to disk,
+// before they are handed to the targets, so that the last messages logged before a crash can be
+// recovered with ReadWAL even if the targets had not flushed them. Add its Hook to a logger last,
+// so that the entries it writes carry the fields added by the other hooks:
+//
+//	wal := log.NewWAL("app.wal")
+//	logger.AddHook(wal.Hook)
+//	...
+//	logger.Close()
+//	wal.Close()
+//
+// The messages logged while the logger is open are written to the WAL. When the WAL exceeds MaxSize,
+// it is renamed with the ".1" suffix and a new one is started, so that the WAL stays small.
+type WAL struct {
+	// the WAL file.
+	FileName string
+	// the maximum level of the messages written to the WAL.
+	MaxLevel Level
+	// the size of the WAL from which it is rotated. 0 means no limit.
+	MaxSize int64
+
+	lock sync.Mutex
+	file *os.File
+	size int64
+	err  error // the last write error, reported only when the WAL starts failing
+}
+
+// NewWAL creates a WAL writing to the file fileName.
+// The new WAL takes these default options:
+// MaxLevel: LevelError, MaxSize: 1MB.
+func NewWAL(fileName string) *WAL {
+	return &WAL{
+		FileName: fileName,
+		MaxLevel: LevelError,
+		MaxSize:  1 << 20,
+	}
+}
+
+// Hook writes an entry to the WAL and syncs it to disk if its level is MaxLevel or more severe.
+// It can be added to a logger with Logger.AddHook. A failure to write the WAL is reported to the
+// standard error when the WAL starts failing, and does not prevent the entry from being logged.
+func (w *WAL) Hook(e *Entry) *Entry {
+	if e.Level > w.MaxLevel {
+		return e
+	}
+	data, err := json.Marshal(newSpoolRecord(e))
+	if err != nil {
+		return e
+	}
+	data = append(data, '\n')
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	err = w.write(data)
+	if err != nil && w.err == nil {
+		fmt.Fprintf(os.Stderr, "WAL write error: %v\n", err)
+	}
+	w.err = err
+	return e
+}
+
+// write appends a record to the WAL file and syncs it. It must be called with w.lock held.
+func (w *WAL) write(data []byte) error {
+	if w.file != nil && w.MaxSize > 0 && w.size+int64(len(data)) > w.MaxSize {
+		w.file.Close()
+		w.file = nil
+		if err := os.Rename(w.FileName, w.FileName+".1"); err != nil {
+			return err
+		}
+	}
+	if w.file == nil {
+		file, err := os.OpenFile(w.FileName, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0660)
+		if err != nil {
+			return err
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return err
+		}
+		w.file, w.size = file, info.Size()
+	}
+	n, err := w.file.Write(data)
+	w.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+// Close closes the WAL and removes its files. It should be called once the logger is closed,
+// as the messages have then been handed to the targets.
+func (w *WAL) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+	for _, name := range []string{w.FileName + ".1", w.FileName} {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadWAL reads the entries left in the WAL fileName by a program which did not close it, e.g. because
+// it crashed, in the order they were logged. The entries have no FormattedMessage. A record which was
+// not completely written is skipped. ReadWAL returns no entry and no error if the WAL does not exist.
+func ReadWAL(fileName string) ([]*Entry, error) {
+	var entries []*Entry
+	for _, name := range []string{fileName + ".1", fileName} {
+		file, err := os.Open(name)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return entries, err
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			var r spoolRecord
+			if json.Unmarshal(scanner.Bytes(), &r) == nil {
+				entries = append(entries, r.entry())
+			}
+		}
+		file.Close()
+		if err := scanner.Err(); err != nil {
+			return entries, err
+		}
+	}
+	return entries, nil
+}
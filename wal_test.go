@@ -0,0 +1,61 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestWAL(t *testing.T) {
+	walFile := "app.wal"
+	os.Remove(walFile)
+	os.Remove(walFile + ".1")
+	defer os.Remove(walFile)
+	defer os.Remove(walFile + ".1")
+
+	wal := log.NewWAL(walFile)
+	wal.MaxSize = 300
+	logger := log.NewLogger()
+	logger.SetTarget(log.NewWriterTarget(ioutil.Discard))
+	logger.AddHook(wal.Hook)
+
+	logger.Info("t1")
+	logger.WithFields(log.Fields{"id": "x"}).WithError(errors.New("e2")).Error("t2")
+	logger.Critical("t3")
+	logger.Error("t4")
+
+	// the WAL is left as it is when the program crashes
+	entries, err := log.ReadWAL(walFile)
+	if err != nil {
+		t.Fatalf("ReadWAL(): %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("ReadWAL() returned %v entries, expected %v", len(entries), 3)
+	}
+	for i, message := range []string{"t2", "t3", "t4"} {
+		if entries[i].Message != message {
+			t.Errorf("entries[%v].Message = %v, expected %v", i, entries[i].Message, message)
+		}
+	}
+	if e := entries[0]; e.Fields["id"] != "x" || e.Error == nil || e.Error.Error() != "e2" || e.Level != log.LevelError {
+		t.Errorf("entries[0] = %+v, expected the fields, the error and the level of t2", e)
+	}
+	if _, err := os.Stat(walFile + ".1"); err != nil {
+		t.Errorf("the WAL was not rotated: %v", err)
+	}
+
+	logger.Close()
+	if err := wal.Close(); err != nil {
+		t.Errorf("Close(): %v", err)
+	}
+	if entries, err := log.ReadWAL(walFile); err != nil || len(entries) != 0 {
+		t.Errorf("ReadWAL() = %v, %v after Close, expected no entry", len(entries), err)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// WriterTarget writes filtered log messages to an arbitrary io.Writer, such as a pipe,
+// a bytes.Buffer or a custom sink.
+type WriterTarget struct {
+	*Filter
+	Writer io.Writer // the writer to write log messages
+	// the locker held during each write. Set it to share the writer safely with other code,
+	// e.g. a mutex which also guards other writes to the same writer. Nil means no locking.
+	Locker sync.Locker
+	// whether to close the writer when the target is closed, if it implements io.Closer.
+	CloseWriter bool
+
+	errWriter io.Writer
+	close     chan bool
+}
+
+// NewWriterTarget creates a WriterTarget writing to w.
+// The new WriterTarget takes these default options:
+// MaxLevel: LevelDebug, Locker: nil, CloseWriter: false
+func NewWriterTarget(w io.Writer) *WriterTarget {
+	return &WriterTarget{
+		Filter: &Filter{MaxLevel: LevelDebug},
+		Writer: w,
+		close:  make(chan bool, 0),
+	}
+}
+
+// Open prepares WriterTarget for processing log messages.
+func (t *WriterTarget) Open(errWriter io.Writer) error {
+	t.Filter.Init()
+	if t.Writer == nil {
+		return errors.New("WriterTarget.Writer cannot be nil")
+	}
+	t.errWriter = errWriter
+	return nil
+}
+
+// Process writes a log message using Writer.
+func (t *WriterTarget) Process(e *Entry) {
+	if e == nil {
+		t.close <- true
+		return
+	}
+	if !t.Allow(e) {
+		return
+	}
+	if err := t.WriteEntry(e); err != nil {
+		fmt.Fprintf(t.errWriter, "WriterTarget write error: %v\n", err)
+	}
+}
+
+// WriteEntry writes a log message using Writer and returns the write error.
+func (t *WriterTarget) WriteEntry(e *Entry) error {
+	if t.Locker != nil {
+		t.Locker.Lock()
+		defer t.Locker.Unlock()
+	}
+	_, err := io.WriteString(t.Writer, e.String()+"\n")
+	return err
+}
+
+// ProcessConcurrently returns whether the writes are serialized by Locker, in which case the messages can be
+// processed by several goroutines, see ConcurrentTarget.
+func (t *WriterTarget) ProcessConcurrently() bool {
+	return t.Locker != nil
+}
+
+// Close closes the writer target.
+func (t *WriterTarget) Close() {
+	<-t.close
+	if c, ok := t.Writer.(io.Closer); ok && t.CloseWriter {
+		c.Close()
+	}
+}
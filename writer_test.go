@@ -0,0 +1,46 @@
+// Copyright 2015 Qiang Xue. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log_test
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/admpub/log"
+)
+
+func TestNewWriterTarget(t *testing.T) {
+	target := log.NewWriterTarget(&bytes.Buffer{})
+	if target.MaxLevel != log.LevelDebug {
+		t.Errorf("NewWriterTarget.MaxLevel = %v, expected %v", target.MaxLevel, log.LevelDebug)
+	}
+	if target.Locker != nil {
+		t.Errorf("NewWriterTarget.Locker = %v, expected nil", target.Locker)
+	}
+}
+
+func TestWriterTarget(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := log.NewLogger()
+	logger.Sync()
+	target := log.NewWriterTarget(buf)
+	target.Locker = &sync.Mutex{}
+	target.MaxLevel = log.LevelWarn
+	logger.SetTarget(target)
+
+	logger.Info("t1")
+	logger.Warn("t2")
+
+	logger.Close()
+
+	if strings.Contains(buf.String(), "t1") {
+		t.Errorf("Found unexpected %q", "t1")
+	}
+	if !strings.HasSuffix(buf.String(), "t2\n") {
+		t.Errorf("Expected %q not found in %q", "t2", buf.String())
+	}
+}